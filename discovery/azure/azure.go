@@ -1,8 +1,10 @@
 package azure
 
 import (
+	"flag"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/cprobe/cprobe/lib/discoveryutils"
 	"github.com/cprobe/cprobe/lib/promauth"
@@ -11,9 +13,9 @@ import (
 )
 
 // SDCheckInterval is check interval for Azure service discovery.
-// var SDCheckInterval = flag.Duration("scrape.azureSDCheckInterval", 60*time.Second, "Interval for checking for changes in Azure. "+
-// 	"This works only if azure_sd_configs is configured in '-promscrape.config' file. "+
-// 	"See https://docs.victoriametrics.com/sd_configs.html#azure_sd_configs for details")
+var SDCheckInterval = flag.Duration("scrape.azureSDCheckInterval", 60*time.Second, "Interval for checking for changes in Azure. "+
+	"This works only if azure_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in azure_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#azure_sd_configs for details")
 
 // SDConfig represents service discovery config for Azure.
 //
@@ -31,14 +33,12 @@ type SDConfig struct {
 	ClientSecret   *promauth.Secret `yaml:"client_secret,omitempty"`
 	ResourceGroup  string           `yaml:"resource_group,omitempty"`
 
-	// RefreshInterval time.Duration `yaml:"refresh_interval"`
-	// refresh_interval is obtained from `-promscrape.azureSDCheckInterval` command-line option.
-
 	Port int `yaml:"port"`
 
 	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
 	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
 	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
+	RefreshInterval   *promutils.Duration        `yaml:"refresh_interval,omitempty"`
 }
 
 // GetLabels returns Azure labels according to sdc.
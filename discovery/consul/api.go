@@ -0,0 +1,222 @@
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+)
+
+var configMap = discoveryutils.NewConfigMap()
+
+type apiConfig struct {
+	client *discoveryutils.Client
+
+	token        string
+	datacenter   string
+	tagSeparator string
+
+	services    []string
+	tags        []string
+	passingOnly bool
+
+	// queryArgs are passed to every Consul API request
+	queryArgs url.Values
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	hcc := sdc.HTTPClientConfig
+	token := sdc.Token
+	if token == "" {
+		token = os.Getenv("CONSUL_HTTP_TOKEN")
+	}
+	ac, err := hcc.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	apiServer := sdc.Server
+	if apiServer == "" {
+		apiServer = "localhost:8500"
+	}
+	if !strings.Contains(apiServer, "://") {
+		scheme := sdc.Scheme
+		if scheme == "" {
+			scheme = "http"
+			if hcc.TLSConfig != nil {
+				scheme = "https"
+			}
+		}
+		apiServer = scheme + "://" + apiServer
+	}
+	apiServer = strings.TrimSuffix(apiServer, "/")
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	client, err := discoveryutils.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, &hcc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+
+	tagSeparator := ","
+	if sdc.TagSeparator != nil {
+		tagSeparator = *sdc.TagSeparator
+	}
+
+	cfg := &apiConfig{
+		client:       client,
+		token:        token,
+		tagSeparator: tagSeparator,
+		services:     sdc.Services,
+		tags:         sdc.Tags,
+		passingOnly:  sdc.PassingOnly,
+	}
+
+	dc := sdc.Datacenter
+	if dc == "" {
+		// __meta_consul_dc 需要 datacenter，没配置的话就取 agent 所在的 datacenter
+		dc, err = getDatacenter(cfg)
+		if err != nil {
+			client.Stop()
+			return nil, fmt.Errorf("cannot obtain consul datacenter: %w", err)
+		}
+	}
+	cfg.datacenter = dc
+
+	qa := url.Values{}
+	qa.Set("dc", dc)
+	if sdc.AllowStale == nil || *sdc.AllowStale {
+		qa.Set("stale", "")
+	}
+	if sdc.Namespace != "" {
+		qa.Set("ns", sdc.Namespace)
+	}
+	if sdc.Partition != "" {
+		qa.Set("partition", sdc.Partition)
+	}
+	if sdc.Filter != "" {
+		qa.Set("filter", sdc.Filter)
+	}
+	keys := make([]string, 0, len(sdc.NodeMeta))
+	for k := range sdc.NodeMeta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		qa.Add("node-meta", k+":"+sdc.NodeMeta[k])
+	}
+	cfg.queryArgs = qa
+
+	return cfg, nil
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (interface{}, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func (cfg *apiConfig) getAPIResponse(path string) ([]byte, error) {
+	return cfg.client.GetAPIResponseWithReqParams(path, func(req *http.Request) {
+		if cfg.token != "" {
+			req.Header.Set("X-Consul-Token", cfg.token)
+		}
+	})
+}
+
+func getDatacenter(cfg *apiConfig) (string, error) {
+	data, err := cfg.getAPIResponse("/v1/agent/self")
+	if err != nil {
+		return "", fmt.Errorf("cannot query consul agent info: %w", err)
+	}
+	var info struct {
+		Config struct {
+			Datacenter string
+		}
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return "", fmt.Errorf("cannot parse consul agent info %q: %w", data, err)
+	}
+	return info.Config.Datacenter, nil
+}
+
+// getServiceNames returns names of the services to discover.
+func getServiceNames(cfg *apiConfig) ([]string, error) {
+	if len(cfg.services) > 0 {
+		return cfg.services, nil
+	}
+	path := "/v1/catalog/services?" + cfg.queryArgs.Encode()
+	data, err := cfg.getAPIResponse(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain consul services: %w", err)
+	}
+	var m map[string][]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("cannot parse consul services response %q: %w", data, err)
+	}
+	names := make([]string, 0, len(m))
+	for name, tags := range m {
+		if !hasAllTags(tags, cfg.tags) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func getServiceNodes(cfg *apiConfig) ([]ServiceNode, error) {
+	names, err := getServiceNames(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var sns []ServiceNode
+	for _, name := range names {
+		qa := url.Values{}
+		for k, vs := range cfg.queryArgs {
+			qa[k] = vs
+		}
+		if cfg.passingOnly {
+			qa.Set("passing", "true")
+		}
+		path := "/v1/health/service/" + url.PathEscape(name) + "?" + qa.Encode()
+		data, err := cfg.getAPIResponse(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain instances for consul service %q: %w", name, err)
+		}
+		items, err := parseServiceNodes(data)
+		if err != nil {
+			return nil, err
+		}
+		for _, sn := range items {
+			if !hasAllTags(sn.Service.Tags, cfg.tags) {
+				continue
+			}
+			sns = append(sns, sn)
+		}
+	}
+	return sns, nil
+}
+
+func hasAllTags(tags, wantTags []string) bool {
+	for _, want := range wantTags {
+		found := false
+		for _, tag := range tags {
+			if tag == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
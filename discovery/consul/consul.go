@@ -0,0 +1,68 @@
+package consul
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/promauth"
+	"github.com/cprobe/cprobe/lib/promutils"
+	"github.com/cprobe/cprobe/lib/proxy"
+)
+
+// SDCheckInterval is check interval for Consul service discovery.
+var SDCheckInterval = flag.Duration("scrape.consulSDCheckInterval", 30*time.Second, "Interval for checking for changes in Consul. "+
+	"This works only if consul_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in consul_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#consul_sd_configs for details")
+
+// SDConfig represents service discovery config for Consul.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#consul_sd_config
+type SDConfig struct {
+	Server     string `yaml:"server,omitempty"`
+	Token      string `yaml:"token,omitempty"`
+	Datacenter string `yaml:"datacenter,omitempty"`
+	Namespace  string `yaml:"namespace,omitempty"`
+	Partition  string `yaml:"partition,omitempty"`
+	Scheme     string `yaml:"scheme,omitempty"`
+
+	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
+	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
+	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
+
+	// Services 为空表示发现 catalog 里的所有服务
+	Services []string `yaml:"services,omitempty"`
+	// Tags 表示服务实例必须同时带有这些 tag
+	Tags         []string          `yaml:"tags,omitempty"`
+	NodeMeta     map[string]string `yaml:"node_meta,omitempty"`
+	TagSeparator *string           `yaml:"tag_separator,omitempty"`
+	AllowStale   *bool             `yaml:"allow_stale,omitempty"`
+	// Filter 是 Consul 的 filter 表达式，see https://developer.hashicorp.com/consul/api-docs/features/filtering
+	Filter string `yaml:"filter,omitempty"`
+	// PassingOnly 为 true 时只返回所有健康检查都是 passing 的实例
+	PassingOnly bool `yaml:"passing_only,omitempty"`
+
+	RefreshInterval *promutils.Duration `yaml:"refresh_interval,omitempty"`
+}
+
+// GetLabels returns Consul labels according to sdc.
+func (sdc *SDConfig) GetLabels(baseDir string) ([]*promutils.Labels, error) {
+	cfg, err := getAPIConfig(sdc, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get API config: %w", err)
+	}
+	sns, err := getServiceNodes(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return getServiceNodesLabels(sns, cfg.tagSeparator, cfg.datacenter, sdc.Partition), nil
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *SDConfig) MustStop() {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		cfg := v.(*apiConfig)
+		cfg.client.Stop()
+	}
+}
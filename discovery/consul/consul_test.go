@@ -0,0 +1,98 @@
+package consul
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+func TestGetLabels(t *testing.T) {
+	var paths []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != "secret" {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+		paths = append(paths, r.URL.Path+"?"+r.URL.RawQuery)
+		switch r.URL.Path {
+		case "/v1/agent/self":
+			_, _ = w.Write([]byte(`{"Config": {"Datacenter": "dc1"}}`))
+		case "/v1/catalog/services":
+			_, _ = w.Write([]byte(`{"consul": [], "mysql": ["db", "primary"], "redis": ["cache"]}`))
+		case "/v1/health/service/mysql":
+			_, _ = w.Write([]byte(`[
+				{
+					"Node": {"Node": "db-1", "Address": "10.0.0.1", "Datacenter": "dc1", "Meta": {"rack": "r1"}, "TaggedAddresses": {"lan": "10.0.0.1"}},
+					"Service": {"ID": "mysql-1", "Service": "mysql", "Address": "", "Port": 3306, "Tags": ["db", "primary"], "Meta": {"version": "8.0"}},
+					"Checks": [{"CheckID": "serfHealth", "Status": "passing"}, {"CheckID": "service:mysql-1", "Status": "warning"}]
+				},
+				{
+					"Node": {"Node": "db-2", "Address": "10.0.0.2", "Datacenter": "dc1"},
+					"Service": {"ID": "mysql-2", "Service": "mysql", "Address": "10.0.1.2", "Port": 3307, "Tags": ["replica"]},
+					"Checks": [{"CheckID": "serfHealth", "Status": "passing"}]
+				}
+			]`))
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	sdc := &SDConfig{
+		Server:   s.URL,
+		Token:    "secret",
+		Tags:     []string{"db"},
+		NodeMeta: map[string]string{"rack": "r1"},
+	}
+	defer sdc.MustStop()
+	ms, err := sdc.GetLabels("")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	discoveryutils.TestEqualLabelss(t, ms, []*promutils.Labels{
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                            "10.0.0.1:3306",
+			"__meta_consul_address":                  "10.0.0.1",
+			"__meta_consul_dc":                       "dc1",
+			"__meta_consul_health":                   "warning",
+			"__meta_consul_namespace":                "",
+			"__meta_consul_node":                     "db-1",
+			"__meta_consul_service":                  "mysql",
+			"__meta_consul_service_address":          "",
+			"__meta_consul_service_id":               "mysql-1",
+			"__meta_consul_service_port":             "3306",
+			"__meta_consul_tags":                     ",db,primary,",
+			"__meta_consul_tag_db":                   "",
+			"__meta_consul_tagpresent_db":            "true",
+			"__meta_consul_tag_primary":              "",
+			"__meta_consul_tagpresent_primary":       "true",
+			"__meta_consul_metadata_rack":            "r1",
+			"__meta_consul_service_metadata_version": "8.0",
+			"__meta_consul_tagged_address_lan":       "10.0.0.1",
+		}),
+	})
+
+	for _, p := range paths[1:] {
+		if !strings.Contains(p, "dc=dc1") || !strings.Contains(p, "node-meta=rack%3Ar1") {
+			t.Fatalf("missing dc or node-meta query args in %q", p)
+		}
+	}
+}
+
+func TestAggregatedStatus(t *testing.T) {
+	f := func(checks []Check, want string) {
+		t.Helper()
+		sn := &ServiceNode{Checks: checks}
+		if got := sn.aggregatedStatus(); got != want {
+			t.Fatalf("unexpected status; got %q; want %q", got, want)
+		}
+	}
+	f(nil, "passing")
+	f([]Check{{Status: "passing"}, {Status: "warning"}}, "warning")
+	f([]Check{{Status: "warning"}, {Status: "critical"}}, "critical")
+	f([]Check{{Status: "passing"}, {CheckID: "_service_maintenance:mysql-1", Status: "critical"}}, "maintenance")
+}
@@ -0,0 +1,160 @@
+package consul
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+// ServiceNode is Consul service node.
+//
+// See https://developer.hashicorp.com/consul/api-docs/health#list-nodes-for-service
+type ServiceNode struct {
+	Service Service
+	Node    Node
+	Checks  []Check
+}
+
+// Service is Consul service.
+//
+// See https://developer.hashicorp.com/consul/api-docs/health#list-nodes-for-service
+type Service struct {
+	ID              string
+	Service         string
+	Address         string
+	Namespace       string
+	Partition       string
+	Port            int
+	Tags            []string
+	Meta            map[string]string
+	TaggedAddresses map[string]ServiceTaggedAddress
+}
+
+// ServiceTaggedAddress is Consul service tagged address.
+type ServiceTaggedAddress struct {
+	Address string
+	Port    int
+}
+
+// Node is Consul node.
+//
+// See https://developer.hashicorp.com/consul/api-docs/health#list-nodes-for-service
+type Node struct {
+	Address         string
+	Datacenter      string
+	Node            string
+	Meta            map[string]string
+	TaggedAddresses map[string]string
+}
+
+// Check is Consul check.
+//
+// See https://developer.hashicorp.com/consul/api-docs/health#list-nodes-for-service
+type Check struct {
+	CheckID string
+	Status  string
+}
+
+func parseServiceNodes(data []byte) ([]ServiceNode, error) {
+	var sns []ServiceNode
+	if err := json.Unmarshal(data, &sns); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal ServiceNodes from %q: %w", data, err)
+	}
+	return sns, nil
+}
+
+// aggregatedStatus returns the worst status among the checks of sn.
+//
+// The order is the same as in Consul: maintenance > critical > warning > passing.
+func (sn *ServiceNode) aggregatedStatus() string {
+	var warning, critical, maintenance bool
+	for _, c := range sn.Checks {
+		if strings.HasPrefix(c.CheckID, "_service_maintenance:") || strings.HasPrefix(c.CheckID, "_node_maintenance") {
+			maintenance = true
+			continue
+		}
+		switch c.Status {
+		case "passing":
+		case "warning":
+			warning = true
+		case "critical":
+			critical = true
+		default:
+			return ""
+		}
+	}
+	switch {
+	case maintenance:
+		return "maintenance"
+	case critical:
+		return "critical"
+	case warning:
+		return "warning"
+	default:
+		return "passing"
+	}
+}
+
+func getServiceNodesLabels(sns []ServiceNode, tagSeparator, dc, partition string) []*promutils.Labels {
+	ms := make([]*promutils.Labels, 0, len(sns))
+	for i := range sns {
+		ms = append(ms, sns[i].getTargetLabels(tagSeparator, dc, partition))
+	}
+	return ms
+}
+
+// getTargetLabels returns labels for sn.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#consul_sd_config
+func (sn *ServiceNode) getTargetLabels(tagSeparator, dc, partition string) *promutils.Labels {
+	addr := sn.Service.Address
+	if addr == "" {
+		addr = sn.Node.Address
+	}
+	m := promutils.NewLabels(16)
+	m.Add("__address__", discoveryutils.JoinHostPort(addr, sn.Service.Port))
+	m.Add("__meta_consul_address", sn.Node.Address)
+	m.Add("__meta_consul_dc", dc)
+	m.Add("__meta_consul_health", sn.aggregatedStatus())
+	m.Add("__meta_consul_namespace", sn.Service.Namespace)
+	if partition != "" {
+		m.Add("__meta_consul_partition", partition)
+	}
+	m.Add("__meta_consul_node", sn.Node.Node)
+	m.Add("__meta_consul_service", sn.Service.Service)
+	m.Add("__meta_consul_service_address", sn.Service.Address)
+	m.Add("__meta_consul_service_id", sn.Service.ID)
+	m.Add("__meta_consul_service_port", strconv.Itoa(sn.Service.Port))
+
+	// We surround the separated list with the separator as well. This way regular expressions
+	// in relabeling rules don't have to consider tag positions.
+	m.Add("__meta_consul_tags", tagSeparator+strings.Join(sn.Service.Tags, tagSeparator)+tagSeparator)
+
+	// Expose individual tags via __meta_consul_tag_<tagname> labels.
+	for _, tag := range sn.Service.Tags {
+		k := tag
+		v := ""
+		if n := strings.IndexByte(tag, '='); n >= 0 {
+			k = tag[:n]
+			v = tag[n+1:]
+		}
+		m.Add(discoveryutils.SanitizeLabelName("__meta_consul_tag_"+k), v)
+		m.Add(discoveryutils.SanitizeLabelName("__meta_consul_tagpresent_"+k), "true")
+	}
+
+	for k, v := range sn.Node.Meta {
+		m.Add(discoveryutils.SanitizeLabelName("__meta_consul_metadata_"+k), v)
+	}
+	for k, v := range sn.Service.Meta {
+		m.Add(discoveryutils.SanitizeLabelName("__meta_consul_service_metadata_"+k), v)
+	}
+	for k, v := range sn.Node.TaggedAddresses {
+		m.Add(discoveryutils.SanitizeLabelName("__meta_consul_tagged_address_"+k), v)
+	}
+	m.RemoveDuplicates()
+	return m
+}
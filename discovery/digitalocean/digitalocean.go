@@ -1,9 +1,11 @@
 package digitalocean
 
 import (
+	"flag"
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/cprobe/cprobe/lib/discoveryutils"
 	"github.com/cprobe/cprobe/lib/logger"
@@ -13,9 +15,9 @@ import (
 )
 
 // SDCheckInterval defines interval for targets refresh.
-// var SDCheckInterval = flag.Duration("scrape.digitaloceanSDCheckInterval", time.Minute, "Interval for checking for changes in digital ocean. "+
-// 	"This works only if digitalocean_sd_configs is configured in '-promscrape.config' file. "+
-// 	"See https://docs.victoriametrics.com/sd_configs.html#digitalocean_sd_configs for details")
+var SDCheckInterval = flag.Duration("scrape.digitaloceanSDCheckInterval", time.Minute, "Interval for checking for changes in digital ocean. "+
+	"This works only if digitalocean_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in digitalocean_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#digitalocean_sd_configs for details")
 
 // SDConfig represents service discovery config for digital ocean.
 //
@@ -26,6 +28,7 @@ type SDConfig struct {
 	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
 	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
 	Port              int                        `yaml:"port,omitempty"`
+	RefreshInterval   *promutils.Duration        `yaml:"refresh_interval,omitempty"`
 }
 
 // GetLabels returns Digital Ocean droplet labels according to sdc.
@@ -2,6 +2,7 @@ package dns
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net"
 	"strconv"
@@ -14,19 +15,18 @@ import (
 )
 
 // SDCheckInterval defines interval for targets refresh.
-// var SDCheckInterval = flag.Duration("scrape.dnsSDCheckInterval", 30*time.Second, "Interval for checking for changes in dns. "+
-// 	"This works only if dns_sd_configs is configured in '-promscrape.config' file. "+
-// 	"See https://docs.victoriametrics.com/sd_configs.html#dns_sd_configs for details")
+var SDCheckInterval = flag.Duration("scrape.dnsSDCheckInterval", 30*time.Second, "Interval for checking for changes in dns. "+
+	"This works only if dns_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in dns_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#dns_sd_configs for details")
 
 // SDConfig represents service discovery config for DNS.
 //
 // See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#dns_sd_config
 type SDConfig struct {
-	Names []string `yaml:"names"`
-	Type  string   `yaml:"type,omitempty"`
-	Port  *int     `yaml:"port,omitempty"`
-	// RefreshInterval time.Duration `yaml:"refresh_interval"`
-	// refresh_interval is obtained from `-promscrape.dnsSDCheckInterval` command-line option.
+	Names           []string            `yaml:"names"`
+	Type            string              `yaml:"type,omitempty"`
+	Port            *int                `yaml:"port,omitempty"`
+	RefreshInterval *promutils.Duration `yaml:"refresh_interval,omitempty"`
 }
 
 // GetLabels returns DNS labels according to sdc.
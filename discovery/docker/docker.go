@@ -1,7 +1,9 @@
 package docker
 
 import (
+	"flag"
 	"fmt"
+	"time"
 
 	"github.com/cprobe/cprobe/lib/promauth"
 	"github.com/cprobe/cprobe/lib/promutils"
@@ -9,9 +11,9 @@ import (
 )
 
 // SDCheckInterval defines interval for docker targets refresh.
-// var SDCheckInterval = flag.Duration("scrape.dockerSDCheckInterval", 30*time.Second, "Interval for checking for changes in docker. "+
-// 	"This works only if docker_sd_configs is configured in '-promscrape.config' file. "+
-// 	"See https://docs.victoriametrics.com/sd_configs.html#docker_sd_configs for details")
+var SDCheckInterval = flag.Duration("scrape.dockerSDCheckInterval", 30*time.Second, "Interval for checking for changes in docker. "+
+	"This works only if docker_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in docker_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#docker_sd_configs for details")
 
 // SDConfig defines the `docker_sd` section for Docker based discovery
 //
@@ -25,7 +27,7 @@ type SDConfig struct {
 	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
 	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
 	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
-	// refresh_interval is obtained from `-promscrape.dockerSDCheckInterval` command-line option
+	RefreshInterval   *promutils.Duration        `yaml:"refresh_interval,omitempty"`
 }
 
 // Filter is a filter, which can be passed to SDConfig.
@@ -1,7 +1,9 @@
 package dockerswarm
 
 import (
+	"flag"
 	"fmt"
+	"time"
 
 	"github.com/cprobe/cprobe/lib/promauth"
 	"github.com/cprobe/cprobe/lib/promutils"
@@ -9,9 +11,9 @@ import (
 )
 
 // SDCheckInterval defines interval for dockerswarm targets refresh.
-// var SDCheckInterval = flag.Duration("scrape.dockerswarmSDCheckInterval", 30*time.Second, "Interval for checking for changes in dockerswarm. "+
-// 	"This works only if dockerswarm_sd_configs is configured in '-promscrape.config' file. "+
-// 	"See https://docs.victoriametrics.com/sd_configs.html#dockerswarm_sd_configs for details")
+var SDCheckInterval = flag.Duration("scrape.dockerswarmSDCheckInterval", 30*time.Second, "Interval for checking for changes in dockerswarm. "+
+	"This works only if dockerswarm_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in dockerswarm_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#dockerswarm_sd_configs for details")
 
 // SDConfig represents docker swarm service discovery configuration
 //
@@ -25,7 +27,7 @@ type SDConfig struct {
 	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
 	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
 	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
-	// refresh_interval is obtained from `-promscrape.dockerswarmSDCheckInterval` command-line option
+	RefreshInterval   *promutils.Duration        `yaml:"refresh_interval,omitempty"`
 }
 
 // Filter is a filter, which can be passed to SDConfig.
@@ -1,7 +1,9 @@
 package ec2
 
 import (
+	"flag"
 	"fmt"
+	"time"
 
 	"github.com/cprobe/cprobe/lib/awsapi"
 	"github.com/cprobe/cprobe/lib/promauth"
@@ -9,9 +11,9 @@ import (
 )
 
 // SDCheckInterval defines interval for targets refresh.
-// var SDCheckInterval = flag.Duration("scrape.ec2SDCheckInterval", time.Minute, "Interval for checking for changes in ec2. "+
-// 	"This works only if ec2_sd_configs is configured in '-promscrape.config' file. "+
-// 	"See https://docs.victoriametrics.com/sd_configs.html#ec2_sd_configs for details")
+var SDCheckInterval = flag.Duration("scrape.ec2SDCheckInterval", time.Minute, "Interval for checking for changes in ec2. "+
+	"This works only if ec2_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in ec2_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#ec2_sd_configs for details")
 
 // SDConfig represents service discovery config for ec2.
 //
@@ -24,12 +26,11 @@ type SDConfig struct {
 	SecretKey   *promauth.Secret `yaml:"secret_key,omitempty"`
 	// TODO add support for Profile, not working atm
 	// Profile string `yaml:"profile,omitempty"`
-	RoleARN string `yaml:"role_arn,omitempty"`
-	// RefreshInterval time.Duration `yaml:"refresh_interval"`
-	// refresh_interval is obtained from `-promscrape.ec2SDCheckInterval` command-line option.
-	Port            *int            `yaml:"port,omitempty"`
-	InstanceFilters []awsapi.Filter `yaml:"filters,omitempty"`
-	AZFilters       []awsapi.Filter `yaml:"az_filters,omitempty"`
+	RoleARN         string              `yaml:"role_arn,omitempty"`
+	Port            *int                `yaml:"port,omitempty"`
+	InstanceFilters []awsapi.Filter     `yaml:"filters,omitempty"`
+	AZFilters       []awsapi.Filter     `yaml:"az_filters,omitempty"`
+	RefreshInterval *promutils.Duration `yaml:"refresh_interval,omitempty"`
 }
 
 // GetLabels returns ec2 labels according to sdc.
@@ -2,8 +2,10 @@ package eureka
 
 import (
 	"encoding/xml"
+	"flag"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/cprobe/cprobe/lib/discoveryutils"
 	"github.com/cprobe/cprobe/lib/promauth"
@@ -12,9 +14,9 @@ import (
 )
 
 // SDCheckInterval defines interval for targets refresh.
-// var SDCheckInterval = flag.Duration("scrape.eurekaSDCheckInterval", 30*time.Second, "Interval for checking for changes in eureka. "+
-// 	"This works only if eureka_sd_configs is configured in '-promscrape.config' file. "+
-// 	"See https://docs.victoriametrics.com/sd_configs.html#eureka_sd_configs for details")
+var SDCheckInterval = flag.Duration("scrape.eurekaSDCheckInterval", 30*time.Second, "Interval for checking for changes in eureka. "+
+	"This works only if eureka_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in eureka_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#eureka_sd_configs for details")
 
 // SDConfig represents service discovery config for eureka.
 //
@@ -24,8 +26,7 @@ type SDConfig struct {
 	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
 	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
 	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
-	// RefreshInterval time.Duration `yaml:"refresh_interval"`
-	// refresh_interval is obtained from `-promscrape.ec2SDCheckInterval` command-line option.
+	RefreshInterval   *promutils.Duration        `yaml:"refresh_interval,omitempty"`
 }
 
 type applications struct {
@@ -1,27 +1,28 @@
 package gce
 
 import (
+	"flag"
 	"fmt"
+	"time"
 
 	"github.com/cprobe/cprobe/lib/promutils"
 )
 
 // SDCheckInterval defines interval for targets refresh.
-// var SDCheckInterval = flag.Duration("scrape.gceSDCheckInterval", time.Minute, "Interval for checking for changes in gce. "+
-// 	"This works only if gce_sd_configs is configured in '-promscrape.config' file. "+
-// 	"See https://docs.victoriametrics.com/sd_configs.html#gce_sd_configs for details")
+var SDCheckInterval = flag.Duration("scrape.gceSDCheckInterval", time.Minute, "Interval for checking for changes in gce. "+
+	"This works only if gce_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in gce_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#gce_sd_configs for details")
 
 // SDConfig represents service discovery config for gce.
 //
 // See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#gce_sd_config
 type SDConfig struct {
-	Project string   `yaml:"project"`
-	Zone    ZoneYAML `yaml:"zone"`
-	Filter  string   `yaml:"filter,omitempty"`
-	// RefreshInterval time.Duration `yaml:"refresh_interval"`
-	// refresh_interval is obtained from `-promscrape.gceSDCheckInterval` command-line option.
-	Port         *int    `yaml:"port,omitempty"`
-	TagSeparator *string `yaml:"tag_separator,omitempty"`
+	Project         string              `yaml:"project"`
+	Zone            ZoneYAML            `yaml:"zone"`
+	Filter          string              `yaml:"filter,omitempty"`
+	Port            *int                `yaml:"port,omitempty"`
+	TagSeparator    *string             `yaml:"tag_separator,omitempty"`
+	RefreshInterval *promutils.Duration `yaml:"refresh_interval,omitempty"`
 }
 
 // ZoneYAML holds info about zones.
@@ -1,7 +1,9 @@
 package http
 
 import (
+	"flag"
 	"fmt"
+	"time"
 
 	"github.com/cprobe/cprobe/lib/promauth"
 	"github.com/cprobe/cprobe/lib/promutils"
@@ -9,9 +11,9 @@ import (
 )
 
 // SDCheckInterval defines interval for targets refresh.
-// var SDCheckInterval = flag.Duration("scrape.httpSDCheckInterval", time.Minute, "Interval for checking for changes in http endpoint service discovery. "+
-// 	"This works only if http_sd_configs is configured in '-promscrape.config' file. "+
-// 	"See https://docs.victoriametrics.com/sd_configs.html#http_sd_configs for details")
+var SDCheckInterval = flag.Duration("scrape.httpSDCheckInterval", time.Minute, "Interval for checking for changes in http endpoint service discovery. "+
+	"This works only if http_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in http_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#http_sd_configs for details")
 
 // SDConfig represents service discovery config for http.
 //
@@ -21,6 +23,7 @@ type SDConfig struct {
 	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
 	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
 	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
+	RefreshInterval   *promutils.Duration        `yaml:"refresh_interval,omitempty"`
 }
 
 // GetLabels returns http service discovery labels according to sdc.
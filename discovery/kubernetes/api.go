@@ -0,0 +1,261 @@
+package kubernetes
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/fs"
+	"github.com/cprobe/cprobe/lib/promauth"
+)
+
+const (
+	rolePod           = "pod"
+	roleService       = "service"
+	roleEndpoints     = "endpoints"
+	roleEndpointSlice = "endpointslice"
+	roleNode          = "node"
+	roleIngress       = "ingress"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	// listPageSize is the maximum number of objects returned by a single list request.
+	listPageSize = 500
+)
+
+var configMap = discoveryutils.NewConfigMap()
+
+type apiConfig struct {
+	client *discoveryutils.Client
+	role   string
+
+	// namespaces to discover objects in. Empty list means all the namespaces.
+	namespaces []string
+
+	// selectors contains label and field selectors per role.
+	selectors map[string]Selector
+}
+
+// allowedSelectorRoles contains roles, which can be used in `selectors` for the given `role`.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#kubernetes_sd_config
+var allowedSelectorRoles = map[string][]string{
+	rolePod:           {rolePod},
+	roleService:       {roleService},
+	roleEndpoints:     {roleEndpoints, rolePod, roleService},
+	roleEndpointSlice: {roleEndpointSlice, rolePod, roleService},
+	roleNode:          {roleNode},
+	roleIngress:       {roleIngress},
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	allowedRoles, ok := allowedSelectorRoles[sdc.Role]
+	if !ok {
+		return nil, fmt.Errorf("unexpected `role`: %q; must be one of `node`, `pod`, `service`, `endpoints`, `endpointslice` or `ingress`", sdc.Role)
+	}
+
+	selectors := make(map[string]Selector, len(sdc.Selectors))
+	for _, s := range sdc.Selectors {
+		if !contains(allowedRoles, s.Role) {
+			return nil, fmt.Errorf("unexpected selector role %q for `role: %s`; allowed roles: %s", s.Role, sdc.Role, strings.Join(allowedRoles, ", "))
+		}
+		if _, ok := selectors[s.Role]; ok {
+			return nil, fmt.Errorf("duplicate selector role %q", s.Role)
+		}
+		selectors[s.Role] = s
+	}
+
+	if sdc.APIServer != "" && sdc.KubeConfigFile != "" {
+		return nil, fmt.Errorf("`api_server` and `kubeconfig_file` cannot be set simultaneously")
+	}
+
+	ac, err := sdc.HTTPClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	apiServer := sdc.APIServer
+	proxyURL := sdc.ProxyURL
+	ownNamespace := ""
+
+	switch {
+	case sdc.KubeConfigFile != "":
+		kc, err := newKubeConfig(fs.GetFilepath(baseDir, sdc.KubeConfigFile))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse kubeconfig_file %q: %w", sdc.KubeConfigFile, err)
+		}
+		ac, err = kc.authOpts.NewConfig()
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize auth config from kubeconfig_file %q: %w", sdc.KubeConfigFile, err)
+		}
+		apiServer = kc.server
+		if kc.proxyURL != nil {
+			proxyURL = kc.proxyURL
+		}
+		ownNamespace = kc.namespace
+	case apiServer == "":
+		// Assume we run inside a Kubernetes pod, so discover the API server and auth config according to
+		// https://kubernetes.io/docs/tasks/run-application/access-api-from-pod/
+		host := os.Getenv("KUBERNETES_SERVICE_HOST")
+		port := os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" {
+			return nil, fmt.Errorf("cannot find KUBERNETES_SERVICE_HOST env var; it must be defined when running in k8s; " +
+				"probably, `kubernetes_sd_config->api_server` or `kubernetes_sd_config->kubeconfig_file` is missing in scrape configs?")
+		}
+		if port == "" {
+			return nil, fmt.Errorf("cannot find KUBERNETES_SERVICE_PORT env var; it must be defined when running in k8s; KUBERNETES_SERVICE_HOST=%q", host)
+		}
+		apiServer = "https://" + net.JoinHostPort(host, port)
+		opts := &promauth.Options{
+			BaseDir:         baseDir,
+			BearerTokenFile: serviceAccountDir + "/token",
+			TLSConfig: &promauth.TLSConfig{
+				CAFile: serviceAccountDir + "/ca.crt",
+			},
+		}
+		ac, err = opts.NewConfig()
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize in-cluster auth config: %w", err)
+		}
+	}
+
+	if !strings.Contains(apiServer, "://") {
+		scheme := "http"
+		if sdc.HTTPClientConfig.TLSConfig != nil {
+			scheme = "https"
+		}
+		apiServer = scheme + "://" + apiServer
+	}
+	apiServer = strings.TrimSuffix(apiServer, "/")
+
+	namespaces := append([]string{}, sdc.Namespaces.Names...)
+	if sdc.Namespaces.OwnNamespace {
+		if ownNamespace == "" {
+			data, err := os.ReadFile(serviceAccountDir + "/namespace")
+			if err != nil {
+				return nil, fmt.Errorf("cannot determine own namespace: %w", err)
+			}
+			ownNamespace = strings.TrimSpace(string(data))
+		}
+		if ownNamespace != "" && !contains(namespaces, ownNamespace) {
+			namespaces = append(namespaces, ownNamespace)
+		}
+	}
+
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	client, err := discoveryutils.NewClient(apiServer, ac, proxyURL, proxyAC, &sdc.HTTPClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+
+	cfg := &apiConfig{
+		client:     client,
+		role:       sdc.Role,
+		namespaces: namespaces,
+		selectors:  selectors,
+	}
+	return cfg, nil
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (interface{}, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+// ListMeta is a Kubernetes list metadata.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#listmeta-v1-meta
+type ListMeta struct {
+	ResourceVersion string `json:"resourceVersion"`
+	Continue        string `json:"continue"`
+}
+
+// objectsPath returns the API path for listing objects of the given role in the given namespace.
+//
+// An empty namespace means all the namespaces.
+func objectsPath(role, namespace string) string {
+	var prefix, resource string
+	switch role {
+	case rolePod:
+		prefix, resource = "/api/v1", "pods"
+	case roleService:
+		prefix, resource = "/api/v1", "services"
+	case roleEndpoints:
+		prefix, resource = "/api/v1", "endpoints"
+	case roleNode:
+		return "/api/v1/nodes"
+	case roleEndpointSlice:
+		prefix, resource = "/apis/discovery.k8s.io/v1", "endpointslices"
+	case roleIngress:
+		prefix, resource = "/apis/networking.k8s.io/v1", "ingresses"
+	default:
+		panic(fmt.Errorf("BUG: unexpected role %q", role))
+	}
+	if namespace == "" {
+		return prefix + "/" + resource
+	}
+	return prefix + "/namespaces/" + url.PathEscape(namespace) + "/" + resource
+}
+
+// listObjects lists all the objects for the given role in the configured namespaces page by page
+// and passes every page to f, which must unmarshal the page and return its ListMeta.
+func (cfg *apiConfig) listObjects(role string, f func(data []byte) (*ListMeta, error)) error {
+	namespaces := cfg.namespaces
+	if len(namespaces) == 0 || role == roleNode {
+		namespaces = []string{""}
+	}
+
+	for _, ns := range namespaces {
+		continueToken := ""
+		for {
+			q := url.Values{}
+			if s, ok := cfg.selectors[role]; ok {
+				if s.Label != "" {
+					q.Set("labelSelector", s.Label)
+				}
+				if s.Field != "" {
+					q.Set("fieldSelector", s.Field)
+				}
+			}
+			q.Set("limit", fmt.Sprintf("%d", listPageSize))
+			if continueToken != "" {
+				q.Set("continue", continueToken)
+			}
+
+			path := objectsPath(role, ns) + "?" + q.Encode()
+			data, err := cfg.client.GetAPIResponse(path)
+			if err != nil {
+				return fmt.Errorf("cannot list %s objects: %w", role, err)
+			}
+			lm, err := f(data)
+			if err != nil {
+				return fmt.Errorf("cannot parse %s objects returned from %q: %w", role, path, err)
+			}
+			if lm == nil || lm.Continue == "" {
+				break
+			}
+			continueToken = lm.Continue
+		}
+	}
+
+	return nil
+}
+
+func contains(a []string, s string) bool {
+	for _, x := range a {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package kubernetes
+
+import (
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+// ObjectMeta represents ObjectMeta from k8s API.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#objectmeta-v1-meta
+type ObjectMeta struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	UID             string            `json:"uid"`
+	Labels          *promutils.Labels `json:"labels"`
+	Annotations     *promutils.Labels `json:"annotations"`
+	OwnerReferences []OwnerReference  `json:"ownerReferences"`
+}
+
+func (om *ObjectMeta) key() string {
+	return om.Namespace + "/" + om.Name
+}
+
+// registerLabelsAndAnnotations adds <prefix>_label_*, <prefix>_labelpresent_*, <prefix>_annotation_*
+// and <prefix>_annotationpresent_* labels to m.
+func (om *ObjectMeta) registerLabelsAndAnnotations(prefix string, m *promutils.Labels) {
+	for _, lb := range om.Labels.GetLabels() {
+		m.Add(discoveryutils.SanitizeLabelName(prefix+"_label_"+lb.Name), lb.Value)
+		m.Add(discoveryutils.SanitizeLabelName(prefix+"_labelpresent_"+lb.Name), "true")
+	}
+	for _, a := range om.Annotations.GetLabels() {
+		m.Add(discoveryutils.SanitizeLabelName(prefix+"_annotation_"+a.Name), a.Value)
+		m.Add(discoveryutils.SanitizeLabelName(prefix+"_annotationpresent_"+a.Name), "true")
+	}
+}
+
+// OwnerReference represents OwnerReferense from k8s API.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#ownerreference-v1-meta
+type OwnerReference struct {
+	Name       string `json:"name"`
+	Controller bool   `json:"controller"`
+	Kind       string `json:"kind"`
+}
+
+// ObjectReference represents ObjectReference from k8s API.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#objectreference-v1-core
+type ObjectReference struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,220 @@
+package kubernetes
+
+import (
+	"encoding/json"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+// EndpointsList implements k8s endpoints list.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#endpointslist-v1-core
+type EndpointsList struct {
+	Metadata ListMeta     `json:"metadata"`
+	Items    []*Endpoints `json:"items"`
+}
+
+// Endpoints implements k8s endpoints.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#endpoints-v1-core
+type Endpoints struct {
+	Metadata ObjectMeta       `json:"metadata"`
+	Subsets  []EndpointSubset `json:"subsets"`
+}
+
+// EndpointSubset implements k8s endpoint subset.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#endpointsubset-v1-core
+type EndpointSubset struct {
+	Addresses         []EndpointAddress `json:"addresses"`
+	NotReadyAddresses []EndpointAddress `json:"notReadyAddresses"`
+	Ports             []EndpointPort    `json:"ports"`
+}
+
+// EndpointAddress implements k8s endpoint address.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#endpointaddress-v1-core
+type EndpointAddress struct {
+	IP        string          `json:"ip"`
+	Hostname  string          `json:"hostname"`
+	NodeName  string          `json:"nodeName"`
+	TargetRef ObjectReference `json:"targetRef"`
+}
+
+// EndpointPort implements k8s endpoint port.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#endpointport-v1-discovery-k8s-io
+type EndpointPort struct {
+	AppProtocol string `json:"appProtocol"`
+	Name        string `json:"name"`
+	Port        int    `json:"port"`
+	Protocol    string `json:"protocol"`
+}
+
+func listEndpoints(cfg *apiConfig) ([]*Endpoints, error) {
+	var eps []*Endpoints
+	err := cfg.listObjects(roleEndpoints, func(data []byte) (*ListMeta, error) {
+		var el EndpointsList
+		if err := json.Unmarshal(data, &el); err != nil {
+			return nil, err
+		}
+		eps = append(eps, el.Items...)
+		return &el.Metadata, nil
+	})
+	return eps, err
+}
+
+// getServicesAndPodsByKey returns services and pods referenced by endpoints and endpointslices
+// keyed by `namespace/name`.
+func getServicesAndPodsByKey(cfg *apiConfig) (map[string]*Service, map[string]*Pod, error) {
+	services, err := listServices(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	pods, err := listPods(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	svcs := make(map[string]*Service, len(services))
+	for _, s := range services {
+		svcs[s.Metadata.key()] = s
+	}
+	ps := make(map[string]*Pod, len(pods))
+	for _, p := range pods {
+		ps[p.Metadata.key()] = p
+	}
+	return svcs, ps, nil
+}
+
+func getEndpointsLabels(cfg *apiConfig) ([]*promutils.Labels, error) {
+	eps, err := listEndpoints(cfg)
+	if err != nil {
+		return nil, err
+	}
+	svcs, pods, err := getServicesAndPodsByKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var ms []*promutils.Labels
+	for _, ep := range eps {
+		ms = append(ms, ep.getTargetLabels(svcs, pods)...)
+	}
+	return ms, nil
+}
+
+// getTargetLabels returns labels for each endpoint in eps.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#endpoints
+func (eps *Endpoints) getTargetLabels(svcs map[string]*Service, pods map[string]*Pod) []*promutils.Labels {
+	svc := svcs[eps.Metadata.key()]
+	podPortsSeen := make(map[*Pod][]int)
+	var ms []*promutils.Labels
+	for _, ess := range eps.Subsets {
+		for _, epp := range ess.Ports {
+			ms = appendEndpointLabelsForAddresses(ms, podPortsSeen, eps, ess.Addresses, epp, pods, svc, "true")
+			ms = appendEndpointLabelsForAddresses(ms, podPortsSeen, eps, ess.NotReadyAddresses, epp, pods, svc, "false")
+		}
+	}
+
+	// Append labels for skipped ports on seen pods.
+	portSeen := func(port int, ports []int) bool {
+		for _, p := range ports {
+			if p == port {
+				return true
+			}
+		}
+		return false
+	}
+	for p, ports := range podPortsSeen {
+		for i := range p.Spec.Containers {
+			c := &p.Spec.Containers[i]
+			for j := range c.Ports {
+				cp := &c.Ports[j]
+				if portSeen(cp.ContainerPort, ports) {
+					continue
+				}
+				m := promutils.NewLabels(32)
+				m.Add("__address__", discoveryutils.JoinHostPort(p.Status.PodIP, cp.ContainerPort))
+				p.appendCommonLabels(m)
+				p.addContainerLabels(m, c, cp, false)
+				eps.appendCommonLabels(m)
+				if svc != nil {
+					svc.appendCommonLabels(m)
+				}
+				// Remove possible duplicate labels, which can appear after appendCommonLabels() calls
+				m.RemoveDuplicates()
+				ms = append(ms, m)
+			}
+		}
+	}
+	return ms
+}
+
+func appendEndpointLabelsForAddresses(ms []*promutils.Labels, podPortsSeen map[*Pod][]int, eps *Endpoints,
+	eas []EndpointAddress, epp EndpointPort, pods map[string]*Pod, svc *Service, ready string) []*promutils.Labels {
+	for _, ea := range eas {
+		var p *Pod
+		if ea.TargetRef.Kind == "Pod" {
+			p = pods[ea.TargetRef.Namespace+"/"+ea.TargetRef.Name]
+		}
+		m := getEndpointLabelsForAddressAndPort(podPortsSeen, eps, ea, epp, p, svc, ready)
+		ms = append(ms, m)
+	}
+	return ms
+}
+
+func getEndpointLabelsForAddressAndPort(podPortsSeen map[*Pod][]int, eps *Endpoints, ea EndpointAddress, epp EndpointPort,
+	p *Pod, svc *Service, ready string) *promutils.Labels {
+	m := getEndpointLabels(eps.Metadata, ea, epp, ready)
+	if svc != nil {
+		svc.appendCommonLabels(m)
+	}
+	eps.appendCommonLabels(m)
+	if p != nil && ea.TargetRef.Kind == "Pod" {
+		p.appendCommonLabels(m)
+		for i := range p.Spec.Containers {
+			c := &p.Spec.Containers[i]
+			for j := range c.Ports {
+				cp := &c.Ports[j]
+				if cp.ContainerPort == epp.Port {
+					p.addContainerLabels(m, c, cp, false)
+					podPortsSeen[p] = append(podPortsSeen[p], cp.ContainerPort)
+					break
+				}
+			}
+		}
+	}
+	// Remove possible duplicate labels, which can appear after appendCommonLabels() calls
+	m.RemoveDuplicates()
+	return m
+}
+
+func getEndpointLabels(om ObjectMeta, ea EndpointAddress, epp EndpointPort, ready string) *promutils.Labels {
+	m := promutils.NewLabels(32)
+	m.Add("__address__", discoveryutils.JoinHostPort(ea.IP, epp.Port))
+	m.Add("__meta_kubernetes_namespace", om.Namespace)
+	m.Add("__meta_kubernetes_endpoints_name", om.Name)
+	m.Add("__meta_kubernetes_endpoint_ready", ready)
+	m.Add("__meta_kubernetes_endpoint_port_name", epp.Name)
+	m.Add("__meta_kubernetes_endpoint_port_protocol", epp.Protocol)
+	if epp.AppProtocol != "" {
+		m.Add("__meta_kubernetes_endpoint_port_app_protocol", epp.AppProtocol)
+	}
+	if ea.TargetRef.Kind != "" {
+		m.Add("__meta_kubernetes_endpoint_address_target_kind", ea.TargetRef.Kind)
+		m.Add("__meta_kubernetes_endpoint_address_target_name", ea.TargetRef.Name)
+	}
+	if ea.NodeName != "" {
+		m.Add("__meta_kubernetes_endpoint_node_name", ea.NodeName)
+	}
+	if ea.Hostname != "" {
+		m.Add("__meta_kubernetes_endpoint_hostname", ea.Hostname)
+	}
+	return m
+}
+
+// appendCommonLabels adds labels, which are common for all the targets of the eps.
+func (eps *Endpoints) appendCommonLabels(m *promutils.Labels) {
+	eps.Metadata.registerLabelsAndAnnotations("__meta_kubernetes_endpoints", m)
+}
@@ -0,0 +1,205 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+// EndpointSliceList - implements kubernetes endpoint slice list object, that groups service endpoints slices.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#endpointslicelist-v1-discovery-k8s-io
+type EndpointSliceList struct {
+	Metadata ListMeta         `json:"metadata"`
+	Items    []*EndpointSlice `json:"items"`
+}
+
+// EndpointSlice - implements kubernetes endpoint slice.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#endpointslice-v1-discovery-k8s-io
+type EndpointSlice struct {
+	Metadata    ObjectMeta     `json:"metadata"`
+	Endpoints   []Endpoint     `json:"endpoints"`
+	AddressType string         `json:"addressType"`
+	Ports       []EndpointPort `json:"ports"`
+}
+
+// Endpoint implements kubernetes object endpoint for endpoint slice.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#endpoint-v1-discovery-k8s-io
+type Endpoint struct {
+	Addresses  []string           `json:"addresses"`
+	Conditions EndpointConditions `json:"conditions"`
+	Hostname   string             `json:"hostname"`
+	NodeName   string             `json:"nodeName"`
+	Zone       string             `json:"zone"`
+	TargetRef  ObjectReference    `json:"targetRef"`
+}
+
+// EndpointConditions implements kubernetes endpoint condition.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#endpointconditions-v1-discovery-k8s-io
+type EndpointConditions struct {
+	Ready       *bool `json:"ready"`
+	Serving     *bool `json:"serving"`
+	Terminating *bool `json:"terminating"`
+}
+
+func listEndpointSlices(cfg *apiConfig) ([]*EndpointSlice, error) {
+	var ess []*EndpointSlice
+	err := cfg.listObjects(roleEndpointSlice, func(data []byte) (*ListMeta, error) {
+		var esl EndpointSliceList
+		if err := json.Unmarshal(data, &esl); err != nil {
+			return nil, err
+		}
+		ess = append(ess, esl.Items...)
+		return &esl.Metadata, nil
+	})
+	return ess, err
+}
+
+func getEndpointSlicesLabels(cfg *apiConfig) ([]*promutils.Labels, error) {
+	ess, err := listEndpointSlices(cfg)
+	if err != nil {
+		return nil, err
+	}
+	svcs, pods, err := getServicesAndPodsByKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var ms []*promutils.Labels
+	for _, es := range ess {
+		ms = append(ms, es.getTargetLabels(svcs, pods)...)
+	}
+	return ms, nil
+}
+
+// getTargetLabels returns labels for each endpoint in eps.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#endpointslice
+func (eps *EndpointSlice) getTargetLabels(svcs map[string]*Service, pods map[string]*Pod) []*promutils.Labels {
+	var svc *Service
+	if svcName := eps.Metadata.Labels.Get("kubernetes.io/service-name"); svcName != "" {
+		svc = svcs[eps.Metadata.Namespace+"/"+svcName]
+	}
+	podPortsSeen := make(map[*Pod][]int)
+	var ms []*promutils.Labels
+	for _, ess := range eps.Endpoints {
+		var p *Pod
+		if ess.TargetRef.Kind == "Pod" {
+			p = pods[ess.TargetRef.Namespace+"/"+ess.TargetRef.Name]
+		}
+		for _, epp := range eps.Ports {
+			for _, addr := range ess.Addresses {
+				m := getEndpointSliceLabelsForAddressAndPort(podPortsSeen, addr, eps, ess, epp, p, svc)
+				ms = append(ms, m)
+			}
+		}
+	}
+
+	// Append labels for skipped ports on seen pods.
+	portSeen := func(port int, ports []int) bool {
+		for _, p := range ports {
+			if p == port {
+				return true
+			}
+		}
+		return false
+	}
+	for p, ports := range podPortsSeen {
+		for i := range p.Spec.Containers {
+			c := &p.Spec.Containers[i]
+			for j := range c.Ports {
+				cp := &c.Ports[j]
+				if portSeen(cp.ContainerPort, ports) {
+					continue
+				}
+				m := promutils.NewLabels(32)
+				m.Add("__address__", discoveryutils.JoinHostPort(p.Status.PodIP, cp.ContainerPort))
+				p.appendCommonLabels(m)
+				p.addContainerLabels(m, c, cp, false)
+				eps.appendCommonLabels(m)
+				if svc != nil {
+					svc.appendCommonLabels(m)
+				}
+				// Remove possible duplicate labels, which can appear after appendCommonLabels() calls
+				m.RemoveDuplicates()
+				ms = append(ms, m)
+			}
+		}
+	}
+	return ms
+}
+
+// getEndpointSliceLabelsForAddressAndPort returns labels for the given addr and epp.
+//
+// Pod labels are added if ea points to p. The matching container port of p is recorded in podPortsSeen.
+func getEndpointSliceLabelsForAddressAndPort(podPortsSeen map[*Pod][]int, addr string, eps *EndpointSlice, ea Endpoint, epp EndpointPort,
+	p *Pod, svc *Service) *promutils.Labels {
+	m := getEndpointSliceLabels(eps, addr, ea, epp)
+	if svc != nil {
+		svc.appendCommonLabels(m)
+	}
+	eps.appendCommonLabels(m)
+	if p != nil && ea.TargetRef.Kind == "Pod" {
+		p.appendCommonLabels(m)
+		for i := range p.Spec.Containers {
+			c := &p.Spec.Containers[i]
+			for j := range c.Ports {
+				cp := &c.Ports[j]
+				if cp.ContainerPort == epp.Port {
+					p.addContainerLabels(m, c, cp, false)
+					podPortsSeen[p] = append(podPortsSeen[p], cp.ContainerPort)
+					break
+				}
+			}
+		}
+	}
+	// Remove possible duplicate labels, which can appear after appendCommonLabels() calls
+	m.RemoveDuplicates()
+	return m
+}
+
+// getEndpointSliceLabels builds labels for the given EndpointSlice.
+func getEndpointSliceLabels(eps *EndpointSlice, addr string, ea Endpoint, epp EndpointPort) *promutils.Labels {
+	m := promutils.NewLabels(32)
+	m.Add("__address__", discoveryutils.JoinHostPort(addr, epp.Port))
+	m.Add("__meta_kubernetes_namespace", eps.Metadata.Namespace)
+	m.Add("__meta_kubernetes_endpointslice_name", eps.Metadata.Name)
+	m.Add("__meta_kubernetes_endpointslice_address_type", eps.AddressType)
+	m.Add("__meta_kubernetes_endpointslice_port", strconv.Itoa(epp.Port))
+	m.Add("__meta_kubernetes_endpointslice_port_name", epp.Name)
+	m.Add("__meta_kubernetes_endpointslice_port_protocol", epp.Protocol)
+	if epp.AppProtocol != "" {
+		m.Add("__meta_kubernetes_endpointslice_port_app_protocol", epp.AppProtocol)
+	}
+	if ea.TargetRef.Kind != "" {
+		m.Add("__meta_kubernetes_endpointslice_address_target_kind", ea.TargetRef.Kind)
+		m.Add("__meta_kubernetes_endpointslice_address_target_name", ea.TargetRef.Name)
+	}
+	if ea.Hostname != "" {
+		m.Add("__meta_kubernetes_endpointslice_endpoint_hostname", ea.Hostname)
+	}
+	if ea.NodeName != "" {
+		m.Add("__meta_kubernetes_endpointslice_endpoint_node_name", ea.NodeName)
+	}
+	if ea.Zone != "" {
+		m.Add("__meta_kubernetes_endpointslice_endpoint_zone", ea.Zone)
+	}
+	addCondition := func(name string, v *bool) {
+		if v != nil {
+			m.Add("__meta_kubernetes_endpointslice_endpoint_conditions_"+name, boolString(*v))
+		}
+	}
+	addCondition("ready", ea.Conditions.Ready)
+	addCondition("serving", ea.Conditions.Serving)
+	addCondition("terminating", ea.Conditions.Terminating)
+	return m
+}
+
+// appendCommonLabels adds labels, which are common for all the targets of the eps.
+func (eps *EndpointSlice) appendCommonLabels(m *promutils.Labels) {
+	eps.Metadata.registerLabelsAndAnnotations("__meta_kubernetes_endpointslice", m)
+}
@@ -0,0 +1,152 @@
+package kubernetes
+
+import (
+	"encoding/json"
+
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+// IngressList represents ingress list in k8s.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#ingresslist-v1-networking-k8s-io
+type IngressList struct {
+	Metadata ListMeta   `json:"metadata"`
+	Items    []*Ingress `json:"items"`
+}
+
+// Ingress represents ingress in k8s.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#ingress-v1-networking-k8s-io
+type Ingress struct {
+	Metadata ObjectMeta  `json:"metadata"`
+	Spec     IngressSpec `json:"spec"`
+}
+
+// IngressSpec represents ingress spec in k8s.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#ingressspec-v1-networking-k8s-io
+type IngressSpec struct {
+	TLS              []IngressTLS  `json:"tls"`
+	Rules            []IngressRule `json:"rules"`
+	IngressClassName string        `json:"ingressClassName"`
+}
+
+// IngressTLS represents ingress TLS spec in k8s.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#ingresstls-v1-networking-k8s-io
+type IngressTLS struct {
+	Hosts []string `json:"hosts"`
+}
+
+// IngressRule represents ingress rule in k8s.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#ingressrule-v1-networking-k8s-io
+type IngressRule struct {
+	Host string `json:"host"`
+	HTTP struct {
+		Paths []HTTPIngressPath `json:"paths"`
+	} `json:"http"`
+}
+
+// HTTPIngressPath represents HTTP ingress path in k8s.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#httpingresspath-v1-networking-k8s-io
+type HTTPIngressPath struct {
+	Path string `json:"path"`
+}
+
+func listIngresses(cfg *apiConfig) ([]*Ingress, error) {
+	var ingresses []*Ingress
+	err := cfg.listObjects(roleIngress, func(data []byte) (*ListMeta, error) {
+		var il IngressList
+		if err := json.Unmarshal(data, &il); err != nil {
+			return nil, err
+		}
+		ingresses = append(ingresses, il.Items...)
+		return &il.Metadata, nil
+	})
+	return ingresses, err
+}
+
+func getIngressesLabels(cfg *apiConfig) ([]*promutils.Labels, error) {
+	ingresses, err := listIngresses(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var ms []*promutils.Labels
+	for _, ig := range ingresses {
+		ms = append(ms, ig.getTargetLabels()...)
+	}
+	return ms, nil
+}
+
+// getTargetLabels returns labels for ig.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#ingress
+func (ig *Ingress) getTargetLabels() []*promutils.Labels {
+	var ms []*promutils.Labels
+	for _, r := range ig.Spec.Rules {
+		paths := getIngressRulePaths(r.HTTP.Paths)
+		scheme := getSchemeForHost(r.Host, ig.Spec.TLS)
+		for _, path := range paths {
+			m := promutils.NewLabels(16)
+			m.Add("__address__", r.Host)
+			m.Add("__meta_kubernetes_namespace", ig.Metadata.Namespace)
+			m.Add("__meta_kubernetes_ingress_name", ig.Metadata.Name)
+			m.Add("__meta_kubernetes_ingress_scheme", scheme)
+			m.Add("__meta_kubernetes_ingress_host", r.Host)
+			m.Add("__meta_kubernetes_ingress_path", path)
+			m.Add("__meta_kubernetes_ingress_class_name", ig.Spec.IngressClassName)
+			ig.Metadata.registerLabelsAndAnnotations("__meta_kubernetes_ingress", m)
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+func getSchemeForHost(host string, tlss []IngressTLS) string {
+	for _, tls := range tlss {
+		for _, hostPattern := range tls.Hosts {
+			if matchesHostPattern(hostPattern, host) {
+				return "https"
+			}
+		}
+	}
+	return "http"
+}
+
+// matchesHostPattern supports wildcard hosts such as `*.example.com`.
+func matchesHostPattern(pattern, host string) bool {
+	if pattern == host {
+		return true
+	}
+	if len(pattern) < 2 || pattern[0] != '*' || pattern[1] != '.' {
+		return false
+	}
+	suffix := pattern[1:]
+	if len(host) <= len(suffix) || host[len(host)-len(suffix):] != suffix {
+		return false
+	}
+	// The wildcard must match exactly one DNS label.
+	for _, c := range host[:len(host)-len(suffix)] {
+		if c == '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func getIngressRulePaths(paths []HTTPIngressPath) []string {
+	if len(paths) == 0 {
+		return []string{"/"}
+	}
+	var result []string
+	for _, p := range paths {
+		path := p.Path
+		if path == "" {
+			path = "/"
+		}
+		result = append(result, path)
+	}
+	return result
+}
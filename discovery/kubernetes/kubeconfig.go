@@ -0,0 +1,192 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path/filepath"
+
+	"github.com/cprobe/cprobe/lib/fs"
+	"github.com/cprobe/cprobe/lib/promauth"
+	"github.com/cprobe/cprobe/lib/proxy"
+	"gopkg.in/yaml.v2"
+)
+
+// kubeConfig contains the subset of kubeconfig fields needed for connecting to the API server.
+//
+// See https://kubernetes.io/docs/concepts/configuration/organize-cluster-access-kubeconfig/
+type kubeConfig struct {
+	Clusters []struct {
+		Name    string       `yaml:"name"`
+		Cluster *kubeCluster `yaml:"cluster"`
+	} `yaml:"clusters"`
+	AuthInfos []struct {
+		Name     string        `yaml:"name"`
+		AuthInfo *kubeAuthInfo `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string       `yaml:"name"`
+		Context *kubeContext `yaml:"context"`
+	} `yaml:"contexts"`
+	CurrentContext string `yaml:"current-context"`
+}
+
+type kubeCluster struct {
+	Server                   string `yaml:"server"`
+	CertificateAuthority     string `yaml:"certificate-authority,omitempty"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data,omitempty"`
+	InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify,omitempty"`
+	TLSServerName            string `yaml:"tls-server-name,omitempty"`
+	ProxyURL                 string `yaml:"proxy-url,omitempty"`
+}
+
+type kubeAuthInfo struct {
+	ClientCertificate     string                 `yaml:"client-certificate,omitempty"`
+	ClientCertificateData string                 `yaml:"client-certificate-data,omitempty"`
+	ClientKey             string                 `yaml:"client-key,omitempty"`
+	ClientKeyData         string                 `yaml:"client-key-data,omitempty"`
+	Token                 string                 `yaml:"token,omitempty"`
+	TokenFile             string                 `yaml:"tokenFile,omitempty"`
+	Username              string                 `yaml:"username,omitempty"`
+	Password              string                 `yaml:"password,omitempty"`
+	Exec                  map[string]interface{} `yaml:"exec,omitempty"`
+	AuthProvider          map[string]interface{} `yaml:"auth-provider,omitempty"`
+}
+
+type kubeContext struct {
+	Cluster   string `yaml:"cluster"`
+	AuthInfo  string `yaml:"user"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// apiServerConfig is the result of kubeconfig parsing.
+type apiServerConfig struct {
+	server    string
+	namespace string
+	proxyURL  *proxy.URL
+	authOpts  *promauth.Options
+}
+
+func newKubeConfig(path string) (*apiServerConfig, error) {
+	data, err := fs.ReadFileOrHTTP(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %w", path, err)
+	}
+	var kc kubeConfig
+	if err := yaml.Unmarshal(data, &kc); err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+	return kc.apiServerConfig(filepath.Dir(path))
+}
+
+func (kc *kubeConfig) apiServerConfig(baseDir string) (*apiServerConfig, error) {
+	if kc.CurrentContext == "" {
+		return nil, fmt.Errorf("missing `current-context` in kubeconfig")
+	}
+
+	var ctx *kubeContext
+	for _, c := range kc.Contexts {
+		if c.Name == kc.CurrentContext {
+			ctx = c.Context
+			break
+		}
+	}
+	if ctx == nil {
+		return nil, fmt.Errorf("cannot find context %q in kubeconfig", kc.CurrentContext)
+	}
+
+	var cluster *kubeCluster
+	for _, c := range kc.Clusters {
+		if c.Name == ctx.Cluster {
+			cluster = c.Cluster
+			break
+		}
+	}
+	if cluster == nil {
+		return nil, fmt.Errorf("cannot find cluster %q in kubeconfig", ctx.Cluster)
+	}
+	if cluster.Server == "" {
+		return nil, fmt.Errorf("missing `server` for cluster %q in kubeconfig", ctx.Cluster)
+	}
+
+	var authInfo *kubeAuthInfo
+	if ctx.AuthInfo != "" {
+		for _, u := range kc.AuthInfos {
+			if u.Name == ctx.AuthInfo {
+				authInfo = u.AuthInfo
+				break
+			}
+		}
+		if authInfo == nil {
+			return nil, fmt.Errorf("cannot find user %q in kubeconfig", ctx.AuthInfo)
+		}
+	}
+
+	tlsConfig := &promauth.TLSConfig{
+		InsecureSkipVerify: cluster.InsecureSkipTLSVerify,
+		ServerName:         cluster.TLSServerName,
+	}
+	if cluster.CertificateAuthorityData != "" {
+		ca, err := base64.StdEncoding.DecodeString(cluster.CertificateAuthorityData)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode `certificate-authority-data` for cluster %q: %w", ctx.Cluster, err)
+		}
+		tlsConfig.CA = string(ca)
+	} else if cluster.CertificateAuthority != "" {
+		tlsConfig.CAFile = fs.GetFilepath(baseDir, cluster.CertificateAuthority)
+	}
+
+	opts := &promauth.Options{
+		BaseDir:   baseDir,
+		TLSConfig: tlsConfig,
+	}
+
+	if authInfo != nil {
+		if len(authInfo.Exec) > 0 || len(authInfo.AuthProvider) > 0 {
+			return nil, fmt.Errorf("`exec` and `auth-provider` are not supported in kubeconfig user %q; use token, client certificate or basic auth instead", ctx.AuthInfo)
+		}
+
+		switch {
+		case authInfo.ClientCertificateData != "" || authInfo.ClientKeyData != "":
+			cert, err := base64.StdEncoding.DecodeString(authInfo.ClientCertificateData)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decode `client-certificate-data` for user %q: %w", ctx.AuthInfo, err)
+			}
+			key, err := base64.StdEncoding.DecodeString(authInfo.ClientKeyData)
+			if err != nil {
+				return nil, fmt.Errorf("cannot decode `client-key-data` for user %q: %w", ctx.AuthInfo, err)
+			}
+			tlsConfig.Cert = string(cert)
+			tlsConfig.Key = string(key)
+		case authInfo.ClientCertificate != "" || authInfo.ClientKey != "":
+			tlsConfig.CertFile = fs.GetFilepath(baseDir, authInfo.ClientCertificate)
+			tlsConfig.KeyFile = fs.GetFilepath(baseDir, authInfo.ClientKey)
+		}
+
+		switch {
+		case authInfo.Token != "":
+			opts.BearerToken = authInfo.Token
+		case authInfo.TokenFile != "":
+			opts.BearerTokenFile = fs.GetFilepath(baseDir, authInfo.TokenFile)
+		case authInfo.Username != "":
+			opts.BasicAuth = &promauth.BasicAuthConfig{
+				Username: authInfo.Username,
+				Password: promauth.NewSecret(authInfo.Password),
+			}
+		}
+	}
+
+	cfg := &apiServerConfig{
+		server:    cluster.Server,
+		namespace: ctx.Namespace,
+		authOpts:  opts,
+	}
+	if cluster.ProxyURL != "" {
+		pu, err := url.Parse(cluster.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse `proxy-url` for cluster %q: %w", ctx.Cluster, err)
+		}
+		cfg.proxyURL = &proxy.URL{URL: pu}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/promauth"
+	"github.com/cprobe/cprobe/lib/promutils"
+	"github.com/cprobe/cprobe/lib/proxy"
+)
+
+// SDCheckInterval defines interval for targets refresh.
+var SDCheckInterval = flag.Duration("scrape.kubernetesSDCheckInterval", 30*time.Second, "Interval for checking for changes in Kubernetes API server. "+
+	"This works only if kubernetes_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in kubernetes_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#kubernetes_sd_configs for details")
+
+// SDConfig represents kubernetes-based service discovery config.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#kubernetes_sd_config
+type SDConfig struct {
+	APIServer         string                     `yaml:"api_server,omitempty"`
+	Role              string                     `yaml:"role"`
+	KubeConfigFile    string                     `yaml:"kubeconfig_file,omitempty"`
+	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
+	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
+	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
+	Namespaces        Namespaces                 `yaml:"namespaces,omitempty"`
+	Selectors         []Selector                 `yaml:"selectors,omitempty"`
+	RefreshInterval   *promutils.Duration        `yaml:"refresh_interval,omitempty"`
+}
+
+// Namespaces represents namespaces for SDConfig
+type Namespaces struct {
+	OwnNamespace bool     `yaml:"own_namespace,omitempty"`
+	Names        []string `yaml:"names,omitempty"`
+}
+
+// Selector represents kubernetes selector.
+//
+// See https://kubernetes.io/docs/concepts/overview/working-with-objects/labels/
+// and https://kubernetes.io/docs/concepts/overview/working-with-objects/field-selectors/
+type Selector struct {
+	Role  string `yaml:"role"`
+	Label string `yaml:"label,omitempty"`
+	Field string `yaml:"field,omitempty"`
+}
+
+// GetLabels returns labels for the given sdc and baseDir.
+func (sdc *SDConfig) GetLabels(baseDir string) ([]*promutils.Labels, error) {
+	cfg, err := getAPIConfig(sdc, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create API config: %w", err)
+	}
+	switch sdc.Role {
+	case rolePod:
+		return getPodsLabels(cfg)
+	case roleService:
+		return getServicesLabels(cfg)
+	case roleEndpoints:
+		return getEndpointsLabels(cfg)
+	case roleEndpointSlice:
+		return getEndpointSlicesLabels(cfg)
+	case roleNode:
+		return getNodesLabels(cfg)
+	case roleIngress:
+		return getIngressesLabels(cfg)
+	default:
+		return nil, fmt.Errorf("unexpected `role`: %q; must be one of `node`, `pod`, `service`, `endpoints`, `endpointslice` or `ingress`", sdc.Role)
+	}
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *SDConfig) MustStop() {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		cfg := v.(*apiConfig)
+		cfg.client.Stop()
+	}
+}
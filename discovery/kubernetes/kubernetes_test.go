@@ -0,0 +1,392 @@
+package kubernetes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+// fakeAPIServer serves canned list responses keyed by request path and the `continue` query arg.
+type fakeAPIServer struct {
+	mu        sync.Mutex
+	responses map[string]string
+	queries   []string
+}
+
+func newFakeAPIServer(t *testing.T, responses map[string]string) (*fakeAPIServer, string) {
+	t.Helper()
+	fs := &fakeAPIServer{
+		responses: responses,
+	}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fs.mu.Lock()
+		fs.queries = append(fs.queries, r.URL.Path+"?"+r.URL.RawQuery)
+		fs.mu.Unlock()
+		key := r.URL.Path
+		if c := r.URL.Query().Get("continue"); c != "" {
+			key += "#" + c
+		}
+		resp, ok := responses[key]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(resp))
+	}))
+	t.Cleanup(s.Close)
+	return fs, s.URL
+}
+
+func getTestLabels(t *testing.T, sdc *SDConfig) []*promutils.Labels {
+	t.Helper()
+	defer sdc.MustStop()
+	ms, err := sdc.GetLabels("")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return ms
+}
+
+func TestGetLabelsPod(t *testing.T) {
+	fs, apiServer := newFakeAPIServer(t, map[string]string{
+		"/api/v1/namespaces/default/pods": `{
+			"metadata": {"continue": "page2"},
+			"items": [{
+				"metadata": {
+					"name": "web-1",
+					"namespace": "default",
+					"uid": "uid-1",
+					"labels": {"app": "web"},
+					"ownerReferences": [{"kind": "ReplicaSet", "name": "web-rs", "controller": true}]
+				},
+				"spec": {
+					"nodeName": "node-1",
+					"containers": [{"name": "nginx", "image": "nginx:1.25", "ports": [{"name": "http", "containerPort": 80, "protocol": "TCP"}]}]
+				},
+				"status": {
+					"phase": "Running",
+					"podIP": "10.0.0.1",
+					"hostIP": "192.168.0.1",
+					"conditions": [{"type": "Ready", "status": "True"}]
+				}
+			}]
+		}`,
+		"/api/v1/namespaces/default/pods#page2": `{
+			"metadata": {},
+			"items": [{
+				"metadata": {"name": "pending", "namespace": "default"},
+				"spec": {"containers": [{"name": "c"}]},
+				"status": {"phase": "Pending"}
+			}]
+		}`,
+	})
+
+	sdc := &SDConfig{
+		APIServer:  apiServer,
+		Role:       "pod",
+		Namespaces: Namespaces{Names: []string{"default"}},
+		Selectors: []Selector{{
+			Role:  "pod",
+			Label: "app=web",
+			Field: "status.phase=Running",
+		}},
+	}
+	ms := getTestLabels(t, sdc)
+	discoveryutils.TestEqualLabelss(t, ms, []*promutils.Labels{
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                                   "10.0.0.1:80",
+			"__meta_kubernetes_namespace":                   "default",
+			"__meta_kubernetes_pod_name":                    "web-1",
+			"__meta_kubernetes_pod_ip":                      "10.0.0.1",
+			"__meta_kubernetes_pod_ready":                   "true",
+			"__meta_kubernetes_pod_phase":                   "Running",
+			"__meta_kubernetes_pod_node_name":               "node-1",
+			"__meta_kubernetes_pod_host_ip":                 "192.168.0.1",
+			"__meta_kubernetes_pod_uid":                     "uid-1",
+			"__meta_kubernetes_pod_controller_kind":         "ReplicaSet",
+			"__meta_kubernetes_pod_controller_name":         "web-rs",
+			"__meta_kubernetes_pod_label_app":               "web",
+			"__meta_kubernetes_pod_labelpresent_app":        "true",
+			"__meta_kubernetes_pod_container_image":         "nginx:1.25",
+			"__meta_kubernetes_pod_container_name":          "nginx",
+			"__meta_kubernetes_pod_container_init":          "false",
+			"__meta_kubernetes_pod_container_port_name":     "http",
+			"__meta_kubernetes_pod_container_port_number":   "80",
+			"__meta_kubernetes_pod_container_port_protocol": "TCP",
+		}),
+	})
+
+	if len(fs.queries) != 2 {
+		t.Fatalf("unexpected number of list requests; got %d; want 2: %q", len(fs.queries), fs.queries)
+	}
+	for _, q := range fs.queries {
+		for _, want := range []string{"labelSelector=app%3Dweb", "fieldSelector=status.phase%3DRunning", "limit=500"} {
+			if !strings.Contains(q, want) {
+				t.Fatalf("missing %q in request %q", want, q)
+			}
+		}
+	}
+	if !strings.Contains(fs.queries[1], "continue=page2") {
+		t.Fatalf("missing continue token in the second request %q", fs.queries[1])
+	}
+}
+
+func TestGetLabelsEndpoints(t *testing.T) {
+	_, apiServer := newFakeAPIServer(t, map[string]string{
+		"/api/v1/endpoints": `{
+			"items": [{
+				"metadata": {"name": "web", "namespace": "default"},
+				"subsets": [{
+					"addresses": [{"ip": "10.0.0.1", "nodeName": "node-1", "targetRef": {"kind": "Pod", "name": "web-1", "namespace": "default"}}],
+					"notReadyAddresses": [{"ip": "10.0.0.2"}],
+					"ports": [{"name": "http", "port": 80, "protocol": "TCP"}]
+				}]
+			}]
+		}`,
+		"/api/v1/services": `{
+			"items": [{
+				"metadata": {"name": "web", "namespace": "default"},
+				"spec": {"type": "ClusterIP", "clusterIP": "10.96.0.10", "ports": [{"name": "http", "port": 80, "protocol": "TCP"}]}
+			}]
+		}`,
+		"/api/v1/pods": `{
+			"items": [{
+				"metadata": {"name": "web-1", "namespace": "default"},
+				"spec": {
+					"nodeName": "node-1",
+					"containers": [{"name": "nginx", "image": "nginx", "ports": [
+						{"name": "http", "containerPort": 80, "protocol": "TCP"},
+						{"name": "metrics", "containerPort": 9113, "protocol": "TCP"}
+					]}]
+				},
+				"status": {"phase": "Running", "podIP": "10.0.0.1", "hostIP": "192.168.0.1"}
+			}]
+		}`,
+	})
+
+	sdc := &SDConfig{
+		APIServer: apiServer,
+		Role:      "endpoints",
+	}
+	ms := getTestLabels(t, sdc)
+	svcLabels := map[string]string{
+		"__meta_kubernetes_service_name":       "web",
+		"__meta_kubernetes_service_type":       "ClusterIP",
+		"__meta_kubernetes_service_cluster_ip": "10.96.0.10",
+	}
+	podLabels := map[string]string{
+		"__meta_kubernetes_pod_name":            "web-1",
+		"__meta_kubernetes_pod_ip":              "10.0.0.1",
+		"__meta_kubernetes_pod_ready":           "unknown",
+		"__meta_kubernetes_pod_phase":           "Running",
+		"__meta_kubernetes_pod_node_name":       "node-1",
+		"__meta_kubernetes_pod_host_ip":         "192.168.0.1",
+		"__meta_kubernetes_pod_uid":             "",
+		"__meta_kubernetes_pod_container_name":  "nginx",
+		"__meta_kubernetes_pod_container_init":  "false",
+		"__meta_kubernetes_pod_container_image": "nginx",
+	}
+	merge := func(ms ...map[string]string) *promutils.Labels {
+		result := make(map[string]string)
+		for _, m := range ms {
+			for k, v := range m {
+				result[k] = v
+			}
+		}
+		return promutils.NewLabelsFromMap(result)
+	}
+	discoveryutils.TestEqualLabelss(t, ms, []*promutils.Labels{
+		merge(svcLabels, podLabels, map[string]string{
+			"__address__":                                    "10.0.0.1:80",
+			"__meta_kubernetes_namespace":                    "default",
+			"__meta_kubernetes_endpoints_name":               "web",
+			"__meta_kubernetes_endpoint_ready":               "true",
+			"__meta_kubernetes_endpoint_port_name":           "http",
+			"__meta_kubernetes_endpoint_port_protocol":       "TCP",
+			"__meta_kubernetes_endpoint_node_name":           "node-1",
+			"__meta_kubernetes_endpoint_address_target_kind": "Pod",
+			"__meta_kubernetes_endpoint_address_target_name": "web-1",
+			"__meta_kubernetes_pod_container_port_name":      "http",
+			"__meta_kubernetes_pod_container_port_number":    "80",
+			"__meta_kubernetes_pod_container_port_protocol":  "TCP",
+		}),
+		merge(svcLabels, map[string]string{
+			"__address__":                              "10.0.0.2:80",
+			"__meta_kubernetes_namespace":              "default",
+			"__meta_kubernetes_endpoints_name":         "web",
+			"__meta_kubernetes_endpoint_ready":         "false",
+			"__meta_kubernetes_endpoint_port_name":     "http",
+			"__meta_kubernetes_endpoint_port_protocol": "TCP",
+		}),
+		merge(svcLabels, podLabels, map[string]string{
+			"__address__":                                   "10.0.0.1:9113",
+			"__meta_kubernetes_namespace":                   "default",
+			"__meta_kubernetes_pod_container_port_name":     "metrics",
+			"__meta_kubernetes_pod_container_port_number":   "9113",
+			"__meta_kubernetes_pod_container_port_protocol": "TCP",
+		}),
+	})
+}
+
+func TestGetLabelsNodeAndIngress(t *testing.T) {
+	_, apiServer := newFakeAPIServer(t, map[string]string{
+		"/api/v1/nodes": `{
+			"items": [{
+				"metadata": {"name": "node-1", "labels": {"kubernetes.io/os": "linux"}},
+				"spec": {"providerID": "aws:///i-123"},
+				"status": {
+					"addresses": [{"type": "Hostname", "address": "node-1"}, {"type": "InternalIP", "address": "192.168.0.1"}],
+					"daemonEndpoints": {"kubeletEndpoint": {"port": 10250}}
+				}
+			}]
+		}`,
+		"/apis/networking.k8s.io/v1/namespaces/prod/ingresses": `{
+			"items": [{
+				"metadata": {"name": "site", "namespace": "prod"},
+				"spec": {
+					"ingressClassName": "nginx",
+					"tls": [{"hosts": ["*.example.com"]}],
+					"rules": [
+						{"host": "www.example.com", "http": {"paths": [{"path": "/api"}, {"path": ""}]}},
+						{"host": "example.org"}
+					]
+				}
+			}]
+		}`,
+	})
+
+	ms := getTestLabels(t, &SDConfig{
+		APIServer:  apiServer,
+		Role:       "node",
+		Namespaces: Namespaces{Names: []string{"prod"}},
+	})
+	discoveryutils.TestEqualLabelss(t, ms, []*promutils.Labels{
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                                          "192.168.0.1:10250",
+			"instance":                                             "node-1",
+			"__meta_kubernetes_node_name":                          "node-1",
+			"__meta_kubernetes_node_provider_id":                   "aws:///i-123",
+			"__meta_kubernetes_node_label_kubernetes_io_os":        "linux",
+			"__meta_kubernetes_node_labelpresent_kubernetes_io_os": "true",
+			"__meta_kubernetes_node_address_Hostname":              "node-1",
+			"__meta_kubernetes_node_address_InternalIP":            "192.168.0.1",
+		}),
+	})
+
+	ms = getTestLabels(t, &SDConfig{
+		APIServer:  apiServer,
+		Role:       "ingress",
+		Namespaces: Namespaces{Names: []string{"prod"}},
+	})
+	ingressLabels := func(host, scheme, path string) *promutils.Labels {
+		return promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                          host,
+			"__meta_kubernetes_namespace":          "prod",
+			"__meta_kubernetes_ingress_name":       "site",
+			"__meta_kubernetes_ingress_class_name": "nginx",
+			"__meta_kubernetes_ingress_host":       host,
+			"__meta_kubernetes_ingress_scheme":     scheme,
+			"__meta_kubernetes_ingress_path":       path,
+		})
+	}
+	discoveryutils.TestEqualLabelss(t, ms, []*promutils.Labels{
+		ingressLabels("www.example.com", "https", "/api"),
+		ingressLabels("www.example.com", "https", "/"),
+		ingressLabels("example.org", "http", "/"),
+	})
+}
+
+func TestNewAPIConfigFailure(t *testing.T) {
+	f := func(sdc *SDConfig) {
+		t.Helper()
+		if _, err := newAPIConfig(sdc, ""); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// unknown role
+	f(&SDConfig{APIServer: "http://localhost:8080", Role: "foo"})
+
+	// selector role not allowed for the role
+	f(&SDConfig{APIServer: "http://localhost:8080", Role: "pod", Selectors: []Selector{{Role: "node", Label: "a=b"}}})
+
+	// duplicate selector role
+	f(&SDConfig{APIServer: "http://localhost:8080", Role: "endpoints", Selectors: []Selector{{Role: "pod"}, {Role: "pod"}}})
+
+	// api_server and kubeconfig_file at the same time
+	f(&SDConfig{APIServer: "http://localhost:8080", KubeConfigFile: "/path/to/kubeconfig", Role: "pod"})
+
+	// missing kubeconfig_file
+	f(&SDConfig{KubeConfigFile: "/non-existing/kubeconfig", Role: "pod"})
+}
+
+func TestKubeConfig(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("secret-token"), 0o600); err != nil {
+		t.Fatalf("cannot write token file: %s", err)
+	}
+	kubeconfig := `
+apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+- name: dev
+  cluster:
+    server: https://dev:6443
+- name: prod
+  cluster:
+    server: https://prod:6443
+    insecure-skip-tls-verify: true
+users:
+- name: admin
+  user:
+    tokenFile: token
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: admin
+- name: prod
+  context:
+    cluster: prod
+    user: admin
+    namespace: monitoring
+`
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatalf("cannot write kubeconfig: %s", err)
+	}
+	kc, err := newKubeConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if kc.server != "https://prod:6443" {
+		t.Fatalf("unexpected server; got %q; want %q", kc.server, "https://prod:6443")
+	}
+	if kc.namespace != "monitoring" {
+		t.Fatalf("unexpected namespace; got %q; want %q", kc.namespace, "monitoring")
+	}
+	if kc.authOpts.BearerTokenFile != filepath.Join(dir, "token") {
+		t.Fatalf("unexpected bearer token file; got %q", kc.authOpts.BearerTokenFile)
+	}
+	if kc.authOpts.TLSConfig == nil || !kc.authOpts.TLSConfig.InsecureSkipVerify {
+		t.Fatalf("expecting insecure_skip_verify to be set")
+	}
+
+	// exec-based auth isn't supported
+	kubeconfig = strings.Replace(kubeconfig, "    tokenFile: token", "    exec:\n      command: aws", 1)
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatalf("cannot write kubeconfig: %s", err)
+	}
+	if _, err := newKubeConfig(path); err == nil {
+		t.Fatalf("expecting non-nil error for exec-based auth")
+	}
+}
@@ -0,0 +1,146 @@
+package kubernetes
+
+import (
+	"encoding/json"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+// NodeList represents NodeList from k8s API.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#nodelist-v1-core
+type NodeList struct {
+	Metadata ListMeta `json:"metadata"`
+	Items    []*Node  `json:"items"`
+}
+
+// Node represents Node from k8s API.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#node-v1-core
+type Node struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Status   NodeStatus `json:"status"`
+	Spec     NodeSpec   `json:"spec"`
+}
+
+// NodeStatus represents NodeStatus from k8s API.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#nodestatus-v1-core
+type NodeStatus struct {
+	Addresses       []NodeAddress       `json:"addresses"`
+	DaemonEndpoints NodeDaemonEndpoints `json:"daemonEndpoints"`
+}
+
+// NodeSpec represents NodeSpec from k8s API.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#nodespec-v1-core
+type NodeSpec struct {
+	ProviderID string `json:"providerID"`
+}
+
+// NodeAddress represents NodeAddress from k8s API.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#nodeaddress-v1-core
+type NodeAddress struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+}
+
+// NodeDaemonEndpoints represents NodeDaemonEndpoints from k8s API.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#nodedaemonendpoints-v1-core
+type NodeDaemonEndpoints struct {
+	KubeletEndpoint struct {
+		Port int `json:"port"`
+	} `json:"kubeletEndpoint"`
+}
+
+func listNodes(cfg *apiConfig) ([]*Node, error) {
+	var nodes []*Node
+	err := cfg.listObjects(roleNode, func(data []byte) (*ListMeta, error) {
+		var nl NodeList
+		if err := json.Unmarshal(data, &nl); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, nl.Items...)
+		return &nl.Metadata, nil
+	})
+	return nodes, err
+}
+
+func getNodesLabels(cfg *apiConfig) ([]*promutils.Labels, error) {
+	nodes, err := listNodes(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var ms []*promutils.Labels
+	for _, n := range nodes {
+		if m := n.getTargetLabels(); m != nil {
+			ms = append(ms, m)
+		}
+	}
+	return ms, nil
+}
+
+// getTargetLabels returns labels for the given n.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#node
+func (n *Node) getTargetLabels() *promutils.Labels {
+	addr := getNodeAddr(n.Status.Addresses)
+	if len(addr) == 0 {
+		// Skip node without address
+		return nil
+	}
+	addr = discoveryutils.JoinHostPort(addr, n.Status.DaemonEndpoints.KubeletEndpoint.Port)
+	m := promutils.NewLabels(16)
+	m.Add("__address__", addr)
+	m.Add("instance", n.Metadata.Name)
+	m.Add("__meta_kubernetes_node_name", n.Metadata.Name)
+	m.Add("__meta_kubernetes_node_provider_id", n.Spec.ProviderID)
+	n.Metadata.registerLabelsAndAnnotations("__meta_kubernetes_node", m)
+	var addrTypesUsed map[string]bool
+	for _, a := range n.Status.Addresses {
+		if addrTypesUsed[a.Type] {
+			continue
+		}
+		if addrTypesUsed == nil {
+			addrTypesUsed = make(map[string]bool)
+		}
+		addrTypesUsed[a.Type] = true
+		m.Add(discoveryutils.SanitizeLabelName("__meta_kubernetes_node_address_"+a.Type), a.Address)
+	}
+	return m
+}
+
+// getNodeAddr returns the node address according to the priority used by Prometheus.
+func getNodeAddr(nas []NodeAddress) string {
+	if addr := getAddrByType(nas, "InternalIP"); len(addr) > 0 {
+		return addr
+	}
+	if addr := getAddrByType(nas, "InternalDNS"); len(addr) > 0 {
+		return addr
+	}
+	if addr := getAddrByType(nas, "ExternalIP"); len(addr) > 0 {
+		return addr
+	}
+	if addr := getAddrByType(nas, "ExternalDNS"); len(addr) > 0 {
+		return addr
+	}
+	if addr := getAddrByType(nas, "LegacyHostIP"); len(addr) > 0 {
+		return addr
+	}
+	if addr := getAddrByType(nas, "Hostname"); len(addr) > 0 {
+		return addr
+	}
+	return ""
+}
+
+func getAddrByType(nas []NodeAddress, typ string) string {
+	for _, na := range nas {
+		if na.Type == typ {
+			return na.Address
+		}
+	}
+	return ""
+}
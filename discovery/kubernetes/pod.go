@@ -0,0 +1,209 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+// PodList implements k8s pod list.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#podlist-v1-core
+type PodList struct {
+	Metadata ListMeta `json:"metadata"`
+	Items    []*Pod   `json:"items"`
+}
+
+// Pod implements k8s pod.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#pod-v1-core
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     PodSpec    `json:"spec"`
+	Status   PodStatus  `json:"status"`
+}
+
+// PodSpec implements k8s pod spec.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#podspec-v1-core
+type PodSpec struct {
+	NodeName       string      `json:"nodeName"`
+	Containers     []Container `json:"containers"`
+	InitContainers []Container `json:"initContainers"`
+}
+
+// Container implements k8s container.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#container-v1-core
+type Container struct {
+	Name  string          `json:"name"`
+	Image string          `json:"image"`
+	Ports []ContainerPort `json:"ports"`
+}
+
+// ContainerPort implements k8s container port.
+type ContainerPort struct {
+	Name          string `json:"name"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"`
+}
+
+// PodStatus implements k8s pod status.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#podstatus-v1-core
+type PodStatus struct {
+	Phase                 string            `json:"phase"`
+	PodIP                 string            `json:"podIP"`
+	HostIP                string            `json:"hostIP"`
+	Conditions            []PodCondition    `json:"conditions"`
+	ContainerStatuses     []ContainerStatus `json:"containerStatuses"`
+	InitContainerStatuses []ContainerStatus `json:"initContainerStatuses"`
+}
+
+// PodCondition implements k8s pod condition.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#podcondition-v1-core
+type PodCondition struct {
+	Type   string `json:"type"`
+	Status string `json:"status"`
+}
+
+// ContainerStatus implements k8s container status.
+//
+// See https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.27/#containerstatus-v1-core
+type ContainerStatus struct {
+	Name        string `json:"name"`
+	ContainerID string `json:"containerID"`
+}
+
+func listPods(cfg *apiConfig) ([]*Pod, error) {
+	var pods []*Pod
+	err := cfg.listObjects(rolePod, func(data []byte) (*ListMeta, error) {
+		var pl PodList
+		if err := json.Unmarshal(data, &pl); err != nil {
+			return nil, err
+		}
+		pods = append(pods, pl.Items...)
+		return &pl.Metadata, nil
+	})
+	return pods, err
+}
+
+func getPodsLabels(cfg *apiConfig) ([]*promutils.Labels, error) {
+	pods, err := listPods(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var ms []*promutils.Labels
+	for _, p := range pods {
+		ms = append(ms, p.getTargetLabels()...)
+	}
+	return ms, nil
+}
+
+// getTargetLabels returns labels for each container port of the given p.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#pod
+func (p *Pod) getTargetLabels() []*promutils.Labels {
+	if len(p.Status.PodIP) == 0 {
+		// Skip pods without IP
+		return nil
+	}
+	var ms []*promutils.Labels
+	ms = p.appendContainerTargets(ms, p.Spec.Containers, false)
+	ms = p.appendContainerTargets(ms, p.Spec.InitContainers, true)
+	return ms
+}
+
+func (p *Pod) appendContainerTargets(ms []*promutils.Labels, containers []Container, isInit bool) []*promutils.Labels {
+	for i := range containers {
+		c := &containers[i]
+		if len(c.Ports) == 0 {
+			m := promutils.NewLabels(32)
+			m.Add("__address__", p.Status.PodIP)
+			p.appendCommonLabels(m)
+			p.addContainerLabels(m, c, nil, isInit)
+			ms = append(ms, m)
+			continue
+		}
+		for j := range c.Ports {
+			cp := &c.Ports[j]
+			m := promutils.NewLabels(32)
+			m.Add("__address__", discoveryutils.JoinHostPort(p.Status.PodIP, cp.ContainerPort))
+			p.appendCommonLabels(m)
+			p.addContainerLabels(m, c, cp, isInit)
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+func (p *Pod) addContainerLabels(m *promutils.Labels, c *Container, cp *ContainerPort, isInit bool) {
+	m.Add("__meta_kubernetes_pod_container_image", c.Image)
+	m.Add("__meta_kubernetes_pod_container_name", c.Name)
+	m.Add("__meta_kubernetes_pod_container_init", boolString(isInit))
+	if id := p.getContainerID(c.Name, isInit); id != "" {
+		m.Add("__meta_kubernetes_pod_container_id", id)
+	}
+	if cp != nil {
+		m.Add("__meta_kubernetes_pod_container_port_name", cp.Name)
+		m.Add("__meta_kubernetes_pod_container_port_number", strconv.Itoa(cp.ContainerPort))
+		m.Add("__meta_kubernetes_pod_container_port_protocol", cp.Protocol)
+	}
+}
+
+func (p *Pod) getContainerID(name string, isInit bool) string {
+	statuses := p.Status.ContainerStatuses
+	if isInit {
+		statuses = p.Status.InitContainerStatuses
+	}
+	for _, cs := range statuses {
+		if cs.Name == name {
+			return cs.ContainerID
+		}
+	}
+	return ""
+}
+
+// appendCommonLabels adds labels, which are common for all the targets of the pod.
+// These labels are also added to endpoints and endpointslice targets pointing to the pod.
+func (p *Pod) appendCommonLabels(m *promutils.Labels) {
+	m.Add("__meta_kubernetes_namespace", p.Metadata.Namespace)
+	m.Add("__meta_kubernetes_pod_name", p.Metadata.Name)
+	m.Add("__meta_kubernetes_pod_ip", p.Status.PodIP)
+	m.Add("__meta_kubernetes_pod_ready", getPodReadyStatus(p.Status.Conditions))
+	m.Add("__meta_kubernetes_pod_phase", p.Status.Phase)
+	m.Add("__meta_kubernetes_pod_node_name", p.Spec.NodeName)
+	m.Add("__meta_kubernetes_pod_host_ip", p.Status.HostIP)
+	m.Add("__meta_kubernetes_pod_uid", p.Metadata.UID)
+	if pc := getPodController(p.Metadata.OwnerReferences); pc != nil {
+		if pc.Kind != "" {
+			m.Add("__meta_kubernetes_pod_controller_kind", pc.Kind)
+		}
+		if pc.Name != "" {
+			m.Add("__meta_kubernetes_pod_controller_name", pc.Name)
+		}
+	}
+	p.Metadata.registerLabelsAndAnnotations("__meta_kubernetes_pod", m)
+}
+
+func getPodController(ors []OwnerReference) *OwnerReference {
+	for _, or := range ors {
+		if or.Controller {
+			return &or
+		}
+	}
+	return nil
+}
+
+func getPodReadyStatus(conds []PodCondition) string {
+	for _, c := range conds {
+		if c.Type == "Ready" {
+			return strings.ToLower(c.Status)
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,270 @@
+package nacos
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+var configMap = discoveryutils.NewConfigMap()
+
+const (
+	defaultGroup = "DEFAULT_GROUP"
+
+	// servicePageSize is the page size for listing services.
+	servicePageSize = 500
+)
+
+type apiConfig struct {
+	client      *discoveryutils.Client
+	contextPath string
+
+	namespace   string
+	group       string
+	services    []string
+	clusters    []string
+	healthyOnly bool
+
+	username string
+	password string
+
+	// tokenLock guards accessToken refresh
+	tokenLock   sync.Mutex
+	accessToken string
+	expiration  time.Time
+}
+
+func newAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	ac, err := sdc.HTTPClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse auth config: %w", err)
+	}
+	apiServer := sdc.Server
+	if apiServer == "" {
+		apiServer = "localhost:8848"
+	}
+	if !strings.Contains(apiServer, "://") {
+		scheme := "http"
+		if sdc.HTTPClientConfig.TLSConfig != nil {
+			scheme = "https"
+		}
+		apiServer = scheme + "://" + apiServer
+	}
+	apiServer = strings.TrimSuffix(apiServer, "/")
+	proxyAC, err := sdc.ProxyClientConfig.NewConfig(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse proxy auth config: %w", err)
+	}
+	client, err := discoveryutils.NewClient(apiServer, ac, sdc.ProxyURL, proxyAC, &sdc.HTTPClientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create HTTP client for %q: %w", apiServer, err)
+	}
+
+	contextPath := sdc.ContextPath
+	if contextPath == "" {
+		contextPath = "/nacos"
+	}
+	contextPath = "/" + strings.Trim(contextPath, "/")
+	group := sdc.Group
+	if group == "" {
+		group = defaultGroup
+	}
+	cfg := &apiConfig{
+		client:      client,
+		contextPath: contextPath,
+		namespace:   sdc.Namespace,
+		group:       group,
+		services:    sdc.Services,
+		clusters:    sdc.Clusters,
+		healthyOnly: sdc.HealthyOnly,
+		username:    sdc.Username,
+		password:    sdc.Password.String(),
+	}
+	return cfg, nil
+}
+
+func getAPIConfig(sdc *SDConfig, baseDir string) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (interface{}, error) { return newAPIConfig(sdc, baseDir) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+// getFreshAccessToken returns the accessToken for Nacos with auth enabled.
+//
+// An empty token is returned if username isn't configured.
+func (cfg *apiConfig) getFreshAccessToken() (string, error) {
+	if cfg.username == "" {
+		return "", nil
+	}
+	cfg.tokenLock.Lock()
+	defer cfg.tokenLock.Unlock()
+
+	if cfg.accessToken != "" && time.Until(cfg.expiration) > 10*time.Second {
+		return cfg.accessToken, nil
+	}
+	q := url.Values{}
+	q.Set("username", cfg.username)
+	q.Set("password", cfg.password)
+	data, err := cfg.client.GetAPIResponseWithReqParams(cfg.contextPath+"/v1/auth/login", func(req *http.Request) {
+		// login 接口只接受 POST，参数放在 form 里
+		req.Method = http.MethodPost
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		body := q.Encode()
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(body)), nil
+		}
+		req.Body, _ = req.GetBody()
+		req.ContentLength = int64(len(body))
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot login to nacos as %q: %w", cfg.username, err)
+	}
+	var resp struct {
+		AccessToken string `json:"accessToken"`
+		TokenTTL    int64  `json:"tokenTtl"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", fmt.Errorf("cannot parse nacos login response %q: %w", data, err)
+	}
+	if resp.AccessToken == "" {
+		return "", fmt.Errorf("missing accessToken in nacos login response %q", data)
+	}
+	cfg.accessToken = resp.AccessToken
+	cfg.expiration = time.Now().Add(time.Duration(resp.TokenTTL) * time.Second)
+	logger.Infof("nacos_sd: successfully refreshed access token; expiration: %d seconds", resp.TokenTTL)
+	return cfg.accessToken, nil
+}
+
+func (cfg *apiConfig) getAPIResponse(path string, q url.Values) ([]byte, error) {
+	token, err := cfg.getFreshAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		q.Set("accessToken", token)
+	}
+	return cfg.client.GetAPIResponse(cfg.contextPath + path + "?" + q.Encode())
+}
+
+// getServiceNames returns the names of the services to discover.
+//
+// See https://nacos.io/docs/latest/guide/user/open-api/#2.7
+func getServiceNames(cfg *apiConfig) ([]string, error) {
+	if len(cfg.services) > 0 {
+		return cfg.services, nil
+	}
+	var names []string
+	for pageNo := 1; ; pageNo++ {
+		q := url.Values{}
+		q.Set("pageNo", strconv.Itoa(pageNo))
+		q.Set("pageSize", strconv.Itoa(servicePageSize))
+		q.Set("groupName", cfg.group)
+		if cfg.namespace != "" {
+			q.Set("namespaceId", cfg.namespace)
+		}
+		data, err := cfg.getAPIResponse("/v1/ns/service/list", q)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list nacos services: %w", err)
+		}
+		var sl serviceList
+		if err := json.Unmarshal(data, &sl); err != nil {
+			return nil, fmt.Errorf("cannot parse nacos service list %q: %w", data, err)
+		}
+		names = append(names, sl.Doms...)
+		if len(sl.Doms) == 0 || len(names) >= sl.Count {
+			break
+		}
+	}
+	return names, nil
+}
+
+// getInstances returns instances of the given service.
+//
+// See https://nacos.io/docs/latest/guide/user/open-api/#1.4
+func getInstances(cfg *apiConfig, service string) (*instanceList, error) {
+	q := url.Values{}
+	q.Set("serviceName", service)
+	q.Set("groupName", cfg.group)
+	if cfg.namespace != "" {
+		q.Set("namespaceId", cfg.namespace)
+	}
+	if len(cfg.clusters) > 0 {
+		q.Set("clusters", strings.Join(cfg.clusters, ","))
+	}
+	if cfg.healthyOnly {
+		q.Set("healthyOnly", "true")
+	}
+	data, err := cfg.getAPIResponse("/v1/ns/instance/list", q)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list instances for nacos service %q: %w", service, err)
+	}
+	var il instanceList
+	if err := json.Unmarshal(data, &il); err != nil {
+		return nil, fmt.Errorf("cannot parse instances for nacos service %q: %w", service, err)
+	}
+	return &il, nil
+}
+
+type serviceList struct {
+	Count int      `json:"count"`
+	Doms  []string `json:"doms"`
+}
+
+type instanceList struct {
+	Name  string     `json:"name"`
+	Hosts []instance `json:"hosts"`
+}
+
+type instance struct {
+	InstanceID  string            `json:"instanceId"`
+	IP          string            `json:"ip"`
+	Port        int               `json:"port"`
+	Weight      float64           `json:"weight"`
+	Healthy     bool              `json:"healthy"`
+	Enabled     bool              `json:"enabled"`
+	Ephemeral   bool              `json:"ephemeral"`
+	ClusterName string            `json:"clusterName"`
+	ServiceName string            `json:"serviceName"`
+	Metadata    map[string]string `json:"metadata"`
+}
+
+func appendInstanceLabels(ms []*promutils.Labels, il *instanceList, namespace, group, service string) []*promutils.Labels {
+	if namespace == "" {
+		namespace = "public"
+	}
+	for _, ins := range il.Hosts {
+		if !ins.Enabled {
+			// 下线的实例不再采集
+			continue
+		}
+		m := promutils.NewLabels(16)
+		m.Add("__address__", discoveryutils.JoinHostPort(ins.IP, ins.Port))
+		m.Add("__meta_nacos_namespace", namespace)
+		m.Add("__meta_nacos_group", group)
+		m.Add("__meta_nacos_service", service)
+		m.Add("__meta_nacos_cluster", ins.ClusterName)
+		m.Add("__meta_nacos_instance_id", ins.InstanceID)
+		m.Add("__meta_nacos_ip", ins.IP)
+		m.Add("__meta_nacos_port", strconv.Itoa(ins.Port))
+		m.Add("__meta_nacos_healthy", strconv.FormatBool(ins.Healthy))
+		m.Add("__meta_nacos_ephemeral", strconv.FormatBool(ins.Ephemeral))
+		m.Add("__meta_nacos_weight", strconv.FormatFloat(ins.Weight, 'f', -1, 64))
+		for k, v := range ins.Metadata {
+			m.Add(discoveryutils.SanitizeLabelName("__meta_nacos_metadata_"+k), v)
+		}
+		ms = append(ms, m)
+	}
+	return ms
+}
@@ -0,0 +1,73 @@
+package nacos
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/promauth"
+	"github.com/cprobe/cprobe/lib/promutils"
+	"github.com/cprobe/cprobe/lib/proxy"
+)
+
+// SDCheckInterval defines interval for targets refresh.
+var SDCheckInterval = flag.Duration("scrape.nacosSDCheckInterval", 30*time.Second, "Interval for checking for changes in Nacos. "+
+	"This works only if nacos_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in nacos_sd_configs")
+
+// SDConfig represents service discovery config for Nacos.
+//
+// See https://nacos.io/docs/latest/guide/user/open-api/
+type SDConfig struct {
+	// Server 是 Nacos 的地址，比如 localhost:8848，默认 localhost:8848
+	Server string `yaml:"server,omitempty"`
+	// ContextPath 默认是 /nacos
+	ContextPath string `yaml:"context_path,omitempty"`
+	// Namespace 是 namespaceId，为空表示 public
+	Namespace string `yaml:"namespace,omitempty"`
+	// Group 默认是 DEFAULT_GROUP
+	Group string `yaml:"group,omitempty"`
+	// Services 为空表示发现 namespace + group 下的所有服务
+	Services []string `yaml:"services,omitempty"`
+	// Clusters 为空表示所有集群
+	Clusters    []string `yaml:"clusters,omitempty"`
+	HealthyOnly bool     `yaml:"healthy_only,omitempty"`
+
+	// Username 和 Password 用于开启了鉴权的 Nacos，会先调用 login 接口换取 accessToken
+	Username string           `yaml:"username,omitempty"`
+	Password *promauth.Secret `yaml:"password,omitempty"`
+
+	HTTPClientConfig  promauth.HTTPClientConfig  `yaml:",inline"`
+	ProxyURL          *proxy.URL                 `yaml:"proxy_url,omitempty"`
+	ProxyClientConfig promauth.ProxyClientConfig `yaml:",inline"`
+	RefreshInterval   *promutils.Duration        `yaml:"refresh_interval,omitempty"`
+}
+
+// GetLabels returns Nacos labels according to sdc.
+func (sdc *SDConfig) GetLabels(baseDir string) ([]*promutils.Labels, error) {
+	cfg, err := getAPIConfig(sdc, baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get API config: %w", err)
+	}
+	services, err := getServiceNames(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var ms []*promutils.Labels
+	for _, service := range services {
+		il, err := getInstances(cfg, service)
+		if err != nil {
+			return nil, err
+		}
+		ms = appendInstanceLabels(ms, il, cfg.namespace, cfg.group, service)
+	}
+	return ms, nil
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *SDConfig) MustStop() {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		cfg := v.(*apiConfig)
+		cfg.client.Stop()
+	}
+}
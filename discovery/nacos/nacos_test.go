@@ -0,0 +1,95 @@
+package nacos
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/promauth"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+func TestGetLabels(t *testing.T) {
+	logins := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/nacos/v1/auth/login" {
+			if r.Method != http.MethodPost || r.FormValue("username") != "nacos" || r.FormValue("password") != "secret" {
+				http.Error(w, "unknown user!", http.StatusForbidden)
+				return
+			}
+			logins++
+			_, _ = w.Write([]byte(`{"accessToken": "token-1", "tokenTtl": 18000, "globalAdmin": true}`))
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("accessToken") != "token-1" {
+			http.Error(w, "token invalid!", http.StatusForbidden)
+			return
+		}
+		if q.Get("namespaceId") != "prod" || q.Get("groupName") != "JVM" {
+			http.Error(w, "unexpected namespace or group", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/nacos/v1/ns/service/list":
+			switch q.Get("pageNo") {
+			case "1":
+				_, _ = w.Write([]byte(`{"count": 2, "doms": ["order-service"]}`))
+			case "2":
+				_, _ = w.Write([]byte(`{"count": 2, "doms": ["user-service"]}`))
+			default:
+				_, _ = w.Write([]byte(`{"count": 2, "doms": []}`))
+			}
+		case "/nacos/v1/ns/instance/list":
+			switch q.Get("serviceName") {
+			case "order-service":
+				_, _ = w.Write([]byte(`{"name": "JVM@@order-service", "hosts": [
+					{"instanceId": "10.0.0.1#8080#c1#JVM@@order-service", "ip": "10.0.0.1", "port": 8080, "weight": 1.0,
+					 "healthy": true, "enabled": true, "ephemeral": true, "clusterName": "c1", "metadata": {"jmx.port": "9404"}},
+					{"instanceId": "10.0.0.2#8080#c1#JVM@@order-service", "ip": "10.0.0.2", "port": 8080, "weight": 1.0,
+					 "healthy": true, "enabled": false, "ephemeral": true, "clusterName": "c1"}
+				]}`))
+			default:
+				_, _ = w.Write([]byte(`{"name": "JVM@@user-service", "hosts": []}`))
+			}
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	sdc := &SDConfig{
+		Server:    s.URL,
+		Namespace: "prod",
+		Group:     "JVM",
+		Username:  "nacos",
+		Password:  promauth.NewSecret("secret"),
+	}
+	defer sdc.MustStop()
+	for i := 0; i < 2; i++ {
+		ms, err := sdc.GetLabels("")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		discoveryutils.TestEqualLabelss(t, ms, []*promutils.Labels{
+			promutils.NewLabelsFromMap(map[string]string{
+				"__address__":                    "10.0.0.1:8080",
+				"__meta_nacos_namespace":         "prod",
+				"__meta_nacos_group":             "JVM",
+				"__meta_nacos_service":           "order-service",
+				"__meta_nacos_cluster":           "c1",
+				"__meta_nacos_instance_id":       "10.0.0.1#8080#c1#JVM@@order-service",
+				"__meta_nacos_ip":                "10.0.0.1",
+				"__meta_nacos_port":              "8080",
+				"__meta_nacos_healthy":           "true",
+				"__meta_nacos_ephemeral":         "true",
+				"__meta_nacos_weight":            "1",
+				"__meta_nacos_metadata_jmx_port": "9404",
+			}),
+		})
+	}
+	if logins != 1 {
+		t.Fatalf("expecting the access token to be reused; got %d logins", logins)
+	}
+}
@@ -1,40 +1,41 @@
 package openstack
 
 import (
+	"flag"
 	"fmt"
+	"time"
 
 	"github.com/cprobe/cprobe/lib/promauth"
 	"github.com/cprobe/cprobe/lib/promutils"
 )
 
 // SDCheckInterval defines interval for targets refresh.
-// var SDCheckInterval = flag.Duration("scrape.openstackSDCheckInterval", 30*time.Second, "Interval for checking for changes in openstack API server. "+
-// 	"This works only if openstack_sd_configs is configured in '-promscrape.config' file. "+
-// 	"See https://docs.victoriametrics.com/sd_configs.html#openstack_sd_configs for details")
+var SDCheckInterval = flag.Duration("scrape.openstackSDCheckInterval", 30*time.Second, "Interval for checking for changes in openstack API server. "+
+	"This works only if openstack_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in openstack_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#openstack_sd_configs for details")
 
 // SDConfig is the configuration for OpenStack based service discovery.
 //
 // See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#openstack_sd_config
 type SDConfig struct {
-	IdentityEndpoint            string           `yaml:"identity_endpoint,omitempty"`
-	Username                    string           `yaml:"username,omitempty"`
-	UserID                      string           `yaml:"userid,omitempty"`
-	Password                    *promauth.Secret `yaml:"password,omitempty"`
-	ProjectName                 string           `yaml:"project_name,omitempty"`
-	ProjectID                   string           `yaml:"project_id,omitempty"`
-	DomainName                  string           `yaml:"domain_name,omitempty"`
-	DomainID                    string           `yaml:"domain_id,omitempty"`
-	ApplicationCredentialName   string           `yaml:"application_credential_name,omitempty"`
-	ApplicationCredentialID     string           `yaml:"application_credential_id,omitempty"`
-	ApplicationCredentialSecret *promauth.Secret `yaml:"application_credential_secret,omitempty"`
-	Role                        string           `yaml:"role"`
-	Region                      string           `yaml:"region"`
-	// RefreshInterval time.Duration `yaml:"refresh_interval"`
-	// refresh_interval is obtained from `-promscrape.openstackSDCheckInterval` command-line option.
-	Port         int                 `yaml:"port,omitempty"`
-	AllTenants   bool                `yaml:"all_tenants,omitempty"`
-	TLSConfig    *promauth.TLSConfig `yaml:"tls_config,omitempty"`
-	Availability string              `yaml:"availability,omitempty"`
+	IdentityEndpoint            string              `yaml:"identity_endpoint,omitempty"`
+	Username                    string              `yaml:"username,omitempty"`
+	UserID                      string              `yaml:"userid,omitempty"`
+	Password                    *promauth.Secret    `yaml:"password,omitempty"`
+	ProjectName                 string              `yaml:"project_name,omitempty"`
+	ProjectID                   string              `yaml:"project_id,omitempty"`
+	DomainName                  string              `yaml:"domain_name,omitempty"`
+	DomainID                    string              `yaml:"domain_id,omitempty"`
+	ApplicationCredentialName   string              `yaml:"application_credential_name,omitempty"`
+	ApplicationCredentialID     string              `yaml:"application_credential_id,omitempty"`
+	ApplicationCredentialSecret *promauth.Secret    `yaml:"application_credential_secret,omitempty"`
+	Role                        string              `yaml:"role"`
+	Region                      string              `yaml:"region"`
+	Port                        int                 `yaml:"port,omitempty"`
+	AllTenants                  bool                `yaml:"all_tenants,omitempty"`
+	TLSConfig                   *promauth.TLSConfig `yaml:"tls_config,omitempty"`
+	Availability                string              `yaml:"availability,omitempty"`
+	RefreshInterval             *promutils.Duration `yaml:"refresh_interval,omitempty"`
 }
 
 // GetLabels returns OpenStack labels according to sdc.
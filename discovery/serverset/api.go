@@ -0,0 +1,204 @@
+package serverset
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/cprobe/cprobe/lib/promutils"
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+var configMap = discoveryutils.NewConfigMap()
+
+// zkConn is the subset of *zk.Conn used for discovery. It is an interface for the sake of tests.
+type zkConn interface {
+	Children(path string) ([]string, *zk.Stat, error)
+	Get(path string) ([]byte, *zk.Stat, error)
+	Close()
+}
+
+type apiConfig struct {
+	conn  zkConn
+	paths []string
+}
+
+func newAPIConfig(sdc *SDConfig) (*apiConfig, error) {
+	if len(sdc.Servers) == 0 {
+		return nil, fmt.Errorf("`servers` cannot be empty in serverset_sd_config")
+	}
+	if len(sdc.Paths) == 0 {
+		return nil, fmt.Errorf("`paths` cannot be empty in serverset_sd_config")
+	}
+	for _, path := range sdc.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("serverset path %q must start with `/`", path)
+		}
+	}
+	timeout := 10 * time.Second
+	if sdc.Timeout != nil && sdc.Timeout.Duration() > 0 {
+		timeout = sdc.Timeout.Duration()
+	}
+	conn, events, err := zk.Connect(sdc.Servers, timeout, zk.WithLogger(zkLogger{}), zk.WithLogInfo(false))
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to zookeeper %q: %w", sdc.Servers, err)
+	}
+	// zk.Connect() 是异步的，这里等 session 建立起来，否则后面的请求会一直排队
+	if err := waitForSession(events, timeout); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot connect to zookeeper %q: %w", sdc.Servers, err)
+	}
+	cfg := &apiConfig{
+		conn:  conn,
+		paths: sdc.Paths,
+	}
+	return cfg, nil
+}
+
+func getAPIConfig(sdc *SDConfig) (*apiConfig, error) {
+	v, err := configMap.Get(sdc, func() (interface{}, error) { return newAPIConfig(sdc) })
+	if err != nil {
+		return nil, err
+	}
+	return v.(*apiConfig), nil
+}
+
+func waitForSession(events <-chan zk.Event, timeout time.Duration) error {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return fmt.Errorf("connection closed")
+			}
+			if ev.State == zk.StateHasSession {
+				return nil
+			}
+			if ev.State == zk.StateAuthFailed {
+				return fmt.Errorf("authentication failed")
+			}
+		case <-t.C:
+			return fmt.Errorf("session isn't established in %s", timeout)
+		}
+	}
+}
+
+// zkLogger sends zookeeper client logs to lib/logger.
+type zkLogger struct{}
+
+func (zkLogger) Printf(format string, args ...interface{}) {
+	logger.Warnf("serverset_sd: "+format, args...)
+}
+
+// serversetMember is the data of a serverset member znode.
+//
+// See https://github.com/twitter/finagle/blob/develop/finagle-serversets/src/main/scala/com/twitter/finagle/serverset2/Entry.scala
+type serversetMember struct {
+	ServiceEndpoint     serversetEndpoint            `json:"serviceEndpoint"`
+	AdditionalEndpoints map[string]serversetEndpoint `json:"additionalEndpoints"`
+	Status              string                       `json:"status"`
+	Shard               int                          `json:"shard"`
+}
+
+type serversetEndpoint struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+func appendPathLabels(ms []*promutils.Labels, conn zkConn, path string) ([]*promutils.Labels, error) {
+	children, _, err := conn.Children(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list children of zookeeper path %q: %w", path, err)
+	}
+	for _, child := range children {
+		childPath := strings.TrimSuffix(path, "/") + "/" + child
+		if m := getDubboLabels(path, childPath, child); m != nil {
+			ms = append(ms, m)
+			continue
+		}
+		data, _, err := conn.Get(childPath)
+		if err != nil {
+			if err == zk.ErrNoNode {
+				// The member disappeared between Children() and Get() calls
+				continue
+			}
+			return nil, fmt.Errorf("cannot read zookeeper node %q: %w", childPath, err)
+		}
+		m, err := getServersetLabels(childPath, data)
+		if err != nil {
+			// Ignore non-member nodes such as locks or nested directories, like Prometheus does.
+			logger.Warnf("serverset_sd: skipping zookeeper node %q: %s", childPath, err)
+			continue
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// getServersetLabels returns labels for the serverset member stored at path.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#serverset_sd_config
+func getServersetLabels(path string, data []byte) (*promutils.Labels, error) {
+	var member serversetMember
+	if err := json.Unmarshal(data, &member); err != nil {
+		return nil, fmt.Errorf("cannot parse serverset member: %w", err)
+	}
+	if member.ServiceEndpoint.Host == "" {
+		return nil, fmt.Errorf("missing serviceEndpoint in serverset member %q", data)
+	}
+	m := promutils.NewLabels(16)
+	m.Add("__address__", discoveryutils.JoinHostPort(member.ServiceEndpoint.Host, member.ServiceEndpoint.Port))
+	m.Add("__meta_serverset_path", path)
+	m.Add("__meta_serverset_endpoint_host", member.ServiceEndpoint.Host)
+	m.Add("__meta_serverset_endpoint_port", strconv.Itoa(member.ServiceEndpoint.Port))
+	for name, ep := range member.AdditionalEndpoints {
+		m.Add(discoveryutils.SanitizeLabelName("__meta_serverset_endpoint_host_"+name), ep.Host)
+		m.Add(discoveryutils.SanitizeLabelName("__meta_serverset_endpoint_port_"+name), strconv.Itoa(ep.Port))
+	}
+	m.Add("__meta_serverset_status", member.Status)
+	m.Add("__meta_serverset_shard", strconv.Itoa(member.Shard))
+	return m, nil
+}
+
+// getDubboLabels returns labels for Dubbo provider node, whose name is the url-encoded provider URL
+// such as `dubbo%3A%2F%2F10.0.0.1%3A20880%2Fcom.foo.DemoService%3Fapplication%3Ddemo`.
+//
+// nil is returned if the node isn't a Dubbo provider.
+func getDubboLabels(parentPath, path, name string) *promutils.Labels {
+	if !strings.Contains(name, "%3A%2F%2F") {
+		return nil
+	}
+	s, err := url.QueryUnescape(name)
+	if err != nil {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return nil
+	}
+	m := promutils.NewLabels(16)
+	m.Add("__address__", u.Host)
+	m.Add("__meta_serverset_path", path)
+	m.Add("__meta_serverset_endpoint_host", host)
+	m.Add("__meta_serverset_endpoint_port", port)
+	m.Add("__meta_serverset_dubbo_protocol", u.Scheme)
+	m.Add("__meta_serverset_dubbo_interface", strings.TrimPrefix(u.Path, "/"))
+	m.Add("__meta_serverset_dubbo_parent_path", parentPath)
+	for k, vs := range u.Query() {
+		if len(vs) > 0 {
+			m.Add(discoveryutils.SanitizeLabelName("__meta_serverset_dubbo_param_"+k), vs[0])
+		}
+	}
+	return m
+}
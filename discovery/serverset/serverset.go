@@ -0,0 +1,50 @@
+package serverset
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+// SDCheckInterval defines interval for targets refresh.
+var SDCheckInterval = flag.Duration("scrape.serversetSDCheckInterval", 30*time.Second, "Interval for checking for changes in ZooKeeper serversets. "+
+	"This works only if serverset_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in serverset_sd_configs")
+
+// SDConfig represents service discovery config for ZooKeeper serversets.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#serverset_sd_config
+type SDConfig struct {
+	Servers []string `yaml:"servers"`
+	// Paths 下面的每个子节点都是一个成员，支持 Twitter serverset 的 JSON 格式，
+	// 也支持 Dubbo 的 providers 目录（子节点名是 URL encode 之后的服务 URL）
+	Paths           []string            `yaml:"paths"`
+	Timeout         *promutils.Duration `yaml:"timeout,omitempty"`
+	RefreshInterval *promutils.Duration `yaml:"refresh_interval,omitempty"`
+}
+
+// GetLabels returns serverset labels according to sdc.
+func (sdc *SDConfig) GetLabels(baseDir string) ([]*promutils.Labels, error) {
+	cfg, err := getAPIConfig(sdc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get API config: %w", err)
+	}
+	var ms []*promutils.Labels
+	for _, path := range cfg.paths {
+		ms, err = appendPathLabels(ms, cfg.conn, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ms, nil
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *SDConfig) MustStop() {
+	v := configMap.Delete(sdc)
+	if v != nil {
+		cfg := v.(*apiConfig)
+		cfg.conn.Close()
+	}
+}
@@ -0,0 +1,93 @@
+package serverset
+
+import (
+	"testing"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/promutils"
+	"github.com/samuel/go-zookeeper/zk"
+)
+
+type fakeConn struct {
+	children map[string][]string
+	data     map[string]string
+}
+
+func (fc *fakeConn) Children(path string) ([]string, *zk.Stat, error) {
+	children, ok := fc.children[path]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return children, &zk.Stat{}, nil
+}
+
+func (fc *fakeConn) Get(path string) ([]byte, *zk.Stat, error) {
+	data, ok := fc.data[path]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return []byte(data), &zk.Stat{}, nil
+}
+
+func (fc *fakeConn) Close() {}
+
+func TestAppendPathLabels(t *testing.T) {
+	conn := &fakeConn{
+		children: map[string][]string{
+			"/aurora/jobs/web": {"member_0000000001", "member_0000000002", "lock", "member_0000000003"},
+			"/dubbo/com.foo.DemoService/providers": {
+				"dubbo%3A%2F%2F10.0.0.5%3A20880%2Fcom.foo.DemoService%3Fapplication%3Ddemo%26version%3D1.0.0",
+			},
+		},
+		data: map[string]string{
+			"/aurora/jobs/web/member_0000000001": `{"serviceEndpoint": {"host": "10.0.0.1", "port": 8080},
+				"additionalEndpoints": {"http-admin": {"host": "10.0.0.1", "port": 9990}}, "status": "ALIVE", "shard": 0}`,
+			"/aurora/jobs/web/member_0000000002": `{"serviceEndpoint": {"host": "10.0.0.2", "port": 8080}, "status": "ALIVE", "shard": 1}`,
+			"/aurora/jobs/web/lock":              `not a member`,
+		},
+	}
+
+	ms, err := appendPathLabels(nil, conn, "/aurora/jobs/web")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ms, err = appendPathLabels(ms, conn, "/dubbo/com.foo.DemoService/providers")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	discoveryutils.TestEqualLabelss(t, ms, []*promutils.Labels{
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                               "10.0.0.1:8080",
+			"__meta_serverset_path":                     "/aurora/jobs/web/member_0000000001",
+			"__meta_serverset_endpoint_host":            "10.0.0.1",
+			"__meta_serverset_endpoint_port":            "8080",
+			"__meta_serverset_endpoint_host_http_admin": "10.0.0.1",
+			"__meta_serverset_endpoint_port_http_admin": "9990",
+			"__meta_serverset_status":                   "ALIVE",
+			"__meta_serverset_shard":                    "0",
+		}),
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                    "10.0.0.2:8080",
+			"__meta_serverset_path":          "/aurora/jobs/web/member_0000000002",
+			"__meta_serverset_endpoint_host": "10.0.0.2",
+			"__meta_serverset_endpoint_port": "8080",
+			"__meta_serverset_status":        "ALIVE",
+			"__meta_serverset_shard":         "1",
+		}),
+		promutils.NewLabelsFromMap(map[string]string{
+			"__address__":                              "10.0.0.5:20880",
+			"__meta_serverset_path":                    "/dubbo/com.foo.DemoService/providers/dubbo%3A%2F%2F10.0.0.5%3A20880%2Fcom.foo.DemoService%3Fapplication%3Ddemo%26version%3D1.0.0",
+			"__meta_serverset_endpoint_host":           "10.0.0.5",
+			"__meta_serverset_endpoint_port":           "20880",
+			"__meta_serverset_dubbo_protocol":          "dubbo",
+			"__meta_serverset_dubbo_interface":         "com.foo.DemoService",
+			"__meta_serverset_dubbo_parent_path":       "/dubbo/com.foo.DemoService/providers",
+			"__meta_serverset_dubbo_param_application": "demo",
+			"__meta_serverset_dubbo_param_version":     "1.0.0",
+		}),
+	})
+
+	if _, err := appendPathLabels(nil, conn, "/missing"); err == nil {
+		t.Fatalf("expecting non-nil error for missing path")
+	}
+}
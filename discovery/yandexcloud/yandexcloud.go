@@ -2,6 +2,7 @@ package yandexcloud
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"net/url"
 	"time"
@@ -11,9 +12,9 @@ import (
 )
 
 // SDCheckInterval defines interval for targets refresh.
-// var SDCheckInterval = flag.Duration("scrape.yandexcloudSDCheckInterval", 30*time.Second, "Interval for checking for changes in Yandex Cloud API. "+
-// 	"This works only if yandexcloud_sd_configs is configured in '-promscrape.config' file. "+
-// 	"See https://docs.victoriametrics.com/sd_configs.html#yandexcloud_sd_configs for details")
+var SDCheckInterval = flag.Duration("scrape.yandexcloudSDCheckInterval", 30*time.Second, "Interval for checking for changes in Yandex Cloud API. "+
+	"This works only if yandexcloud_sd_configs is configured in scrape configs. It can be overridden by `refresh_interval` in yandexcloud_sd_configs. "+
+	"See https://docs.victoriametrics.com/sd_configs.html#yandexcloud_sd_configs for details")
 
 // SDConfig is the configuration for Yandex Cloud service discovery.
 type SDConfig struct {
@@ -21,6 +22,7 @@ type SDConfig struct {
 	YandexPassportOAuthToken *promauth.Secret    `yaml:"yandex_passport_oauth_token,omitempty"`
 	APIEndpoint              string              `yaml:"api_endpoint,omitempty"`
 	TLSConfig                *promauth.TLSConfig `yaml:"tls_config,omitempty"`
+	RefreshInterval          *promutils.Duration `yaml:"refresh_interval,omitempty"`
 }
 
 // GetLabels returns labels for Yandex Cloud according to service discover config.
@@ -37,6 +39,11 @@ func (sdc *SDConfig) GetLabels(baseDir string) ([]*promutils.Labels, error) {
 	}
 }
 
+// MustStop stops further usage for sdc.
+func (sdc *SDConfig) MustStop() {
+	configMap.Delete(sdc)
+}
+
 func (cfg *apiConfig) getInstances(folderID string) ([]instance, error) {
 	instancesURL := cfg.serviceEndpoints["compute"] + "/compute/v1/instances"
 	instancesURL += "?folderId=" + url.QueryEscape(folderID)
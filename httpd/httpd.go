@@ -9,14 +9,17 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/cprobe/cprobe/flags"
 	"github.com/cprobe/cprobe/lib/flagutil"
 	"github.com/cprobe/cprobe/lib/fs"
+	"github.com/cprobe/cprobe/lib/promrelabel"
 	"github.com/cprobe/cprobe/probe"
 	"github.com/cprobe/cprobe/writer"
 	"gopkg.in/yaml.v2"
@@ -102,11 +105,15 @@ func Router() *HTTPRouter {
 
 	r.GET("/", func(c *gin.Context) {
 		endpoints := map[string]string{
-			"targets": "status for discovered active targets",
-			"metrics": "available service metrics",
-			"flags":   "command-line flags",
-			"config":  "cprobe config contents",
-			"reload":  "reload configuration",
+			"targets":        "status for discovered active targets; use ?show=problems for duplicate and conflicting targets only",
+			"api/v1/targets": "status for discovered active targets in JSON",
+			"metrics":        "available service metrics",
+			"flags":          "command-line flags",
+			"config":         "cprobe config contents",
+			"reload":         "reload configuration",
+
+			"target-relabel-debug": "debug target relabeling",
+			"metric-relabel-debug": "debug metric relabeling",
 		}
 		if HTTPPProf {
 			endpoints["/debug/pprof"] = "pprof"
@@ -123,6 +130,9 @@ func Router() *HTTPRouter {
 		parse, _ := template.New("index").Parse(indexHtlm)
 		parse.Execute(c.Writer, temp)
 	})
+	r.GET("/metrics", func(c *gin.Context) {
+		metrics.WritePrometheus(c.Writer, true)
+	})
 	r.GET("/flags", func(c *gin.Context) {
 		flagutil.WriteFlags(c.Writer)
 	})
@@ -152,6 +162,28 @@ func Router() *HTTPRouter {
 			}
 		}
 	})
+	r.GET("/targets", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain; charset=utf-8")
+		probe.WriteTargetsText(c.Writer, c.Query("show") == "problems")
+	})
+	r.GET("/api/v1/targets", func(c *gin.Context) {
+		targets := probe.GetTargetStatuses(c.Query("show") == "problems")
+		if targets == nil {
+			targets = []probe.TargetStatus{}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data": gin.H{
+				"activeTargets": targets,
+			},
+		})
+	})
+	r.Any("/target-relabel-debug", func(c *gin.Context) {
+		relabelDebug(c, true)
+	})
+	r.Any("/metric-relabel-debug", func(c *gin.Context) {
+		relabelDebug(c, false)
+	})
 	r.GET("/reload", func(c *gin.Context) {
 		probe.Reload(c, flags.ConfigDirectory)
 		c.String(http.StatusOK, "OK")
@@ -180,6 +212,42 @@ func Router() *HTTPRouter {
 	return &HTTPRouter{engine: r}
 }
 
+// relabelDebug 渲染 relabel 调试页面，format=json 时返回 JSON，方便在 CI 里测试 relabel 规则
+//
+// 传了 job（以及可选的 target）并且表单为空时，用这个 job 的 relabel 配置和 target 的标签预填表单
+func relabelDebug(c *gin.Context, isTargetRelabel bool) {
+	metric := c.Request.FormValue("metric")
+	relabelConfigs := c.Request.FormValue("relabel_configs")
+	format := c.Request.FormValue("format")
+	job := c.Request.FormValue("job")
+	target := c.Request.FormValue("target")
+
+	var targetID string
+	var err error
+	if job != "" {
+		q := url.Values{}
+		q.Set("job", job)
+		if target != "" {
+			q.Set("target", target)
+		}
+		targetID = q.Encode()
+		if metric == "" && relabelConfigs == "" {
+			metric, relabelConfigs, err = probe.GetRelabelDebugInput(job, target, isTargetRelabel)
+		}
+	}
+
+	if format == "json" {
+		c.Header("Content-Type", "application/json")
+	} else {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+	}
+	if isTargetRelabel {
+		promrelabel.WriteTargetRelabelDebug(c.Writer, targetID, metric, relabelConfigs, format, err)
+	} else {
+		promrelabel.WriteMetricRelabelDebug(c.Writer, targetID, metric, relabelConfigs, format, err)
+	}
+}
+
 // Init initializes http server and return close function
 func (r *HTTPRouter) Start() func() error {
 	server := &http.Server{
@@ -366,6 +366,14 @@ func MustCreateFlockFile(dir string) *os.File {
 	return f
 }
 
+// CreateFlockFile creates the file at flockFilepath and acquires an exclusive lock on it without waiting.
+//
+// The lock is held until the returned file is closed or the process exits.
+// The returned error wraps syscall.EWOULDBLOCK or syscall.EAGAIN if the lock is held by another file handle.
+func CreateFlockFile(flockFilepath string) (*os.File, error) {
+	return createFlockFile(flockFilepath)
+}
+
 // FlockFilename is the filename for the file created by MustCreateFlockFile().
 const FlockFilename = "flock.lock"
 
@@ -53,6 +53,7 @@ func createFlockFile(flockFile string) (*os.File, error) {
 		Whence: 0,
 	}
 	if err := unix.FcntlFlock(flockF.Fd(), unix.F_SETLK, &flock); err != nil {
+		_ = flockF.Close()
 		return nil, fmt.Errorf("cannot acquire lock on file %q: %w", flockFile, err)
 	}
 	return flockF, nil
@@ -0,0 +1,113 @@
+package lease
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	consul_api "github.com/hashicorp/consul/api"
+)
+
+// consulMinSessionTTL is the minimum session TTL accepted by Consul.
+const consulMinSessionTTL = 10 * time.Second
+
+// consulBackend implements Backend with Consul sessions and KV locks.
+//
+// Note that Consul may invalidate expired sessions up to 2*ttl later, so takeover after a crash may be slower than with other backends.
+//
+// See https://developer.hashicorp.com/consul/tutorials/developer-configuration/application-leader-elections
+type consulBackend struct {
+	client *consul_api.Client
+
+	mu       sync.Mutex
+	sessions map[string]string
+}
+
+func newConsulBackend(endpoint string) (*consulBackend, error) {
+	cfg := consul_api.DefaultConfig()
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid url for consul lease backend %q; it must look like http://127.0.0.1:8500", endpoint)
+		}
+		cfg.Address = u.Host
+		cfg.Scheme = u.Scheme
+	}
+	client, err := consul_api.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create consul client: %w", err)
+	}
+	cb := &consulBackend{
+		client:   client,
+		sessions: make(map[string]string),
+	}
+	return cb, nil
+}
+
+func (cb *consulBackend) Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	wo := (&consul_api.WriteOptions{}).WithContext(ctx)
+	sessionKey := key + "\x00" + holder
+	sid := cb.sessions[sessionKey]
+	if sid != "" {
+		entry, _, err := cb.client.Session().Renew(sid, wo)
+		if err != nil {
+			return false, fmt.Errorf("cannot renew consul session: %w", err)
+		}
+		if entry == nil {
+			// The session has been expired
+			sid = ""
+			delete(cb.sessions, sessionKey)
+		}
+	}
+	if sid == "" {
+		if ttl < consulMinSessionTTL {
+			ttl = consulMinSessionTTL
+		}
+		id, _, err := cb.client.Session().CreateNoChecks(&consul_api.SessionEntry{
+			Name:     "cprobe-" + holder,
+			TTL:      ttl.String(),
+			Behavior: consul_api.SessionBehaviorRelease,
+			// The default lock-delay is 15s, which prevents from quick takeover after Release
+			LockDelay: time.Millisecond,
+		}, wo)
+		if err != nil {
+			return false, fmt.Errorf("cannot create consul session: %w", err)
+		}
+		sid = id
+		cb.sessions[sessionKey] = sid
+	}
+	ok, _, err := cb.client.KV().Acquire(&consul_api.KVPair{
+		Key:     key,
+		Value:   []byte(holder),
+		Session: sid,
+	}, wo)
+	if err != nil {
+		return false, fmt.Errorf("cannot acquire consul lock %q: %w", key, err)
+	}
+	return ok, nil
+}
+
+func (cb *consulBackend) Release(ctx context.Context, key, holder string) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	sessionKey := key + "\x00" + holder
+	sid := cb.sessions[sessionKey]
+	if sid == "" {
+		return nil
+	}
+	delete(cb.sessions, sessionKey)
+	wo := (&consul_api.WriteOptions{}).WithContext(ctx)
+	if _, _, err := cb.client.KV().Release(&consul_api.KVPair{Key: key, Session: sid}, wo); err != nil {
+		return fmt.Errorf("cannot release consul lock %q: %w", key, err)
+	}
+	if _, err := cb.client.Session().Destroy(sid, wo); err != nil {
+		return fmt.Errorf("cannot destroy consul session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package lease
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// etcdBackend implements Backend with etcd v3 leases and transactions.
+//
+// It talks to the JSON gateway of etcd v3 API, so it doesn't need etcd client dependencies.
+// See https://etcd.io/docs/v3.5/dev-guide/api_grpc_gateway/
+type etcdBackend struct {
+	url    string
+	client *http.Client
+
+	mu     sync.Mutex
+	leases map[string]string
+}
+
+func newEtcdBackend(endpoint string) (*etcdBackend, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid url for etcd lease backend %q; it must look like http://127.0.0.1:2379", endpoint)
+	}
+	eb := &etcdBackend{
+		url: strings.TrimSuffix(endpoint, "/"),
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+		leases: make(map[string]string),
+	}
+	return eb, nil
+}
+
+// etcdInt64 is int64 value from etcd JSON gateway. It is marshaled as string, while older versions marshal it as number.
+type etcdInt64 string
+
+func (v *etcdInt64) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		s = ""
+	}
+	*v = etcdInt64(s)
+	return nil
+}
+
+type etcdLeaseResponse struct {
+	ID  etcdInt64 `json:"ID"`
+	TTL etcdInt64 `json:"TTL"`
+}
+
+type etcdKeyValue struct {
+	Value string    `json:"value"`
+	Lease etcdInt64 `json:"lease"`
+}
+
+type etcdTxnResponse struct {
+	Succeeded bool `json:"succeeded"`
+	Responses []struct {
+		ResponseRange struct {
+			Kvs []etcdKeyValue `json:"kvs"`
+		} `json:"response_range"`
+	} `json:"responses"`
+}
+
+func (eb *etcdBackend) Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	leaseKey := key + "\x00" + holder
+	leaseID := eb.leases[leaseKey]
+	if leaseID != "" {
+		var resp struct {
+			Result etcdLeaseResponse `json:"result"`
+		}
+		if err := eb.post(ctx, "/v3/lease/keepalive", map[string]string{"ID": leaseID}, &resp); err != nil {
+			return false, fmt.Errorf("cannot renew etcd lease: %w", err)
+		}
+		if resp.Result.TTL == "" || resp.Result.TTL == "0" {
+			// The lease has been expired
+			leaseID = ""
+			delete(eb.leases, leaseKey)
+		}
+	}
+	if leaseID == "" {
+		var resp etcdLeaseResponse
+		ttlSeconds := int64(math.Ceil(ttl.Seconds()))
+		if err := eb.post(ctx, "/v3/lease/grant", map[string]int64{"TTL": ttlSeconds}, &resp); err != nil {
+			return false, fmt.Errorf("cannot grant etcd lease: %w", err)
+		}
+		if resp.ID == "" {
+			return false, fmt.Errorf("missing lease ID in etcd response")
+		}
+		leaseID = string(resp.ID)
+		eb.leases[leaseKey] = leaseID
+	}
+
+	// Put the key only if it doesn't exist. Otherwise return the current value in order to check the holder.
+	encodedKey := base64.StdEncoding.EncodeToString([]byte(key))
+	txn := map[string]interface{}{
+		"compare": []map[string]string{{
+			"key":             encodedKey,
+			"result":          "EQUAL",
+			"target":          "CREATE",
+			"create_revision": "0",
+		}},
+		"success": []map[string]interface{}{{
+			"request_put": map[string]string{
+				"key":   encodedKey,
+				"value": base64.StdEncoding.EncodeToString([]byte(holder)),
+				"lease": leaseID,
+			},
+		}},
+		"failure": []map[string]interface{}{{
+			"request_range": map[string]string{
+				"key": encodedKey,
+			},
+		}},
+	}
+	var resp etcdTxnResponse
+	if err := eb.post(ctx, "/v3/kv/txn", txn, &resp); err != nil {
+		return false, fmt.Errorf("cannot acquire etcd lock %q: %w", key, err)
+	}
+	if resp.Succeeded {
+		return true, nil
+	}
+	for _, r := range resp.Responses {
+		for _, kv := range r.ResponseRange.Kvs {
+			if string(kv.Lease) == leaseID {
+				// The key is already owned by holder
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (eb *etcdBackend) Release(ctx context.Context, key, holder string) error {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+
+	leaseKey := key + "\x00" + holder
+	leaseID := eb.leases[leaseKey]
+	if leaseID == "" {
+		return nil
+	}
+	delete(eb.leases, leaseKey)
+	// Revoking the lease deletes the key attached to it
+	if err := eb.post(ctx, "/v3/lease/revoke", map[string]string{"ID": leaseID}, nil); err != nil {
+		return fmt.Errorf("cannot revoke etcd lease: %w", err)
+	}
+	return nil
+}
+
+func (eb *etcdBackend) post(ctx context.Context, path string, body, dst interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, eb.url+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := eb.client.Do(req)
+	if err != nil {
+		return err
+	}
+	data, err = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("cannot read response from %q: %w", eb.url+path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d from %q; response body: %q", resp.StatusCode, eb.url+path, data)
+	}
+	if dst == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("cannot parse response from %q: %w", eb.url+path, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/fs"
+)
+
+// fileBackend implements Backend with exclusive file locks in a local directory.
+//
+// It is suitable for replicas running on the same host or sharing a filesystem with working locks.
+// The lease is held as long as the lock file is open, so ttl is ignored: the lock is released
+// automatically when the holder process exits.
+type fileBackend struct {
+	dir string
+
+	mu    sync.Mutex
+	locks map[string]*os.File
+}
+
+func newFileBackend(dir string) (*fileBackend, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("missing directory for file lease backend")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create directory for file lease backend: %w", err)
+	}
+	fb := &fileBackend{
+		dir:   dir,
+		locks: make(map[string]*os.File),
+	}
+	return fb, nil
+}
+
+func (fb *fileBackend) Acquire(_ context.Context, key, holder string, _ time.Duration) (bool, error) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	lockKey := key + "\x00" + holder
+	if _, ok := fb.locks[lockKey]; ok {
+		return true, nil
+	}
+	f, err := fs.CreateFlockFile(fb.getPath(key))
+	if errors.Is(err, syscall.EWOULDBLOCK) || errors.Is(err, syscall.EAGAIN) {
+		// The lock is held by another holder
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	fb.locks[lockKey] = f
+	return true, nil
+}
+
+func (fb *fileBackend) Release(_ context.Context, key, holder string) error {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+
+	lockKey := key + "\x00" + holder
+	f, ok := fb.locks[lockKey]
+	if !ok {
+		return nil
+	}
+	delete(fb.locks, lockKey)
+	return f.Close()
+}
+
+func (fb *fileBackend) getPath(key string) string {
+	name := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(key)
+	return filepath.Join(fb.dir, name+".lock")
+}
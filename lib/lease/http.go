@@ -0,0 +1,100 @@
+package lease
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// httpBackend implements Backend on top of a simple HTTP lease service.
+//
+// Acquire sends `POST <url>` with JSON body {"key": "...", "holder": "...", "ttl_seconds": N}.
+// The service must respond with 200 if the lease is acquired or renewed by holder,
+// and with 409 if it is owned by another holder.
+//
+// Release sends `DELETE <url>?key=...&holder=...`. 200, 204 and 404 responses are treated as success.
+type httpBackend struct {
+	url    string
+	client *http.Client
+}
+
+type httpLeaseRequest struct {
+	Key        string  `json:"key"`
+	Holder     string  `json:"holder"`
+	TTLSeconds float64 `json:"ttl_seconds"`
+}
+
+func newHTTPBackend(u string) (*httpBackend, error) {
+	if _, err := url.ParseRequestURI(u); err != nil {
+		return nil, fmt.Errorf("invalid url for http lease backend %q: %w", u, err)
+	}
+	hb := &httpBackend{
+		url: u,
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+	return hb, nil
+}
+
+func (hb *httpBackend) Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	body, err := json.Marshal(&httpLeaseRequest{
+		Key:        key,
+		Holder:     holder,
+		TTLSeconds: ttl.Seconds(),
+	})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hb.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := hb.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("cannot acquire lease from %q: %w", hb.url, err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusConflict:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected status code %d when acquiring lease from %q; response body: %q", resp.StatusCode, hb.url, data)
+	}
+}
+
+func (hb *httpBackend) Release(ctx context.Context, key, holder string) error {
+	u, err := url.Parse(hb.url)
+	if err != nil {
+		return err
+	}
+	args := u.Query()
+	args.Set("key", key)
+	args.Set("holder", holder)
+	u.RawQuery = args.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := hb.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot release lease at %q: %w", hb.url, err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return fmt.Errorf("unexpected status code %d when releasing lease at %q; response body: %q", resp.StatusCode, hb.url, data)
+	}
+}
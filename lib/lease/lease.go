@@ -0,0 +1,36 @@
+package lease
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Backend stores leases, so only a single holder owns the lease for the given key at a time.
+type Backend interface {
+	// Acquire acquires the lease for key on behalf of holder or renews it if holder already owns it.
+	//
+	// It returns true if holder owns the lease for the next ttl.
+	Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error)
+
+	// Release releases the lease for key if it is owned by holder, so other holders can take it over immediately.
+	Release(ctx context.Context, key, holder string) error
+}
+
+// NewBackend returns Backend of the given kind.
+//
+// endpoint is the directory for lease files if kind is "file", or the url of the lease service otherwise.
+func NewBackend(kind, endpoint string) (Backend, error) {
+	switch kind {
+	case "file":
+		return newFileBackend(endpoint)
+	case "http":
+		return newHTTPBackend(endpoint)
+	case "consul":
+		return newConsulBackend(endpoint)
+	case "etcd":
+		return newEtcdBackend(endpoint)
+	default:
+		return nil, fmt.Errorf("unsupported lease backend %q; supported values: file, http, consul, etcd", kind)
+	}
+}
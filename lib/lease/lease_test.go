@@ -0,0 +1,361 @@
+package lease
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testBackendTakeover(t *testing.T, b1, b2 Backend) {
+	t.Helper()
+	ctx := context.Background()
+	const key = "cprobe/leader/mysql/job1"
+
+	mustAcquire := func(b Backend, holder string, want bool) {
+		t.Helper()
+		ok, err := b.Acquire(ctx, key, holder, 15*time.Second)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if ok != want {
+			t.Fatalf("unexpected result of Acquire for %q; got %v; want %v", holder, ok, want)
+		}
+	}
+
+	mustAcquire(b1, "replica-1", true)
+	// renew
+	mustAcquire(b1, "replica-1", true)
+	mustAcquire(b2, "replica-2", false)
+
+	if err := b1.Release(ctx, key, "replica-1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	mustAcquire(b2, "replica-2", true)
+	mustAcquire(b1, "replica-1", false)
+
+	// release by non-holder is noop
+	if err := b1.Release(ctx, key, "replica-1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	mustAcquire(b2, "replica-2", true)
+}
+
+func TestFileBackend(t *testing.T) {
+	dir := t.TempDir()
+	b1, err := NewBackend("file", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b2, err := NewBackend("file", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testBackendTakeover(t, b1, b2)
+}
+
+func TestFileBackendHeldLockDoesNotLeakFDs(t *testing.T) {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("cannot count open files: %s", err)
+	}
+	fdsBefore := len(fds)
+
+	dir := t.TempDir()
+	b1, err := NewBackend("file", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b2, err := NewBackend("file", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ctx := context.Background()
+	if ok, err := b1.Acquire(ctx, "key", "replica-1", time.Second); err != nil || !ok {
+		t.Fatalf("cannot acquire lease; ok: %v; err: %v", ok, err)
+	}
+	defer b1.Release(ctx, "key", "replica-1")
+	for i := 0; i < 100; i++ {
+		if ok, err := b2.Acquire(ctx, "key", "replica-2", time.Second); err != nil || ok {
+			t.Fatalf("unexpected result of Acquire for held lease; ok: %v; err: %v", ok, err)
+		}
+	}
+
+	fds, err = os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatalf("cannot count open files: %s", err)
+	}
+	// one fd is held by b1
+	if n := len(fds) - fdsBefore; n > 1 {
+		t.Fatalf("unexpected number of open files after acquiring held lease; got %d more; want at most 1", n)
+	}
+}
+
+func TestFileBackendAcquireError(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewBackend("file", dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// the lease directory disappears after start
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("cannot remove %s: %s", dir, err)
+	}
+	ok, err := b.Acquire(context.Background(), "key", "replica-1", time.Second)
+	if err == nil || ok {
+		t.Fatalf("expecting non-nil error; got ok: %v; err: %v", ok, err)
+	}
+}
+
+// fakeLeaseService is a local stand-in for lease services, which keeps leases in memory.
+type fakeLeaseService struct {
+	mu      sync.Mutex
+	holders map[string]string
+}
+
+func (s *fakeLeaseService) acquire(key, holder string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h, ok := s.holders[key]; ok && h != holder {
+		return false
+	}
+	s.holders[key] = holder
+	return true
+}
+
+func (s *fakeLeaseService) release(key, holder string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.holders[key] == holder {
+		delete(s.holders, key)
+	}
+}
+
+func newFakeLeaseService() *fakeLeaseService {
+	return &fakeLeaseService{
+		holders: make(map[string]string),
+	}
+}
+
+func TestHTTPBackend(t *testing.T) {
+	s := newFakeLeaseService()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			var req httpLeaseRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TTLSeconds != 15 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if !s.acquire(req.Key, req.Holder) {
+				w.WriteHeader(http.StatusConflict)
+			}
+		case http.MethodDelete:
+			if r.URL.Query().Get("token") != "secret" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			s.release(r.URL.Query().Get("key"), r.URL.Query().Get("holder"))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	b1, err := NewBackend("http", srv.URL+"/lease?token=secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b2, err := NewBackend("http", srv.URL+"/lease?token=secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testBackendTakeover(t, b1, b2)
+
+	// unexpected status code
+	b, err := NewBackend("http", srv.URL+"/lease")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := b.Release(context.Background(), "foo", "bar"); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+// newFakeEtcdServer returns a local stand-in for etcd v3 JSON gateway, which supports only the requests used by etcdBackend.
+func newFakeEtcdServer(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	var nextLease int
+	leases := make(map[string]bool)
+	// key -> [value, lease]
+	kvs := make(map[string][2]string)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var req map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("cannot parse request: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		leaseID := strings.Trim(string(req["ID"]), `"`)
+		switch r.URL.Path {
+		case "/v3/lease/grant":
+			nextLease++
+			id := strings.Repeat("7", nextLease)
+			leases[id] = true
+			_, _ = w.Write([]byte(`{"ID":"` + id + `","TTL":` + string(req["TTL"]) + `}`))
+		case "/v3/lease/keepalive":
+			ttl := "0"
+			if leases[leaseID] {
+				ttl = "15"
+			}
+			_, _ = w.Write([]byte(`{"result":{"ID":"` + leaseID + `","TTL":"` + ttl + `"}}`))
+		case "/v3/lease/revoke":
+			delete(leases, leaseID)
+			for k, kv := range kvs {
+				if kv[1] == leaseID {
+					delete(kvs, k)
+				}
+			}
+			_, _ = w.Write([]byte(`{}`))
+		case "/v3/kv/txn":
+			var txn struct {
+				Success []struct {
+					RequestPut struct {
+						Key   string `json:"key"`
+						Value string `json:"value"`
+						Lease string `json:"lease"`
+					} `json:"request_put"`
+				} `json:"success"`
+			}
+			data, _ := json.Marshal(req)
+			_ = json.Unmarshal(data, &txn)
+			put := txn.Success[0].RequestPut
+			if kv, ok := kvs[put.Key]; ok {
+				_, _ = w.Write([]byte(`{"succeeded":false,"responses":[{"response_range":{"kvs":[{"value":"` + kv[0] + `","lease":"` + kv[1] + `"}]}}]}`))
+				return
+			}
+			kvs[put.Key] = [2]string{put.Value, put.Lease}
+			_, _ = w.Write([]byte(`{"succeeded":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestEtcdBackend(t *testing.T) {
+	srv := newFakeEtcdServer(t)
+	defer srv.Close()
+
+	b1, err := NewBackend("etcd", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b2, err := NewBackend("etcd", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testBackendTakeover(t, b1, b2)
+}
+
+// newFakeConsulServer returns a local stand-in for Consul session and KV API, which supports only the requests used by consulBackend.
+func newFakeConsulServer() *httptest.Server {
+	var mu sync.Mutex
+	var nextSession int
+	sessions := make(map[string]bool)
+	// key -> session
+	locks := make(map[string]string)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		path := r.URL.Path
+		switch {
+		case path == "/v1/session/create":
+			var se map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&se)
+			if se["TTL"] != "15s" || se["LockDelay"] != "1ms" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			nextSession++
+			id := strings.Repeat("s", nextSession)
+			sessions[id] = true
+			_, _ = w.Write([]byte(`{"ID":"` + id + `"}`))
+		case strings.HasPrefix(path, "/v1/session/renew/"):
+			id := strings.TrimPrefix(path, "/v1/session/renew/")
+			if !sessions[id] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(`[{"ID":"` + id + `"}]`))
+		case strings.HasPrefix(path, "/v1/session/destroy/"):
+			id := strings.TrimPrefix(path, "/v1/session/destroy/")
+			delete(sessions, id)
+			for k, sid := range locks {
+				if sid == id {
+					delete(locks, k)
+				}
+			}
+			_, _ = w.Write([]byte(`true`))
+		case strings.HasPrefix(path, "/v1/kv/"):
+			key := strings.TrimPrefix(path, "/v1/kv/")
+			if id := r.URL.Query().Get("acquire"); id != "" {
+				if sid, ok := locks[key]; ok && sid != id {
+					_, _ = w.Write([]byte(`false`))
+					return
+				}
+				locks[key] = id
+				_, _ = w.Write([]byte(`true`))
+				return
+			}
+			if id := r.URL.Query().Get("release"); id != "" {
+				if locks[key] == id {
+					delete(locks, key)
+				}
+				_, _ = w.Write([]byte(`true`))
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestConsulBackend(t *testing.T) {
+	srv := newFakeConsulServer()
+	defer srv.Close()
+
+	b1, err := NewBackend("consul", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b2, err := NewBackend("consul", srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testBackendTakeover(t, b1, b2)
+}
+
+func TestNewBackendFailure(t *testing.T) {
+	f := func(kind, endpoint string) {
+		t.Helper()
+		if _, err := NewBackend(kind, endpoint); err == nil {
+			t.Fatalf("expecting non-nil error for kind=%q, endpoint=%q", kind, endpoint)
+		}
+	}
+	f("zookeeper", "")
+	f("file", "")
+	f("http", "")
+	f("etcd", "127.0.0.1:2379")
+	f("consul", "127.0.0.1:8500")
+}
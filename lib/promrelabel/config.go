@@ -152,6 +152,15 @@ func (pcs *ParsedConfigs) String() string {
 	return strings.Join(a, "")
 }
 
+// ParseRelabelConfigsData parses relabel configs from the given YAML data.
+func ParseRelabelConfigsData(data []byte) (*ParsedConfigs, error) {
+	var rcs []RelabelConfig
+	if err := yaml.UnmarshalStrict(data, &rcs); err != nil {
+		return nil, err
+	}
+	return ParseRelabelConfigs(rcs)
+}
+
 // ParseRelabelConfigs parses rcs to dst.
 func ParseRelabelConfigs(rcs []RelabelConfig) (*ParsedConfigs, error) {
 	if len(rcs) == 0 {
@@ -1,96 +1,119 @@
 package promrelabel
 
 import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/prompbmarshal"
 	"github.com/cprobe/cprobe/lib/promutils"
 )
 
-// // WriteMetricRelabelDebug writes /metric-relabel-debug page to w with the corresponding args.
-// func WriteMetricRelabelDebug(w io.Writer, targetID, metric, relabelConfigs, format string, err error) {
-// 	writeRelabelDebug(w, false, targetID, metric, relabelConfigs, format, err)
-// }
-
-// // WriteTargetRelabelDebug writes /target-relabel-debug page to w with the corresponding args.
-// func WriteTargetRelabelDebug(w io.Writer, targetID, metric, relabelConfigs, format string, err error) {
-// 	writeRelabelDebug(w, true, targetID, metric, relabelConfigs, format, err)
-// }
-
-// func writeRelabelDebug(w io.Writer, isTargetRelabel bool, targetID, metric, relabelConfigs, format string, err error) {
-// 	if metric == "" {
-// 		metric = "{}"
-// 	}
-// 	targetURL := ""
-// 	if err != nil {
-// 		WriteRelabelDebugSteps(w, targetURL, targetID, format, nil, metric, relabelConfigs, err)
-// 		return
-// 	}
-// 	labels, err := promutils.NewLabelsFromString(metric)
-// 	if err != nil {
-// 		err = fmt.Errorf("cannot parse metric: %w", err)
-// 		WriteRelabelDebugSteps(w, targetURL, targetID, format, nil, metric, relabelConfigs, err)
-// 		return
-// 	}
-// 	pcs, err := ParseRelabelConfigsData([]byte(relabelConfigs))
-// 	if err != nil {
-// 		err = fmt.Errorf("cannot parse relabel configs: %w", err)
-// 		WriteRelabelDebugSteps(w, targetURL, targetID, format, nil, metric, relabelConfigs, err)
-// 		return
-// 	}
-
-// 	dss, targetURL := newDebugRelabelSteps(pcs, labels, isTargetRelabel)
-// 	WriteRelabelDebugSteps(w, targetURL, targetID, format, dss, metric, relabelConfigs, nil)
-// }
+// WriteMetricRelabelDebug writes /metric-relabel-debug page to w with the corresponding args.
+func WriteMetricRelabelDebug(w io.Writer, targetID, metric, relabelConfigs, format string, err error) {
+	writeRelabelDebug(w, false, targetID, metric, relabelConfigs, format, err)
+}
+
+// WriteTargetRelabelDebug writes /target-relabel-debug page to w with the corresponding args.
+func WriteTargetRelabelDebug(w io.Writer, targetID, metric, relabelConfigs, format string, err error) {
+	writeRelabelDebug(w, true, targetID, metric, relabelConfigs, format, err)
+}
+
+func writeRelabelDebug(w io.Writer, isTargetRelabel bool, targetID, metric, relabelConfigs, format string, err error) {
+	if metric == "" {
+		metric = "{}"
+	}
+	targetAddress := ""
+	if err != nil {
+		WriteRelabelDebugSteps(w, isTargetRelabel, targetAddress, targetID, format, nil, metric, relabelConfigs, err)
+		return
+	}
+	labels, err := promutils.NewLabelsFromString(metric)
+	if err != nil {
+		err = fmt.Errorf("cannot parse metric: %w", err)
+		WriteRelabelDebugSteps(w, isTargetRelabel, targetAddress, targetID, format, nil, metric, relabelConfigs, err)
+		return
+	}
+	pcs, err := ParseRelabelConfigsData([]byte(relabelConfigs))
+	if err != nil {
+		err = fmt.Errorf("cannot parse relabel configs: %w", err)
+		WriteRelabelDebugSteps(w, isTargetRelabel, targetAddress, targetID, format, nil, metric, relabelConfigs, err)
+		return
+	}
+
+	dss, targetAddress := newDebugRelabelSteps(pcs, labels, isTargetRelabel)
+	WriteRelabelDebugSteps(w, isTargetRelabel, targetAddress, targetID, format, dss, metric, relabelConfigs, nil)
+}
 
 func newDebugRelabelSteps(pcs *ParsedConfigs, labels *promutils.Labels, isTargetRelabel bool) ([]DebugStep, string) {
-	// The target relabeling below must be in sync with the code at scrapeWorkConfig.getScrapeWork if isTargetRelabel=true
-	// and with the code at scrapeWork.addRowToTimeseries when isTargetRelabeling=false
-	targetURL := ""
+	// The target relabeling below must be in sync with the code at JobGoroutine.parseTarget if isTargetRelabel=true
+	// and with the code at JobGoroutine.run when isTargetRelabeling=false
+	targetAddress := ""
 
 	// Prevent from modifying the original labels
 	labels = labels.Clone()
 
-	// Apply relabeling
-	labelsResult, dss := pcs.ApplyDebug(labels.GetLabels())
-	labels.Labels = labelsResult
-	outStr := LabelsToString(labels.GetLabels())
-
+	var dss []DebugStep
 	if isTargetRelabel {
-		// Add missing instance label
+		// Add missing instance label. cprobe does this before relabeling, so relabel_configs may override it.
 		if labels.Get("instance") == "" {
 			address := labels.Get("__address__")
 			if address != "" {
-				inStr := outStr
+				inStr := LabelsToString(labels.GetLabels())
 				labels.Add("instance", address)
-				outStr = LabelsToString(labels.GetLabels())
 				dss = append(dss, DebugStep{
 					Rule: "add missing instance label from __address__ label",
 					In:   inStr,
-					Out:  outStr,
+					Out:  LabelsToString(labels.GetLabels()),
 				})
 			}
 		}
+	}
+
+	// Apply relabeling
+	labelsResult, relabelSteps := pcs.ApplyDebug(labels.GetLabels())
+	labels.Labels = labelsResult
+	dss = append(dss, relabelSteps...)
+	outStr := LabelsToString(labels.GetLabels())
 
-		// Generate targetURL
-		targetURL, _ = GetScrapeURL(labels, nil)
+	// Remove labels with __meta_ prefix
+	inStr := outStr
+	labels.RemoveMetaLabels()
+	outStr = LabelsToString(labels.GetLabels())
+	if inStr != outStr {
+		dss = append(dss, DebugStep{
+			Rule: "remove labels with __meta_ prefix",
+			In:   inStr,
+			Out:  outStr,
+		})
+	}
 
-		// Remove labels with __ prefix
-		inStr := outStr
-		labels.RemoveLabelsWithDoubleUnderscorePrefix()
-		outStr = LabelsToString(labels.GetLabels())
-		if inStr != outStr {
-			dss = append(dss, DebugStep{
-				Rule: "remove labels with __ prefix",
-				In:   inStr,
-				Out:  outStr,
-			})
+	if isTargetRelabel {
+		targetAddress = labels.Get("__address__")
+		if targetAddress == "" {
+			inStr := outStr
+			labels.Labels = labels.Labels[:0]
+			outStr = LabelsToString(labels.GetLabels())
+			if inStr != outStr {
+				dss = append(dss, DebugStep{
+					Rule: "drop target without __address__ label",
+					In:   inStr,
+					Out:  outStr,
+				})
+			}
+			return dss, targetAddress
 		}
-	} else {
-		// Remove labels with __ prefix except of __name__
+
+		// __address__ is passed to the plugin, while per-target auth and params are passed via ctx.
+		// Neither of them is attached to the scraped series.
 		inStr := outStr
-		labels.Labels = FinalizeLabels(labels.Labels[:0], labels.Labels)
+		labels.Labels = removeTargetOnlyLabels(labels.Labels)
 		outStr = LabelsToString(labels.GetLabels())
 		if inStr != outStr {
 			dss = append(dss, DebugStep{
-				Rule: "remove labels with __ prefix except of __name__",
+				Rule: "remove __address__, __auth_*__ and __param_*__ labels",
 				In:   inStr,
 				Out:  outStr,
 			})
@@ -98,7 +121,19 @@ func newDebugRelabelSteps(pcs *ParsedConfigs, labels *promutils.Labels, isTarget
 	}
 
 	// There is no need in labels' sorting, since LabelsToString() automatically sorts labels.
-	return dss, targetURL
+	return dss, targetAddress
+}
+
+func removeTargetOnlyLabels(labels []prompbmarshal.Label) []prompbmarshal.Label {
+	dst := labels[:0]
+	for _, label := range labels {
+		name := label.Name
+		if name == "__address__" || strings.HasPrefix(name, "__auth_") || strings.HasPrefix(name, "__param_") {
+			continue
+		}
+		dst = append(dst, label)
+	}
+	return dst
 }
 
 func getChangedLabelNames(in, out *promutils.Labels) map[string]struct{} {
@@ -119,3 +154,203 @@ func getChangedLabelNames(in, out *promutils.Labels) map[string]struct{} {
 	}
 	return changed
 }
+
+// WriteRelabelDebugSteps writes relabel debug steps to w in the given format.
+//
+// format can be either "json" or "html". html is used if format is empty.
+// targetID is the url query selecting the job and the target such as `job=mysql&target=10.0.0.1%3A3306`.
+func WriteRelabelDebugSteps(w io.Writer, isTargetRelabel bool, targetAddress, targetID, format string, dss []DebugStep, metric, relabelConfigs string, err error) {
+	if format == "json" {
+		writeRelabelDebugStepsJSON(w, targetAddress, dss, err)
+		return
+	}
+	writeRelabelDebugStepsHTML(w, isTargetRelabel, targetAddress, targetID, dss, metric, relabelConfigs, err)
+}
+
+type relabelDebugStepJSON struct {
+	Rule      string `json:"rule"`
+	InLabels  string `json:"inLabels"`
+	OutLabels string `json:"outLabels"`
+}
+
+type relabelDebugResultJSON struct {
+	Status          string                 `json:"status"`
+	Error           string                 `json:"error,omitempty"`
+	OriginalLabels  string                 `json:"originalLabels,omitempty"`
+	ResultingLabels string                 `json:"resultingLabels,omitempty"`
+	TargetAddress   string                 `json:"targetAddress,omitempty"`
+	Steps           []relabelDebugStepJSON `json:"steps"`
+}
+
+func writeRelabelDebugStepsJSON(w io.Writer, targetAddress string, dss []DebugStep, err error) {
+	var r relabelDebugResultJSON
+	if err != nil {
+		r.Status = "error"
+		r.Error = fmt.Sprintf("Error: %s", err)
+	} else {
+		r.Status = "success"
+		if len(dss) > 0 {
+			r.OriginalLabels = dss[0].In
+			r.ResultingLabels = dss[len(dss)-1].Out
+		}
+		r.TargetAddress = targetAddress
+		r.Steps = make([]relabelDebugStepJSON, 0, len(dss))
+		for _, ds := range dss {
+			r.Steps = append(r.Steps, relabelDebugStepJSON{
+				Rule:      ds.Rule,
+				InLabels:  ds.In,
+				OutLabels: ds.Out,
+			})
+		}
+	}
+	_ = json.NewEncoder(w).Encode(&r)
+}
+
+type relabelDebugLabelHTML struct {
+	Name    string
+	Value   string
+	Changed bool
+}
+
+type relabelDebugStepHTML struct {
+	Rule      string
+	In        string
+	Out       string
+	Error     error
+	InLabels  []relabelDebugLabelHTML
+	OutLabels []relabelDebugLabelHTML
+}
+
+type relabelDebugPageHTML struct {
+	IsTargetRelabel bool
+	TargetAddress   string
+	TargetID        template.URL
+	Metric          string
+	RelabelConfigs  string
+	Error           error
+	OriginalLabels  string
+	ResultingLabels string
+	Steps           []relabelDebugStepHTML
+}
+
+func writeRelabelDebugStepsHTML(w io.Writer, isTargetRelabel bool, targetAddress, targetID string, dss []DebugStep, metric, relabelConfigs string, err error) {
+	p := relabelDebugPageHTML{
+		IsTargetRelabel: isTargetRelabel,
+		TargetAddress:   targetAddress,
+		TargetID:        template.URL(targetID),
+		Metric:          metric,
+		RelabelConfigs:  relabelConfigs,
+		Error:           err,
+	}
+	if len(dss) > 0 {
+		p.OriginalLabels = dss[0].In
+		p.ResultingLabels = dss[len(dss)-1].Out
+	}
+	for _, ds := range dss {
+		step := relabelDebugStepHTML{
+			Rule: ds.Rule,
+			In:   ds.In,
+			Out:  ds.Out,
+		}
+		// Labels created by relabeling, e.g. target_label: "a=b", may not round-trip through LabelsToString.
+		// Show the raw labels for such steps instead of failing the whole page.
+		inLabels, err := promutils.NewLabelsFromString(ds.In)
+		if err != nil {
+			step.Error = fmt.Errorf("cannot parse input labels: %w", err)
+		}
+		outLabels, err := promutils.NewLabelsFromString(ds.Out)
+		if err != nil && step.Error == nil {
+			step.Error = fmt.Errorf("cannot parse output labels: %w", err)
+		}
+		if step.Error == nil {
+			changedLabels := getChangedLabelNames(inLabels, outLabels)
+			step.InLabels = labelsWithHighlight(inLabels, changedLabels)
+			step.OutLabels = labelsWithHighlight(outLabels, changedLabels)
+		}
+		p.Steps = append(p.Steps, step)
+	}
+	if err := relabelDebugTemplate.Execute(w, &p); err != nil {
+		fmt.Fprintf(w, "cannot render relabel debug page: %s", err)
+	}
+}
+
+func labelsWithHighlight(labels *promutils.Labels, highlight map[string]struct{}) []relabelDebugLabelHTML {
+	labels.Sort()
+	a := make([]relabelDebugLabelHTML, 0, labels.Len())
+	for _, label := range labels.GetLabels() {
+		_, changed := highlight[label.Name]
+		a = append(a, relabelDebugLabelHTML{
+			Name:    label.Name,
+			Value:   label.Value,
+			Changed: changed,
+		})
+	}
+	return a
+}
+
+var relabelDebugTemplate = template.Must(template.New("relabel-debug").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{ if .IsTargetRelabel }}Target{{ else }}Metric{{ end }} relabel debug</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+textarea { width: 100%; font-family: monospace; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 4px; vertical-align: top; text-align: left; }
+pre { margin: 0; }
+.error { color: red; }
+.in { font-weight: bold; color: red; }
+.out { font-weight: bold; color: blue; }
+</style>
+<script>
+function submitRelabelDebugForm(e) {
+  var form = e.target;
+  var method = "GET";
+  if (form.elements["relabel_configs"].value.length + form.elements["metric"].value.length > 1000) {
+    method = "POST";
+  }
+  form.method = method;
+}
+</script>
+</head>
+<body>
+<h2>{{ if .IsTargetRelabel }}Target{{ else }}Metric{{ end }} relabel debug</h2>
+<a href="https://docs.victoriametrics.com/relabeling.html" target="_blank">Relabeling docs</a>
+{{ if .IsTargetRelabel }}
+<a href="metric-relabel-debug{{ if .TargetID }}?{{ .TargetID }}{{ end }}">Metric relabel debug</a>
+{{ else }}
+<a href="target-relabel-debug{{ if .TargetID }}?{{ .TargetID }}{{ end }}">Target relabel debug</a>
+{{ end }}
+{{ if .Error }}<p class="error">{{ .Error }}</p>{{ end }}
+<form method="POST" onsubmit="submitRelabelDebugForm(event)">
+<div>Relabel configs:<br/><textarea name="relabel_configs" style="height: 15em">{{ .RelabelConfigs }}</textarea></div>
+<div>Labels:<br/><textarea name="metric" style="height: 5em">{{ .Metric }}</textarea></div>
+<input type="submit" value="Submit" />
+</form>
+{{ if .Steps }}
+<p><b>Original labels:</b> <samp>{{ .OriginalLabels }}</samp></p>
+<table>
+<thead><tr><th style="width: 5%">Step</th><th style="width: 25%">Relabeling Rule</th><th style="width: 35%">Input Labels</th><th style="width: 35%">Output labels</th></tr></thead>
+<tbody>
+{{ range $i, $s := .Steps }}
+<tr>
+<td>{{ $i }}</td>
+<td><pre>{{ $s.Rule }}</pre></td>
+{{ if $s.Error }}
+<td><samp>{{ $s.In }}</samp></td>
+<td><samp>{{ $s.Out }}</samp><div class="error">{{ $s.Error }}</div></td>
+{{ else }}
+<td title="deleted and updated labels highlighted in red">{{ range $s.InLabels }}<div{{ if .Changed }} class="in"{{ end }}>{{ .Name }}={{ printf "%q" .Value }}</div>{{ end }}</td>
+<td title="added and updated labels highlighted in blue">{{ range $s.OutLabels }}<div{{ if .Changed }} class="out"{{ end }}>{{ .Name }}={{ printf "%q" .Value }}</div>{{ end }}</td>
+{{ end }}
+</tr>
+{{ end }}
+</tbody>
+</table>
+<p><b>Resulting labels:</b> <samp>{{ .ResultingLabels }}</samp></p>
+{{ if .TargetAddress }}<p><b>Target address:</b> <samp>{{ .TargetAddress }}</samp></p>{{ end }}
+{{ end }}
+</body>
+</html>
+`))
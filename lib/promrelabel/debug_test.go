@@ -0,0 +1,98 @@
+package promrelabel
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteTargetRelabelDebugJSON(t *testing.T) {
+	f := func(metric, relabelConfigs string, wantResult relabelDebugResultJSON) {
+		t.Helper()
+		var bb bytes.Buffer
+		WriteTargetRelabelDebug(&bb, "", metric, relabelConfigs, "json", nil)
+		var r relabelDebugResultJSON
+		if err := json.Unmarshal(bb.Bytes(), &r); err != nil {
+			t.Fatalf("cannot unmarshal response %q: %s", bb.String(), err)
+		}
+		if r.Status != wantResult.Status {
+			t.Fatalf("unexpected status; got %q; want %q; error: %s", r.Status, wantResult.Status, r.Error)
+		}
+		if r.OriginalLabels != wantResult.OriginalLabels {
+			t.Fatalf("unexpected originalLabels; got %s; want %s", r.OriginalLabels, wantResult.OriginalLabels)
+		}
+		if r.ResultingLabels != wantResult.ResultingLabels {
+			t.Fatalf("unexpected resultingLabels; got %s; want %s", r.ResultingLabels, wantResult.ResultingLabels)
+		}
+		if r.TargetAddress != wantResult.TargetAddress {
+			t.Fatalf("unexpected targetAddress; got %q; want %q", r.TargetAddress, wantResult.TargetAddress)
+		}
+	}
+
+	f(`{__address__="10.0.0.1:3306", __meta_consul_service="mysql", __auth_username__="monitor"}`, `
+- source_labels: [__meta_consul_service]
+  target_label: service
+`, relabelDebugResultJSON{
+		Status:          "success",
+		OriginalLabels:  `{__address__="10.0.0.1:3306",__auth_username__="monitor",__meta_consul_service="mysql"}`,
+		ResultingLabels: `{instance="10.0.0.1:3306",service="mysql"}`,
+		TargetAddress:   "10.0.0.1:3306",
+	})
+
+	// the target is dropped
+	f(`{__address__="10.0.0.1:3306"}`, `
+- action: drop
+  source_labels: [__address__]
+  regex: '10\..+'
+`, relabelDebugResultJSON{
+		Status:          "success",
+		OriginalLabels:  `{__address__="10.0.0.1:3306"}`,
+		ResultingLabels: `{}`,
+	})
+
+	// invalid relabel configs
+	f(`{__address__="10.0.0.1:3306"}`, `- action: foobar`, relabelDebugResultJSON{
+		Status: "error",
+	})
+
+	// invalid labels
+	f(`{__address__=10.0.0.1:3306}`, ``, relabelDebugResultJSON{
+		Status: "error",
+	})
+}
+
+func TestWriteMetricRelabelDebugHTML(t *testing.T) {
+	var bb bytes.Buffer
+	WriteMetricRelabelDebug(&bb, "job=mysql&target=10.0.0.1%3A3306", `mysql_up{instance="10.0.0.1:3306",__meta_x="y"}`, `
+- target_label: env
+  replacement: prod
+`, "", nil)
+	s := bb.String()
+	for _, want := range []string{
+		`href="target-relabel-debug?job=mysql&amp;target=10.0.0.1%3A3306"`,
+		`<samp>mysql_up{env=&#34;prod&#34;,instance=&#34;10.0.0.1:3306&#34;}</samp>`,
+		`remove labels with __meta_ prefix`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("missing %q in page:\n%s", want, s)
+		}
+	}
+}
+
+func TestWriteMetricRelabelDebugHTMLUnparsableLabels(t *testing.T) {
+	var bb bytes.Buffer
+	WriteMetricRelabelDebug(&bb, "", `mysql_up{instance="10.0.0.1:3306"}`, `
+- target_label: "a=b"
+  replacement: c
+`, "", nil)
+	s := bb.String()
+	for _, want := range []string{
+		`target_label: a=b`,
+		`<div class="error">cannot parse output labels: `,
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("missing %q in page:\n%s", want, s)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package promutils
 
 import (
 	"encoding/json"
+	"fmt"
 	"sort"
 	"strconv"
 	"strings"
@@ -309,44 +310,66 @@ var labelsPool sync.Pool
 // MustNewLabelsFromString creates labels from s, which can have the form `metric{labels}`.
 //
 // This function must be used only in tests. Use NewLabelsFromString in production code.
-// func MustNewLabelsFromString(metricWithLabels string) *Labels {
-// 	labels, err := NewLabelsFromString(metricWithLabels)
-// 	if err != nil {
-// 		logger.Panicf("BUG: cannot parse %q: %s", metricWithLabels, err)
-// 	}
-// 	return labels
-// }
-
-// // NewLabelsFromString creates labels from s, which can have the form `metric{labels}`.
-// //
-// // This function must be used only in non performance-critical code, since it allocates too much
-// func NewLabelsFromString(metricWithLabels string) (*Labels, error) {
-// 	stripDummyMetric := false
-// 	if strings.HasPrefix(metricWithLabels, "{") {
-// 		// Add a dummy metric name, since the parser needs it
-// 		metricWithLabels = "dummy_metric" + metricWithLabels
-// 		stripDummyMetric = true
-// 	}
-// 	// add a value to metricWithLabels, so it could be parsed by prometheus protocol parser.
-// 	s := metricWithLabels + " 123"
-// 	var rows prometheus.Rows
-// 	var err error
-// 	rows.UnmarshalWithErrLogger(s, func(s string) {
-// 		err = fmt.Errorf("error during metric parse: %s", s)
-// 	})
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	if len(rows.Rows) != 1 {
-// 		return nil, fmt.Errorf("unexpected number of rows parsed; got %d; want 1", len(rows.Rows))
-// 	}
-// 	r := rows.Rows[0]
-// 	var x Labels
-// 	if !stripDummyMetric {
-// 		x.Add("__name__", r.Metric)
-// 	}
-// 	for _, tag := range r.Tags {
-// 		x.Add(tag.Key, tag.Value)
-// 	}
-// 	return &x, nil
-// }
+func MustNewLabelsFromString(metricWithLabels string) *Labels {
+	labels, err := NewLabelsFromString(metricWithLabels)
+	if err != nil {
+		panic(fmt.Errorf("BUG: cannot parse %q: %w", metricWithLabels, err))
+	}
+	return labels
+}
+
+// NewLabelsFromString creates labels from s, which can have the form `metric{labels}`.
+//
+// Label values must be quoted. This function must be used only in non performance-critical code, since it allocates too much
+func NewLabelsFromString(metricWithLabels string) (*Labels, error) {
+	s := strings.TrimSpace(metricWithLabels)
+	var x Labels
+	n := strings.IndexByte(s, '{')
+	if n < 0 {
+		if s != "" {
+			x.Add("__name__", s)
+		}
+		return &x, nil
+	}
+	if name := strings.TrimSpace(s[:n]); name != "" {
+		x.Add("__name__", name)
+	}
+	s = s[n+1:]
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if strings.HasPrefix(s, "}") {
+			s = s[1:]
+			break
+		}
+		n := strings.IndexByte(s, '=')
+		if n < 0 {
+			return nil, fmt.Errorf("missing `=` after label name in %q", metricWithLabels)
+		}
+		name := strings.TrimSpace(s[:n])
+		if name == "" {
+			return nil, fmt.Errorf("missing label name in %q", metricWithLabels)
+		}
+		s = strings.TrimLeft(s[n+1:], " \t\r\n")
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find quoted value for label %q in %q", name, metricWithLabels)
+		}
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("cannot unquote value for label %q in %q: %w", name, metricWithLabels, err)
+		}
+		x.Add(name, value)
+		s = strings.TrimLeft(s[len(quoted):], " \t\r\n")
+		if strings.HasPrefix(s, ",") {
+			s = s[1:]
+			continue
+		}
+		if !strings.HasPrefix(s, "}") {
+			return nil, fmt.Errorf("missing `,` or `}` after label %q in %q", name, metricWithLabels)
+		}
+	}
+	if strings.TrimSpace(s) != "" {
+		return nil, fmt.Errorf("unexpected trailing data after `}` in %q", metricWithLabels)
+	}
+	return &x, nil
+}
@@ -0,0 +1,40 @@
+package promutils
+
+import (
+	"testing"
+)
+
+func TestNewLabelsFromString(t *testing.T) {
+	f := func(s, want string) {
+		t.Helper()
+		labels, err := NewLabelsFromString(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got := labels.String(); got != want {
+			t.Fatalf("unexpected labels; got %s; want %s", got, want)
+		}
+	}
+
+	f(``, `{}`)
+	f(`{}`, `{}`)
+	f(`foo`, `{__name__="foo"}`)
+	f(` foo { a = "b" , c="d\"e",} `, `{__name__="foo",a="b",c="d\"e"}`)
+	f(`{__address__="10.0.0.1:3306",instance="x\ny"}`, `{__address__="10.0.0.1:3306",instance="x\ny"}`)
+}
+
+func TestNewLabelsFromStringFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		if _, err := NewLabelsFromString(s); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+
+	f(`{a}`)
+	f(`{a=b}`)
+	f(`{="b"}`)
+	f(`{a="b"`)
+	f(`{a="b" c="d"}`)
+	f(`{a="b"} foo`)
+}
@@ -0,0 +1,199 @@
+package apache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/clienttls"
+	"github.com/cprobe/cprobe/lib/httpreq"
+	"github.com/cprobe/cprobe/types"
+	"github.com/pkg/errors"
+)
+
+type Config struct {
+	BaseDir string `toml:"-"`
+
+	httpreq.RequestOptions
+	clienttls.ClientConfig
+}
+
+// target: http(s)://127.0.0.1/server-status，没有写路径的话默认是 /server-status，会自动加上 ?auto 参数
+func (c *Config) Scrape(ctx context.Context, target string, ss *types.Samples) error {
+	if !strings.HasPrefix(target, "http") {
+		target = "http://" + target
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return errors.WithMessagef(err, "invalid target: %s", target)
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/server-status"
+	}
+
+	q := u.Query()
+	q.Set("auto", "")
+	u.RawQuery = strings.TrimSuffix(q.Encode(), "=")
+	target = u.String()
+
+	var tlsConfig *tls.Config
+	if u.Scheme == "https" {
+		tlsConfig, err = c.ClientConfig.TLSConfig()
+		if err != nil {
+			return err
+		}
+	}
+
+	cli, err := c.RequestOptions.NewClient(tlsConfig, true)
+	if err != nil {
+		return errors.WithMessagef(err, "new client failed, target: %s", target)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return errors.WithMessagef(err, "new request failed, target: %s", target)
+	}
+
+	if err = c.RequestOptions.FillHeaders(req, c.BaseDir); err != nil {
+		return errors.WithMessagef(err, "fill headers failed, target: %s", target)
+	}
+
+	res, err := cli.Do(req)
+	if err != nil {
+		return errors.WithMessagef(err, "do request failed, target: %s", target)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		bs, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return errors.Errorf("response status code is not 200, target: %s, code: %d, response body: %s", target, res.StatusCode, string(bs))
+	}
+
+	return parseStatus(res.Body, ss)
+}
+
+// scoreboardStates mod_status 的 scoreboard 中每个字符代表一个 worker 的状态
+var scoreboardStates = map[rune]string{
+	'_': "waiting",
+	'S': "starting",
+	'R': "reading",
+	'W': "sending",
+	'K': "keepalive",
+	'D': "dns",
+	'C': "closing",
+	'L': "logging",
+	'G': "graceful",
+	'I': "idle_cleanup",
+	'.': "open_slot",
+}
+
+// statusFields ?auto 输出中的字段 -> 指标名称
+var statusFields = map[string]string{
+	"Total Accesses": "accesses_total",
+	"Total kBytes":   "sent_kilobytes_total",
+	"Total Duration": "duration_milliseconds_total",
+	"CPULoad":        "cpu_load",
+	"CPUUser":        "cpu_user_seconds_total",
+	"CPUSystem":      "cpu_system_seconds_total",
+	"Uptime":         "uptime_seconds",
+	"ReqPerSec":      "requests_per_second",
+	"BytesPerSec":    "bytes_per_second",
+	"BytesPerReq":    "bytes_per_request",
+	"Load1":          "load1",
+	"Load5":          "load5",
+	"Load15":         "load15",
+	"Processes":      "processes",
+	"Stopping":       "processes_stopping",
+}
+
+func parseStatus(r io.Reader, ss *types.Samples) error {
+	fields := make(map[string]interface{})
+	info := make(map[string]string)
+	found := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(kv[0])
+		value := strings.TrimSpace(kv[1])
+
+		switch key {
+		case "ServerVersion":
+			info["version"] = value
+			found = true
+		case "ServerMPM":
+			info["mpm"] = value
+		case "BusyWorkers":
+			addNumber(ss, "workers", value, map[string]string{"state": "busy"})
+			found = true
+		case "IdleWorkers":
+			addNumber(ss, "workers", value, map[string]string{"state": "idle"})
+		case "ConnsTotal":
+			addNumber(ss, "connections", value, map[string]string{"state": "total"})
+		case "ConnsAsyncWriting":
+			addNumber(ss, "connections", value, map[string]string{"state": "writing"})
+		case "ConnsAsyncKeepAlive":
+			addNumber(ss, "connections", value, map[string]string{"state": "keepalive"})
+		case "ConnsAsyncClosing":
+			addNumber(ss, "connections", value, map[string]string{"state": "closing"})
+		case "Scoreboard":
+			counts := make(map[string]int, len(scoreboardStates))
+			for _, state := range scoreboardStates {
+				counts[state] = 0
+			}
+			for _, ch := range value {
+				if state, ok := scoreboardStates[ch]; ok {
+					counts[state]++
+				}
+			}
+			for state, count := range counts {
+				ss.AddMetric(types.PluginApache, map[string]interface{}{"scoreboard": count}, map[string]string{"state": state})
+			}
+		default:
+			name, ok := statusFields[key]
+			if !ok {
+				continue
+			}
+			// CPULoad 之类的字段可能是 .0123 这种格式，ParseFloat 可以正确处理
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				fields[name] = f
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if !found {
+		return errors.New("invalid mod_status response, make sure the target is a mod_status page")
+	}
+
+	if len(fields) > 0 {
+		ss.AddMetric(types.PluginApache, fields)
+	}
+
+	ss.AddMetric(types.PluginApache, map[string]interface{}{"version_info": 1}, info)
+	return nil
+}
+
+func addNumber(ss *types.Samples, name, value string, tags map[string]string) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+	ss.AddMetric(types.PluginApache, map[string]interface{}{name: f}, tags)
+}
@@ -0,0 +1,95 @@
+package apache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cprobe/cprobe/lib/conv"
+	"github.com/cprobe/cprobe/types"
+)
+
+const statusAuto = `localhost
+ServerVersion: Apache/2.4.57 (Unix)
+ServerMPM: event
+Server Built: Apr  6 2023 14:12:35
+CurrentTime: Thursday, 19-Oct-2023 08:00:00 UTC
+Total Accesses: 1234
+Total kBytes: 5678
+Total Duration: 910
+CPULoad: .0123
+Uptime: 3600
+ReqPerSec: .342778
+BusyWorkers: 3
+IdleWorkers: 72
+ConnsTotal: 5
+ConnsAsyncWriting: 1
+ConnsAsyncKeepAlive: 2
+ConnsAsyncClosing: 0
+Scoreboard: __W_K_R__....
+`
+
+func TestScrape(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/server-status" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.RawQuery
+		fmt.Fprint(w, statusAuto)
+	}))
+	defer srv.Close()
+
+	cfg := &Config{}
+	ss := types.NewSamples()
+	if err := cfg.Scrape(context.Background(), strings.TrimPrefix(srv.URL, "http://"), ss); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if query != "auto" {
+		t.Errorf("expected query auto, got %q", query)
+	}
+
+	values := make(map[string]float64)
+	for _, m := range ss.PopBackAll() {
+		for k, v := range m.Fields() {
+			f, _ := conv.ToFloat64(v)
+			key := k
+			if state := m.Tags()["state"]; state != "" {
+				key += "/" + state
+			}
+			values[key] = f
+		}
+	}
+
+	expected := map[string]float64{
+		"accesses_total":        1234,
+		"sent_kilobytes_total":  5678,
+		"cpu_load":              0.0123,
+		"uptime_seconds":        3600,
+		"workers/busy":          3,
+		"workers/idle":          72,
+		"connections/keepalive": 2,
+		"scoreboard/waiting":    6,
+		"scoreboard/sending":    1,
+		"scoreboard/keepalive":  1,
+		"scoreboard/reading":    1,
+		"scoreboard/open_slot":  4,
+		"scoreboard/logging":    0,
+		"version_info":          1,
+	}
+
+	for k, want := range expected {
+		if got, ok := values[k]; !ok || got != want {
+			t.Errorf("%s: expected %v, got %v (present: %v)", k, want, got, ok)
+		}
+	}
+
+	if err := parseStatus(strings.NewReader("<html>It works!</html>"), types.NewSamples()); err == nil {
+		t.Errorf("expected error for non mod_status response")
+	}
+}
@@ -0,0 +1,114 @@
+package prober
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// match 校验从响应中取到的值，不满足的时候返回 error
+func (m *ValueMatch) match(v string) error {
+	if m.Op == "" {
+		if !m.Regexp.MatchString(v) {
+			return fmt.Errorf("value %q did not match regular expression: %v", v, m.Regexp)
+		}
+		return nil
+	}
+
+	a, errA := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	b, errB := strconv.ParseFloat(strings.TrimSpace(m.Value), 64)
+	isNumber := errA == nil && errB == nil
+
+	var ok bool
+	switch m.Op {
+	case "==":
+		ok = (isNumber && a == b) || (!isNumber && v == m.Value)
+	case "!=":
+		ok = (isNumber && a != b) || (!isNumber && v != m.Value)
+	default:
+		if !isNumber {
+			return fmt.Errorf("cannot compare %q %s %q: both values must be numbers", v, m.Op, m.Value)
+		}
+		switch m.Op {
+		case ">":
+			ok = a > b
+		case ">=":
+			ok = a >= b
+		case "<":
+			ok = a < b
+		case "<=":
+			ok = a <= b
+		}
+	}
+	if !ok {
+		return fmt.Errorf("value %q doesn't satisfy %s %q", v, m.Op, m.Value)
+	}
+	return nil
+}
+
+func matchJSONPath(data interface{}, httpConfig HTTPProbe) bool {
+	for _, m := range httpConfig.FailIfJSONNotMatches {
+		v, err := extractJSONPath(data, m.Path)
+		if err != nil {
+			logger.Errorf("%v", err)
+			return false
+		}
+		if err := m.match(v); err != nil {
+			logger.Errorf("unexpected value at jsonpath %q: %v", m.Path, err)
+			return false
+		}
+	}
+	return true
+}
+
+func matchXPath(body []byte, httpConfig HTTPProbe) bool {
+	root, err := parseXMLBody(body)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return false
+	}
+	for _, m := range httpConfig.FailIfXPathNotMatches {
+		values, err := evalXPath(root, m.Path)
+		if err != nil {
+			logger.Errorf("%v", err)
+			return false
+		}
+		if len(values) == 0 {
+			logger.Errorf("xpath %q didn't match any node", m.Path)
+			return false
+		}
+		if err := m.match(values[0]); err != nil {
+			logger.Errorf("unexpected value at xpath %q: %v", m.Path, err)
+			return false
+		}
+	}
+	return true
+}
+
+// extractJSONValues 把 json_values 配置的字段值放到 gauge 里，取不到或者不是数值的字段直接跳过
+func extractJSONValues(data interface{}, httpConfig HTTPProbe, gaugeVec *prometheus.GaugeVec) {
+	for _, jv := range httpConfig.JSONValues {
+		v, err := extractJSONPath(data, jv.Path)
+		if err != nil {
+			logger.Errorf("cannot extract json_values %q: %v", jv.Name, err)
+			continue
+		}
+		var f float64
+		switch v = strings.TrimSpace(v); v {
+		case "true":
+			f = 1
+		case "false":
+			f = 0
+		default:
+			f, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				logger.Errorf("cannot extract json_values %q: value %q at jsonpath %q isn't a number", jv.Name, v, jv.Path)
+				continue
+			}
+		}
+		gaugeVec.WithLabelValues(jv.Name).Set(f)
+	}
+}
@@ -0,0 +1,190 @@
+package prober
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestValueMatch(t *testing.T) {
+	f := func(config, v string, wantOK bool) {
+		t.Helper()
+		var m ValueMatch
+		if err := yaml.Unmarshal([]byte(config), &m); err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		err := m.match(v)
+		if (err == nil) != wantOK {
+			t.Fatalf("unexpected result for %q with %s; got error %v; want ok=%v", v, config, err, wantOK)
+		}
+	}
+	f(`{path: "{.status}", regexp: "^(ok|green)$"}`, "green", true)
+	f(`{path: "{.status}", regexp: "^(ok|green)$"}`, "red", false)
+	f(`{path: "{.status}", op: "==", value: "ok"}`, "ok", true)
+	f(`{path: "{.count}", op: "==", value: "1"}`, "1.0", true)
+	f(`{path: "{.count}", op: "!=", value: "0"}`, "0", false)
+	f(`{path: "{.count}", op: ">", value: "10"}`, "11", true)
+	f(`{path: "{.count}", op: ">=", value: "10"}`, "9.5", false)
+	f(`{path: "{.count}", op: "<", value: "10"}`, "abc", false)
+	f(`{path: "{.count}", op: "<=", value: "10"}`, "10", true)
+
+	for _, config := range []string{
+		`{regexp: "ok"}`,
+		`{path: "{.status}"}`,
+		`{path: "{.status}", regexp: "ok", op: "==", value: "ok"}`,
+		`{path: "{.status}", op: "=~", value: "ok"}`,
+	} {
+		var m ValueMatch
+		if err := yaml.Unmarshal([]byte(config), &m); err == nil {
+			t.Fatalf("expecting non-nil error for %s", config)
+		}
+	}
+}
+
+func TestEvalXPath(t *testing.T) {
+	body := []byte(`<?xml version="1.0"?>
+<response>
+  <status code="0">ok</status>
+  <items>
+    <item id="1">a</item>
+    <item id="2" enabled="true">b</item>
+  </items>
+</response>`)
+	root, err := parseXMLBody(body)
+	if err != nil {
+		t.Fatalf("cannot parse xml: %s", err)
+	}
+	f := func(path string, want []string) {
+		t.Helper()
+		got, err := evalXPath(root, path)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", path, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("unexpected result for %q; got %q; want %q", path, got, want)
+		}
+	}
+	f("/response/status", []string{"ok"})
+	f("/response/status/text()", []string{"ok"})
+	f("/response/status/@code", []string{"0"})
+	f("//item", []string{"a", "b"})
+	f("/response/items/item[2]", []string{"b"})
+	f("/response/items/item[@enabled]/@id", []string{"2"})
+	f("/response/*/item[@id='1']", []string{"a"})
+	f("//item/@id", []string{"1", "2"})
+	f("/response/missing", []string{})
+
+	for _, path := range []string{"response/status", "/response/item[0]", "/response/@code/status", "/response/item[@id=1]"} {
+		if _, err := evalXPath(root, path); err == nil {
+			t.Fatalf("expecting non-nil error for %q", path)
+		}
+	}
+
+	if _, err := parseXMLBody([]byte("not xml")); err == nil {
+		t.Fatalf("expecting non-nil error for invalid xml")
+	}
+}
+
+func TestProbeHTTPJSONAndXPath(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Write([]byte(`{"status": "ok", "queue": {"size": 12, "ready": true}}`))
+		case "/xml":
+			w.Write([]byte(`<health><status>ok</status><queue size="12"/></health>`))
+		}
+	}))
+	defer ts.Close()
+
+	f := func(path, config string, wantSuccess bool, wantValues map[string]float64) {
+		t.Helper()
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		registry := prometheus.NewRegistry()
+		if success := ProbeHTTP(ctx, ts.URL+path, module, registry); success != wantSuccess {
+			t.Fatalf("unexpected probe result; got %v; want %v", success, wantSuccess)
+		}
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("cannot gather metrics: %s", err)
+		}
+		got := make(map[string]float64)
+		for _, mf := range mfs {
+			if mf.GetName() != "probe_http_json_value" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				got[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+			}
+		}
+		if len(wantValues) > 0 && !reflect.DeepEqual(got, wantValues) {
+			t.Fatalf("unexpected probe_http_json_value; got %v; want %v", got, wantValues)
+		}
+	}
+
+	f("/json", `
+prober: http
+http:
+  fail_if_json_not_matches:
+  - path: '{.status}'
+    op: '=='
+    value: ok
+  - path: '{.queue.size}'
+    op: '<'
+    value: '100'
+  json_values:
+  - name: queue_size
+    path: '{.queue.size}'
+  - path: '{.queue.ready}'
+`, true, map[string]float64{"queue_size": 12, "{.queue.ready}": 1})
+
+	f("/json", `
+prober: http
+http:
+  fail_if_json_not_matches:
+  - path: '{.queue.size}'
+    op: '<'
+    value: '10'
+  json_values:
+  - name: queue_size
+    path: '{.queue.size}'
+`, false, map[string]float64{"queue_size": 12})
+
+	f("/json", `
+prober: http
+http:
+  fail_if_json_not_matches:
+  - path: '{.missing}'
+    regexp: '.*'
+`, false, nil)
+
+	f("/xml", `
+prober: http
+http:
+  fail_if_xpath_not_matches:
+  - path: /health/status
+    regexp: '^ok$'
+  - path: /health/queue/@size
+    op: '<='
+    value: '12'
+`, true, nil)
+
+	f("/xml", `
+prober: http
+http:
+  fail_if_xpath_not_matches:
+  - path: /health/status
+    op: '=='
+    value: degraded
+`, false, nil)
+}
@@ -1,6 +1,8 @@
 package prober
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -75,6 +77,15 @@ type Module struct {
 	ICMP    ICMPProbe     `yaml:"icmp,omitempty"`
 	DNS     DNSProbe      `yaml:"dns,omitempty"`
 	GRPC    GRPCProbe     `yaml:"grpc,omitempty"`
+
+	HTTPSteps HTTPStepsProbe `yaml:"http_steps,omitempty"`
+	UDP       UDPProbe       `yaml:"udp,omitempty"`
+	NTP       NTPProbe       `yaml:"ntp,omitempty"`
+	SSH       SSHProbe       `yaml:"ssh,omitempty"`
+	SMTP      SMTPProbe      `yaml:"smtp,omitempty"`
+
+	Traceroute TracerouteProbe `yaml:"traceroute,omitempty"`
+	X509       X509Probe       `yaml:"x509,omitempty"`
 }
 
 type HTTPProbe struct {
@@ -93,11 +104,78 @@ type HTTPProbe struct {
 	FailIfBodyNotMatchesRegexp   []Regexp                `yaml:"fail_if_body_not_matches_regexp,omitempty"`
 	FailIfHeaderMatchesRegexp    []HeaderMatch           `yaml:"fail_if_header_matches,omitempty"`
 	FailIfHeaderNotMatchesRegexp []HeaderMatch           `yaml:"fail_if_header_not_matches,omitempty"`
+	FailIfJSONNotMatches         []ValueMatch            `yaml:"fail_if_json_not_matches,omitempty"`
+	FailIfXPathNotMatches        []ValueMatch            `yaml:"fail_if_xpath_not_matches,omitempty"`
+	JSONValues                   []JSONValue             `yaml:"json_values,omitempty"`
 	Body                         string                  `yaml:"body,omitempty"`
 	BodyFile                     string                  `yaml:"body_file,omitempty"`
 	HTTPClientConfig             config.HTTPClientConfig `yaml:"http_client_config,inline"`
 	Compression                  string                  `yaml:"compression,omitempty"`
 	BodySizeLimit                units.Base2Bytes        `yaml:"body_size_limit,omitempty"`
+	HTTP3                        HTTP3Config             `yaml:"http3,omitempty"`
+}
+
+// HTTP3Config 开启之后通过 QUIC 发送请求，只支持 https 的 target。
+// QUIC 把建连和 TLS 握手合并成一次握手，握手耗时记在 probe_http_duration_seconds 的 tls 阶段
+type HTTP3Config struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Fallback 为 true 的时候，QUIC 握手失败（比如 UDP 被拦截）会改用 TCP 上的 HTTP/1.1 或 HTTP/2 重试，否则探测直接失败
+	Fallback bool `yaml:"fallback,omitempty"`
+	// Enable0RTT 会在探测之间缓存 TLS session ticket，服务端支持的话后续的 GET 请求通过 0-RTT 发送
+	Enable0RTT bool `yaml:"enable_0rtt,omitempty"`
+}
+
+// HTTPStepsProbe 按顺序执行多个 HTTP 请求，比如先登录拿到 token，再用 token 访问接口
+// 所有 step 共用一个 http client 和 cookie jar
+type HTTPStepsProbe struct {
+	// Vars 是初始变量，step 中可以通过 {{ .name }} 引用，另外内置了 {{ .target }} 变量
+	Vars             map[string]string       `yaml:"vars,omitempty"`
+	Steps            []HTTPStep              `yaml:"steps,omitempty"`
+	HTTPClientConfig config.HTTPClientConfig `yaml:"http_client_config,inline"`
+	BodySizeLimit    units.Base2Bytes        `yaml:"body_size_limit,omitempty"`
+}
+
+type HTTPStep struct {
+	Name string `yaml:"name,omitempty"`
+	// Method defaults to GET.
+	Method string `yaml:"method,omitempty"`
+	// URL 支持模板，相对路径会基于 target 解析，为空的时候直接请求 target
+	URL     string            `yaml:"url,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+	// Defaults to 2xx.
+	ValidStatusCodes           []int                `yaml:"valid_status_codes,omitempty"`
+	FailIfBodyMatchesRegexp    []Regexp             `yaml:"fail_if_body_matches_regexp,omitempty"`
+	FailIfBodyNotMatchesRegexp []Regexp             `yaml:"fail_if_body_not_matches_regexp,omitempty"`
+	FailIfJSONNotMatches       []ValueMatch         `yaml:"fail_if_json_not_matches,omitempty"`
+	Extract                    []HTTPStepExtraction `yaml:"extract,omitempty"`
+}
+
+// ValueMatch 用 JSONPath 或 XPath 从响应中取值，取到的值必须匹配 Regexp，或者和 Value 比较的结果为真
+type ValueMatch struct {
+	Path   string `yaml:"path,omitempty"`
+	Regexp Regexp `yaml:"regexp,omitempty"`
+	// Op is one of ==, !=, >, >=, < and <=. Values are compared as numbers if both of them are numbers.
+	Op    string `yaml:"op,omitempty"`
+	Value string `yaml:"value,omitempty"`
+}
+
+// JSONValue 把 JSONPath 取到的数值暴露成 probe_http_json_value 指标，name 是指标的 name 标签
+type JSONValue struct {
+	Name string `yaml:"name,omitempty"`
+	Path string `yaml:"path,omitempty"`
+}
+
+// HTTPStepExtraction 从响应中提取变量，给后面的 step 使用
+type HTTPStepExtraction struct {
+	Name string `yaml:"name,omitempty"`
+	// From is one of json, header, cookie or regexp.
+	From   string `yaml:"from,omitempty"`
+	Path   string `yaml:"path,omitempty"`
+	Header string `yaml:"header,omitempty"`
+	Cookie string `yaml:"cookie,omitempty"`
+	// Regexp 作用于响应 body，取第一个捕获组，没有捕获组的话取整个匹配
+	Regexp Regexp `yaml:"regexp,omitempty"`
 }
 
 type HeaderMatch struct {
@@ -112,6 +190,17 @@ type GRPCProbe struct {
 	TLSConfig           config.TLSConfig `yaml:"tls_config,omitempty"`
 	IPProtocolFallback  bool             `yaml:"ip_protocol_fallback,omitempty"`
 	PreferredIPProtocol string           `yaml:"preferred_ip_protocol,omitempty"`
+	// Method 不为空的时候不再调用健康检查，而是调用这个 unary 方法，格式是 package.Service/Method
+	Method string `yaml:"method,omitempty"`
+	// DescriptorSetFile 是 protoc --include_imports --descriptor_set_out 生成的文件，不配置的时候通过服务端反射获取方法的定义
+	DescriptorSetFile string `yaml:"descriptor_set_file,omitempty"`
+	// Request 是 JSON 格式的请求消息，为空的时候发送空消息
+	Request  string            `yaml:"request,omitempty"`
+	Metadata map[string]string `yaml:"metadata,omitempty"`
+	// ValidStatusCodes 是状态码的名字，比如 OK、NOT_FOUND，默认只有 OK
+	ValidStatusCodes []string `yaml:"valid_status_codes,omitempty"`
+	// FailIfJSONNotMatches 作用于 JSON 格式的响应消息，字段名是 lowerCamelCase，没有赋值的字段也会输出
+	FailIfJSONNotMatches []ValueMatch `yaml:"fail_if_json_not_matches,omitempty"`
 }
 
 type QueryResponse struct {
@@ -136,6 +225,9 @@ type ICMPProbe struct {
 	PayloadSize        int    `yaml:"payload_size,omitempty"`
 	DontFragment       bool   `yaml:"dont_fragment,omitempty"`
 	TTL                int    `yaml:"ttl,omitempty"`
+	// PacketCount 大于 1 的时候每次探测按照 Interval 的间隔发送多个包，并采集丢包率、RTT 统计和抖动，Defaults to 1.
+	PacketCount int           `yaml:"packet_count,omitempty"`
+	Interval    time.Duration `yaml:"interval,omitempty"`
 }
 
 type DNSProbe struct {
@@ -153,6 +245,113 @@ type DNSProbe struct {
 	ValidateAnswer     DNSRRValidator   `yaml:"validate_answer_rrs,omitempty"`
 	ValidateAuthority  DNSRRValidator   `yaml:"validate_authority_rrs,omitempty"`
 	ValidateAdditional DNSRRValidator   `yaml:"validate_additional_rrs,omitempty"`
+	DNSSEC             DNSSECConfig     `yaml:"dnssec,omitempty"`
+	Consistency        DNSConsistency   `yaml:"consistency,omitempty"`
+}
+
+// DNSSECConfig 开启之后查询时设置 EDNS0 的 DO 位，上报应答中 RRSIG 的过期时间
+type DNSSECConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// Validate 从应答的签名开始，通过 DNSKEY 和 DS 逐级校验信任链，直到 TrustAnchors 中的某个锚点，校验失败时探测失败
+	Validate bool `yaml:"validate,omitempty"`
+	// TrustAnchors 是 DS 或者 DNSKEY 记录，比如 ". IN DS 20326 8 2 E06D44B8..."，不配置的时候使用根区的 KSK
+	TrustAnchors []string `yaml:"trust_anchors,omitempty"`
+}
+
+// DNSConsistency 用同样的查询请求 Servers 中的每个服务器，和 target 的应答做比较。
+// query_type 为 SOA 时比较 serial 的差值，其他类型比较应答中的记录（忽略 TTL 和顺序）
+type DNSConsistency struct {
+	// Servers 是 host 或者 host:port，不带端口的时候和 target 一样默认 53 或者 853
+	Servers            []string `yaml:"servers,omitempty"`
+	MaxSOASerialDrift  uint32   `yaml:"max_soa_serial_drift,omitempty"`
+	FailIfInconsistent bool     `yaml:"fail_if_inconsistent,omitempty"`
+}
+
+// UDPProbe 发送一个 UDP 包，配置了 Expect 的时候要求收到的响应匹配 Expect，否则发送成功就算探测成功
+type UDPProbe struct {
+	IPProtocol         string `yaml:"preferred_ip_protocol,omitempty"`
+	IPProtocolFallback bool   `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress    string `yaml:"source_ip_address,omitempty"`
+	Payload            string `yaml:"payload,omitempty"`
+	// PayloadHex 用于发送二进制内容，比如 "0a0b0c"，和 Payload 只能配置一个
+	PayloadHex string `yaml:"payload_hex,omitempty"`
+	Expect     Regexp `yaml:"expect,omitempty"`
+}
+
+// NTPProbe 向 NTP 服务器发送一个 SNTP 请求，检查时间偏差和 stratum，target 不带端口的时候默认 123
+type NTPProbe struct {
+	IPProtocol         string `yaml:"preferred_ip_protocol,omitempty"`
+	IPProtocolFallback bool   `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress    string `yaml:"source_ip_address,omitempty"`
+	// MaxOffset 为 0 的时候不检查时间偏差
+	MaxOffset time.Duration `yaml:"max_offset,omitempty"`
+	// Defaults to 15.
+	MaxStratum int `yaml:"max_stratum,omitempty"`
+}
+
+// SSHProbe 检查 SSH 服务的 banner 和 host key，只做到密钥交换，不会尝试登录，target 不带端口的时候默认 22
+type SSHProbe struct {
+	IPProtocol             string `yaml:"preferred_ip_protocol,omitempty"`
+	IPProtocolFallback     bool   `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress        string `yaml:"source_ip_address,omitempty"`
+	FailIfBannerNotMatches Regexp `yaml:"fail_if_banner_not_matches,omitempty"`
+	// HostKeyFingerprints 是允许的 host key 指纹，格式和 ssh-keygen -lf 的输出一致，比如 SHA256:xxx，为空的时候不检查
+	HostKeyFingerprints []string `yaml:"host_key_fingerprints,omitempty"`
+	HostKeyAlgorithms   []string `yaml:"host_key_algorithms,omitempty"`
+}
+
+// SMTPProbe 发送 EHLO，检查 STARTTLS 和支持的认证方式，target 不带端口的时候默认 25。
+// protocol 为 imap 的时候改为发送 CAPABILITY，默认端口是 143
+type SMTPProbe struct {
+	IPProtocol         string `yaml:"preferred_ip_protocol,omitempty"`
+	IPProtocolFallback bool   `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress    string `yaml:"source_ip_address,omitempty"`
+	// Protocol 可选 smtp、imap，Defaults to smtp.
+	Protocol string `yaml:"protocol,omitempty"`
+	// EHLO 只在 protocol 为 smtp 的时候使用，Defaults to localhost.
+	EHLO string `yaml:"ehlo,omitempty"`
+	// TLS 用于 465 端口这种直接 TLS 的服务，StartTLS 要求服务端支持 STARTTLS 并完成 TLS 握手
+	TLS                    bool             `yaml:"tls,omitempty"`
+	StartTLS               bool             `yaml:"starttls,omitempty"`
+	TLSConfig              config.TLSConfig `yaml:"tls_config,omitempty"`
+	RequiredAuthMechanisms []string         `yaml:"required_auth_mechanisms,omitempty"`
+}
+
+// TracerouteProbe 逐跳增加 TTL 探测到 target 的路径，需要 raw socket 权限（root 或者 CAP_NET_RAW）
+type TracerouteProbe struct {
+	IPProtocol         string `yaml:"preferred_ip_protocol,omitempty"`
+	IPProtocolFallback bool   `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress    string `yaml:"source_ip_address,omitempty"`
+	PayloadSize        int    `yaml:"payload_size,omitempty"`
+	// DontFragment 只在 protocol 为 icmp 并且使用 ipv4 的时候生效
+	DontFragment bool `yaml:"dont_fragment,omitempty"`
+	// Protocol 是探测包使用的协议，可选 icmp、udp、tcp，Defaults to icmp.
+	Protocol string `yaml:"protocol,omitempty"`
+	// Port 是 udp 和 tcp 探测包的目标端口，udp 默认 33434 并且每个探测包递增，tcp 默认 80
+	Port int `yaml:"port,omitempty"`
+	// Defaults to 1.
+	FirstHop int `yaml:"first_hop,omitempty"`
+	// Defaults to 30.
+	MaxHops int `yaml:"max_hops,omitempty"`
+	// Defaults to 3.
+	ProbesPerHop int `yaml:"probes_per_hop,omitempty"`
+	// ReplyTimeout 是最后一个探测包发出之后等待响应的时间，Defaults to 1s.
+	ReplyTimeout time.Duration `yaml:"reply_timeout,omitempty"`
+}
+
+// X509Probe 检查证书，target 是 host:port 的时候通过 TLS 握手获取证书，是 file:// 开头的路径的时候读取本地的 PEM、DER 或者 PKCS12 文件
+type X509Probe struct {
+	IPProtocol         string `yaml:"preferred_ip_protocol,omitempty"`
+	IPProtocolFallback bool   `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress    string `yaml:"source_ip_address,omitempty"`
+	// StartTLS 是 TLS 握手之前使用的协议，可选 smtp、imap、postgres、mysql，为空的时候直接 TLS 握手
+	StartTLS string `yaml:"starttls,omitempty"`
+	// TLSConfig 的 ca_file 同时用于校验证书链，不配置的时候使用系统的 CA，insecure_skip_verify 为 true 的时候证书链无效不会导致探测失败
+	TLSConfig config.TLSConfig `yaml:"tls_config,omitempty"`
+	// Password 是 PKCS12 文件的密码
+	Password config.Secret `yaml:"password,omitempty"`
+	// OCSP 为 true 的时候，如果服务端没有 OCSP stapling 或者检查的是本地文件，向证书中的 OCSP 地址查询吊销状态
+	OCSP bool `yaml:"ocsp,omitempty"`
 }
 
 type DNSRRValidator struct {
@@ -211,6 +410,231 @@ func (s *HTTPProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		}
 	}
 
+	if s.HTTP3.Enabled {
+		if s.HTTPClientConfig.ProxyURL.URL != nil || s.HTTPClientConfig.ProxyFromEnvironment {
+			return errors.New("http3 cannot be used with proxy_url or proxy_from_environment")
+		}
+		if s.HTTPClientConfig.OAuth2 != nil {
+			return errors.New("http3 cannot be used with oauth2")
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *HTTPStepsProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultHTTPStepsProbe
+	type plain HTTPStepsProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+
+	if s.BodySizeLimit < 0 || s.BodySizeLimit == math.MaxInt64 {
+		s.BodySizeLimit = math.MaxInt64 - 1
+	}
+
+	if err := s.HTTPClientConfig.Validate(); err != nil {
+		return err
+	}
+
+	if len(s.Steps) == 0 {
+		return errors.New("at least one step must be set for http_steps module")
+	}
+	names := make(map[string]struct{}, len(s.Steps))
+	for i, step := range s.Steps {
+		if step.Name == "" {
+			return fmt.Errorf("name must be set for step #%d", i+1)
+		}
+		if _, ok := names[step.Name]; ok {
+			return fmt.Errorf("duplicate step name %q", step.Name)
+		}
+		names[step.Name] = struct{}{}
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *ValueMatch) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain ValueMatch
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	if s.Path == "" {
+		return errors.New("path must be set for JSON and XPath matchers")
+	}
+	hasRegexp := s.Regexp.Regexp != nil
+	hasOp := s.Op != ""
+	if hasRegexp == hasOp {
+		return fmt.Errorf("either regexp or op must be set for matcher with path %q", s.Path)
+	}
+	if hasOp {
+		switch s.Op {
+		case "==", "!=", ">", ">=", "<", "<=":
+		default:
+			return fmt.Errorf("unsupported op %q for matcher with path %q; supported ops: ==, !=, >, >=, <, <=", s.Op, s.Path)
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *JSONValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain JSONValue
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	if s.Path == "" {
+		return errors.New("path must be set for json_values")
+	}
+	if s.Name == "" {
+		s.Name = s.Path
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *HTTPStepExtraction) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain HTTPStepExtraction
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	if s.Name == "" {
+		return errors.New("name must be set for step extraction")
+	}
+	switch s.From {
+	case "json":
+		if s.Path == "" {
+			return fmt.Errorf("path must be set for extraction %q from json", s.Name)
+		}
+	case "header":
+		if s.Header == "" {
+			return fmt.Errorf("header must be set for extraction %q from header", s.Name)
+		}
+	case "cookie":
+		if s.Cookie == "" {
+			return fmt.Errorf("cookie must be set for extraction %q from cookie", s.Name)
+		}
+	case "regexp":
+		if s.Regexp.Regexp == nil {
+			return fmt.Errorf("regexp must be set for extraction %q from regexp", s.Name)
+		}
+	default:
+		return fmt.Errorf("unsupported `from: %q` for extraction %q; supported values: json, header, cookie, regexp", s.From, s.Name)
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *UDPProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultUDPProbe
+	type plain UDPProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	if s.Payload != "" && s.PayloadHex != "" {
+		return errors.New("setting payload and payload_hex both are not allowed")
+	}
+	if _, err := hex.DecodeString(s.PayloadHex); err != nil {
+		return fmt.Errorf("invalid payload_hex: %w", err)
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *NTPProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultNTPProbe
+	type plain NTPProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	if s.MaxOffset < 0 {
+		return errors.New("\"max_offset\" cannot be negative")
+	}
+	if s.MaxStratum < 1 || s.MaxStratum > 15 {
+		return errors.New("\"max_stratum\" must be in the range [1..15]")
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *SSHProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultSSHProbe
+	type plain SSHProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	for _, fp := range s.HostKeyFingerprints {
+		if !strings.HasPrefix(fp, "SHA256:") {
+			return fmt.Errorf("unsupported host key fingerprint %q; it must start with SHA256:", fp)
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *SMTPProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultSMTPProbe
+	type plain SMTPProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	if s.TLS && s.StartTLS {
+		return errors.New("setting tls and starttls both are not allowed")
+	}
+	if s.Protocol != "smtp" && s.Protocol != "imap" {
+		return fmt.Errorf("unsupported smtp protocol %q; supported values: smtp, imap", s.Protocol)
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *TracerouteProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultTracerouteProbe
+	type plain TracerouteProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+
+	switch s.Protocol {
+	case "icmp":
+	case "udp", "tcp":
+		if s.DontFragment {
+			return fmt.Errorf("dont_fragment is only supported by protocol icmp")
+		}
+	default:
+		return fmt.Errorf("unsupported traceroute protocol %q; supported values: icmp, udp, tcp", s.Protocol)
+	}
+	if s.MaxHops < 1 || s.MaxHops > 255 {
+		return fmt.Errorf("max_hops must be in the range [1, 255]; got %d", s.MaxHops)
+	}
+	if s.FirstHop < 1 || s.FirstHop > s.MaxHops {
+		return fmt.Errorf("first_hop must be in the range [1, max_hops]; got %d", s.FirstHop)
+	}
+	if s.ProbesPerHop < 1 || s.ProbesPerHop > 10 {
+		return fmt.Errorf("probes_per_hop must be in the range [1, 10]; got %d", s.ProbesPerHop)
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		return fmt.Errorf("invalid port %d", s.Port)
+	}
+	if s.ReplyTimeout <= 0 {
+		return fmt.Errorf("reply_timeout must be positive")
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *X509Probe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultX509Probe
+	type plain X509Probe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	switch s.StartTLS {
+	case "", "smtp", "imap", "postgres", "mysql":
+	default:
+		return fmt.Errorf("unsupported starttls protocol %q; supported values: smtp, imap, postgres, mysql", s.StartTLS)
+	}
 	return nil
 }
 
@@ -221,6 +645,24 @@ func (s *GRPCProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal((*plain)(s)); err != nil {
 		return err
 	}
+	if s.Method == "" {
+		if s.DescriptorSetFile != "" || s.Request != "" || len(s.Metadata) > 0 || len(s.ValidStatusCodes) > 0 || len(s.FailIfJSONNotMatches) > 0 {
+			return errors.New("method must be set for grpc module when descriptor_set_file, request, metadata, valid_status_codes or fail_if_json_not_matches is set")
+		}
+		return nil
+	}
+	s.Method = strings.TrimPrefix(s.Method, "/")
+	if _, _, err := splitGRPCMethod(s.Method); err != nil {
+		return err
+	}
+	if s.Request != "" && !json.Valid([]byte(s.Request)) {
+		return fmt.Errorf("request of grpc method %s isn't valid JSON", s.Method)
+	}
+	for _, name := range s.ValidStatusCodes {
+		if _, err := parseGRPCStatusCode(name); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -244,6 +686,12 @@ func (s *DNSProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			return fmt.Errorf("query type '%s' is not valid", s.QueryType)
 		}
 	}
+	if s.DNSSEC.Validate {
+		s.DNSSEC.Enabled = true
+	}
+	if _, err := parseTrustAnchors(s.DNSSEC.TrustAnchors); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -285,6 +733,13 @@ func (s *ICMPProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if s.TTL > 255 {
 		return errors.New("\"ttl\" cannot exceed 255")
 	}
+
+	if s.PacketCount < 1 {
+		return errors.New("\"packet_count\" must be at least 1")
+	}
+	if s.PacketCount > 1 && s.Interval <= 0 {
+		return errors.New("\"interval\" must be positive")
+	}
 	return nil
 }
 
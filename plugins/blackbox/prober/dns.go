@@ -15,8 +15,11 @@ package prober
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"net"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/cprobe/cprobe/lib/logger"
@@ -218,8 +221,9 @@ func ProbeDNS(ctx context.Context, target string, module Module, registry *prome
 	client := new(dns.Client)
 	client.Net = dialProtocol
 
+	var tlsConfig *tls.Config
 	if module.DNS.DNSOverTLS {
-		tlsConfig, err := pconfig.NewTLSConfig(&module.DNS.TLSConfig)
+		tlsConfig, err = pconfig.NewTLSConfig(&module.DNS.TLSConfig)
 		if err != nil {
 			logger.Errorf("Error creating TLS configuration. error: %s", err)
 			return false
@@ -256,6 +260,9 @@ func ProbeDNS(ctx context.Context, target string, module Module, registry *prome
 		Qtype:  qt,
 		Qclass: qc,
 	}
+	if module.DNS.DNSSEC.Enabled {
+		msg.SetEdns0(4096, true)
+	}
 
 	timeoutDeadline, _ := ctx.Deadline()
 	client.Timeout = time.Until(timeoutDeadline)
@@ -306,5 +313,68 @@ func ProbeDNS(ctx context.Context, target string, module Module, registry *prome
 		logger.Errorf("Additional RRs validation failed")
 		return false
 	}
-	return true
+
+	success := true
+	if module.DNS.DNSSEC.Enabled {
+		exchange := func(name string, qtype uint16) (*dns.Msg, error) {
+			m := new(dns.Msg)
+			m.SetQuestion(dns.Fqdn(name), qtype)
+			m.RecursionDesired = module.DNS.Recursion
+			m.SetEdns0(4096, true)
+			client.Timeout = time.Until(timeoutDeadline)
+			r, _, err := client.Exchange(m, targetIP)
+			if err == nil && r.Truncated && module.DNS.TransportProtocol == "udp" {
+				r, _, err = tcpFallbackClient(client).Exchange(m, targetIP)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if r.Rcode != dns.RcodeSuccess {
+				return nil, fmt.Errorf("unexpected rcode %s", dns.RcodeToString[r.Rcode])
+			}
+			return r, nil
+		}
+		if !probeDNSSEC(exchange, response, module, registry) {
+			success = false
+		}
+	}
+
+	if len(module.DNS.Consistency.Servers) > 0 {
+		defaultPort := "53"
+		if module.DNS.DNSOverTLS {
+			defaultPort = "853"
+		}
+		newClient := func(server string) (*dns.Client, string) {
+			host, addr := dnsServerAddr(server, defaultPort)
+			c := *client
+			c.Net = module.DNS.TransportProtocol
+			if module.DNS.DNSOverTLS {
+				c.Net = "tcp-tls"
+				c.TLSConfig = tlsConfig.Clone()
+				if module.DNS.TLSConfig.ServerName == "" {
+					c.TLSConfig.ServerName = host
+				}
+			}
+			c.Timeout = time.Until(timeoutDeadline)
+			return &c, addr
+		}
+		if !probeDNSConsistency(newClient, msg, response, module, registry) && module.DNS.Consistency.FailIfInconsistent {
+			success = false
+		}
+	}
+	return success
+}
+
+// tcpFallbackClient 返回 client 对应的 tcp client，用于 udp 应答被截断时重试
+func tcpFallbackClient(client *dns.Client) *dns.Client {
+	c := *client
+	c.Net = strings.Replace(client.Net, "udp", "tcp", 1)
+	if client.Dialer != nil {
+		if addr, ok := client.Dialer.LocalAddr.(*net.UDPAddr); ok {
+			d := *client.Dialer
+			d.LocalAddr = &net.TCPAddr{IP: addr.IP}
+			c.Dialer = &d
+		}
+	}
+	return &c
 }
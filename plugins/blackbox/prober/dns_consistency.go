@@ -0,0 +1,180 @@
+package prober
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type dnsConsistencyResult struct {
+	server   string
+	response *dns.Msg
+	err      error
+}
+
+// probeDNSConsistency 用 msg 并发查询 module.DNS.Consistency.Servers，和 target 的应答 reference 做比较，返回是否一致
+func probeDNSConsistency(newClient func(server string) (*dns.Client, string), msg *dns.Msg, reference *dns.Msg, module Module, registry *prometheus.Registry) bool {
+	probeDNSConsistencySucceededGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_dns_consistency_query_succeeded",
+		Help: "Displays whether or not the query to the server was executed successfully",
+	}, []string{"server"})
+	probeDNSConsistentGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_dns_consistent",
+		Help: "Returns whether all servers returned consistent answers",
+	})
+	registry.MustRegister(probeDNSConsistencySucceededGaugeVec)
+	registry.MustRegister(probeDNSConsistentGauge)
+
+	results := make([]dnsConsistencyResult, len(module.DNS.Consistency.Servers))
+	var wg sync.WaitGroup
+	for i, server := range module.DNS.Consistency.Servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			client, addr := newClient(server)
+			m := msg.Copy()
+			m.Id = dns.Id()
+			response, _, err := client.Exchange(m, addr)
+			results[i] = dnsConsistencyResult{server: server, response: response, err: err}
+		}(i, server)
+	}
+	wg.Wait()
+
+	consistent := true
+	for _, r := range results {
+		if r.err != nil {
+			logger.Errorf("Error while sending a DNS query to %s. error: %s", r.server, r.err)
+			probeDNSConsistencySucceededGaugeVec.WithLabelValues(r.server).Set(0)
+			consistent = false
+			continue
+		}
+		probeDNSConsistencySucceededGaugeVec.WithLabelValues(r.server).Set(1)
+		if r.response.Rcode != reference.Rcode {
+			logger.Errorf("DNS server %s returned rcode %s, target returned %s", r.server, dns.RcodeToString[r.response.Rcode], dns.RcodeToString[reference.Rcode])
+			consistent = false
+		}
+	}
+
+	if msg.Question[0].Qtype == dns.TypeSOA {
+		if !checkSOASerialDrift(results, reference, module.DNS.Consistency.MaxSOASerialDrift, registry) {
+			consistent = false
+		}
+	} else if !checkAnswersMatch(results, reference, registry) {
+		consistent = false
+	}
+
+	if consistent {
+		probeDNSConsistentGauge.Set(1)
+	}
+	return consistent
+}
+
+// checkAnswersMatch 比较每个服务器应答中的记录和 target 是否一致，忽略 TTL、大小写和顺序
+func checkAnswersMatch(results []dnsConsistencyResult, reference *dns.Msg, registry *prometheus.Registry) bool {
+	probeDNSAnswersMatchGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_dns_consistency_answers_match",
+		Help: "Returns whether the answer RRs of the server match the target",
+	}, []string{"server"})
+	registry.MustRegister(probeDNSAnswersMatchGaugeVec)
+
+	want := normalizeAnswer(reference.Answer)
+	match := true
+	for _, r := range results {
+		if r.err != nil {
+			continue
+		}
+		if got := normalizeAnswer(r.response.Answer); got != want {
+			logger.Errorf("DNS server %s returned inconsistent answers. got: %q, target: %q", r.server, got, want)
+			probeDNSAnswersMatchGaugeVec.WithLabelValues(r.server).Set(0)
+			match = false
+			continue
+		}
+		probeDNSAnswersMatchGaugeVec.WithLabelValues(r.server).Set(1)
+	}
+	return match
+}
+
+// checkSOASerialDrift 上报每个服务器的 serial，target 和所有服务器之间最大的差值超过 maxDrift 时返回 false
+func checkSOASerialDrift(results []dnsConsistencyResult, reference *dns.Msg, maxDrift uint32, registry *prometheus.Registry) bool {
+	probeDNSSerialGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_dns_consistency_serial",
+		Help: "Returns the serial number of the zone returned by the server",
+	}, []string{"server"})
+	probeDNSSerialDriftGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_dns_soa_serial_drift",
+		Help: "Returns the difference between the largest and smallest serial number of all servers",
+	})
+	registry.MustRegister(probeDNSSerialGaugeVec)
+	registry.MustRegister(probeDNSSerialDriftGauge)
+
+	serial, ok := soaSerial(reference)
+	if !ok {
+		logger.Errorf("No SOA record in the answer of target")
+		return false
+	}
+	minSerial, maxSerial := serial, serial
+	consistent := true
+	for _, r := range results {
+		if r.err != nil {
+			continue
+		}
+		s, ok := soaSerial(r.response)
+		if !ok {
+			logger.Errorf("No SOA record in the answer of DNS server %s", r.server)
+			consistent = false
+			continue
+		}
+		probeDNSSerialGaugeVec.WithLabelValues(r.server).Set(float64(s))
+		if s < minSerial {
+			minSerial = s
+		}
+		if s > maxSerial {
+			maxSerial = s
+		}
+	}
+
+	drift := maxSerial - minSerial
+	probeDNSSerialDriftGauge.Set(float64(drift))
+	if drift > maxDrift {
+		logger.Errorf("SOA serial drift %d exceeds max_soa_serial_drift %d", drift, maxDrift)
+		consistent = false
+	}
+	return consistent
+}
+
+func soaSerial(m *dns.Msg) (uint32, bool) {
+	for _, rr := range m.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, true
+		}
+	}
+	return 0, false
+}
+
+func normalizeAnswer(rrs []dns.RR) string {
+	lines := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		if _, ok := rr.(*dns.RRSIG); ok {
+			continue
+		}
+		rr = dns.Copy(rr)
+		rr.Header().Name = dns.CanonicalName(rr.Header().Name)
+		rr.Header().Ttl = 0
+		lines = append(lines, rr.String())
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// dnsServerAddr 给不带端口的 server 加上默认端口
+func dnsServerAddr(server, defaultPort string) (host, addr string) {
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		return h, server
+	}
+	return server, net.JoinHostPort(server, defaultPort)
+}
@@ -0,0 +1,363 @@
+package prober
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 根区的 KSK-2017 和 KSK-2024，来自 https://data.iana.org/root-anchors/root-anchors.xml
+var defaultTrustAnchors = []string{
+	". IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+// 校验信任链时最多向上追溯的层数，防止服务端返回异常的签名者导致死循环
+const maxDNSSECChainDepth = 32
+
+// dnsExchangeFunc 向 target 发送设置了 DO 位的查询
+type dnsExchangeFunc func(name string, qtype uint16) (*dns.Msg, error)
+
+// parseTrustAnchors 解析 DS 或者 DNSKEY 格式的信任锚点，anchors 为空的时候使用根区的 KSK
+func parseTrustAnchors(anchors []string) ([]dns.RR, error) {
+	if len(anchors) == 0 {
+		anchors = defaultTrustAnchors
+	}
+	rrs := make([]dns.RR, 0, len(anchors))
+	for _, a := range anchors {
+		rr, err := dns.NewRR(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trust anchor %q: %w", a, err)
+		}
+		switch rr.(type) {
+		case *dns.DS, *dns.DNSKEY:
+		default:
+			return nil, fmt.Errorf("trust anchor %q must be a DS or DNSKEY record", a)
+		}
+		rr.Header().Name = dns.CanonicalName(rr.Header().Name)
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
+
+// earliestRRSIGExpiry 返回 rrs 中所有 RRSIG 最早的过期时间，没有 RRSIG 的时候返回零值
+func earliestRRSIGExpiry(rrs []dns.RR) time.Time {
+	var earliest time.Time
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			earliest = earlierTime(earliest, rrsigExpiration(sig))
+		}
+	}
+	return earliest
+}
+
+func rrsigExpiration(sig *dns.RRSIG) time.Time {
+	return time.Unix(int64(sig.Expiration), 0)
+}
+
+func earlierTime(a, b time.Time) time.Time {
+	if a.IsZero() || b.Before(a) {
+		return b
+	}
+	return a
+}
+
+// probeDNSSEC 上报 AD 位和 RRSIG 的过期时间，配置了 validate 的时候校验信任链
+func probeDNSSEC(exchange dnsExchangeFunc, response *dns.Msg, module Module, registry *prometheus.Registry) bool {
+	probeDNSSECAuthenticatedGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_dns_dnssec_authenticated",
+		Help: "Returns whether the AD bit is set in the response",
+	})
+	registry.MustRegister(probeDNSSECAuthenticatedGauge)
+	if response.AuthenticatedData {
+		probeDNSSECAuthenticatedGauge.Set(1)
+	}
+
+	earliest := earliestRRSIGExpiry(response.Answer)
+	success := true
+	if module.DNS.DNSSEC.Validate {
+		probeDNSSECValidGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_dnssec_valid",
+			Help: "Returns whether the answer is validated through the chain of trust",
+		})
+		registry.MustRegister(probeDNSSECValidGauge)
+
+		anchors, err := parseTrustAnchors(module.DNS.DNSSEC.TrustAnchors)
+		if err != nil {
+			logger.Errorf("Error parsing trust anchors. error: %s", err)
+			return false
+		}
+		v := newDNSSECValidator(exchange, anchors, time.Now())
+		if err := v.validateAnswer(response.Answer); err != nil {
+			logger.Errorf("DNSSEC validation failed. error: %s", err)
+			success = false
+		} else {
+			probeDNSSECValidGauge.Set(1)
+		}
+		if !v.earliestExpiry.IsZero() {
+			earliest = earlierTime(earliest, v.earliestExpiry)
+		}
+	}
+
+	if !earliest.IsZero() {
+		probeDNSRRSIGExpiryGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_rrsig_expiry_timestamp_seconds",
+			Help: "Returns the earliest expiration of the RRSIG records in the answer and the chain of trust",
+		})
+		registry.MustRegister(probeDNSRRSIGExpiryGauge)
+		probeDNSRRSIGExpiryGauge.Set(float64(earliest.Unix()))
+	}
+	return success
+}
+
+// dnssecValidator 从应答的签名开始逐级向上校验 DNSKEY 和 DS，直到某个信任锚点
+type dnssecValidator struct {
+	exchange dnsExchangeFunc
+	anchors  []dns.RR
+	now      time.Time
+	// keys 缓存已经校验过的 zone 的 DNSKEY
+	keys map[string][]*dns.DNSKEY
+	// earliestExpiry 是校验过程中用到的所有 RRSIG 最早的过期时间
+	earliestExpiry time.Time
+}
+
+func newDNSSECValidator(exchange dnsExchangeFunc, anchors []dns.RR, now time.Time) *dnssecValidator {
+	return &dnssecValidator{
+		exchange: exchange,
+		anchors:  anchors,
+		now:      now,
+		keys:     make(map[string][]*dns.DNSKEY),
+	}
+}
+
+// validateAnswer 校验应答中的每个 RRset，暂不支持 NSEC/NSEC3 否定应答的校验
+func (v *dnssecValidator) validateAnswer(answer []dns.RR) error {
+	rrsets, sigs := splitRRsets(answer)
+	if len(rrsets) == 0 {
+		return errors.New("no answer RRs to validate, validating denial of existence is not supported")
+	}
+	for _, rrset := range rrsets {
+		if err := v.verifyRRset(rrset, sigs, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyRRset 要求 rrset 至少有一个有效期内的 RRSIG 能用已经校验过的 zone key 验证通过
+func (v *dnssecValidator) verifyRRset(rrset []dns.RR, sigs []*dns.RRSIG, depth int) error {
+	hdr := rrset[0].Header()
+	name := dns.CanonicalName(hdr.Name)
+	typ := dns.TypeToString[hdr.Rrtype]
+
+	var lastErr error
+	for _, sig := range sigs {
+		if sig.TypeCovered != hdr.Rrtype || dns.CanonicalName(sig.Hdr.Name) != name {
+			continue
+		}
+		signer := dns.CanonicalName(sig.SignerName)
+		if !dns.IsSubDomain(signer, name) {
+			lastErr = fmt.Errorf("RRSIG for %s %s has signer %s outside of the zone", name, typ, signer)
+			continue
+		}
+		if hdr.Rrtype == dns.TypeDNSKEY && signer == name {
+			// DNSKEY 是自签的，validateZone 会重新查询并校验它
+			_, err := v.validateZone(signer, depth+1)
+			return err
+		}
+		keys, err := v.validateZone(signer, depth+1)
+		if err != nil {
+			return err
+		}
+		if err := verifyWithKeys(sig, keys, rrset, v.now); err != nil {
+			lastErr = fmt.Errorf("%s %s: %w", name, typ, err)
+			continue
+		}
+		v.earliestExpiry = earlierTime(v.earliestExpiry, rrsigExpiration(sig))
+		return nil
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	return fmt.Errorf("no RRSIG for %s %s", name, typ)
+}
+
+// validateZone 返回 zone 经过校验的 DNSKEY：DNSKEY RRset 需要被某个可信的 key 签名，
+// 可信的 key 要么匹配信任锚点，要么匹配父 zone 中已经校验过的 DS 记录
+func (v *dnssecValidator) validateZone(zone string, depth int) ([]*dns.DNSKEY, error) {
+	if keys, has := v.keys[zone]; has {
+		return keys, nil
+	}
+	if depth > maxDNSSECChainDepth {
+		return nil, fmt.Errorf("chain of trust for %s is too deep", zone)
+	}
+
+	resp, err := v.exchange(zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, fmt.Errorf("error querying DNSKEY of %s: %w", zone, err)
+	}
+	var keys []*dns.DNSKEY
+	var keyset []dns.RR
+	var sigs []*dns.RRSIG
+	for _, rr := range resp.Answer {
+		if dns.CanonicalName(rr.Header().Name) != zone {
+			continue
+		}
+		switch rr := rr.(type) {
+		case *dns.DNSKEY:
+			keys = append(keys, rr)
+			keyset = append(keyset, rr)
+		case *dns.RRSIG:
+			if rr.TypeCovered == dns.TypeDNSKEY {
+				sigs = append(sigs, rr)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no DNSKEY found for %s", zone)
+	}
+
+	var trusted []*dns.DNSKEY
+	if anchors := v.anchorsFor(zone); len(anchors) > 0 {
+		trusted = matchKeys(keys, anchors)
+		if len(trusted) == 0 {
+			return nil, fmt.Errorf("no DNSKEY of %s matches the trust anchors", zone)
+		}
+	} else {
+		if zone == "." {
+			return nil, errors.New("no trust anchor for the root zone")
+		}
+		dsResp, err := v.exchange(zone, dns.TypeDS)
+		if err != nil {
+			return nil, fmt.Errorf("error querying DS of %s: %w", zone, err)
+		}
+		var dsset []dns.RR
+		var dsSigs []*dns.RRSIG
+		for _, rr := range dsResp.Answer {
+			if dns.CanonicalName(rr.Header().Name) != zone {
+				continue
+			}
+			switch rr := rr.(type) {
+			case *dns.DS:
+				dsset = append(dsset, rr)
+			case *dns.RRSIG:
+				if rr.TypeCovered == dns.TypeDS {
+					dsSigs = append(dsSigs, rr)
+				}
+			}
+		}
+		if len(dsset) == 0 {
+			return nil, fmt.Errorf("no DS found for %s, zone is not signed or the chain of trust is broken", zone)
+		}
+		// DS 由父 zone 签名，所以 signer 必须是 zone 的上级
+		for _, sig := range dsSigs {
+			if dns.CanonicalName(sig.SignerName) == zone {
+				return nil, fmt.Errorf("DS of %s is signed by itself", zone)
+			}
+		}
+		if err := v.verifyRRset(dsset, dsSigs, depth); err != nil {
+			return nil, err
+		}
+		trusted = matchKeys(keys, dsset)
+		if len(trusted) == 0 {
+			return nil, fmt.Errorf("no DNSKEY of %s matches its DS records", zone)
+		}
+	}
+
+	var lastErr error = fmt.Errorf("no RRSIG for %s DNSKEY", zone)
+	for _, sig := range sigs {
+		if dns.CanonicalName(sig.SignerName) != zone {
+			continue
+		}
+		if err := verifyWithKeys(sig, trusted, keyset, v.now); err != nil {
+			lastErr = fmt.Errorf("%s DNSKEY: %w", zone, err)
+			continue
+		}
+		v.earliestExpiry = earlierTime(v.earliestExpiry, rrsigExpiration(sig))
+		v.keys[zone] = keys
+		return keys, nil
+	}
+	return nil, lastErr
+}
+
+func (v *dnssecValidator) anchorsFor(zone string) []dns.RR {
+	var anchors []dns.RR
+	for _, a := range v.anchors {
+		if a.Header().Name == zone {
+			anchors = append(anchors, a)
+		}
+	}
+	return anchors
+}
+
+// verifyWithKeys 用 key tag 和算法匹配的 key 校验签名，并检查签名的有效期
+func verifyWithKeys(sig *dns.RRSIG, keys []*dns.DNSKEY, rrset []dns.RR, now time.Time) error {
+	var lastErr error = fmt.Errorf("no DNSKEY with key tag %d", sig.KeyTag)
+	for _, k := range keys {
+		if k.Algorithm != sig.Algorithm || k.KeyTag() != sig.KeyTag {
+			continue
+		}
+		if err := sig.Verify(k, rrset); err != nil {
+			lastErr = err
+			continue
+		}
+		if !sig.ValidityPeriod(now) {
+			return fmt.Errorf("RRSIG with key tag %d is not valid at %s (inception %s, expiration %s)",
+				sig.KeyTag, now.UTC().Format(time.RFC3339),
+				dns.TimeToString(sig.Inception), dns.TimeToString(sig.Expiration))
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// matchKeys 返回匹配 DS 或者 DNSKEY 记录的 key
+func matchKeys(keys []*dns.DNSKEY, refs []dns.RR) []*dns.DNSKEY {
+	var matched []*dns.DNSKEY
+	for _, k := range keys {
+		for _, ref := range refs {
+			switch ref := ref.(type) {
+			case *dns.DS:
+				if ref.KeyTag != k.KeyTag() || ref.Algorithm != k.Algorithm {
+					continue
+				}
+				ds := k.ToDS(ref.DigestType)
+				if ds != nil && strings.EqualFold(ds.Digest, ref.Digest) {
+					matched = append(matched, k)
+				}
+			case *dns.DNSKEY:
+				if ref.Flags == k.Flags && ref.Protocol == k.Protocol && ref.Algorithm == k.Algorithm && ref.PublicKey == k.PublicKey {
+					matched = append(matched, k)
+				}
+			}
+		}
+	}
+	return matched
+}
+
+// splitRRsets 按照 name 和 type 把 rrs 分组，RRSIG 单独返回
+func splitRRsets(rrs []dns.RR) ([][]dns.RR, []*dns.RRSIG) {
+	var rrsets [][]dns.RR
+	var sigs []*dns.RRSIG
+	index := make(map[string]int)
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			sigs = append(sigs, sig)
+			continue
+		}
+		key := dns.CanonicalName(rr.Header().Name) + "/" + dns.TypeToString[rr.Header().Rrtype]
+		i, has := index[key]
+		if !has {
+			i = len(rrsets)
+			index[key] = i
+			rrsets = append(rrsets, nil)
+		}
+		rrsets[i] = append(rrsets[i], rr)
+	}
+	return rrsets, sigs
+}
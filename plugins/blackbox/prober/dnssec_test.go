@@ -0,0 +1,239 @@
+package prober
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+type testDNSSECZone struct {
+	name string
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newTestDNSSECZone(t *testing.T, name string) *testDNSSECZone {
+	t.Helper()
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     257,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatalf("cannot generate key: %s", err)
+	}
+	return &testDNSSECZone{name: name, key: key, priv: priv.(crypto.Signer)}
+}
+
+func (z *testDNSSECZone) sign(t *testing.T, rrset []dns.RR, expiration time.Time) []dns.RR {
+	t.Helper()
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: rrset[0].Header().Name, Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 3600},
+		KeyTag:     z.key.KeyTag(),
+		SignerName: z.name,
+		Algorithm:  z.key.Algorithm,
+		Inception:  uint32(time.Now().Add(-time.Hour).Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	if err := sig.Sign(z.priv, rrset); err != nil {
+		t.Fatalf("cannot sign rrset: %s", err)
+	}
+	return append(append([]dns.RR{}, rrset...), sig)
+}
+
+func mustNewRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatalf("cannot parse RR %q: %s", s, err)
+	}
+	return rr
+}
+
+// newTestDNSSECRecords 返回一个签名的 . -> example. -> www.example. 的信任链，以及根区的 DS 锚点
+func newTestDNSSECRecords(t *testing.T, answerExpiration time.Time) (map[string][]dns.RR, string) {
+	root := newTestDNSSECZone(t, ".")
+	example := newTestDNSSECZone(t, "example.")
+	expiration := time.Now().Add(30 * 24 * time.Hour)
+
+	records := map[string][]dns.RR{
+		"./DNSKEY":        root.sign(t, []dns.RR{root.key}, expiration),
+		"example./DNSKEY": example.sign(t, []dns.RR{example.key}, expiration),
+		"example./DS":     root.sign(t, []dns.RR{example.key.ToDS(dns.SHA256)}, expiration),
+		"www.example./A":  example.sign(t, []dns.RR{mustNewRR(t, "www.example. 300 IN A 192.0.2.1")}, answerExpiration),
+	}
+	return records, root.key.ToDS(dns.SHA256).String()
+}
+
+func testDNSExchange(records map[string][]dns.RR) dnsExchangeFunc {
+	return func(name string, qtype uint16) (*dns.Msg, error) {
+		m := new(dns.Msg)
+		m.Answer = records[name+"/"+dns.TypeToString[qtype]]
+		return m, nil
+	}
+}
+
+// startTestDNSServer 在 127.0.0.1 上启动一个 udp 的 DNS 服务器，按照 "name/TYPE" 返回 records 中的记录
+func startTestDNSServer(t *testing.T, records map[string][]dns.RR) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen udp: %s", err)
+	}
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		q := r.Question[0]
+		m.Answer = records[strings.ToLower(q.Name)+"/"+dns.TypeToString[q.Qtype]]
+		if opt := r.IsEdns0(); opt != nil && opt.Do() {
+			m.AuthenticatedData = true
+			m.SetEdns0(4096, true)
+		}
+		w.WriteMsg(m)
+	})}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestDNSSECValidator(t *testing.T) {
+	records, anchor := newTestDNSSECRecords(t, time.Now().Add(48*time.Hour))
+	anchors, err := parseTrustAnchors([]string{anchor})
+	if err != nil {
+		t.Fatalf("cannot parse trust anchor: %s", err)
+	}
+
+	v := newDNSSECValidator(testDNSExchange(records), anchors, time.Now())
+	if err := v.validateAnswer(records["www.example./A"]); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d := time.Until(v.earliestExpiry); d < 47*time.Hour || d > 48*time.Hour {
+		t.Fatalf("unexpected earliest RRSIG expiry %s", v.earliestExpiry)
+	}
+
+	f := func(name string, answer []dns.RR, records map[string][]dns.RR, anchors []dns.RR, now time.Time) {
+		t.Helper()
+		v := newDNSSECValidator(testDNSExchange(records), anchors, now)
+		if err := v.validateAnswer(answer); err == nil {
+			t.Fatalf("%s: expecting non-nil error", name)
+		}
+	}
+
+	tampered := []dns.RR{mustNewRR(t, "www.example. 300 IN A 192.0.2.2"), records["www.example./A"][1]}
+	f("tampered answer", tampered, records, anchors, time.Now())
+	f("unsigned answer", records["www.example./A"][:1], records, anchors, time.Now())
+	f("expired signature", records["www.example./A"], records, anchors, time.Now().Add(72*time.Hour))
+	f("empty answer", nil, records, anchors, time.Now())
+
+	otherAnchors, err := parseTrustAnchors(nil)
+	if err != nil {
+		t.Fatalf("cannot parse default trust anchors: %s", err)
+	}
+	f("wrong trust anchor", records["www.example./A"], records, otherAnchors, time.Now())
+
+	noDS := make(map[string][]dns.RR)
+	for k, rrs := range records {
+		if k != "example./DS" {
+			noDS[k] = rrs
+		}
+	}
+	f("missing DS", records["www.example./A"], noDS, anchors, time.Now())
+
+	if _, err := parseTrustAnchors([]string{"example. IN A 192.0.2.1"}); err == nil {
+		t.Fatalf("expecting non-nil error for trust anchor which is not DS or DNSKEY")
+	}
+}
+
+func TestProbeDNSSEC(t *testing.T) {
+	records, anchor := newTestDNSSECRecords(t, time.Now().Add(48*time.Hour))
+	addr := startTestDNSServer(t, records)
+
+	f := func(config string, wantSuccess bool) *prometheus.Registry {
+		t.Helper()
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		registry := prometheus.NewRegistry()
+		if success := ProbeDNS(ctx, addr, module, registry); success != wantSuccess {
+			t.Fatalf("unexpected probe result for config %s; got %v; want %v", config, success, wantSuccess)
+		}
+		return registry
+	}
+
+	registry := f(fmt.Sprintf(`{prober: dns, dns: {query_name: www.example, query_type: A, dnssec: {validate: true, trust_anchors: [%q]}}}`, anchor), true)
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("cannot gather metrics: %s", err)
+	}
+	values := make(map[string]float64)
+	for _, mf := range mfs {
+		values[mf.GetName()] = mf.GetMetric()[0].GetGauge().GetValue()
+	}
+	if values["probe_dns_dnssec_valid"] != 1 || values["probe_dns_dnssec_authenticated"] != 1 {
+		t.Fatalf("unexpected dnssec metrics: %v", values)
+	}
+	if expiry := values["probe_dns_rrsig_expiry_timestamp_seconds"]; expiry < float64(time.Now().Add(47*time.Hour).Unix()) {
+		t.Fatalf("unexpected probe_dns_rrsig_expiry_timestamp_seconds %v", expiry)
+	}
+
+	f(`{prober: dns, dns: {query_name: www.example, query_type: A, dnssec: {enabled: true}}}`, true)
+	f(`{prober: dns, dns: {query_name: www.example, query_type: A, dnssec: {validate: true}}}`, false)
+
+	var module Module
+	if err := yaml.Unmarshal([]byte(`{dns: {query_name: example, dnssec: {trust_anchors: ["invalid"]}}}`), &module); err == nil {
+		t.Fatalf("expecting non-nil error for invalid trust anchor")
+	}
+}
+
+func TestProbeDNSConsistency(t *testing.T) {
+	a := func(ip string) map[string][]dns.RR {
+		return map[string][]dns.RR{
+			"www.example./A": {mustNewRR(t, "www.example. 300 IN A "+ip), mustNewRR(t, "www.example. 300 IN A 192.0.2.10")},
+		}
+	}
+	soa := func(serial int) map[string][]dns.RR {
+		return map[string][]dns.RR{
+			"example./SOA": {mustNewRR(t, fmt.Sprintf("example. 3600 IN SOA ns1.example. admin.example. %d 7200 900 1209600 300", serial))},
+		}
+	}
+	target := startTestDNSServer(t, a("192.0.2.1"))
+	// 记录的顺序、大小写和 TTL 不影响比较
+	same := startTestDNSServer(t, map[string][]dns.RR{
+		"www.example./A": {mustNewRR(t, "WWW.example. 60 IN A 192.0.2.10"), mustNewRR(t, "www.example. 60 IN A 192.0.2.1")},
+	})
+	different := startTestDNSServer(t, a("192.0.2.2"))
+	soaTarget := startTestDNSServer(t, soa(2024010101))
+	soaLagging := startTestDNSServer(t, soa(2024010100))
+
+	f := func(target, config string, wantSuccess bool) {
+		t.Helper()
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if success := ProbeDNS(ctx, target, module, prometheus.NewRegistry()); success != wantSuccess {
+			t.Fatalf("unexpected probe result for config %s; got %v; want %v", config, success, wantSuccess)
+		}
+	}
+
+	f(target, fmt.Sprintf(`{prober: dns, dns: {query_name: www.example, query_type: A, consistency: {servers: [%q], fail_if_inconsistent: true}}}`, same), true)
+	f(target, fmt.Sprintf(`{prober: dns, dns: {query_name: www.example, query_type: A, consistency: {servers: [%q, %q], fail_if_inconsistent: true}}}`, same, different), false)
+	f(target, fmt.Sprintf(`{prober: dns, dns: {query_name: www.example, query_type: A, consistency: {servers: [%q]}}}`, different), true)
+	f(soaTarget, fmt.Sprintf(`{prober: dns, dns: {query_name: example, query_type: SOA, consistency: {servers: [%q], fail_if_inconsistent: true}}}`, soaLagging), false)
+	f(soaTarget, fmt.Sprintf(`{prober: dns, dns: {query_name: example, query_type: SOA, consistency: {servers: [%q], max_soa_serial_drift: 1, fail_if_inconsistent: true}}}`, soaLagging), true)
+}
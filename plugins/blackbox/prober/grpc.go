@@ -185,16 +185,27 @@ func ProbeGRPC(ctx context.Context, target string, module Module, registry *prom
 		logger.Errorf("cannot dial grpc server(%s): %v", target, err)
 	}
 
-	client := NewGrpcHealthCheckClient(conn)
 	defer conn.Close()
-	ok, statusCode, serverPeer, servingStatus, err := client.Check(context.Background(), module.GRPC.Service)
-	durationGaugeVec.WithLabelValues("check").Add(time.Since(checkStart).Seconds())
 
-	for servingStatusName := range grpc_health_v1.HealthCheckResponse_ServingStatus_value {
-		healthCheckResponseGaugeVec.WithLabelValues(servingStatusName).Set(float64(0))
-	}
-	if servingStatus != "" {
-		healthCheckResponseGaugeVec.WithLabelValues(servingStatus).Set(float64(1))
+	var (
+		ok         bool
+		statusCode codes.Code
+		serverPeer *peer.Peer
+	)
+	if module.GRPC.Method != "" {
+		ok, statusCode, serverPeer, err = invokeGRPCMethod(ctx, conn, module, durationGaugeVec, registry)
+	} else {
+		client := NewGrpcHealthCheckClient(conn)
+		var servingStatus string
+		ok, statusCode, serverPeer, servingStatus, err = client.Check(context.Background(), module.GRPC.Service)
+		durationGaugeVec.WithLabelValues("check").Add(time.Since(checkStart).Seconds())
+
+		for servingStatusName := range grpc_health_v1.HealthCheckResponse_ServingStatus_value {
+			healthCheckResponseGaugeVec.WithLabelValues(servingStatusName).Set(float64(0))
+		}
+		if servingStatus != "" {
+			healthCheckResponseGaugeVec.WithLabelValues(servingStatus).Set(float64(1))
+		}
 	}
 
 	if serverPeer != nil {
@@ -211,7 +222,11 @@ func ProbeGRPC(ctx context.Context, target string, module Module, registry *prom
 	statusCodeGauge.Set(float64(statusCode))
 
 	if !ok || err != nil {
-		logger.Errorf("can't connect grpc server(%s): %v", target, err)
+		if module.GRPC.Method != "" {
+			logger.Errorf("failed to invoke grpc method %s on server(%s): %v", module.GRPC.Method, target, err)
+		} else {
+			logger.Errorf("can't connect grpc server(%s): %v", target, err)
+		}
 		success = false
 	} else {
 		success = true
@@ -0,0 +1,274 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// splitGRPCMethod 把 package.Service/Method 拆成 service 和 method
+func splitGRPCMethod(fullMethod string) (string, string, error) {
+	service, method, found := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !found || service == "" || method == "" || strings.Contains(method, "/") {
+		return "", "", fmt.Errorf("invalid grpc method %q, expected format is package.Service/Method", fullMethod)
+	}
+	return service, method, nil
+}
+
+// parseGRPCStatusCode 解析 OK、NOT_FOUND 这样的状态码名字，也支持数字
+func parseGRPCStatusCode(name string) (codes.Code, error) {
+	var c codes.Code
+	if n, err := strconv.ParseUint(name, 10, 32); err == nil {
+		if err := c.UnmarshalJSON([]byte(strconv.FormatUint(n, 10))); err != nil {
+			return 0, fmt.Errorf("invalid grpc status code %q: %w", name, err)
+		}
+		return c, nil
+	}
+	if err := c.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(name)))); err != nil {
+		return 0, fmt.Errorf("invalid grpc status code %q: %w", name, err)
+	}
+	return c, nil
+}
+
+func validGRPCStatusCode(code codes.Code, valid []string) bool {
+	if len(valid) == 0 {
+		return code == codes.OK
+	}
+	for _, name := range valid {
+		if c, err := parseGRPCStatusCode(name); err == nil && c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// invokeGRPCMethod 调用 module.GRPC.Method，按照 valid_status_codes 和 fail_if_json_not_matches 校验结果
+func invokeGRPCMethod(ctx context.Context, conn *grpc.ClientConn, module Module, durationGaugeVec *prometheus.GaugeVec, registry *prometheus.Registry) (bool, codes.Code, *peer.Peer, error) {
+	probeFailedDueToJSON := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_failed_due_to_json",
+		Help: "Indicates if probe failed due to fail_if_json_not_matches",
+	})
+	registry.MustRegister(probeFailedDueToJSON)
+
+	grpcConfig := module.GRPC
+	service, method, err := splitGRPCMethod(grpcConfig.Method)
+	if err != nil {
+		return false, codes.Unknown, nil, err
+	}
+
+	// grpc.Dial 是异步建连的，这里等连接建立好再开始计时，这样 invoke 阶段只包含请求本身
+	connectStart := time.Now()
+	conn.Connect()
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if state == connectivity.TransientFailure || !conn.WaitForStateChange(ctx, state) {
+			break
+		}
+	}
+	durationGaugeVec.WithLabelValues("connect").Add(time.Since(connectStart).Seconds())
+
+	if len(grpcConfig.Metadata) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(grpcConfig.Metadata))
+	}
+
+	resolveStart := time.Now()
+	md, err := resolveGRPCMethod(ctx, conn, service, method, grpcConfig.DescriptorSetFile, module.BaseDir)
+	durationGaugeVec.WithLabelValues("reflection").Add(time.Since(resolveStart).Seconds())
+	if err != nil {
+		return false, status.Code(err), nil, err
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return false, codes.Unknown, nil, fmt.Errorf("grpc method %s is a streaming method, only unary methods are supported", grpcConfig.Method)
+	}
+
+	in := dynamicpb.NewMessage(md.Input())
+	if grpcConfig.Request != "" {
+		if err := protojson.Unmarshal([]byte(grpcConfig.Request), in); err != nil {
+			return false, codes.Unknown, nil, fmt.Errorf("cannot parse request as %s: %w", md.Input().FullName(), err)
+		}
+	}
+	out := dynamicpb.NewMessage(md.Output())
+
+	serverPeer := new(peer.Peer)
+	invokeStart := time.Now()
+	err = conn.Invoke(ctx, "/"+service+"/"+method, in, out, grpc.Peer(serverPeer))
+	durationGaugeVec.WithLabelValues("invoke").Add(time.Since(invokeStart).Seconds())
+	if serverPeer.Addr == nil {
+		serverPeer = nil
+	}
+
+	code := status.Code(err)
+	if !validGRPCStatusCode(code, grpcConfig.ValidStatusCodes) {
+		if err == nil {
+			err = fmt.Errorf("status code %s is not one of valid_status_codes %v", code, grpcConfig.ValidStatusCodes)
+		}
+		return false, code, serverPeer, err
+	}
+	if err != nil {
+		// 状态码符合预期，但是没有响应消息可以用来校验 JSON
+		if len(grpcConfig.FailIfJSONNotMatches) > 0 {
+			probeFailedDueToJSON.Set(1)
+			return false, code, serverPeer, errors.New("cannot match fail_if_json_not_matches against an error response")
+		}
+		return true, code, serverPeer, nil
+	}
+
+	if len(grpcConfig.FailIfJSONNotMatches) > 0 {
+		body, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(out)
+		if err != nil {
+			probeFailedDueToJSON.Set(1)
+			return false, code, serverPeer, fmt.Errorf("cannot encode response as JSON: %w", err)
+		}
+		data, err := unmarshalJSONBody(body)
+		if err != nil {
+			probeFailedDueToJSON.Set(1)
+			return false, code, serverPeer, err
+		}
+		for _, m := range grpcConfig.FailIfJSONNotMatches {
+			v, err := extractJSONPath(data, m.Path)
+			if err == nil {
+				err = m.match(v)
+			}
+			if err != nil {
+				probeFailedDueToJSON.Set(1)
+				return false, code, serverPeer, fmt.Errorf("unexpected value at jsonpath %q: %w", m.Path, err)
+			}
+		}
+	}
+	return true, code, serverPeer, nil
+}
+
+// resolveGRPCMethod 从描述文件或者服务端反射中找到方法的定义
+func resolveGRPCMethod(ctx context.Context, conn *grpc.ClientConn, service, method, descriptorSetFile, baseDir string) (protoreflect.MethodDescriptor, error) {
+	var fds *descriptorpb.FileDescriptorSet
+	var err error
+	if descriptorSetFile != "" {
+		if !filepath.IsAbs(descriptorSetFile) {
+			descriptorSetFile = filepath.Join(baseDir, descriptorSetFile)
+		}
+		fds, err = loadDescriptorSetFile(descriptorSetFile)
+	} else {
+		fds, err = fetchFileDescriptorsByReflection(ctx, conn, service)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, fmt.Errorf("cannot build file descriptors: %w", err)
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("cannot find grpc service %s: %w", service, err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a grpc service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, fmt.Errorf("grpc service %s has no method %s", service, method)
+	}
+	return md, nil
+}
+
+func loadDescriptorSetFile(path string) (*descriptorpb.FileDescriptorSet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read descriptor_set_file: %w", err)
+	}
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(b, &fds); err != nil {
+		return nil, fmt.Errorf("cannot parse descriptor_set_file %s: %w", path, err)
+	}
+	return &fds, nil
+}
+
+// fetchFileDescriptorsByReflection 通过服务端反射获取定义 service 的文件，以及它依赖的所有文件
+func fetchFileDescriptorsByReflection(ctx context.Context, conn *grpc.ClientConn, service string) (*descriptorpb.FileDescriptorSet, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open reflection stream: %w", err)
+	}
+	defer stream.CloseSend()
+
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+	var order []string
+	request := func(req *rpb.ServerReflectionRequest) error {
+		if err := stream.Send(req); err != nil {
+			return fmt.Errorf("cannot send reflection request: %w", err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("cannot receive reflection response: %w", err)
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return status.Error(codes.Code(e.GetErrorCode()), e.GetErrorMessage())
+		}
+		for _, b := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := new(descriptorpb.FileDescriptorProto)
+			if err := proto.Unmarshal(b, fd); err != nil {
+				return fmt.Errorf("cannot parse file descriptor from reflection: %w", err)
+			}
+			if _, has := files[fd.GetName()]; !has {
+				files[fd.GetName()] = fd
+				order = append(order, fd.GetName())
+			}
+		}
+		return nil
+	}
+
+	err = request(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve grpc service %s by reflection: %w", service, err)
+	}
+
+	// 服务端可能只返回了部分依赖，缺少的按照文件名补齐
+	for i := 0; i < len(order); i++ {
+		for _, dep := range files[order[i]].GetDependency() {
+			if _, has := files[dep]; has {
+				continue
+			}
+			err := request(&rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("cannot resolve file %s by reflection: %w", dep, err)
+			}
+			if _, has := files[dep]; !has {
+				return nil, fmt.Errorf("server reflection didn't return file %s", dep)
+			}
+		}
+	}
+
+	fds := &descriptorpb.FileDescriptorSet{}
+	for _, name := range order {
+		fds.File = append(fds.File, files[name])
+	}
+	return fds, nil
+}
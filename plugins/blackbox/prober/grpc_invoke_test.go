@@ -0,0 +1,132 @@
+package prober
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// metadataHealthServer 要求请求带上 x-token: secret，否则返回 UNAUTHENTICATED
+type metadataHealthServer struct {
+	*health.Server
+}
+
+func (s metadataHealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("x-token"); len(v) == 0 || v[0] != "secret" {
+		return nil, status.Error(codes.Unauthenticated, "missing token")
+	}
+	return s.Server.Check(ctx, req)
+}
+
+func startTestGRPCServer(t *testing.T, withReflection bool) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen tcp: %s", err)
+	}
+	hs := health.NewServer()
+	hs.SetServingStatus("greeter", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	s := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(s, metadataHealthServer{hs})
+	if withReflection {
+		reflection.Register(s)
+	}
+	go s.Serve(ln)
+	t.Cleanup(s.Stop)
+	return ln.Addr().String()
+}
+
+func TestProbeGRPCMethod(t *testing.T) {
+	f := func(target, config, baseDir string, wantSuccess bool) {
+		t.Helper()
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		module.BaseDir = baseDir
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if success := ProbeGRPC(ctx, target, module, prometheus.NewRegistry()); success != wantSuccess {
+			t.Fatalf("unexpected probe result for config %s; got %v; want %v", config, success, wantSuccess)
+		}
+	}
+
+	withReflection := startTestGRPCServer(t, true)
+	const method = `method: grpc.health.v1.Health/Check, metadata: {x-token: secret}`
+	f(withReflection, `{prober: grpc, grpc: {`+method+`, fail_if_json_not_matches: [{path: "{.status}", op: "==", value: SERVING}]}}`, "", true)
+	f(withReflection, `{prober: grpc, grpc: {`+method+`, request: '{"service": "greeter"}', fail_if_json_not_matches: [{path: "{.status}", op: "==", value: SERVING}]}}`, "", false)
+	f(withReflection, `{prober: grpc, grpc: {`+method+`, request: '{"service": "unknown"}'}}`, "", false)
+	f(withReflection, `{prober: grpc, grpc: {`+method+`, request: '{"service": "unknown"}', valid_status_codes: [NOT_FOUND]}}`, "", true)
+	f(withReflection, `{prober: grpc, grpc: {method: grpc.health.v1.Health/Check}}`, "", false)
+	f(withReflection, `{prober: grpc, grpc: {method: grpc.health.v1.Health/Watch}}`, "", false)
+	f(withReflection, `{prober: grpc, grpc: {method: grpc.health.v1.Health/Missing}}`, "", false)
+	f(withReflection, `{prober: grpc, grpc: {`+method+`, request: '{"unknown_field": 1}'}}`, "", false)
+
+	// 没有反射的服务端需要通过 descriptor_set_file 提供方法的定义
+	withoutReflection := startTestGRPCServer(t, false)
+	f(withoutReflection, `{prober: grpc, grpc: {`+method+`}}`, "", false)
+
+	fds := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(grpc_health_v1.File_grpc_health_v1_health_proto),
+	}}
+	b, err := proto.Marshal(fds)
+	if err != nil {
+		t.Fatalf("cannot marshal descriptor set: %s", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "health.protoset"), b, 0o644); err != nil {
+		t.Fatalf("cannot write descriptor set: %s", err)
+	}
+	f(withoutReflection, `{prober: grpc, grpc: {`+method+`, descriptor_set_file: health.protoset, fail_if_json_not_matches: [{path: "{.status}", regexp: "^SERVING$"}]}}`, dir, true)
+	f(withoutReflection, `{prober: grpc, grpc: {`+method+`, descriptor_set_file: missing.protoset}}`, dir, false)
+
+	for _, config := range []string{
+		`{grpc: {method: Check}}`,
+		`{grpc: {method: grpc.health.v1.Health/Check, request: "{"}}`,
+		`{grpc: {method: grpc.health.v1.Health/Check, valid_status_codes: [NOPE]}}`,
+		`{grpc: {request: "{}"}}`,
+	} {
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err == nil {
+			t.Fatalf("expecting non-nil error for config %s", config)
+		}
+	}
+}
+
+func TestParseGRPCStatusCode(t *testing.T) {
+	for name, want := range map[string]codes.Code{
+		"OK":          codes.OK,
+		"not_found":   codes.NotFound,
+		"5":           codes.NotFound,
+		"UNAVAILABLE": codes.Unavailable,
+	} {
+		got, err := parseGRPCStatusCode(name)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %s", name, err)
+		}
+		if got != want {
+			t.Fatalf("unexpected code for %q; got %s; want %s", name, got, want)
+		}
+	}
+	if _, err := parseGRPCStatusCode(fmt.Sprint(100)); err == nil {
+		t.Fatalf("expecting non-nil error for unknown code")
+	}
+}
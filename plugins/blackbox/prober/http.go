@@ -40,12 +40,7 @@ import (
 	"golang.org/x/net/publicsuffix"
 )
 
-func matchRegularExpressions(reader io.Reader, httpConfig HTTPProbe) bool {
-	body, err := io.ReadAll(reader)
-	if err != nil {
-		logger.Errorf("error reading HTTP body: %v", err)
-		return false
-	}
+func matchRegularExpressions(body []byte, httpConfig HTTPProbe) bool {
 	for _, expression := range httpConfig.FailIfBodyMatchesRegexp {
 		if expression.Regexp.Match(body) {
 			logger.Errorf("body matched regular expression: %v", expression)
@@ -281,6 +276,21 @@ func ProbeHTTP(ctx context.Context, target string, module Module, registry *prom
 			Help: "Indicates if probe failed due to regex",
 		})
 
+		probeFailedDueToJSON = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_failed_due_to_json",
+			Help: "Indicates if probe failed due to fail_if_json_not_matches",
+		})
+
+		probeFailedDueToXPath = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_failed_due_to_xpath",
+			Help: "Indicates if probe failed due to fail_if_xpath_not_matches",
+		})
+
+		probeHTTPJSONValue = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_http_json_value",
+			Help: "Numeric values extracted from the JSON response body by json_values",
+		}, []string{"name"})
+
 		probeHTTPLastModified = prometheus.NewGauge(prometheus.GaugeOpts{
 			Name: "probe_http_last_modified_timestamp_seconds",
 			Help: "Returns the Last-Modified HTTP response header in unixtime",
@@ -343,6 +353,7 @@ func ProbeHTTP(ctx context.Context, target string, module Module, registry *prom
 		return false
 	}
 
+	serverName := httpClientConfig.TLSConfig.ServerName
 	httpClientConfig.TLSConfig.ServerName = ""
 	noServerName, err := pconfig.NewRoundTripperFromConfig(httpClientConfig, "http_probe", pconfig.WithKeepAlivesDisabled())
 	if err != nil {
@@ -362,6 +373,30 @@ func ProbeHTTP(ctx context.Context, target string, module Module, registry *prom
 	tt := newTransport(client.Transport, noServerName)
 	client.Transport = tt
 
+	if httpConfig.HTTP3.Enabled {
+		if targetURL.Scheme != "https" {
+			logger.Errorf("http3 requires an https target, got %s", target)
+			return false
+		}
+		h3State := &http3State{}
+		h3Config := httpClientConfig
+		h3Config.TLSConfig.ServerName = serverName
+		h3, err := newHTTP3Transport(h3Config, tt.Transport, httpConfig, h3State)
+		if err != nil {
+			logger.Errorf("error generating HTTP/3 transport: %v", err)
+			return false
+		}
+		defer h3.Close()
+		h3NoServerName, err := newHTTP3Transport(httpClientConfig, tt.NoServerNameTransport, httpConfig, h3State)
+		if err != nil {
+			logger.Errorf("error generating HTTP/3 transport without ServerName: %v", err)
+			return false
+		}
+		defer h3NoServerName.Close()
+		tt.Transport, tt.NoServerNameTransport = h3, h3NoServerName
+		defer reportHTTP3State(h3State, registry)
+	}
+
 	client.CheckRedirect = func(r *http.Request, via []*http.Request) error {
 		redirects = len(via)
 		if redirects > 10 || !httpConfig.HTTPClientConfig.FollowRedirects {
@@ -523,8 +558,23 @@ func ProbeHTTP(ctx context.Context, target string, module Module, registry *prom
 
 		byteCounter := &byteCounter{ReadCloser: resp.Body}
 
-		if success && (len(httpConfig.FailIfBodyMatchesRegexp) > 0 || len(httpConfig.FailIfBodyNotMatchesRegexp) > 0) {
-			success = matchRegularExpressions(byteCounter, httpConfig)
+		hasRegexpMatchers := len(httpConfig.FailIfBodyMatchesRegexp) > 0 || len(httpConfig.FailIfBodyNotMatchesRegexp) > 0
+		hasJSONMatchers := len(httpConfig.FailIfJSONNotMatches) > 0
+		hasXPathMatchers := len(httpConfig.FailIfXPathNotMatches) > 0
+		hasJSONValues := len(httpConfig.JSONValues) > 0
+
+		// body 只读一次，给正则、JSONPath、XPath 校验以及 json_values 共用
+		var respBody []byte
+		if !requestErrored && (hasRegexpMatchers || hasJSONMatchers || hasXPathMatchers || hasJSONValues) {
+			respBody, err = io.ReadAll(byteCounter)
+			if err != nil {
+				logger.Errorf("error reading HTTP body: %v", err)
+				success = false
+			}
+		}
+
+		if success && hasRegexpMatchers {
+			success = matchRegularExpressions(respBody, httpConfig)
 			if success {
 				probeFailedDueToRegex.Set(0)
 			} else {
@@ -532,6 +582,42 @@ func ProbeHTTP(ctx context.Context, target string, module Module, registry *prom
 			}
 		}
 
+		var jsonData interface{}
+		jsonParsed := false
+		if (success && hasJSONMatchers) || (respBody != nil && hasJSONValues) {
+			jsonData, err = unmarshalJSONBody(respBody)
+			if err != nil {
+				logger.Errorf("%v", err)
+			} else {
+				jsonParsed = true
+			}
+		}
+
+		if success && hasJSONMatchers {
+			registry.MustRegister(probeFailedDueToJSON)
+			success = jsonParsed && matchJSONPath(jsonData, httpConfig)
+			if success {
+				probeFailedDueToJSON.Set(0)
+			} else {
+				probeFailedDueToJSON.Set(1)
+			}
+		}
+
+		if success && hasXPathMatchers {
+			registry.MustRegister(probeFailedDueToXPath)
+			success = matchXPath(respBody, httpConfig)
+			if success {
+				probeFailedDueToXPath.Set(0)
+			} else {
+				probeFailedDueToXPath.Set(1)
+			}
+		}
+
+		if jsonParsed && hasJSONValues {
+			registry.MustRegister(probeHTTPJSONValue)
+			extractJSONValues(jsonData, httpConfig, probeHTTPJSONValue)
+		}
+
 		if !requestErrored {
 			_, err = io.Copy(io.Discard, byteCounter)
 			if err != nil {
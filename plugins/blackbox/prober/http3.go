@@ -0,0 +1,238 @@
+package prober
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	pconfig "github.com/prometheus/common/config"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// http3SessionCache 在探测之间保存 TLS session ticket，开启 enable_0rtt 的时候用于 0-RTT
+var http3SessionCache = tls.NewLRUClientSessionCache(1024)
+
+// quicDialError 表示 QUIC 握手失败，这时候请求还没有发出去，可以安全地改用 TCP 重试
+type quicDialError struct {
+	err error
+}
+
+func (e *quicDialError) Error() string {
+	return "cannot establish QUIC connection: " + e.err.Error()
+}
+
+func (e *quicDialError) Unwrap() error {
+	return e.err
+}
+
+// http3State 记录最后一个 QUIC 连接的信息，探测结束后用来上报指标
+type http3State struct {
+	mu                sync.Mutex
+	conn              quic.EarlyConnection
+	handshakeDuration time.Duration
+	fellBack          bool
+}
+
+type http3DialInfoKey struct{}
+
+// http3DialInfo 随请求的 context 传给 dial，用来等待 0-RTT 请求的握手完成
+type http3DialInfo struct {
+	handshakeDone chan struct{}
+}
+
+// http3Transport 通过 QUIC 发送 https 请求，http 请求以及回退的时候使用 tcp
+type http3Transport struct {
+	h3         *http3.RoundTripper
+	quic       http.RoundTripper
+	tcp        http.RoundTripper
+	fallback   bool
+	enable0RTT bool
+	state      *http3State
+}
+
+func newHTTP3Transport(httpClientConfig pconfig.HTTPClientConfig, tcp http.RoundTripper, httpConfig HTTPProbe, state *http3State) (*http3Transport, error) {
+	tlsConfig, err := pconfig.NewTLSConfig(&httpClientConfig.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	if httpConfig.HTTP3.Enable0RTT {
+		tlsConfig.ClientSessionCache = http3SessionCache
+	}
+
+	t := &http3Transport{
+		tcp:        tcp,
+		fallback:   httpConfig.HTTP3.Fallback,
+		enable0RTT: httpConfig.HTTP3.Enable0RTT,
+		state:      state,
+	}
+	t.h3 = &http3.RoundTripper{
+		TLSClientConfig: tlsConfig,
+		QuicConfig:      &quic.Config{},
+		Dial:            t.dial,
+	}
+
+	// 和 pconfig.NewRoundTripperFromConfig 一样加上认证信息，bearer_token 在 Validate 的时候已经转换成了 authorization
+	t.quic = t.h3
+	if cfg := httpClientConfig.Authorization; cfg != nil && len(cfg.Credentials) > 0 {
+		t.quic = pconfig.NewAuthorizationCredentialsRoundTripper(cfg.Type, cfg.Credentials, t.quic)
+	} else if cfg != nil && len(cfg.CredentialsFile) > 0 {
+		t.quic = pconfig.NewAuthorizationCredentialsFileRoundTripper(cfg.Type, cfg.CredentialsFile, t.quic)
+	}
+	if cfg := httpClientConfig.BasicAuth; cfg != nil {
+		t.quic = pconfig.NewBasicAuthRoundTripper(cfg.Username, cfg.Password, cfg.UsernameFile, cfg.PasswordFile, t.quic)
+	}
+	return t, nil
+}
+
+func (t *http3Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "https" {
+		return t.tcp.RoundTrip(req)
+	}
+
+	info := &http3DialInfo{}
+	h3req := req.WithContext(context.WithValue(req.Context(), http3DialInfoKey{}, info))
+	if t.enable0RTT && req.Method == http.MethodGet {
+		h3req.Method = http3.MethodGet0RTT
+	}
+
+	trace := httptrace.ContextClientTrace(req.Context())
+	// 复用已有连接的时候不会调用 dial，这里先记下 gotConn，新建连接的时候会在 dial 中覆盖
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{})
+	}
+
+	resp, err := t.quic.RoundTrip(h3req)
+	var dialErr *quicDialError
+	if err != nil && t.fallback && errors.As(err, &dialErr) {
+		logger.Warnf("%s, falling back to TCP", err)
+		t.state.mu.Lock()
+		t.state.fellBack = true
+		t.state.mu.Unlock()
+		return t.tcp.RoundTrip(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 0-RTT 的请求在握手完成之前就发出去了，等握手完成之后再返回，保证握手的耗时已经记录下来
+	if info.handshakeDone != nil {
+		select {
+		case <-info.handshakeDone:
+		case <-req.Context().Done():
+		}
+	}
+	// quic-go 不支持 httptrace，拿到响应头的时候就是首字节的时间
+	if trace != nil && trace.GotFirstResponseByte != nil {
+		trace.GotFirstResponseByte()
+	}
+	return resp, nil
+}
+
+// dial 建立 QUIC 连接，并按照 net/http 的顺序调用 httptrace 的回调。
+// QUIC 没有单独的建连过程，connect 阶段只有创建 socket 的时间，握手的耗时记在 tls 阶段
+func (t *http3Transport) dial(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.ConnectStart != nil {
+		trace.ConnectStart("udp", addr)
+	}
+	if trace != nil && trace.ConnectDone != nil {
+		trace.ConnectDone("udp", addr, nil)
+	}
+	if trace != nil && trace.TLSHandshakeStart != nil {
+		trace.TLSHandshakeStart()
+	}
+
+	dialCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok && t.fallback {
+		// UDP 被拦截的时候握手只能等到超时，留一半的时间给 TCP 重试
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, time.Until(deadline)/2)
+		defer cancel()
+	}
+
+	start := time.Now()
+	conn, err := quic.DialAddrEarly(dialCtx, addr, tlsCfg, cfg)
+	if err != nil {
+		return nil, &quicDialError{err: err}
+	}
+	if trace != nil && trace.GotConn != nil {
+		trace.GotConn(httptrace.GotConnInfo{})
+	}
+
+	t.state.mu.Lock()
+	t.state.conn = conn
+	t.state.mu.Unlock()
+
+	handshakeDone := make(chan struct{})
+	if info, ok := ctx.Value(http3DialInfoKey{}).(*http3DialInfo); ok {
+		info.handshakeDone = handshakeDone
+	}
+	go func() {
+		defer close(handshakeDone)
+		var err error
+		select {
+		case <-conn.HandshakeComplete():
+			t.state.mu.Lock()
+			t.state.handshakeDuration = time.Since(start)
+			t.state.mu.Unlock()
+		case <-conn.Context().Done():
+			err = context.Cause(conn.Context())
+		}
+		if trace != nil && trace.TLSHandshakeDone != nil {
+			trace.TLSHandshakeDone(conn.ConnectionState().TLS, err)
+		}
+	}()
+	return conn, nil
+}
+
+func (t *http3Transport) Close() error {
+	return t.h3.Close()
+}
+
+// reportHTTP3State 上报 QUIC 握手耗时、协商的 ALPN 和是否使用了 0-RTT
+func reportHTTP3State(state *http3State, registry *prometheus.Registry) {
+	probeHTTP3FallbackGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_http3_fallback",
+		Help: "Indicates if the QUIC handshake failed and the request fell back to TCP",
+	})
+	registry.MustRegister(probeHTTP3FallbackGauge)
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.fellBack {
+		probeHTTP3FallbackGauge.Set(1)
+	}
+	if state.conn == nil {
+		return
+	}
+
+	var (
+		probeHTTP3HandshakeGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_http3_quic_handshake_seconds",
+			Help: "Duration of the QUIC handshake of the last QUIC connection",
+		})
+		probeHTTP3Used0RTTGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_http3_0rtt_used",
+			Help: "Indicates if 0-RTT resumption was used by the last QUIC connection",
+		})
+		probeHTTP3InfoGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_http3_info",
+			Help: "Contains the negotiated ALPN and QUIC version of the last QUIC connection",
+		}, []string{"alpn", "quic_version"})
+	)
+	registry.MustRegister(probeHTTP3HandshakeGauge, probeHTTP3Used0RTTGauge, probeHTTP3InfoGauge)
+
+	cs := state.conn.ConnectionState()
+	probeHTTP3HandshakeGauge.Set(state.handshakeDuration.Seconds())
+	if cs.Used0RTT {
+		probeHTTP3Used0RTTGauge.Set(1)
+	}
+	probeHTTP3InfoGauge.WithLabelValues(cs.TLS.NegotiatedProtocol, cs.Version.String()).Set(1)
+}
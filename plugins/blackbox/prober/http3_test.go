@@ -0,0 +1,162 @@
+package prober
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// startTestHTTP3Server 在同一个端口上启动 HTTP/3 和 HTTPS 服务，withQUIC 为 false 的时候只有 HTTPS
+func startTestHTTP3Server(t *testing.T, leaf, ca *testCert, withQUIC bool) string {
+	t.Helper()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "probe" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, "hello from %s", r.Proto)
+	})
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{leaf.cert.Raw, ca.cert.Raw},
+			PrivateKey:  leaf.key,
+		}},
+	}
+
+	ts := httptest.NewUnstartedServer(handler)
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	addr := ts.Listener.Addr().String()
+	if !withQUIC {
+		return addr
+	}
+
+	pc, err := net.ListenPacket("udp4", addr)
+	if err != nil {
+		t.Fatalf("cannot listen udp: %s", err)
+	}
+	srv := &http3.Server{
+		Handler:    handler,
+		TLSConfig:  http3.ConfigureTLSConfig(tlsConfig),
+		QuicConfig: &quic.Config{Allow0RTT: true},
+	}
+	go srv.Serve(pc)
+	t.Cleanup(func() {
+		srv.Close()
+		pc.Close()
+	})
+	return addr
+}
+
+func probeHTTP3(t *testing.T, target, config string, timeout time.Duration) (bool, map[string]float64) {
+	t.Helper()
+	var module Module
+	if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+		t.Fatalf("cannot parse config: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	registry := prometheus.NewRegistry()
+	success := ProbeHTTP(ctx, target, module, registry)
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("cannot gather metrics: %s", err)
+	}
+	values := make(map[string]float64)
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			name := mf.GetName()
+			for _, l := range m.GetLabel() {
+				name += fmt.Sprintf(",%s=%s", l.GetName(), l.GetValue())
+			}
+			values[name] = m.GetGauge().GetValue()
+		}
+	}
+	return success, values
+}
+
+func TestProbeHTTP3(t *testing.T) {
+	ca := newTestCert(t, nil, "ca", time.Now().Add(24*time.Hour))
+	leaf := newTestCert(t, ca, "localhost", time.Now().Add(24*time.Hour))
+	caFile := writeCertFile(t, "ca.pem", ca)
+	withQUIC := startTestHTTP3Server(t, leaf, ca, true)
+	withoutQUIC := startTestHTTP3Server(t, leaf, ca, false)
+
+	common := fmt.Sprintf(`prober: http
+http:
+  tls_config: {ca_file: %q, server_name: localhost}
+  basic_auth: {username: probe, password: secret}
+  fail_if_body_not_matches_regexp: ["hello from HTTP/"]
+`, caFile)
+
+	success, values := probeHTTP3(t, "https://"+withQUIC, common+`  valid_http_versions: [HTTP/3.0]
+  http3: {enabled: true}
+`, 5*time.Second)
+	if !success {
+		t.Fatalf("unexpected probe failure; metrics: %v", values)
+	}
+	if values["probe_http_version"] != 3 || values["probe_http3_fallback"] != 0 || values["probe_http3_0rtt_used"] != 0 {
+		t.Fatalf("unexpected metrics: %v", values)
+	}
+	if values["probe_http3_info,alpn=h3,quic_version=v1"] != 1 {
+		t.Fatalf("unexpected probe_http3_info: %v", values)
+	}
+	if values["probe_http3_quic_handshake_seconds"] <= 0 || values["probe_http_duration_seconds,phase=tls"] <= 0 {
+		t.Fatalf("expecting non-zero handshake duration: %v", values)
+	}
+
+	// 第一次探测拿到 session ticket，第二次通过 0-RTT 发送请求
+	config0RTT := common + `  http3: {enabled: true, enable_0rtt: true}
+`
+	if success, values := probeHTTP3(t, "https://"+withQUIC, config0RTT, 5*time.Second); !success {
+		t.Fatalf("unexpected probe failure; metrics: %v", values)
+	}
+	success, values = probeHTTP3(t, "https://"+withQUIC, config0RTT, 5*time.Second)
+	if !success || values["probe_http3_0rtt_used"] != 1 {
+		t.Fatalf("expecting 0-RTT to be used; success: %v; metrics: %v", success, values)
+	}
+
+	// 没有 UDP 服务的时候，按照 fallback 决定是否改用 TCP
+	success, values = probeHTTP3(t, "https://"+withoutQUIC, common+`  http3: {enabled: true, fallback: true}
+`, 2*time.Second)
+	if !success || values["probe_http3_fallback"] != 1 || values["probe_http_version"] == 3 {
+		t.Fatalf("expecting fallback to TCP; success: %v; metrics: %v", success, values)
+	}
+	if _, ok := values["probe_http3_info,alpn=h3,quic_version=v1"]; ok {
+		t.Fatalf("unexpected probe_http3_info after fallback: %v", values)
+	}
+	if success, values := probeHTTP3(t, "https://"+withoutQUIC, common+`  http3: {enabled: true}
+`, time.Second); success {
+		t.Fatalf("expecting probe failure without fallback; metrics: %v", values)
+	}
+	if success, _ := probeHTTP3(t, "https://"+withoutQUIC, common+`  valid_http_versions: [HTTP/3.0]
+  http3: {enabled: true, fallback: true}
+`, 2*time.Second); success {
+		t.Fatalf("expecting probe failure for HTTP version after fallback")
+	}
+	if success, _ := probeHTTP3(t, "http://"+withQUIC, common+`  http3: {enabled: true}
+`, time.Second); success {
+		t.Fatalf("expecting probe failure for http target")
+	}
+
+	for _, config := range []string{
+		`{http: {http3: {enabled: true}, proxy_url: "http://127.0.0.1:3128"}}`,
+		`{http: {http3: {enabled: true}, oauth2: {client_id: id, client_secret: secret, token_url: "http://127.0.0.1/token"}}}`,
+	} {
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err == nil {
+			t.Fatalf("expecting non-nil error for config %s", config)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package prober
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	pconfig "github.com/prometheus/common/config"
+	"golang.org/x/net/publicsuffix"
+)
+
+// ProbeHTTPSteps 依次执行 http_steps 里的每个 step，前面 step 提取出来的变量可以在后面 step 的 url、headers、body 中引用
+// 某个 step 失败之后，后续的 step 不再执行
+func ProbeHTTPSteps(ctx context.Context, target string, module Module, registry *prometheus.Registry) (success bool) {
+	var (
+		stepDurationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_http_step_duration_seconds",
+			Help: "Duration of http request of the step, including reading the response body",
+		}, []string{"step"})
+		stepSuccessGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_http_step_success",
+			Help: "Displays whether or not the step was a success",
+		}, []string{"step"})
+		stepStatusCodeGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_http_step_status_code",
+			Help: "Response HTTP status code of the step",
+		}, []string{"step"})
+	)
+
+	registry.MustRegister(stepDurationGaugeVec)
+	registry.MustRegister(stepSuccessGaugeVec)
+	registry.MustRegister(stepStatusCodeGaugeVec)
+
+	stepsConfig := module.HTTPSteps
+
+	// 先把所有 step 置为失败，没执行到的 step 也能查到
+	for _, step := range stepsConfig.Steps {
+		stepSuccessGaugeVec.WithLabelValues(step.Name).Set(0)
+	}
+
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		target = "http://" + target
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		logger.Errorf("could not parse target URL(%s): %v", target, err)
+		return false
+	}
+
+	client, err := pconfig.NewClientFromConfig(stepsConfig.HTTPClientConfig, "http_steps_probe", pconfig.WithKeepAlivesDisabled())
+	if err != nil {
+		logger.Errorf("error generating HTTP client: %v", err)
+		return false
+	}
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		logger.Errorf("error generating cookiejar: %v", err)
+		return false
+	}
+	client.Jar = jar
+	if !stepsConfig.HTTPClientConfig.FollowRedirects {
+		client.CheckRedirect = func(r *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	vars := make(map[string]string, len(stepsConfig.Vars)+1)
+	for k, v := range stepsConfig.Vars {
+		vars[k] = v
+	}
+	vars["target"] = target
+
+	for _, step := range stepsConfig.Steps {
+		start := time.Now()
+		statusCode, err := runHTTPStep(ctx, client, targetURL, &step, int64(stepsConfig.BodySizeLimit), vars)
+		stepDurationGaugeVec.WithLabelValues(step.Name).Set(time.Since(start).Seconds())
+		stepStatusCodeGaugeVec.WithLabelValues(step.Name).Set(float64(statusCode))
+		if err != nil {
+			logger.Errorf("http step %q failed, target: %s, error: %v", step.Name, target, err)
+			return false
+		}
+		stepSuccessGaugeVec.WithLabelValues(step.Name).Set(1)
+	}
+
+	return true
+}
+
+// runHTTPStep 执行一个 step，返回响应码，校验不通过的时候返回 error，提取出来的变量直接写入 vars
+func runHTTPStep(ctx context.Context, client *http.Client, targetURL *url.URL, step *HTTPStep, bodySizeLimit int64, vars map[string]string) (int, error) {
+	rawURL, err := renderStepTemplate("url", step.URL, vars)
+	if err != nil {
+		return 0, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse url %q: %w", rawURL, err)
+	}
+	u = targetURL.ResolveReference(u)
+
+	var body io.Reader
+	if step.Body != "" {
+		s, err := renderStepTemplate("body", step.Body, vars)
+		if err != nil {
+			return 0, err
+		}
+		body = strings.NewReader(s)
+	}
+
+	method := step.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return 0, fmt.Errorf("cannot create request: %w", err)
+	}
+	for key, value := range step.Headers {
+		v, err := renderStepTemplate("header "+key, value, vars)
+		if err != nil {
+			return 0, err
+		}
+		if textproto.CanonicalMIMEHeaderKey(key) == "Host" {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(key, v)
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header.Set("User-Agent", userAgentDefaultHeader)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error performing request(%s): %w", u.String(), err)
+	}
+	defer resp.Body.Close()
+
+	var r io.Reader = resp.Body
+	if bodySizeLimit > 0 {
+		r = http.MaxBytesReader(nil, resp.Body, bodySizeLimit)
+	}
+	respBody, err := io.ReadAll(r)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("error reading HTTP response body: %w", err)
+	}
+
+	if len(step.ValidStatusCodes) != 0 {
+		valid := false
+		for _, code := range step.ValidStatusCodes {
+			if resp.StatusCode == code {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return resp.StatusCode, fmt.Errorf("invalid HTTP response status code(%d), valid_status_codes: %v", resp.StatusCode, step.ValidStatusCodes)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("invalid HTTP response status code(%d), expecting 2xx", resp.StatusCode)
+	}
+
+	for _, expression := range step.FailIfBodyMatchesRegexp {
+		if expression.Regexp.Match(respBody) {
+			return resp.StatusCode, fmt.Errorf("body matched regular expression: %v", expression)
+		}
+	}
+	for _, expression := range step.FailIfBodyNotMatchesRegexp {
+		if !expression.Regexp.Match(respBody) {
+			return resp.StatusCode, fmt.Errorf("body did not match regular expression: %v", expression)
+		}
+	}
+
+	// body 只在需要的时候解析一次 JSON
+	var jsonData interface{}
+	jsonParsed := false
+	getJSONData := func() (interface{}, error) {
+		if jsonParsed {
+			return jsonData, nil
+		}
+		data, err := unmarshalJSONBody(respBody)
+		if err != nil {
+			return nil, err
+		}
+		jsonData, jsonParsed = data, true
+		return data, nil
+	}
+
+	for _, m := range step.FailIfJSONNotMatches {
+		data, err := getJSONData()
+		if err != nil {
+			return resp.StatusCode, err
+		}
+		v, err := extractJSONPath(data, m.Path)
+		if err != nil {
+			return resp.StatusCode, err
+		}
+		if err := m.match(v); err != nil {
+			return resp.StatusCode, fmt.Errorf("unexpected value at jsonpath %q: %w", m.Path, err)
+		}
+	}
+
+	for _, e := range step.Extract {
+		var v string
+		switch e.From {
+		case "json":
+			data, err := getJSONData()
+			if err != nil {
+				return resp.StatusCode, err
+			}
+			if v, err = extractJSONPath(data, e.Path); err != nil {
+				return resp.StatusCode, fmt.Errorf("cannot extract %q: %w", e.Name, err)
+			}
+		case "header":
+			v = resp.Header.Get(e.Header)
+			if v == "" {
+				return resp.StatusCode, fmt.Errorf("cannot extract %q: missing header %q", e.Name, e.Header)
+			}
+		case "cookie":
+			found := false
+			for _, c := range resp.Cookies() {
+				if c.Name == e.Cookie {
+					v, found = c.Value, true
+					break
+				}
+			}
+			if !found && client.Jar != nil {
+				// 发生重定向的时候 cookie 可能是中间的响应设置的
+				for _, c := range client.Jar.Cookies(resp.Request.URL) {
+					if c.Name == e.Cookie {
+						v, found = c.Value, true
+						break
+					}
+				}
+			}
+			if !found {
+				return resp.StatusCode, fmt.Errorf("cannot extract %q: missing cookie %q", e.Name, e.Cookie)
+			}
+		case "regexp":
+			match := e.Regexp.FindSubmatch(respBody)
+			if match == nil {
+				return resp.StatusCode, fmt.Errorf("cannot extract %q: body did not match regular expression: %v", e.Name, e.Regexp)
+			}
+			if len(match) > 1 {
+				v = string(match[1])
+			} else {
+				v = string(match[0])
+			}
+		}
+		vars[e.Name] = v
+	}
+
+	return resp.StatusCode, nil
+}
+
+// renderStepTemplate 渲染 step 中的模板，引用不存在的变量时报错，避免拿着空 token 去请求
+func renderStepTemplate(name, text string, vars map[string]string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("cannot parse %s template %q: %w", name, text, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("cannot render %s template %q: %w", name, text, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,175 @@
+package prober
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestProbeHTTPSteps(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("X-User") != "monitor" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		w.Header().Set("X-Request-Id", "r1")
+		w.Write([]byte(`{"code": 0, "data": {"token": "t1"}}`))
+	})
+	mux.HandleFunc("/profile", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err != nil || c.Value != "s1" || r.Header.Get("Authorization") != "Bearer t1" || r.URL.Query().Get("rid") != "r1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`<user id="42">monitor</user>`))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	f := func(config string, wantSuccess bool, wantStepSuccess map[string]float64) {
+		t.Helper()
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		registry := prometheus.NewRegistry()
+		if success := ProbeHTTPSteps(ctx, ts.URL, module, registry); success != wantSuccess {
+			t.Fatalf("unexpected probe result; got %v; want %v", success, wantSuccess)
+		}
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("cannot gather metrics: %s", err)
+		}
+		got := make(map[string]float64)
+		for _, mf := range mfs {
+			if mf.GetName() != "probe_http_step_success" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				got[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+			}
+		}
+		for step, want := range wantStepSuccess {
+			if got[step] != want {
+				t.Fatalf("unexpected probe_http_step_success for step %q; got %v; want %v", step, got[step], want)
+			}
+		}
+	}
+
+	config := `
+prober: http_steps
+http_steps:
+  vars:
+    user: monitor
+  steps:
+  - name: login
+    method: POST
+    url: /login
+    headers:
+      X-User: '{{ .user }}'
+    fail_if_json_not_matches:
+    - path: '{.code}'
+      regexp: '^0$'
+    extract:
+    - name: token
+      from: json
+      path: '{.data.token}'
+    - name: rid
+      from: header
+      header: X-Request-Id
+    - name: session
+      from: cookie
+      cookie: session
+  - name: profile
+    url: '{{ .target }}/profile?rid={{ .rid }}'
+    headers:
+      Authorization: 'Bearer {{ .token }}'
+    extract:
+    - name: uid
+      from: regexp
+      regexp: 'id="(\d+)"'
+  - name: check
+    url: /profile?rid={{ .rid }}&uid={{ .uid }}&session={{ .session }}
+    headers:
+      Authorization: 'Bearer {{ .token }}'
+`
+	f(config, true, map[string]float64{"login": 1, "profile": 1, "check": 1})
+
+	// the second step fails, so the third one isn't executed
+	config = `
+prober: http_steps
+http_steps:
+  steps:
+  - name: login
+    method: POST
+    url: /login
+    headers:
+      X-User: monitor
+  - name: profile
+    url: /profile
+  - name: check
+    url: /profile
+`
+	f(config, false, map[string]float64{"login": 1, "profile": 0, "check": 0})
+
+	// reference to a missing variable
+	config = `
+prober: http_steps
+http_steps:
+  steps:
+  - name: profile
+    url: /profile?rid={{ .rid }}
+    valid_status_codes: [401]
+`
+	f(config, false, map[string]float64{"profile": 0})
+}
+
+func TestHTTPStepsProbeUnmarshalYAMLFailure(t *testing.T) {
+	f := func(config string) {
+		t.Helper()
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err == nil {
+			t.Fatalf("expecting non-nil error for config:\n%s", config)
+		}
+	}
+	f(`
+http_steps:
+  steps: []
+`)
+	f(`
+http_steps:
+  steps:
+  - url: /login
+`)
+	f(`
+http_steps:
+  steps:
+  - name: login
+  - name: login
+`)
+	f(`
+http_steps:
+  steps:
+  - name: login
+    extract:
+    - name: token
+      from: xml
+`)
+	f(`
+http_steps:
+  steps:
+  - name: login
+    extract:
+    - name: token
+      from: json
+`)
+}
@@ -16,6 +16,8 @@ package prober
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"math"
 	"math/rand"
 	"net"
 	"os"
@@ -60,13 +62,210 @@ func getICMPSequence() uint16 {
 	return icmpSequence
 }
 
+// icmpSocket 封装了 ICMP socket 的创建和读写，icmp 和 traceroute 共用
+//
+// 默认优先使用非特权的 socket（linux、darwin），dont_fragment 需要设置 IP 头，只能使用 raw socket
+type icmpSocket struct {
+	conn       *icmp.PacketConn
+	rawConn    *ipv4.RawConn
+	netConn    net.PacketConn
+	isIPv6     bool
+	srcIP      net.IP
+	privileged bool
+
+	hopLimitFlagSet bool
+}
+
+// openICMPSocket 创建 ICMP socket，requirePrivileged 为 true 时不尝试非特权的 socket，
+// 比如 traceroute 需要收到路由器返回的 Time Exceeded，非特权的 socket 收不到
+func openICMPSocket(isIPv6 bool, srcIP net.IP, dontFragment, requirePrivileged bool) (*icmpSocket, error) {
+	s := &icmpSocket{
+		isIPv6:          isIPv6,
+		srcIP:           srcIP,
+		privileged:      true,
+		hopLimitFlagSet: true,
+	}
+
+	// Unprivileged sockets are supported on Darwin and Linux only.
+	tryUnprivileged := !requirePrivileged && (runtime.GOOS == "darwin" || runtime.GOOS == "linux")
+
+	var err error
+	if isIPv6 {
+		if s.srcIP == nil {
+			s.srcIP = net.ParseIP("::")
+		}
+
+		if tryUnprivileged {
+			// "udp" here means unprivileged -- not the protocol "udp".
+			s.conn, err = icmp.ListenPacket("udp6", s.srcIP.String())
+			if err == nil {
+				s.privileged = false
+			}
+		}
+
+		if s.privileged {
+			s.conn, err = icmp.ListenPacket("ip6:ipv6-icmp", s.srcIP.String())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if err := s.conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true); err != nil {
+			s.hopLimitFlagSet = false
+		}
+		return s, nil
+	}
+
+	if s.srcIP == nil {
+		s.srcIP = net.ParseIP("0.0.0.0")
+	}
+
+	if dontFragment {
+		// If the user has set the don't fragment option we cannot use unprivileged
+		// sockets as it is not possible to set IP header level options.
+		s.netConn, err = net.ListenPacket("ip4:icmp", s.srcIP.String())
+		if err != nil {
+			return nil, err
+		}
+
+		s.rawConn, err = ipv4.NewRawConn(s.netConn)
+		if err != nil {
+			s.netConn.Close()
+			return nil, fmt.Errorf("error creating raw connection: %w", err)
+		}
+
+		if err := s.rawConn.SetControlMessage(ipv4.FlagTTL, true); err != nil {
+			s.hopLimitFlagSet = false
+		}
+		return s, nil
+	}
+
+	if tryUnprivileged {
+		s.conn, err = icmp.ListenPacket("udp4", s.srcIP.String())
+		if err == nil {
+			s.privileged = false
+		}
+	}
+
+	if s.privileged {
+		s.conn, err = icmp.ListenPacket("ip4:icmp", s.srcIP.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true); err != nil {
+		s.hopLimitFlagSet = false
+	}
+	return s, nil
+}
+
+func (s *icmpSocket) close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	if s.rawConn != nil {
+		s.rawConn.Close()
+	}
+	if s.netConn != nil {
+		s.netConn.Close()
+	}
+}
+
+// writeTo 发送 ICMP 消息，ttl 为 0 时使用系统默认值
+func (s *icmpSocket) writeTo(b []byte, dst *net.IPAddr, ttl int) error {
+	if s.conn != nil {
+		if ttl > 0 {
+			if c4 := s.conn.IPv4PacketConn(); c4 != nil {
+				c4.SetTTL(ttl)
+			}
+			if c6 := s.conn.IPv6PacketConn(); c6 != nil {
+				c6.SetHopLimit(ttl)
+			}
+		}
+		var addr net.Addr = dst
+		if !s.privileged {
+			addr = &net.UDPAddr{IP: dst.IP, Zone: dst.Zone}
+		}
+		_, err := s.conn.WriteTo(b, addr)
+		return err
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultICMPTTL
+	}
+	// Only for IPv4 raw. Needed for setting DontFragment flag.
+	header := &ipv4.Header{
+		Version:  ipv4.Version,
+		Len:      ipv4.HeaderLen,
+		Protocol: 1,
+		TotalLen: ipv4.HeaderLen + len(b),
+		TTL:      ttl,
+		Dst:      dst.IP,
+		Src:      s.srcIP,
+	}
+	header.Flags |= ipv4.DontFragment
+	return s.rawConn.WriteTo(header, b, nil)
+}
+
+// readFrom 读取一个 ICMP 消息，hopLimit 为 -1 表示拿不到 TTL（ipv4）或者 Hop Limit（ipv6）
+func (s *icmpSocket) readFrom(b []byte) (n int, peer net.IP, hopLimit int, err error) {
+	hopLimit = -1
+	if s.isIPv6 {
+		var cm *ipv6.ControlMessage
+		var addr net.Addr
+		n, cm, addr, err = s.conn.IPv6PacketConn().ReadFrom(b)
+		// HopLimit == 0 is valid for IPv6, although go initialize it as 0.
+		if cm != nil && s.hopLimitFlagSet {
+			hopLimit = cm.HopLimit
+		}
+		return n, addrIP(addr), hopLimit, err
+	}
+
+	var cm *ipv4.ControlMessage
+	if s.conn != nil {
+		var addr net.Addr
+		n, cm, addr, err = s.conn.IPv4PacketConn().ReadFrom(b)
+		peer = addrIP(addr)
+	} else {
+		var h *ipv4.Header
+		var p []byte
+		h, p, cm, err = s.rawConn.ReadFrom(b)
+		if err == nil {
+			copy(b, p)
+			n = len(p)
+			peer = h.Src
+		}
+	}
+	if cm != nil && s.hopLimitFlagSet {
+		// Not really Hop Limit, but it is in practice.
+		hopLimit = cm.TTL
+	}
+	return n, peer, hopLimit, err
+}
+
+func (s *icmpSocket) setReadDeadline(t time.Time) error {
+	if s.conn != nil {
+		return s.conn.SetReadDeadline(t)
+	}
+	return s.rawConn.SetReadDeadline(t)
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	default:
+		return nil
+	}
+}
+
 func ProbeICMP(ctx context.Context, target string, module Module, registry *prometheus.Registry) (success bool) {
 	var (
-		requestType     icmp.Type
-		replyType       icmp.Type
-		icmpConn        *icmp.PacketConn
-		v4RawConn       *ipv4.RawConn
-		hopLimitFlagSet bool = true
+		requestType icmp.Type
+		replyType   icmp.Type
 
 		durationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "probe_icmp_duration_seconds",
@@ -103,100 +302,21 @@ func ProbeICMP(ctx context.Context, target string, module Module, registry *prom
 
 	setupStart := time.Now()
 
-	privileged := true
-	// Unprivileged sockets are supported on Darwin and Linux only.
-	tryUnprivileged := runtime.GOOS == "darwin" || runtime.GOOS == "linux"
-
-	if dstIPAddr.IP.To4() == nil {
+	isIPv6 := dstIPAddr.IP.To4() == nil
+	if isIPv6 {
 		requestType = ipv6.ICMPTypeEchoRequest
 		replyType = ipv6.ICMPTypeEchoReply
-
-		if srcIP == nil {
-			srcIP = net.ParseIP("::")
-		}
-
-		if tryUnprivileged {
-			// "udp" here means unprivileged -- not the protocol "udp".
-			icmpConn, err = icmp.ListenPacket("udp6", srcIP.String())
-			if err != nil {
-				// level.Debug(logger).Log("msg", "Unable to do unprivileged listen on socket, will attempt privileged", "err", err)
-			} else {
-				privileged = false
-			}
-		}
-
-		if privileged {
-			icmpConn, err = icmp.ListenPacket("ip6:ipv6-icmp", srcIP.String())
-			if err != nil {
-				logger.Errorf("error listening to socket: %v", err)
-				return
-			}
-		}
-		defer icmpConn.Close()
-
-		if err := icmpConn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true); err != nil {
-			// level.Debug(logger).Log("msg", "Failed to set Control Message for retrieving Hop Limit", "err", err)
-			hopLimitFlagSet = false
-		}
 	} else {
 		requestType = ipv4.ICMPTypeEcho
 		replyType = ipv4.ICMPTypeEchoReply
-
-		if srcIP == nil {
-			srcIP = net.ParseIP("0.0.0.0")
-		}
-
-		if module.ICMP.DontFragment {
-			// If the user has set the don't fragment option we cannot use unprivileged
-			// sockets as it is not possible to set IP header level options.
-			netConn, err := net.ListenPacket("ip4:icmp", srcIP.String())
-			if err != nil {
-				logger.Errorf("error listening to socket: %v", err)
-				return
-			}
-			defer netConn.Close()
-
-			v4RawConn, err = ipv4.NewRawConn(netConn)
-			if err != nil {
-				logger.Errorf("error creating raw connection: %v", err)
-				return
-			}
-			defer v4RawConn.Close()
-
-			if err := v4RawConn.SetControlMessage(ipv4.FlagTTL, true); err != nil {
-				// level.Debug(logger).Log("msg", "Failed to set Control Message for retrieving TTL", "err", err)
-				hopLimitFlagSet = false
-			}
-		} else {
-			if tryUnprivileged {
-				icmpConn, err = icmp.ListenPacket("udp4", srcIP.String())
-				if err != nil {
-					// level.Debug(logger).Log("msg", "Unable to do unprivileged listen on socket, will attempt privileged", "err", err)
-				} else {
-					privileged = false
-				}
-			}
-
-			if privileged {
-				icmpConn, err = icmp.ListenPacket("ip4:icmp", srcIP.String())
-				if err != nil {
-					logger.Errorf("error listening to socket: %v", err)
-					return
-				}
-			}
-			defer icmpConn.Close()
-
-			if err := icmpConn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true); err != nil {
-				// level.Debug(logger).Log("msg", "Failed to set Control Message for retrieving TTL", "err", err)
-				hopLimitFlagSet = false
-			}
-		}
 	}
 
-	var dst net.Addr = dstIPAddr
-	if !privileged {
-		dst = &net.UDPAddr{IP: dstIPAddr.IP, Zone: dstIPAddr.Zone}
+	sock, err := openICMPSocket(isIPv6, srcIP, module.ICMP.DontFragment, false)
+	if err != nil {
+		logger.Errorf("error listening to socket: %v", err)
+		return
 	}
+	defer sock.close()
 
 	var data []byte
 	if module.ICMP.PayloadSize != 0 {
@@ -206,168 +326,281 @@ func ProbeICMP(ctx context.Context, target string, module Module, registry *prom
 		data = []byte("Prometheus Blackbox Exporter")
 	}
 
-	body := &icmp.Echo{
-		ID:   icmpID,
-		Seq:  int(getICMPSequence()),
-		Data: data,
-	}
-	// level.Info(logger).Log("msg", "Creating ICMP packet", "seq", body.Seq, "id", body.ID)
-	wm := icmp.Message{
-		Type: requestType,
-		Code: 0,
-		Body: body,
+	packetCount := module.ICMP.PacketCount
+	if packetCount < 1 {
+		packetCount = 1
 	}
+	// Unprivileged cannot set IDs on Linux.
+	idUnknown := !sock.privileged && runtime.GOOS == "linux"
 
-	wb, err := wm.Marshal(nil)
-	if err != nil {
-		logger.Errorf("error marshalling packet: %v", err)
+	durationGaugeVec.WithLabelValues("setup").Add(time.Since(setupStart).Seconds())
+
+	deadline, _ := ctx.Deadline()
+	if err := sock.setReadDeadline(deadline); err != nil {
+		logger.Errorf("error setting socket deadline: %v", err)
 		return
 	}
 
-	durationGaugeVec.WithLabelValues("setup").Add(time.Since(setupStart).Seconds())
-	// level.Info(logger).Log("msg", "Writing out packet")
-	rttStart := time.Now()
+	var (
+		mu          sync.Mutex
+		echoes      []*icmpEcho
+		bySeq       = make(map[int]*icmpEcho)
+		received    int
+		hopLimit    = -1
+		expected    = packetCount
+		allReceived = make(chan struct{})
+		closed      bool
+	)
+	// checkAllReceived 必须在持有 mu 的时候调用
+	checkAllReceived := func() {
+		if !closed && received == expected {
+			closed = true
+			close(allReceived)
+		}
+	}
 
-	if icmpConn != nil {
-		ttl := module.ICMP.TTL
-		if ttl > 0 {
-			if c4 := icmpConn.IPv4PacketConn(); c4 != nil {
-				// level.Debug(logger).Log("msg", "Setting TTL (IPv4 unprivileged)", "ttl", ttl)
-				c4.SetTTL(ttl)
+	var readerWG sync.WaitGroup
+	readerWG.Add(1)
+	go func() {
+		defer readerWG.Done()
+		rb := make([]byte, 65536)
+		// level.Info(logger).Log("msg", "Waiting for reply packets")
+		for {
+			n, peer, replyHopLimit, err := sock.readFrom(rb)
+			if err != nil {
+				if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+					return
+				}
+				logger.Errorf("error reading from socket: %v", err)
+				continue
 			}
-			if c6 := icmpConn.IPv6PacketConn(); c6 != nil {
-				// level.Debug(logger).Log("msg", "Setting TTL (IPv6 unprivileged)", "ttl", ttl)
-				c6.SetHopLimit(ttl)
+			if n < 8 || !peer.Equal(dstIPAddr.IP) {
+				continue
+			}
+			if idUnknown {
+				// Clear the ID from the packet, as the kernel will have replaced it (and
+				// kept track of our packet for us, hence clearing is safe).
+				rb[4] = 0
+				rb[5] = 0
 			}
+			if idUnknown || replyType == ipv6.ICMPTypeEchoReply {
+				// Clear checksum to make comparison succeed.
+				rb[2] = 0
+				rb[3] = 0
+			}
+
+			now := time.Now()
+			mu.Lock()
+			e := bySeq[int(rb[6])<<8|int(rb[7])]
+			if e != nil && !e.received && bytes.Equal(rb[:n], e.reply) {
+				// level.Info(logger).Log("msg", "Found matching reply packet")
+				e.received = true
+				e.rtt = now.Sub(e.sentAt)
+				if received == 0 {
+					hopLimit = replyHopLimit
+				}
+				received++
+				checkAllReceived()
+			}
+			mu.Unlock()
 		}
-		_, err = icmpConn.WriteTo(wb, dst)
-	} else {
-		ttl := DefaultICMPTTL
-		if module.ICMP.TTL > 0 {
-			// level.Debug(logger).Log("msg", "Overriding TTL (raw IPv4)", "ttl", ttl)
-			ttl = module.ICMP.TTL
+	}()
+
+	for i := 0; i < packetCount; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(module.ICMP.Interval):
+			}
+			if ctx.Err() != nil {
+				break
+			}
 		}
-		// Only for IPv4 raw. Needed for setting DontFragment flag.
-		header := &ipv4.Header{
-			Version:  ipv4.Version,
-			Len:      ipv4.HeaderLen,
-			Protocol: 1,
-			TotalLen: ipv4.HeaderLen + len(wb),
-			TTL:      ttl,
-			Dst:      dstIPAddr.IP,
-			Src:      srcIP,
+
+		body := &icmp.Echo{
+			ID:   icmpID,
+			Seq:  int(getICMPSequence()),
+			Data: data,
+		}
+		// level.Info(logger).Log("msg", "Creating ICMP packet", "seq", body.Seq, "id", body.ID)
+		wm := icmp.Message{
+			Type: requestType,
+			Code: 0,
+			Body: body,
 		}
 
-		header.Flags |= ipv4.DontFragment
+		wb, err := wm.Marshal(nil)
+		if err != nil {
+			logger.Errorf("error marshalling packet: %v", err)
+			break
+		}
 
-		err = v4RawConn.WriteTo(header, wb, nil)
-	}
-	if err != nil {
-		logger.Warnf("error writing to socket: %v", err)
-		return
+		// Reply should be the same except for the message type and ID if
+		// unprivileged sockets were used and the kernel used its own.
+		wm.Type = replyType
+		if idUnknown {
+			body.ID = 0
+		}
+		reply, err := wm.Marshal(nil)
+		if err != nil {
+			logger.Errorf("error marshalling packet: %v", err)
+			break
+		}
+		if idUnknown {
+			// If the ID is unknown (due to unprivileged sockets) we also cannot know
+			// the checksum in userspace.
+			reply[2] = 0
+			reply[3] = 0
+		}
+
+		e := &icmpEcho{reply: reply}
+		mu.Lock()
+		echoes = append(echoes, e)
+		bySeq[body.Seq] = e
+		e.sentAt = time.Now()
+		mu.Unlock()
+
+		// level.Info(logger).Log("msg", "Writing out packet")
+		if err := sock.writeTo(wb, dstIPAddr, module.ICMP.TTL); err != nil {
+			logger.Warnf("error writing to socket: %v", err)
+			// 没有发出去的包不算丢包
+			mu.Lock()
+			echoes = echoes[:len(echoes)-1]
+			delete(bySeq, body.Seq)
+			mu.Unlock()
+		}
 	}
 
-	// Reply should be the same except for the message type and ID if
-	// unprivileged sockets were used and the kernel used its own.
-	wm.Type = replyType
-	// Unprivileged cannot set IDs on Linux.
-	idUnknown := !privileged && runtime.GOOS == "linux"
-	if idUnknown {
-		body.ID = 0
+	// 发送过程中可能因为超时或者出错提前结束，只等待实际发出去的包
+	mu.Lock()
+	expected = len(echoes)
+	checkAllReceived()
+	mu.Unlock()
+
+	select {
+	case <-allReceived:
+	case <-ctx.Done():
 	}
-	wb, err = wm.Marshal(nil)
-	if err != nil {
-		logger.Errorf("error marshalling packet: %v", err)
-		return
+	// 让读响应的 goroutine 立即退出
+	sock.setReadDeadline(time.Now())
+	readerWG.Wait()
+
+	var rtts []time.Duration
+	for _, e := range echoes {
+		if e.received {
+			rtts = append(rtts, e.rtt)
+		}
 	}
 
-	if idUnknown {
-		// If the ID is unknown (due to unprivileged sockets) we also cannot know
-		// the checksum in userspace.
-		wb[2] = 0
-		wb[3] = 0
+	if packetCount > 1 {
+		registerICMPStats(registry, len(echoes), rtts)
 	}
 
-	rb := make([]byte, 65536)
-	deadline, _ := ctx.Deadline()
-	if icmpConn != nil {
-		err = icmpConn.SetReadDeadline(deadline)
-	} else {
-		err = v4RawConn.SetReadDeadline(deadline)
+	if len(rtts) == 0 {
+		logger.Warnf("timeout waiting for icmp echo reply from %s", target)
+		return false
 	}
-	if err != nil {
-		logger.Errorf("error setting socket deadline: %v", err)
-		return
+
+	stats := newICMPRTTStats(rtts)
+	durationGaugeVec.WithLabelValues("rtt").Add(stats.avg)
+	if hopLimit >= 0 {
+		hopLimitGauge.Set(float64(hopLimit))
+		registry.MustRegister(hopLimitGauge)
 	}
-	// level.Info(logger).Log("msg", "Waiting for reply packets")
-	for {
-		var n int
-		var peer net.Addr
-		var err error
-		var hopLimit float64 = -1
-
-		if dstIPAddr.IP.To4() == nil {
-			var cm *ipv6.ControlMessage
-			n, cm, peer, err = icmpConn.IPv6PacketConn().ReadFrom(rb)
-			// HopLimit == 0 is valid for IPv6, although go initialize it as 0.
-			if cm != nil && hopLimitFlagSet {
-				hopLimit = float64(cm.HopLimit)
-			}
-			// else {
-			// level.Debug(logger).Log("msg", "Cannot get Hop Limit from the received packet. 'probe_icmp_reply_hop_limit' will be missing.")
-			// }
-		} else {
-			var cm *ipv4.ControlMessage
-			if icmpConn != nil {
-				n, cm, peer, err = icmpConn.IPv4PacketConn().ReadFrom(rb)
-			} else {
-				var h *ipv4.Header
-				var p []byte
-				h, p, cm, err = v4RawConn.ReadFrom(rb)
-				if err == nil {
-					copy(rb, p)
-					n = len(p)
-					peer = &net.IPAddr{IP: h.Src}
-				}
-			}
-			if cm != nil && hopLimitFlagSet {
-				// Not really Hop Limit, but it is in practice.
-				hopLimit = float64(cm.TTL)
-			}
-			// else {
-			// level.Debug(logger).Log("msg", "Cannot get TTL from the received packet. 'probe_icmp_reply_hop_limit' will be missing.")
-			// }
-		}
-		if err != nil {
-			if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
-				logger.Warnf("timeout reading from socket: %v", err)
-				return
-			}
-			logger.Errorf("error reading from socket: %v", err)
-			continue
-		}
-		if peer.String() != dst.String() {
-			continue
-		}
-		if idUnknown {
-			// Clear the ID from the packet, as the kernel will have replaced it (and
-			// kept track of our packet for us, hence clearing is safe).
-			rb[4] = 0
-			rb[5] = 0
+	return true
+}
+
+// icmpEcho 是发出去的一个 echo 请求，reply 是期望收到的响应
+type icmpEcho struct {
+	sentAt   time.Time
+	reply    []byte
+	received bool
+	rtt      time.Duration
+}
+
+// icmpRTTStats 是多个 echo 的 RTT 统计，单位都是秒，jitter 是相邻两个 RTT 差值的绝对值的平均值
+type icmpRTTStats struct {
+	min, avg, max, stddev, jitter float64
+}
+
+func newICMPRTTStats(rtts []time.Duration) icmpRTTStats {
+	var s icmpRTTStats
+	if len(rtts) == 0 {
+		return s
+	}
+
+	var sum float64
+	for i, rtt := range rtts {
+		v := rtt.Seconds()
+		if i == 0 || v < s.min {
+			s.min = v
 		}
-		if idUnknown || replyType == ipv6.ICMPTypeEchoReply {
-			// Clear checksum to make comparison succeed.
-			rb[2] = 0
-			rb[3] = 0
+		if v > s.max {
+			s.max = v
 		}
-		if bytes.Equal(rb[:n], wb) {
-			durationGaugeVec.WithLabelValues("rtt").Add(time.Since(rttStart).Seconds())
-			if hopLimit >= 0 {
-				hopLimitGauge.Set(hopLimit)
-				registry.MustRegister(hopLimitGauge)
-			}
-			// level.Info(logger).Log("msg", "Found matching reply packet")
-			return true
+		sum += v
+		if i > 0 {
+			s.jitter += math.Abs(v - rtts[i-1].Seconds())
 		}
 	}
+	s.avg = sum / float64(len(rtts))
+	if len(rtts) > 1 {
+		s.jitter /= float64(len(rtts) - 1)
+	}
+
+	var variance float64
+	for _, rtt := range rtts {
+		d := rtt.Seconds() - s.avg
+		variance += d * d
+	}
+	s.stddev = math.Sqrt(variance / float64(len(rtts)))
+	return s
+}
+
+// registerICMPStats 在 packet_count 大于 1 的时候暴露丢包率和 RTT 统计，sent 是实际发出去的包数
+func registerICMPStats(registry *prometheus.Registry, sent int, rtts []time.Duration) {
+	var (
+		packetsSentGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_icmp_packets_sent",
+			Help: "Number of icmp echo requests sent",
+		})
+
+		packetsReceivedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_icmp_packets_received",
+			Help: "Number of icmp echo replies received",
+		})
+
+		lossRatioGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_icmp_packet_loss_ratio",
+			Help: "Ratio of icmp echo requests without reply",
+		})
+
+		rttGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_icmp_rtt_seconds",
+			Help: "Statistics of icmp round trip time",
+		}, []string{"stat"})
+
+		jitterGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_icmp_jitter_seconds",
+			Help: "Mean absolute difference between consecutive icmp round trip times",
+		})
+	)
+	registry.MustRegister(packetsSentGauge, packetsReceivedGauge, lossRatioGauge)
+
+	packetsSentGauge.Set(float64(sent))
+	packetsReceivedGauge.Set(float64(len(rtts)))
+	if sent > 0 {
+		lossRatioGauge.Set(float64(sent-len(rtts)) / float64(sent))
+	}
+
+	if len(rtts) == 0 {
+		return
+	}
+	registry.MustRegister(rttGaugeVec, jitterGauge)
+	stats := newICMPRTTStats(rtts)
+	rttGaugeVec.WithLabelValues("min").Set(stats.min)
+	rttGaugeVec.WithLabelValues("avg").Set(stats.avg)
+	rttGaugeVec.WithLabelValues("max").Set(stats.max)
+	rttGaugeVec.WithLabelValues("stddev").Set(stats.stddev)
+	jitterGauge.Set(stats.jitter)
 }
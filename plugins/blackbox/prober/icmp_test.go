@@ -0,0 +1,65 @@
+package prober
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestICMPRTTStats(t *testing.T) {
+	f := func(rtts []time.Duration, want icmpRTTStats) {
+		t.Helper()
+		got := newICMPRTTStats(rtts)
+		for _, v := range []struct {
+			name      string
+			got, want float64
+		}{
+			{"min", got.min, want.min},
+			{"avg", got.avg, want.avg},
+			{"max", got.max, want.max},
+			{"stddev", got.stddev, want.stddev},
+			{"jitter", got.jitter, want.jitter},
+		} {
+			if math.Abs(v.got-v.want) > 1e-9 {
+				t.Fatalf("unexpected %s for %v; got %v; want %v", v.name, rtts, v.got, v.want)
+			}
+		}
+	}
+
+	f(nil, icmpRTTStats{})
+	f([]time.Duration{10 * time.Millisecond}, icmpRTTStats{min: 0.01, avg: 0.01, max: 0.01})
+	f([]time.Duration{10 * time.Millisecond, 30 * time.Millisecond}, icmpRTTStats{min: 0.01, avg: 0.02, max: 0.03, stddev: 0.01, jitter: 0.02})
+	// jitter is the mean of |20-10|, |40-20| and |30-40| in send order
+	f([]time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 30 * time.Millisecond}, icmpRTTStats{
+		min:    0.01,
+		avg:    0.025,
+		max:    0.04,
+		stddev: math.Sqrt((0.015*0.015 + 0.005*0.005 + 0.015*0.015 + 0.005*0.005) / 4),
+		jitter: (0.01 + 0.02 + 0.01) / 3,
+	})
+}
+
+func TestICMPProbePacketCount(t *testing.T) {
+	f := func(config string, wantErr bool) {
+		t.Helper()
+		var module Module
+		err := yaml.Unmarshal([]byte(config), &module)
+		if (err != nil) != wantErr {
+			t.Fatalf("unexpected error for config %s; got %v; want error: %v", config, err, wantErr)
+		}
+	}
+
+	f("icmp:\n  packet_count: 10\n  interval: 200ms\n", false)
+	f("icmp:\n  packet_count: 0\n", true)
+	f("icmp:\n  packet_count: 5\n  interval: 0s\n", true)
+
+	var module Module
+	if err := yaml.Unmarshal([]byte("icmp:\n  ttl: 32\n"), &module); err != nil {
+		t.Fatalf("cannot parse config: %s", err)
+	}
+	if module.ICMP.PacketCount != 1 || module.ICMP.Interval != time.Second {
+		t.Fatalf("unexpected defaults: %+v", module.ICMP)
+	}
+}
@@ -0,0 +1,35 @@
+package prober
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// extractJSONPath 用 kubectl 风格的 JSONPath 从 JSON 数据中取值，比如 {.data.token}
+//
+// 取到多个值的时候用空格分隔，和 kubectl 的行为一致
+func extractJSONPath(data interface{}, path string) (string, error) {
+	j := jsonpath.New("jp")
+	if err := j.Parse(path); err != nil {
+		return "", fmt.Errorf("cannot parse jsonpath %q: %w", path, err)
+	}
+	var buf bytes.Buffer
+	if err := j.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("cannot execute jsonpath %q: %w", path, err)
+	}
+	if res, err := jsonpath.UnquoteExtend(buf.String()); err == nil {
+		return res, nil
+	}
+	return buf.String(), nil
+}
+
+func unmarshalJSONBody(body []byte) (interface{}, error) {
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("cannot parse response body as JSON: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,156 @@
+package prober
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+)
+
+// Java keystore 的文件头，JKS 和 JCEKS 的结构相同，JCEKS 多了一种保存对称密钥的条目
+const (
+	jksMagic   = 0xFEEDFEED
+	jceksMagic = 0xCECECECE
+
+	keystorePrivateKeyTag  = 1
+	keystoreTrustedCertTag = 2
+	keystoreSecretKeyTag   = 3
+)
+
+// isJavaKeyStore 根据文件头判断是否为 JKS 或 JCEKS 格式
+func isJavaKeyStore(data []byte) bool {
+	if len(data) < 4 {
+		return false
+	}
+	magic := binary.BigEndian.Uint32(data)
+	return magic == jksMagic || magic == jceksMagic
+}
+
+// parseJavaKeyStore 读取 JKS、JCEKS 中的证书，包括受信任的证书和私钥条目的证书链。
+// 证书在 keystore 中是明文保存的，不需要解密私钥；password 不为空的时候校验文件的完整性
+func parseJavaKeyStore(data []byte, password string) ([]*x509.Certificate, error) {
+	if len(data) < 12+sha1.Size {
+		return nil, errors.New("keystore is too short")
+	}
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	if password != "" {
+		h := sha1.New()
+		for _, c := range utf16.Encode([]rune(password)) {
+			h.Write([]byte{byte(c >> 8), byte(c)})
+		}
+		h.Write([]byte("Mighty Aphrodite"))
+		h.Write(body)
+		if subtle.ConstantTimeCompare(h.Sum(nil), digest) != 1 {
+			return nil, errors.New("keystore password was incorrect or keystore was tampered with")
+		}
+	}
+
+	r := &keystoreReader{r: bytes.NewReader(body)}
+	r.uint32() // magic
+	version := r.uint32()
+	if r.err == nil && version != 1 && version != 2 {
+		return nil, fmt.Errorf("unsupported keystore version %d", version)
+	}
+	// version 1 中没有证书类型，都是 X.509
+	readCert := func() *x509.Certificate {
+		if version == 2 {
+			if typ := r.utf(); r.err == nil && typ != "X.509" {
+				r.err = fmt.Errorf("unsupported certificate type %q", typ)
+			}
+		}
+		der := r.bytes()
+		if r.err != nil {
+			return nil
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			r.err = err
+		}
+		return cert
+	}
+
+	var certs []*x509.Certificate
+	count := r.uint32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		tag := r.uint32()
+		alias := r.utf()
+		r.skip(8) // 创建时间
+		switch tag {
+		case keystorePrivateKeyTag:
+			r.bytes() // 加密的私钥
+			n := r.uint32()
+			for j := uint32(0); j < n && r.err == nil; j++ {
+				if cert := readCert(); cert != nil {
+					certs = append(certs, cert)
+				}
+			}
+		case keystoreTrustedCertTag:
+			if cert := readCert(); cert != nil {
+				certs = append(certs, cert)
+			}
+		case keystoreSecretKeyTag:
+			// 对称密钥是 Java 序列化的对象，没办法跳过，这种 keystore 需要先转成 PKCS12
+			return nil, fmt.Errorf("secret key entry %q is not supported", alias)
+		default:
+			if r.err == nil {
+				return nil, fmt.Errorf("unknown keystore entry tag %d", tag)
+			}
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("cannot read keystore entry %q: %w", alias, r.err)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return certs, nil
+}
+
+// keystoreReader 按照 Java DataInputStream 的格式读取数据，出错之后的读取都返回零值，最后统一检查 err
+type keystoreReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *keystoreReader) read(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > r.r.Len() {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := make([]byte, n)
+	r.r.Read(b)
+	return b
+}
+
+func (r *keystoreReader) skip(n int) {
+	r.read(n)
+}
+
+func (r *keystoreReader) uint32() uint32 {
+	b := r.read(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// utf 读取 DataOutputStream.writeUTF 写入的字符串，alias 和证书类型都是 ASCII，这里不处理 modified UTF-8 的差异
+func (r *keystoreReader) utf() string {
+	b := r.read(2)
+	if b == nil {
+		return ""
+	}
+	return string(r.read(int(binary.BigEndian.Uint16(b))))
+}
+
+func (r *keystoreReader) bytes() []byte {
+	return r.read(int(r.uint32()))
+}
@@ -0,0 +1,184 @@
+package prober
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	ntpPacketSize = 48
+	// ntpEpochOffset 是 1900-01-01 到 1970-01-01 的秒数
+	ntpEpochOffset = 2208988800
+)
+
+// ntpResponse 是 SNTP 响应中探测关心的字段，时间都已经转换成本地时间
+type ntpResponse struct {
+	leap           uint8
+	stratum        uint8
+	rootDelay      time.Duration
+	rootDispersion time.Duration
+	offset         time.Duration
+	rtt            time.Duration
+}
+
+func ProbeNTP(ctx context.Context, target string, module Module, registry *prometheus.Registry) bool {
+	var (
+		durationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_ntp_duration_seconds",
+			Help: "Duration of ntp probe by phase",
+		}, []string{"phase"})
+		offsetGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_ntp_offset_seconds",
+			Help: "Estimated offset of the local clock relative to the NTP server",
+		})
+		rttGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_ntp_rtt_seconds",
+			Help: "Round trip delay to the NTP server, excluding the server processing time",
+		})
+		stratumGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_ntp_stratum",
+			Help: "Stratum of the NTP server",
+		})
+		leapGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_ntp_leap",
+			Help: "Leap indicator of the NTP server, 3 means the clock is unsynchronized",
+		})
+		rootDelayGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_ntp_root_delay_seconds",
+			Help: "Total round trip delay from the NTP server to the reference clock",
+		})
+		rootDispersionGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_ntp_root_dispersion_seconds",
+			Help: "Total dispersion from the NTP server to the reference clock",
+		})
+	)
+	registry.MustRegister(durationGaugeVec)
+
+	ntpConfig := module.NTP
+
+	_, addr, ipVersion, lookupTime, err := resolveTargetAddr(ctx, target, "123", ntpConfig.IPProtocol, ntpConfig.IPProtocolFallback, registry)
+	durationGaugeVec.WithLabelValues("resolve").Set(lookupTime)
+	if err != nil {
+		logger.Errorf("%v, target: %s", err, target)
+		return false
+	}
+
+	dialer, err := newDialer("udp", ntpConfig.SourceIPAddress)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return false
+	}
+	conn, err := dialer.DialContext(ctx, "udp"+ipVersion, addr)
+	if err != nil {
+		logger.Errorf("error dialing udp: %v, target: %s", err, target)
+		return false
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		logger.Errorf("error setting deadline: %v, target: %s", err, target)
+		return false
+	}
+
+	// 请求里的发送时间用随机数，防止伪造的响应，真实的发送时间只保存在本地
+	req := make([]byte, ntpPacketSize)
+	// LI = 0, VN = 4, Mode = 3 (client)
+	req[0] = 0x23
+	if _, err := rand.Read(req[40:]); err != nil {
+		logger.Errorf("cannot generate random transmit timestamp: %v", err)
+		return false
+	}
+
+	start := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		logger.Errorf("error writing to connection: %v, target: %s", err, target)
+		return false
+	}
+	resp := make([]byte, ntpPacketSize)
+	n, err := conn.Read(resp)
+	end := time.Now()
+	durationGaugeVec.WithLabelValues("roundtrip").Set(end.Sub(start).Seconds())
+	if err != nil {
+		logger.Errorf("error reading from connection: %v, target: %s", err, target)
+		return false
+	}
+
+	r, err := parseNTPResponse(req, resp[:n], start, end)
+	if err != nil {
+		logger.Errorf("invalid ntp response: %v, target: %s", err, target)
+		return false
+	}
+
+	registry.MustRegister(offsetGauge, rttGauge, stratumGauge, leapGauge, rootDelayGauge, rootDispersionGauge)
+	offsetGauge.Set(r.offset.Seconds())
+	rttGauge.Set(r.rtt.Seconds())
+	stratumGauge.Set(float64(r.stratum))
+	leapGauge.Set(float64(r.leap))
+	rootDelayGauge.Set(r.rootDelay.Seconds())
+	rootDispersionGauge.Set(r.rootDispersion.Seconds())
+
+	if r.leap == 3 {
+		logger.Errorf("ntp server clock is unsynchronized, target: %s", target)
+		return false
+	}
+	if r.stratum == 0 || int(r.stratum) > ntpConfig.MaxStratum {
+		logger.Errorf("invalid ntp stratum(%d), max_stratum: %d, target: %s", r.stratum, ntpConfig.MaxStratum, target)
+		return false
+	}
+	if ntpConfig.MaxOffset > 0 && (r.offset > ntpConfig.MaxOffset || r.offset < -ntpConfig.MaxOffset) {
+		logger.Errorf("ntp offset(%s) exceeds max_offset(%s), target: %s", r.offset, ntpConfig.MaxOffset, target)
+		return false
+	}
+	return true
+}
+
+// parseNTPResponse 校验响应并计算时间偏差，t1 和 t4 是本地的发送和接收时间
+//
+// offset = ((t2 - t1) + (t3 - t4)) / 2, rtt = (t4 - t1) - (t3 - t2)
+func parseNTPResponse(req, resp []byte, t1, t4 time.Time) (*ntpResponse, error) {
+	if len(resp) < ntpPacketSize {
+		return nil, fmt.Errorf("response is too short: %d bytes", len(resp))
+	}
+	if mode := resp[0] & 0x7; mode != 4 {
+		return nil, fmt.Errorf("unexpected mode %d; want 4 (server)", mode)
+	}
+	// 服务端要把请求里的发送时间原样放到 origin timestamp 里
+	if string(resp[24:32]) != string(req[40:48]) {
+		return nil, fmt.Errorf("origin timestamp doesn't match the request")
+	}
+
+	t2 := ntpTimestampToTime(binary.BigEndian.Uint64(resp[32:40]))
+	t3 := ntpTimestampToTime(binary.BigEndian.Uint64(resp[40:48]))
+	r := &ntpResponse{
+		leap:           resp[0] >> 6,
+		stratum:        resp[1],
+		rootDelay:      ntpShortToDuration(binary.BigEndian.Uint32(resp[4:8])),
+		rootDispersion: ntpShortToDuration(binary.BigEndian.Uint32(resp[8:12])),
+		offset:         (t2.Sub(t1) + t3.Sub(t4)) / 2,
+		rtt:            t4.Sub(t1) - t3.Sub(t2),
+	}
+	if r.rtt < 0 {
+		r.rtt = 0
+	}
+	return r, nil
+}
+
+// ntpTimestampToTime 把 64 位的 NTP 时间戳转换成 time.Time，高 32 位是 1900 年以来的秒数，低 32 位是秒的小数部分
+func ntpTimestampToTime(ts uint64) time.Time {
+	sec := int64(ts>>32) - ntpEpochOffset
+	nsec := (int64(ts&math.MaxUint32) * 1e9) >> 32
+	return time.Unix(sec, nsec)
+}
+
+// ntpShortToDuration 转换 32 位的 NTP short format，高 16 位是秒，低 16 位是秒的小数部分
+func ntpShortToDuration(v uint32) time.Duration {
+	return time.Duration(v>>16)*time.Second + time.Duration((int64(v&0xffff)*1e9)>>16)
+}
@@ -0,0 +1,120 @@
+package prober
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func timeToNTPTimestamp(t time.Time) uint64 {
+	sec := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond()) << 32) / 1e9
+	return sec<<32 | frac
+}
+
+// startNTPServer starts fake NTP server, which clock is shifted by offset.
+func startNTPServer(t *testing.T, stratum uint8, offset time.Duration) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen udp: %s", err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < ntpPacketSize {
+				continue
+			}
+			now := time.Now().Add(offset)
+			resp := make([]byte, ntpPacketSize)
+			// LI = 0, VN = 4, Mode = 4 (server)
+			resp[0] = 0x24
+			resp[1] = stratum
+			// root delay 0.5s, root dispersion 0.25s
+			binary.BigEndian.PutUint32(resp[4:8], 0x8000)
+			binary.BigEndian.PutUint32(resp[8:12], 0x4000)
+			copy(resp[24:32], buf[40:48])
+			binary.BigEndian.PutUint64(resp[32:40], timeToNTPTimestamp(now))
+			binary.BigEndian.PutUint64(resp[40:48], timeToNTPTimestamp(now))
+			pc.WriteTo(resp, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestProbeNTP(t *testing.T) {
+	f := func(stratum uint8, offset time.Duration, config string, wantSuccess bool) {
+		t.Helper()
+		addr := startNTPServer(t, stratum, offset)
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		registry := prometheus.NewRegistry()
+		if success := ProbeNTP(ctx, addr, module, registry); success != wantSuccess {
+			t.Fatalf("unexpected probe result; got %v; want %v", success, wantSuccess)
+		}
+		if !wantSuccess {
+			return
+		}
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("cannot gather metrics: %s", err)
+		}
+		for _, mf := range mfs {
+			v := mf.GetMetric()[0].GetGauge().GetValue()
+			switch mf.GetName() {
+			case "probe_ntp_offset_seconds":
+				if d := v - offset.Seconds(); d > 0.1 || d < -0.1 {
+					t.Fatalf("unexpected offset; got %v; want %v", v, offset.Seconds())
+				}
+			case "probe_ntp_stratum":
+				if v != float64(stratum) {
+					t.Fatalf("unexpected stratum; got %v; want %d", v, stratum)
+				}
+			case "probe_ntp_root_delay_seconds":
+				if v != 0.5 {
+					t.Fatalf("unexpected root delay; got %v; want 0.5", v)
+				}
+			}
+		}
+	}
+	f(2, 3*time.Second, "prober: ntp", true)
+	f(2, 3*time.Second, "prober: ntp\nntp:\n  max_offset: 1s", false)
+	f(2, -300*time.Millisecond, "prober: ntp\nntp:\n  max_offset: 1s", true)
+	f(3, 0, "prober: ntp\nntp:\n  max_stratum: 2", false)
+	f(0, 0, "prober: ntp", false)
+}
+
+func TestParseNTPResponseFailure(t *testing.T) {
+	req := make([]byte, ntpPacketSize)
+	req[40] = 1
+	resp := make([]byte, ntpPacketSize)
+	resp[0] = 0x24
+	now := time.Now()
+
+	// origin timestamp mismatch
+	if _, err := parseNTPResponse(req, resp, now, now); err == nil {
+		t.Fatalf("expecting non-nil error for origin timestamp mismatch")
+	}
+	copy(resp[24:32], req[40:48])
+	if _, err := parseNTPResponse(req, resp[:40], now, now); err == nil {
+		t.Fatalf("expecting non-nil error for short response")
+	}
+	resp[0] = 0x23
+	if _, err := parseNTPResponse(req, resp, now, now); err == nil {
+		t.Fatalf("expecting non-nil error for client mode response")
+	}
+}
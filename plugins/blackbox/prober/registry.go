@@ -7,5 +7,13 @@ var (
 		"icmp": ProbeICMP,
 		"dns":  ProbeDNS,
 		"grpc": ProbeGRPC,
+
+		"http_steps": ProbeHTTPSteps,
+		"udp":        ProbeUDP,
+		"ntp":        ProbeNTP,
+		"ssh":        ProbeSSH,
+		"smtp":       ProbeSMTP,
+		"traceroute": ProbeTraceroute,
+		"x509":       ProbeX509,
 	}
 )
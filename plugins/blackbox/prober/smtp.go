@@ -0,0 +1,281 @@
+package prober
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	pconfig "github.com/prometheus/common/config"
+)
+
+func ProbeSMTP(ctx context.Context, target string, module Module, registry *prometheus.Registry) bool {
+	var (
+		durationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_smtp_duration_seconds",
+			Help: "Duration of smtp probe by phase",
+		}, []string{"phase"})
+		startTLSGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_smtp_starttls",
+			Help: "Indicates if the server supports STARTTLS",
+		})
+		authMechanismGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_smtp_auth_mechanism",
+			Help: "Auth mechanisms advertised by the server in the EHLO response",
+		}, []string{"mechanism"})
+		probeSSLEarliestCertExpiry              = prometheus.NewGauge(sslEarliestCertExpiryGaugeOpts)
+		probeSSLLastChainExpiryTimestampSeconds = prometheus.NewGauge(sslChainExpiryInTimeStampGaugeOpts)
+		probeSSLLastInformation                 = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "probe_ssl_last_chain_info",
+				Help: "Contains SSL leaf certificate information",
+			},
+			[]string{"fingerprint_sha256", "subject", "issuer", "subjectalternative"},
+		)
+		probeTLSVersion = prometheus.NewGaugeVec(
+			probeTLSInfoGaugeOpts,
+			[]string{"version"},
+		)
+	)
+	registry.MustRegister(durationGaugeVec, startTLSGauge, authMechanismGaugeVec)
+
+	smtpConfig := module.SMTP
+
+	defaultPort := "25"
+	switch {
+	case smtpConfig.Protocol == "imap" && smtpConfig.TLS:
+		defaultPort = "993"
+	case smtpConfig.Protocol == "imap":
+		defaultPort = "143"
+	case smtpConfig.TLS:
+		defaultPort = "465"
+	}
+	host, addr, ipVersion, lookupTime, err := resolveTargetAddr(ctx, target, defaultPort, smtpConfig.IPProtocol, smtpConfig.IPProtocolFallback, registry)
+	durationGaugeVec.WithLabelValues("resolve").Set(lookupTime)
+	if err != nil {
+		logger.Errorf("%v, target: %s", err, target)
+		return false
+	}
+
+	var tlsConfig *tls.Config
+	if smtpConfig.TLS || smtpConfig.StartTLS {
+		tlsConfig, err = pconfig.NewTLSConfig(&smtpConfig.TLSConfig)
+		if err != nil {
+			logger.Errorf("error creating tls configuration: %v", err)
+			return false
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = host
+		}
+	}
+
+	dialer, err := newDialer("tcp", smtpConfig.SourceIPAddress)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return false
+	}
+	start := time.Now()
+	var conn net.Conn
+	conn, err = dialer.DialContext(ctx, "tcp"+ipVersion, addr)
+	durationGaugeVec.WithLabelValues("connect").Set(time.Since(start).Seconds())
+	if err != nil {
+		logger.Errorf("error dialing tcp: %v, target: %s", err, target)
+		return false
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		logger.Errorf("error setting deadline: %v, target: %s", err, target)
+		return false
+	}
+
+	setTLSMetrics := func(state tls.ConnectionState) {
+		registry.MustRegister(probeSSLEarliestCertExpiry, probeTLSVersion, probeSSLLastChainExpiryTimestampSeconds, probeSSLLastInformation)
+		probeSSLEarliestCertExpiry.Set(float64(getEarliestCertExpiry(&state).Unix()))
+		probeTLSVersion.WithLabelValues(getTLSVersion(&state)).Set(1)
+		probeSSLLastChainExpiryTimestampSeconds.Set(float64(getLastChainExpiry(&state).Unix()))
+		probeSSLLastInformation.WithLabelValues(getFingerprint(&state), getSubject(&state), getIssuer(&state), getDNSNames(&state)).Set(1)
+	}
+
+	if smtpConfig.TLS {
+		start = time.Now()
+		tlsConn := tls.Client(conn, tlsConfig)
+		err := tlsConn.HandshakeContext(ctx)
+		durationGaugeVec.WithLabelValues("tls").Set(time.Since(start).Seconds())
+		if err != nil {
+			logger.Errorf("error doing tls handshake: %v, target: %s", err, target)
+			return false
+		}
+		setTLSMetrics(tlsConn.ConnectionState())
+		conn = tlsConn
+	}
+
+	var (
+		mechanisms []string
+		ok         bool
+	)
+	if smtpConfig.Protocol == "imap" {
+		mechanisms, ok = probeIMAPSession(ctx, conn, target, smtpConfig, tlsConfig, durationGaugeVec, startTLSGauge, setTLSMetrics)
+	} else {
+		mechanisms, ok = probeSMTPSession(conn, host, target, smtpConfig, tlsConfig, durationGaugeVec, startTLSGauge, setTLSMetrics)
+	}
+	if !ok {
+		return false
+	}
+
+	advertised := make(map[string]bool)
+	for _, m := range mechanisms {
+		advertised[m] = true
+		authMechanismGaugeVec.WithLabelValues(m).Set(1)
+	}
+	for _, m := range smtpConfig.RequiredAuthMechanisms {
+		if !advertised[strings.ToUpper(m)] {
+			logger.Errorf("server doesn't support auth mechanism %s, advertised: %q, target: %s", m, strings.Join(mechanisms, " "), target)
+			return false
+		}
+	}
+	return true
+}
+
+// probeSMTPSession 读取 greeting，发送 EHLO 并按需 STARTTLS，返回服务端支持的认证方式
+func probeSMTPSession(conn net.Conn, host, target string, smtpConfig SMTPProbe, tlsConfig *tls.Config,
+	durationGaugeVec *prometheus.GaugeVec, startTLSGauge prometheus.Gauge, setTLSMetrics func(tls.ConnectionState)) ([]string, bool) {
+	start := time.Now()
+	c, err := smtp.NewClient(conn, host)
+	durationGaugeVec.WithLabelValues("greeting").Set(time.Since(start).Seconds())
+	if err != nil {
+		logger.Errorf("error reading smtp greeting: %v, target: %s", err, target)
+		return nil, false
+	}
+	defer c.Close()
+
+	start = time.Now()
+	err = c.Hello(smtpConfig.EHLO)
+	if err == nil {
+		// Hello 只是记录下 hostname，后面第一个命令才会真正发送 EHLO，Extension 会吞掉 EHLO 的错误，所以这里用 NOOP
+		err = c.Noop()
+	}
+	durationGaugeVec.WithLabelValues("ehlo").Set(time.Since(start).Seconds())
+	if err != nil {
+		logger.Errorf("error sending EHLO: %v, target: %s", err, target)
+		return nil, false
+	}
+
+	hasStartTLS, _ := c.Extension("STARTTLS")
+	if hasStartTLS {
+		startTLSGauge.Set(1)
+	}
+	if smtpConfig.StartTLS {
+		if !hasStartTLS {
+			logger.Errorf("server doesn't support STARTTLS, target: %s", target)
+			return nil, false
+		}
+		start = time.Now()
+		err := c.StartTLS(tlsConfig)
+		durationGaugeVec.WithLabelValues("starttls").Set(time.Since(start).Seconds())
+		if err != nil {
+			logger.Errorf("error doing STARTTLS: %v, target: %s", err, target)
+			return nil, false
+		}
+		state, _ := c.TLSConnectionState()
+		setTLSMetrics(state)
+	}
+
+	// 很多服务端只在 TLS 之后才会返回 AUTH，所以放在 STARTTLS 之后检查
+	_, auth := c.Extension("AUTH")
+	var mechanisms []string
+	for _, m := range strings.Fields(auth) {
+		mechanisms = append(mechanisms, strings.ToUpper(m))
+	}
+	_ = c.Quit()
+	return mechanisms, true
+}
+
+// probeIMAPSession 读取 greeting，发送 CAPABILITY 并按需 STARTTLS，返回服务端支持的认证方式。
+// STARTTLS 之后服务端的能力可能会变化，需要重新发送 CAPABILITY
+func probeIMAPSession(ctx context.Context, conn net.Conn, target string, smtpConfig SMTPProbe, tlsConfig *tls.Config,
+	durationGaugeVec *prometheus.GaugeVec, startTLSGauge prometheus.Gauge, setTLSMetrics func(tls.ConnectionState)) ([]string, bool) {
+	r := bufio.NewReader(conn)
+	start := time.Now()
+	err := readIMAPGreeting(r)
+	durationGaugeVec.WithLabelValues("greeting").Set(time.Since(start).Seconds())
+	if err != nil {
+		logger.Errorf("%v, target: %s", err, target)
+		return nil, false
+	}
+
+	start = time.Now()
+	capabilities, err := imapCapabilities(conn, r, "a001")
+	durationGaugeVec.WithLabelValues("capability").Set(time.Since(start).Seconds())
+	if err != nil {
+		logger.Errorf("error sending CAPABILITY: %v, target: %s", err, target)
+		return nil, false
+	}
+
+	if capabilities["STARTTLS"] {
+		startTLSGauge.Set(1)
+	}
+	if smtpConfig.StartTLS {
+		if !capabilities["STARTTLS"] {
+			logger.Errorf("server doesn't support STARTTLS, target: %s", target)
+			return nil, false
+		}
+		start = time.Now()
+		_, err := imapCommand(conn, r, "a002", "STARTTLS")
+		var tlsConn *tls.Conn
+		if err == nil {
+			tlsConn = tls.Client(conn, tlsConfig)
+			err = tlsConn.HandshakeContext(ctx)
+		}
+		durationGaugeVec.WithLabelValues("starttls").Set(time.Since(start).Seconds())
+		if err != nil {
+			logger.Errorf("error doing STARTTLS: %v, target: %s", err, target)
+			return nil, false
+		}
+		setTLSMetrics(tlsConn.ConnectionState())
+		conn = tlsConn
+		r = bufio.NewReader(conn)
+
+		capabilities, err = imapCapabilities(conn, r, "a003")
+		if err != nil {
+			logger.Errorf("error sending CAPABILITY after STARTTLS: %v, target: %s", err, target)
+			return nil, false
+		}
+	}
+
+	var mechanisms []string
+	for c := range capabilities {
+		if strings.HasPrefix(c, "AUTH=") {
+			mechanisms = append(mechanisms, strings.TrimPrefix(c, "AUTH="))
+		}
+	}
+	sort.Strings(mechanisms)
+	_, _ = imapCommand(conn, r, "a004", "LOGOUT")
+	return mechanisms, true
+}
+
+// imapCapabilities 发送 CAPABILITY，返回大写的能力列表
+func imapCapabilities(conn net.Conn, r *bufio.Reader, tag string) (map[string]bool, error) {
+	lines, err := imapCommand(conn, r, tag, "CAPABILITY")
+	if err != nil {
+		return nil, err
+	}
+	capabilities := make(map[string]bool)
+	for _, line := range lines {
+		fields := strings.Fields(strings.ToUpper(line))
+		if len(fields) < 2 || fields[0] != "*" || fields[1] != "CAPABILITY" {
+			continue
+		}
+		for _, c := range fields[2:] {
+			capabilities[c] = true
+		}
+	}
+	return capabilities, nil
+}
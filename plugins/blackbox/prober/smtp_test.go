@@ -0,0 +1,217 @@
+package prober
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// startSMTPServer starts fake SMTP server, which advertises the given EHLO extensions.
+func startSMTPServer(t *testing.T, extensions ...string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen tcp: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				fmt.Fprintf(c, "220 mail.example.com ESMTP\r\n")
+				r := bufio.NewReader(c)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					cmd := strings.ToUpper(strings.Fields(line + " x")[0])
+					switch cmd {
+					case "EHLO":
+						lines := append([]string{"mail.example.com"}, extensions...)
+						for i, l := range lines {
+							sep := "-"
+							if i == len(lines)-1 {
+								sep = " "
+							}
+							fmt.Fprintf(c, "250%s%s\r\n", sep, l)
+						}
+					case "NOOP":
+						fmt.Fprintf(c, "250 OK\r\n")
+					case "QUIT":
+						fmt.Fprintf(c, "221 Bye\r\n")
+						return
+					default:
+						fmt.Fprintf(c, "502 Command not implemented\r\n")
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestProbeSMTP(t *testing.T) {
+	f := func(addr, config string, wantSuccess bool) {
+		t.Helper()
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if success := ProbeSMTP(ctx, addr, module, prometheus.NewRegistry()); success != wantSuccess {
+			t.Fatalf("unexpected probe result for config %s; got %v; want %v", config, success, wantSuccess)
+		}
+	}
+	addr := startSMTPServer(t, "PIPELINING", "AUTH PLAIN LOGIN")
+	f(addr, `prober: smtp`, true)
+	f(addr, `{prober: smtp, smtp: {required_auth_mechanisms: [plain]}}`, true)
+	f(addr, `{prober: smtp, smtp: {required_auth_mechanisms: [CRAM-MD5]}}`, false)
+	// the server doesn't advertise STARTTLS
+	f(addr, `{prober: smtp, smtp: {starttls: true}}`, false)
+
+	// STARTTLS is advertised, but the command fails
+	addr = startSMTPServer(t, "STARTTLS")
+	f(addr, `{prober: smtp, smtp: {starttls: true}}`, false)
+
+	var module Module
+	if err := yaml.Unmarshal([]byte(`{smtp: {tls: true, starttls: true}}`), &module); err == nil {
+		t.Fatalf("expecting non-nil error when both tls and starttls are set")
+	}
+}
+
+// startIMAPServer starts fake IMAP server, which supports STARTTLS and advertises AUTH=PLAIN only after it when leaf is not nil.
+func startIMAPServer(t *testing.T, leaf, ca *testCert) string {
+	t.Helper()
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen tcp: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	tlsConfig := &tls.Config{}
+	if leaf != nil {
+		tlsConfig.Certificates = []tls.Certificate{{
+			Certificate: [][]byte{leaf.cert.Raw, ca.cert.Raw},
+			PrivateKey:  leaf.key,
+		}}
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+				fmt.Fprintf(c, "* OK IMAP4rev1 ready\r\n")
+				r := bufio.NewReader(c)
+				capabilities := "IMAP4rev1 LOGINDISABLED"
+				if leaf != nil {
+					capabilities += " STARTTLS"
+				} else {
+					capabilities += " AUTH=LOGIN"
+				}
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line + " x x")
+					tag, cmd := fields[0], strings.ToUpper(fields[1])
+					switch cmd {
+					case "CAPABILITY":
+						fmt.Fprintf(c, "* CAPABILITY %s\r\n%s OK CAPABILITY completed\r\n", capabilities, tag)
+					case "STARTTLS":
+						fmt.Fprintf(c, "%s OK Begin TLS negotiation now\r\n", tag)
+						tlsConn := tls.Server(c, tlsConfig)
+						if err := tlsConn.Handshake(); err != nil {
+							return
+						}
+						c = tlsConn
+						r = bufio.NewReader(c)
+						capabilities = "IMAP4rev1 AUTH=PLAIN AUTH=XOAUTH2"
+					case "LOGOUT":
+						fmt.Fprintf(c, "* BYE\r\n%s OK LOGOUT completed\r\n", tag)
+						return
+					default:
+						fmt.Fprintf(c, "%s BAD unknown command\r\n", tag)
+					}
+				}
+			}(c)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestProbeIMAP(t *testing.T) {
+	ca := newTestCert(t, nil, "test ca", time.Now().Add(24*time.Hour))
+	leaf := newTestCert(t, ca, "localhost", time.Now().Add(24*time.Hour))
+	caFile := writeCertFile(t, "ca.pem", ca)
+
+	f := func(addr, config string, wantSuccess bool) map[string]float64 {
+		t.Helper()
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		registry := prometheus.NewRegistry()
+		if success := ProbeSMTP(ctx, addr, module, registry); success != wantSuccess {
+			t.Fatalf("unexpected probe result for config %s; got %v; want %v", config, success, wantSuccess)
+		}
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("cannot gather metrics: %s", err)
+		}
+		values := make(map[string]float64)
+		for _, mf := range mfs {
+			for _, m := range mf.GetMetric() {
+				name := mf.GetName()
+				for _, l := range m.GetLabel() {
+					name += fmt.Sprintf(",%s=%s", l.GetName(), l.GetValue())
+				}
+				values[name] = m.GetGauge().GetValue()
+			}
+		}
+		return values
+	}
+
+	addr := startIMAPServer(t, leaf, ca)
+	values := f(addr, `{prober: smtp, smtp: {protocol: imap}}`, true)
+	if values["probe_smtp_starttls"] != 1 {
+		t.Fatalf("expecting STARTTLS to be advertised; metrics: %v", values)
+	}
+	// AUTH is advertised only after STARTTLS
+	f(addr, `{prober: smtp, smtp: {protocol: imap, required_auth_mechanisms: [plain]}}`, false)
+	starttls := fmt.Sprintf(`{prober: smtp, smtp: {protocol: imap, starttls: true, tls_config: {ca_file: %s, server_name: localhost}, required_auth_mechanisms: [plain]}}`, caFile)
+	values = f(addr, starttls, true)
+	if values["probe_smtp_auth_mechanism,mechanism=XOAUTH2"] != 1 || values["probe_ssl_earliest_cert_expiry"] != float64(leaf.cert.NotAfter.Unix()) {
+		t.Fatalf("unexpected metrics after STARTTLS: %v", values)
+	}
+
+	// the server doesn't advertise STARTTLS
+	addr = startIMAPServer(t, nil, nil)
+	f(addr, `{prober: smtp, smtp: {protocol: imap, required_auth_mechanisms: [LOGIN]}}`, true)
+	f(addr, starttls, false)
+	// SMTP probe fails on IMAP greeting
+	f(addr, `prober: smtp`, false)
+
+	var module Module
+	if err := yaml.Unmarshal([]byte(`{smtp: {protocol: pop3}}`), &module); err == nil {
+		t.Fatalf("expecting non-nil error for unsupported protocol")
+	}
+}
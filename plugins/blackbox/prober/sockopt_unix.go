@@ -0,0 +1,21 @@
+//go:build !windows
+
+package prober
+
+import "syscall"
+
+// setSocketTTL 在 socket 发出第一个包之前设置 TTL（ipv4）或者 Hop Limit（ipv6），用于 net.Dialer 的 Control
+func setSocketTTL(c syscall.RawConn, isIPv6 bool, ttl int) error {
+	var serr error
+	err := c.Control(func(fd uintptr) {
+		if isIPv6 {
+			serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, ttl)
+		} else {
+			serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TTL, ttl)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,157 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ssh"
+)
+
+// errHostKeyReceived 用于在拿到 host key 之后中断 SSH 握手，探测不需要登录
+var errHostKeyReceived = errors.New("host key received")
+
+// bannerConn 记录服务端发送的版本号那一行，比如 SSH-2.0-OpenSSH_8.9p1
+type bannerConn struct {
+	net.Conn
+
+	mu         sync.Mutex
+	line       []byte
+	banner     string
+	bannerTime time.Time
+}
+
+func (c *bannerConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.banner != "" {
+		return n, err
+	}
+	// RFC 4253 允许服务端在版本号之前发送其他的行
+	for _, b := range p[:n] {
+		if b != '\n' {
+			c.line = append(c.line, b)
+			continue
+		}
+		line := strings.TrimRight(string(c.line), "\r")
+		c.line = c.line[:0]
+		if strings.HasPrefix(line, "SSH-") {
+			c.banner = line
+			c.bannerTime = time.Now()
+			break
+		}
+	}
+	return n, err
+}
+
+func (c *bannerConn) getBanner() (string, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.banner, c.bannerTime
+}
+
+func ProbeSSH(ctx context.Context, target string, module Module, registry *prometheus.Registry) bool {
+	var (
+		durationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_ssh_duration_seconds",
+			Help: "Duration of ssh probe by phase",
+		}, []string{"phase"})
+		infoGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_ssh_info",
+			Help: "Contains the SSH banner and the host key of the server",
+		}, []string{"banner", "host_key_type", "fingerprint_sha256"})
+		probeFailedDueToRegex = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_failed_due_to_regex",
+			Help: "Indicates if probe failed due to regex",
+		})
+	)
+	registry.MustRegister(durationGaugeVec)
+
+	sshConfig := module.SSH
+
+	_, addr, ipVersion, lookupTime, err := resolveTargetAddr(ctx, target, "22", sshConfig.IPProtocol, sshConfig.IPProtocolFallback, registry)
+	durationGaugeVec.WithLabelValues("resolve").Set(lookupTime)
+	if err != nil {
+		logger.Errorf("%v, target: %s", err, target)
+		return false
+	}
+
+	dialer, err := newDialer("tcp", sshConfig.SourceIPAddress)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return false
+	}
+	start := time.Now()
+	c, err := dialer.DialContext(ctx, "tcp"+ipVersion, addr)
+	connectDone := time.Now()
+	durationGaugeVec.WithLabelValues("connect").Set(connectDone.Sub(start).Seconds())
+	if err != nil {
+		logger.Errorf("error dialing tcp: %v, target: %s", err, target)
+		return false
+	}
+	defer c.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := c.SetDeadline(deadline); err != nil {
+		logger.Errorf("error setting deadline: %v, target: %s", err, target)
+		return false
+	}
+
+	conn := &bannerConn{Conn: c}
+	var hostKey ssh.PublicKey
+	clientConfig := &ssh.ClientConfig{
+		User: "cprobe",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return errHostKeyReceived
+		},
+		HostKeyAlgorithms: sshConfig.HostKeyAlgorithms,
+	}
+	_, _, _, err = ssh.NewClientConn(conn, addr, clientConfig)
+	kexDone := time.Now()
+
+	banner, bannerTime := conn.getBanner()
+	if !bannerTime.IsZero() {
+		durationGaugeVec.WithLabelValues("banner").Set(bannerTime.Sub(connectDone).Seconds())
+		durationGaugeVec.WithLabelValues("kex").Set(kexDone.Sub(bannerTime).Seconds())
+	}
+	if hostKey == nil {
+		logger.Errorf("error doing ssh handshake: %v, target: %s", err, target)
+		return false
+	}
+
+	fingerprint := ssh.FingerprintSHA256(hostKey)
+	registry.MustRegister(infoGaugeVec)
+	infoGaugeVec.WithLabelValues(banner, hostKey.Type(), fingerprint).Set(1)
+
+	if sshConfig.FailIfBannerNotMatches.Regexp != nil {
+		registry.MustRegister(probeFailedDueToRegex)
+		if !sshConfig.FailIfBannerNotMatches.MatchString(banner) {
+			probeFailedDueToRegex.Set(1)
+			logger.Errorf("ssh banner %q did not match regular expression %v, target: %s", banner, sshConfig.FailIfBannerNotMatches, target)
+			return false
+		}
+		probeFailedDueToRegex.Set(0)
+	}
+
+	if len(sshConfig.HostKeyFingerprints) > 0 {
+		found := false
+		for _, fp := range sshConfig.HostKeyFingerprints {
+			if fp == fingerprint {
+				found = true
+				break
+			}
+		}
+		if !found {
+			logger.Errorf("unexpected ssh host key fingerprint %s, host_key_fingerprints: %v, target: %s", fingerprint, sshConfig.HostKeyFingerprints, target)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package prober
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ssh"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestProbeSSH(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate host key: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("cannot create signer: %s", err)
+	}
+	serverConfig := &ssh.ServerConfig{
+		NoClientAuth:  true,
+		ServerVersion: "SSH-2.0-OpenSSH_9.6",
+	}
+	serverConfig.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen tcp: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				_, _, _, _ = ssh.NewServerConn(c, serverConfig)
+			}()
+		}
+	}()
+
+	fingerprint := ssh.FingerprintSHA256(signer.PublicKey())
+	f := func(config string, wantSuccess bool) {
+		t.Helper()
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		registry := prometheus.NewRegistry()
+		if success := ProbeSSH(ctx, ln.Addr().String(), module, registry); success != wantSuccess {
+			t.Fatalf("unexpected probe result for config %s; got %v; want %v", config, success, wantSuccess)
+		}
+		if !wantSuccess {
+			return
+		}
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("cannot gather metrics: %s", err)
+		}
+		for _, mf := range mfs {
+			if mf.GetName() != "probe_ssh_info" {
+				continue
+			}
+			labels := make(map[string]string)
+			for _, lp := range mf.GetMetric()[0].GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			if labels["banner"] != "SSH-2.0-OpenSSH_9.6" || labels["host_key_type"] != "ssh-ed25519" || labels["fingerprint_sha256"] != fingerprint {
+				t.Fatalf("unexpected probe_ssh_info labels: %v", labels)
+			}
+			return
+		}
+		t.Fatalf("missing probe_ssh_info metric")
+	}
+	f(`prober: ssh`, true)
+	f(`{prober: ssh, ssh: {fail_if_banner_not_matches: "OpenSSH_9"}}`, true)
+	f(`{prober: ssh, ssh: {fail_if_banner_not_matches: "OpenSSH_7"}}`, false)
+	f(`{prober: ssh, ssh: {host_key_fingerprints: ["`+fingerprint+`"]}}`, true)
+	f(`{prober: ssh, ssh: {host_key_fingerprints: ["SHA256:AAAA"]}}`, false)
+}
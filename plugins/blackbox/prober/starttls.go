@@ -0,0 +1,177 @@
+package prober
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+const (
+	mysqlClientLongPassword     = 0x00000001
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+)
+
+// startTLS 在 TLS 握手之前按照 protocol 和服务端协商，返回 nil 之后就可以在 conn 上做 TLS 握手了
+func startTLS(conn net.Conn, protocol string) error {
+	switch protocol {
+	case "smtp":
+		return startTLSSMTP(conn)
+	case "imap":
+		return startTLSIMAP(conn)
+	case "postgres":
+		return startTLSPostgres(conn)
+	case "mysql":
+		return startTLSMySQL(conn)
+	default:
+		return fmt.Errorf("unsupported starttls protocol %q", protocol)
+	}
+}
+
+func startTLSSMTP(conn net.Conn) error {
+	tp := textproto.NewConn(conn)
+	if _, _, err := tp.ReadResponse(220); err != nil {
+		return fmt.Errorf("error reading smtp greeting: %w", err)
+	}
+	if err := tp.PrintfLine("EHLO localhost"); err != nil {
+		return err
+	}
+	_, msg, err := tp.ReadResponse(250)
+	if err != nil {
+		return fmt.Errorf("error sending EHLO: %w", err)
+	}
+	supported := false
+	for _, line := range strings.Split(msg, "\n") {
+		if strings.EqualFold(strings.TrimSpace(line), "STARTTLS") {
+			supported = true
+		}
+	}
+	if !supported {
+		return errors.New("server doesn't support STARTTLS")
+	}
+	if err := tp.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+	if _, _, err := tp.ReadResponse(220); err != nil {
+		return fmt.Errorf("error sending STARTTLS: %w", err)
+	}
+	return nil
+}
+
+func startTLSIMAP(conn net.Conn) error {
+	r := bufio.NewReader(conn)
+	if err := readIMAPGreeting(r); err != nil {
+		return err
+	}
+	if _, err := imapCommand(conn, r, "a001", "STARTTLS"); err != nil {
+		return fmt.Errorf("STARTTLS failed: %w", err)
+	}
+	return nil
+}
+
+func readIMAPGreeting(r *bufio.Reader) error {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("error reading imap greeting: %w", err)
+	}
+	if !strings.HasPrefix(line, "* OK") {
+		return fmt.Errorf("unexpected imap greeting: %q", strings.TrimSpace(line))
+	}
+	return nil
+}
+
+// imapCommand 发送带 tag 的命令，返回 tag 响应之前的所有 untagged 响应，tag 响应不是 OK 的时候返回错误
+func imapCommand(conn net.Conn, r *bufio.Reader, tag, command string) ([]string, error) {
+	if _, err := io.WriteString(conn, tag+" "+command+"\r\n"); err != nil {
+		return nil, err
+	}
+	var untagged []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s response: %w", command, err)
+		}
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, tag+" ") {
+			untagged = append(untagged, line)
+			continue
+		}
+		if !strings.HasPrefix(line, tag+" OK") {
+			return nil, fmt.Errorf("%q", line)
+		}
+		return untagged, nil
+	}
+}
+
+func startTLSPostgres(conn net.Conn) error {
+	// SSLRequest: Int32(8) Int32(80877103)
+	if _, err := conn.Write([]byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}); err != nil {
+		return err
+	}
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		return fmt.Errorf("error reading SSLRequest response: %w", err)
+	}
+	if b[0] != 'S' {
+		return fmt.Errorf("server doesn't support SSL; got response %q", b[0])
+	}
+	return nil
+}
+
+func startTLSMySQL(conn net.Conn) error {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("error reading mysql handshake: %w", err)
+	}
+	payload := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return fmt.Errorf("error reading mysql handshake: %w", err)
+	}
+	if len(payload) == 0 {
+		return errors.New("empty mysql handshake packet")
+	}
+	if payload[0] == 0xff {
+		if len(payload) > 3 {
+			return fmt.Errorf("mysql error: %s", bytes.TrimLeft(payload[3:], "#"))
+		}
+		return errors.New("mysql error")
+	}
+	if payload[0] != 10 {
+		return fmt.Errorf("unsupported mysql protocol version %d", payload[0])
+	}
+
+	// protocol version、以 0 结尾的 server version、connection id、auth-plugin-data-part-1 和 filler 之后是 capability flags
+	n := bytes.IndexByte(payload[1:], 0)
+	if n < 0 {
+		return errors.New("malformed mysql handshake packet")
+	}
+	pos := 1 + n + 1 + 4 + 8 + 1
+	if len(payload) < pos+2 {
+		return errors.New("malformed mysql handshake packet")
+	}
+	capabilities := uint32(binary.LittleEndian.Uint16(payload[pos:]))
+	if capabilities&mysqlClientSSL == 0 {
+		return errors.New("server doesn't support SSL")
+	}
+	charset := byte(33)
+	if len(payload) > pos+2 {
+		charset = payload[pos+2]
+	}
+
+	// SSLRequest 是 HandshakeResponse41 的前 32 个字节
+	req := make([]byte, 4+32)
+	req[0] = 32
+	req[3] = header[3] + 1
+	binary.LittleEndian.PutUint32(req[4:], mysqlClientLongPassword|mysqlClientProtocol41|mysqlClientSSL|mysqlClientSecureConnection)
+	binary.LittleEndian.PutUint32(req[8:], 1<<24)
+	req[12] = charset
+	_, err := conn.Write(req)
+	return err
+}
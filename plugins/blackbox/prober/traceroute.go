@@ -0,0 +1,500 @@
+package prober
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// tracerouteRoundInterval 是两轮探测之间的间隔，每一轮给每个 TTL 发一个探测包，
+// 避免同一跳的多个探测包同时到达路由器，被 ICMP 限速丢掉
+const tracerouteRoundInterval = 50 * time.Millisecond
+
+type traceProbe struct {
+	ttl     int
+	sentAt  time.Time
+	rtt     time.Duration
+	from    string
+	replied bool
+}
+
+// tracer 记录已经发出的探测包，key 是 icmp 的 seq、udp 的目标端口或者 tcp 的源端口
+type tracer struct {
+	mu      sync.Mutex
+	probes  map[int]*traceProbe
+	reached int
+	notify  chan struct{}
+}
+
+func newTracer() *tracer {
+	return &tracer{
+		probes: make(map[int]*traceProbe),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+func (t *tracer) add(key, ttl int) {
+	t.mu.Lock()
+	t.probes[key] = &traceProbe{ttl: ttl, sentAt: time.Now()}
+	t.mu.Unlock()
+}
+
+// reply 记录探测包的响应，fromTarget 为 true 说明已经到达 target
+func (t *tracer) reply(key int, from net.IP, at time.Time, fromTarget bool) {
+	t.mu.Lock()
+	p, ok := t.probes[key]
+	if ok && !p.replied {
+		p.replied = true
+		p.rtt = at.Sub(p.sentAt)
+		p.from = from.String()
+		if fromTarget && (t.reached == 0 || p.ttl < t.reached) {
+			t.reached = p.ttl
+		}
+	}
+	t.mu.Unlock()
+
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+// reachedTTL 返回到达 target 的最小 TTL，还没有到达的时候返回 0
+func (t *tracer) reachedTTL() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.reached
+}
+
+// done 判断是不是所有需要关心的探测包都收到了响应，到达 target 之后更大 TTL 的探测包不用再等
+func (t *tracer) done() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range t.probes {
+		if !p.replied && (t.reached == 0 || p.ttl <= t.reached) {
+			return false
+		}
+	}
+	return true
+}
+
+type traceHop struct {
+	hop     int
+	address string
+	sent    int
+	lost    int
+	rtt     time.Duration
+}
+
+// hops 汇总每一跳的结果，address 取回复次数最多的地址，rtt 是所有回复的平均值
+func (t *tracer) hops(firstHop, lastHop int) []traceHop {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	byHop := make(map[int][]*traceProbe)
+	for _, p := range t.probes {
+		byHop[p.ttl] = append(byHop[p.ttl], p)
+	}
+
+	var result []traceHop
+	for ttl := firstHop; ttl <= lastHop; ttl++ {
+		probes := byHop[ttl]
+		if len(probes) == 0 {
+			continue
+		}
+		h := traceHop{hop: ttl, sent: len(probes)}
+		counts := make(map[string]int)
+		var total time.Duration
+		for _, p := range probes {
+			if !p.replied {
+				h.lost++
+				continue
+			}
+			counts[p.from]++
+			total += p.rtt
+		}
+		if replied := h.sent - h.lost; replied > 0 {
+			h.rtt = total / time.Duration(replied)
+			h.address = mostCommonAddress(counts)
+		}
+		result = append(result, h)
+	}
+	return result
+}
+
+func mostCommonAddress(counts map[string]int) string {
+	addrs := make([]string, 0, len(counts))
+	for addr := range counts {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	var best string
+	for _, addr := range addrs {
+		if best == "" || counts[addr] > counts[best] {
+			best = addr
+		}
+	}
+	return best
+}
+
+// parseTracerouteReply 解析收到的 ICMP 消息，返回对应探测包的 key。
+// Time Exceeded 和 Destination Unreachable 里面带着原始探测包的 IP 头和前 8 个字节，从中取出 key
+func parseTracerouteReply(b []byte, isIPv6 bool, protocol string, dst net.IP) (int, bool) {
+	proto := 1
+	if isIPv6 {
+		proto = 58
+	}
+	m, err := icmp.ParseMessage(proto, b)
+	if err != nil {
+		return 0, false
+	}
+
+	switch body := m.Body.(type) {
+	case *icmp.Echo:
+		if protocol != "icmp" || body.ID != icmpID {
+			return 0, false
+		}
+		if m.Type != ipv4.ICMPTypeEchoReply && m.Type != ipv6.ICMPTypeEchoReply {
+			return 0, false
+		}
+		return body.Seq, true
+	case *icmp.TimeExceeded:
+		return quotedProbeKey(body.Data, isIPv6, protocol, dst)
+	case *icmp.DstUnreach:
+		return quotedProbeKey(body.Data, isIPv6, protocol, dst)
+	default:
+		return 0, false
+	}
+}
+
+func quotedProbeKey(data []byte, isIPv6 bool, protocol string, dst net.IP) (int, bool) {
+	var (
+		proto   int
+		qdst    net.IP
+		payload []byte
+	)
+	if isIPv6 {
+		if len(data) < ipv6.HeaderLen {
+			return 0, false
+		}
+		proto = int(data[6])
+		qdst = net.IP(data[24:40])
+		payload = data[ipv6.HeaderLen:]
+	} else {
+		if len(data) < ipv4.HeaderLen {
+			return 0, false
+		}
+		ihl := int(data[0]&0x0f) * 4
+		if ihl < ipv4.HeaderLen || len(data) < ihl {
+			return 0, false
+		}
+		proto = int(data[9])
+		qdst = net.IP(data[16:20])
+		payload = data[ihl:]
+	}
+	if !qdst.Equal(dst) || len(payload) < 8 {
+		return 0, false
+	}
+
+	switch protocol {
+	case "icmp":
+		if (proto != 1 && proto != 58) || int(binary.BigEndian.Uint16(payload[4:6])) != icmpID {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint16(payload[6:8])), true
+	case "udp":
+		if proto != 17 {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint16(payload[2:4])), true
+	case "tcp":
+		if proto != 6 {
+			return 0, false
+		}
+		return int(binary.BigEndian.Uint16(payload[0:2])), true
+	default:
+		return 0, false
+	}
+}
+
+// traceSender 发送一个 TTL 为 ttl 的探测包，seq 是探测包的序号，从 0 开始
+type traceSender func(ctx context.Context, seq, ttl int) error
+
+func ProbeTraceroute(ctx context.Context, target string, module Module, registry *prometheus.Registry) (success bool) {
+	var (
+		cfg = module.Traceroute
+
+		durationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_traceroute_duration_seconds",
+			Help: "Duration of traceroute by phase",
+		}, []string{"phase"})
+
+		hopsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_traceroute_hops",
+			Help: "Number of hops to the target, or the last probed hop if the target wasn't reached",
+		})
+
+		hopRTTGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_traceroute_hop_rtt_seconds",
+			Help: "Average round trip time of the replies from the hop",
+		}, []string{"hop", "address"})
+
+		hopLossGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_traceroute_hop_loss_ratio",
+			Help: "Ratio of the probes sent to the hop without reply",
+		}, []string{"hop", "address"})
+	)
+
+	for _, lv := range []string{"resolve", "setup", "trace"} {
+		durationGaugeVec.WithLabelValues(lv)
+	}
+	registry.MustRegister(durationGaugeVec, hopsGauge, hopRTTGaugeVec, hopLossGaugeVec)
+
+	dstIPAddr, lookupTime, err := chooseProtocol(ctx, cfg.IPProtocol, cfg.IPProtocolFallback, target, registry)
+	if err != nil {
+		logger.Errorf("error resolving address(%s): %v", target, err)
+		return false
+	}
+	durationGaugeVec.WithLabelValues("resolve").Add(lookupTime)
+
+	var srcIP net.IP
+	if len(cfg.SourceIPAddress) > 0 {
+		if srcIP = net.ParseIP(cfg.SourceIPAddress); srcIP == nil {
+			logger.Errorf("error parsing source ip address(%s)", cfg.SourceIPAddress)
+			return false
+		}
+	}
+
+	setupStart := time.Now()
+	isIPv6 := dstIPAddr.IP.To4() == nil
+
+	// 不管用哪种协议发送探测包，路由器都是用 ICMP 回复的
+	sock, err := openICMPSocket(isIPv6, srcIP, cfg.DontFragment && cfg.Protocol == "icmp", true)
+	if err != nil {
+		logger.Errorf("error listening to icmp socket, traceroute requires root or CAP_NET_RAW: %v", err)
+		return false
+	}
+	defer sock.close()
+
+	t := newTracer()
+	send, closeSender, err := newTraceSender(cfg, sock, dstIPAddr, srcIP, isIPv6, t)
+	if err != nil {
+		logger.Errorf("error creating traceroute sender: %v", err)
+		return false
+	}
+	durationGaugeVec.WithLabelValues("setup").Add(time.Since(setupStart).Seconds())
+
+	traceStart := time.Now()
+	deadline, _ := ctx.Deadline()
+	if err := sock.setReadDeadline(deadline); err != nil {
+		logger.Errorf("error setting socket deadline: %v", err)
+		closeSender()
+		return false
+	}
+
+	var readerWG sync.WaitGroup
+	readerWG.Add(1)
+	go func() {
+		defer readerWG.Done()
+		rb := make([]byte, 1500)
+		for {
+			n, peer, _, err := sock.readFrom(rb)
+			if err != nil {
+				var nerr net.Error
+				if (errors.As(err, &nerr) && nerr.Timeout()) || errors.Is(err, net.ErrClosed) {
+					return
+				}
+				logger.Errorf("error reading from socket: %v", err)
+				continue
+			}
+			if key, ok := parseTracerouteReply(rb[:n], isIPv6, cfg.Protocol, dstIPAddr.IP); ok {
+				t.reply(key, peer, time.Now(), peer.Equal(dstIPAddr.IP))
+			}
+		}
+	}()
+
+	traceCtx, cancel := context.WithCancel(ctx)
+	maxSent := 0
+	seq := 0
+	for round := 0; round < cfg.ProbesPerHop && traceCtx.Err() == nil; round++ {
+		if round > 0 {
+			select {
+			case <-traceCtx.Done():
+			case <-time.After(tracerouteRoundInterval):
+			}
+		}
+		for ttl := cfg.FirstHop; ttl <= cfg.MaxHops && traceCtx.Err() == nil; ttl++ {
+			if reached := t.reachedTTL(); reached > 0 && ttl > reached {
+				break
+			}
+			if err := send(traceCtx, seq, ttl); err != nil {
+				logger.Warnf("error sending traceroute probe(ttl=%d) to %s: %v", ttl, target, err)
+			}
+			seq++
+			if ttl > maxSent {
+				maxSent = ttl
+			}
+		}
+	}
+
+	wait := time.NewTimer(cfg.ReplyTimeout)
+waitLoop:
+	for !t.done() {
+		select {
+		case <-t.notify:
+		case <-wait.C:
+			break waitLoop
+		case <-traceCtx.Done():
+			break waitLoop
+		}
+	}
+	wait.Stop()
+
+	cancel()
+	closeSender()
+	// 让读响应的 goroutine 立即退出
+	sock.setReadDeadline(time.Now())
+	readerWG.Wait()
+	durationGaugeVec.WithLabelValues("trace").Add(time.Since(traceStart).Seconds())
+
+	lastHop := maxSent
+	reached := t.reachedTTL()
+	if reached > 0 {
+		lastHop = reached
+	}
+	hopsGauge.Set(float64(lastHop))
+
+	for _, h := range t.hops(cfg.FirstHop, lastHop) {
+		hop := strconv.Itoa(h.hop)
+		hopLossGaugeVec.WithLabelValues(hop, h.address).Set(float64(h.lost) / float64(h.sent))
+		if h.address != "" {
+			hopRTTGaugeVec.WithLabelValues(hop, h.address).Set(h.rtt.Seconds())
+		}
+	}
+
+	if reached == 0 {
+		logger.Warnf("traceroute to %s didn't reach the target within %d hops", target, cfg.MaxHops)
+		return false
+	}
+	return true
+}
+
+// newTraceSender 按照配置的 protocol 创建发送探测包的函数，返回的 close 函数要在探测结束之后调用
+func newTraceSender(cfg TracerouteProbe, sock *icmpSocket, dst *net.IPAddr, srcIP net.IP, isIPv6 bool, t *tracer) (traceSender, func(), error) {
+	var data []byte
+	if cfg.PayloadSize != 0 {
+		data = make([]byte, cfg.PayloadSize)
+		copy(data, "Prometheus Blackbox Exporter")
+	} else {
+		data = []byte("Prometheus Blackbox Exporter")
+	}
+
+	switch cfg.Protocol {
+	case "icmp":
+		var requestType icmp.Type = ipv4.ICMPTypeEcho
+		if isIPv6 {
+			requestType = ipv6.ICMPTypeEchoRequest
+		}
+		send := func(_ context.Context, _, ttl int) error {
+			seq := int(getICMPSequence())
+			wm := icmp.Message{
+				Type: requestType,
+				Body: &icmp.Echo{ID: icmpID, Seq: seq, Data: data},
+			}
+			wb, err := wm.Marshal(nil)
+			if err != nil {
+				return err
+			}
+			t.add(seq, ttl)
+			return sock.writeTo(wb, dst, ttl)
+		}
+		return send, func() {}, nil
+
+	case "udp":
+		port := cfg.Port
+		if port == 0 {
+			port = 33434
+		}
+		if maxPort := port + cfg.MaxHops*cfg.ProbesPerHop; maxPort > 65535 {
+			return nil, nil, fmt.Errorf("port %d is too large for %d probes", port, cfg.MaxHops*cfg.ProbesPerHop)
+		}
+
+		network, laddr := "udp4", &net.UDPAddr{IP: srcIP}
+		if isIPv6 {
+			network = "udp6"
+		}
+		conn, err := net.ListenUDP(network, laddr)
+		if err != nil {
+			return nil, nil, err
+		}
+		send := func(_ context.Context, seq, ttl int) error {
+			var err error
+			if isIPv6 {
+				err = ipv6.NewPacketConn(conn).SetHopLimit(ttl)
+			} else {
+				err = ipv4.NewPacketConn(conn).SetTTL(ttl)
+			}
+			if err != nil {
+				return err
+			}
+			// 每个探测包使用不同的目标端口，收到 ICMP 响应的时候根据端口找到对应的探测包
+			dport := port + seq
+			t.add(dport, ttl)
+			_, err = conn.WriteTo(data, &net.UDPAddr{IP: dst.IP, Port: dport, Zone: dst.Zone})
+			return err
+		}
+		return send, func() { conn.Close() }, nil
+
+	case "tcp":
+		port := cfg.Port
+		if port == 0 {
+			port = 80
+		}
+		// 每个探测包使用不同的源端口，收到 ICMP 响应的时候根据源端口找到对应的探测包
+		basePort := 40000 + rand.Intn(20000-cfg.MaxHops*cfg.ProbesPerHop)
+		raddr := net.JoinHostPort(dst.IP.String(), strconv.Itoa(port))
+
+		var wg sync.WaitGroup
+		send := func(ctx context.Context, seq, ttl int) error {
+			sport := basePort + seq
+			dialer := &net.Dialer{
+				LocalAddr: &net.TCPAddr{IP: srcIP, Port: sport},
+				Control: func(_, _ string, c syscall.RawConn) error {
+					return setSocketTTL(c, isIPv6, ttl)
+				},
+			}
+			t.add(sport, ttl)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				conn, err := dialer.DialContext(ctx, "tcp", raddr)
+				// 不管是建立连接还是被拒绝，都说明已经到达 target
+				if err == nil {
+					conn.Close()
+					t.reply(sport, dst.IP, time.Now(), true)
+				} else if errors.Is(err, syscall.ECONNREFUSED) {
+					t.reply(sport, dst.IP, time.Now(), true)
+				}
+			}()
+			return nil
+		}
+		return send, wg.Wait, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported traceroute protocol %q", cfg.Protocol)
+	}
+}
@@ -0,0 +1,165 @@
+package prober
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestParseTracerouteReply(t *testing.T) {
+	dst := net.ParseIP("192.0.2.10").To4()
+
+	quoted := func(protocol int, dst net.IP, payload []byte) []byte {
+		t.Helper()
+		h := &ipv4.Header{
+			Version:  ipv4.Version,
+			Len:      ipv4.HeaderLen,
+			TotalLen: ipv4.HeaderLen + len(payload),
+			TTL:      1,
+			Protocol: protocol,
+			Src:      net.ParseIP("192.0.2.1").To4(),
+			Dst:      dst,
+		}
+		b, err := h.Marshal()
+		if err != nil {
+			t.Fatalf("cannot marshal ip header: %s", err)
+		}
+		return append(b, payload...)
+	}
+	marshal := func(m icmp.Message) []byte {
+		t.Helper()
+		b, err := m.Marshal(nil)
+		if err != nil {
+			t.Fatalf("cannot marshal icmp message: %s", err)
+		}
+		return b
+	}
+	f := func(b []byte, protocol string, wantKey int, wantOK bool) {
+		t.Helper()
+		key, ok := parseTracerouteReply(b, false, protocol, dst)
+		if ok != wantOK || key != wantKey {
+			t.Fatalf("unexpected result for protocol %s; got (%d, %v); want (%d, %v)", protocol, key, ok, wantKey, wantOK)
+		}
+	}
+
+	icmpPayload := make([]byte, 8)
+	icmpPayload[0] = 8
+	binary.BigEndian.PutUint16(icmpPayload[4:6], uint16(icmpID))
+	binary.BigEndian.PutUint16(icmpPayload[6:8], 1234)
+
+	udpPayload := make([]byte, 8)
+	binary.BigEndian.PutUint16(udpPayload[0:2], 50000)
+	binary.BigEndian.PutUint16(udpPayload[2:4], 33440)
+
+	tcpPayload := make([]byte, 8)
+	binary.BigEndian.PutUint16(tcpPayload[0:2], 45678)
+	binary.BigEndian.PutUint16(tcpPayload[2:4], 443)
+
+	timeExceeded := func(data []byte) []byte {
+		return marshal(icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: data}})
+	}
+
+	// Time Exceeded from routers
+	f(timeExceeded(quoted(1, dst, icmpPayload)), "icmp", 1234, true)
+	f(timeExceeded(quoted(17, dst, udpPayload)), "udp", 33440, true)
+	f(timeExceeded(quoted(6, dst, tcpPayload)), "tcp", 45678, true)
+
+	// probes of other protocols, other targets or other processes are ignored
+	f(timeExceeded(quoted(17, dst, udpPayload)), "icmp", 0, false)
+	f(timeExceeded(quoted(17, net.ParseIP("192.0.2.11").To4(), udpPayload)), "udp", 0, false)
+	otherID := append([]byte{}, icmpPayload...)
+	binary.BigEndian.PutUint16(otherID[4:6], uint16(icmpID+1))
+	f(timeExceeded(quoted(1, dst, otherID)), "icmp", 0, false)
+
+	// truncated quoted datagram
+	f(timeExceeded(quoted(17, dst, udpPayload[:4])), "udp", 0, false)
+
+	// port unreachable from the target
+	f(marshal(icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: 3, Body: &icmp.DstUnreach{Data: quoted(17, dst, udpPayload)}}), "udp", 33440, true)
+
+	// echo reply from the target
+	f(marshal(icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: icmpID, Seq: 42}}), "icmp", 42, true)
+	f(marshal(icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: icmpID + 1, Seq: 42}}), "icmp", 0, false)
+	f(marshal(icmp.Message{Type: ipv4.ICMPTypeEcho, Body: &icmp.Echo{ID: icmpID, Seq: 42}}), "icmp", 0, false)
+}
+
+func TestTracerHops(t *testing.T) {
+	tr := newTracer()
+	now := time.Now()
+	router := net.ParseIP("10.0.0.1")
+	target := net.ParseIP("192.0.2.10")
+
+	// hop 2 answers from two addresses (ECMP), the target is reached at hop 3
+	for i, ttl := range []int{1, 2, 3, 4, 1, 2, 3, 1, 2, 3} {
+		tr.add(i, ttl)
+	}
+	if tr.done() {
+		t.Fatalf("tracer must not be done before receiving replies")
+	}
+	tr.reply(0, router, now.Add(10*time.Millisecond), false)
+	tr.reply(4, router, now.Add(30*time.Millisecond), false)
+	tr.reply(2, target, now.Add(20*time.Millisecond), true)
+	tr.reply(3, target, now.Add(20*time.Millisecond), true)
+	tr.reply(6, target, now.Add(20*time.Millisecond), true)
+	tr.reply(9, target, now.Add(20*time.Millisecond), true)
+
+	if n := tr.reachedTTL(); n != 3 {
+		t.Fatalf("unexpected reached ttl; got %d; want 3", n)
+	}
+	if tr.done() {
+		t.Fatalf("tracer must wait for hops before the target")
+	}
+	tr.reply(7, router, now.Add(20*time.Millisecond), false)
+	tr.reply(1, net.ParseIP("10.0.0.2"), now, false)
+	tr.reply(5, net.ParseIP("10.0.0.3"), now, false)
+	tr.reply(8, net.ParseIP("10.0.0.3"), now, false)
+	if !tr.done() {
+		t.Fatalf("tracer must be done after all hops replied")
+	}
+
+	hops := tr.hops(1, 3)
+	if len(hops) != 3 {
+		t.Fatalf("unexpected number of hops; got %d; want 3", len(hops))
+	}
+	if h := hops[0]; h.address != "10.0.0.1" || h.lost != 0 || h.sent != 3 {
+		t.Fatalf("unexpected hop 1: %+v", h)
+	}
+	if h := hops[1]; h.address != "10.0.0.3" {
+		t.Fatalf("unexpected address of hop 2; got %q; want the most common one", h.address)
+	}
+	if h := hops[2]; h.address != "192.0.2.10" || h.lost != 0 {
+		t.Fatalf("unexpected hop 3: %+v", h)
+	}
+}
+
+func TestTracerouteProbeConfig(t *testing.T) {
+	f := func(config string, wantErr bool) {
+		t.Helper()
+		var module Module
+		err := yaml.Unmarshal([]byte(config), &module)
+		if (err != nil) != wantErr {
+			t.Fatalf("unexpected error for config %s; got %v; want error: %v", config, err, wantErr)
+		}
+	}
+
+	f("prober: traceroute\n", false)
+	f("traceroute:\n  protocol: udp\n  max_hops: 20\n", false)
+	f("traceroute:\n  protocol: sctp\n", true)
+	f("traceroute:\n  protocol: tcp\n  dont_fragment: true\n", true)
+	f("traceroute:\n  max_hops: 300\n", true)
+	f("traceroute:\n  first_hop: 5\n  max_hops: 4\n", true)
+	f("traceroute:\n  probes_per_hop: 0\n", true)
+
+	var module Module
+	if err := yaml.Unmarshal([]byte("traceroute:\n  max_hops: 16\n"), &module); err != nil {
+		t.Fatalf("cannot parse config: %s", err)
+	}
+	if module.Traceroute.Protocol != "icmp" || module.Traceroute.ProbesPerHop != 3 || module.Traceroute.ReplyTimeout != time.Second {
+		t.Fatalf("unexpected defaults: %+v", module.Traceroute)
+	}
+}
@@ -0,0 +1,87 @@
+package prober
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxUDPPayloadSize 是 UDP 包的最大长度
+const maxUDPPayloadSize = 65535
+
+func ProbeUDP(ctx context.Context, target string, module Module, registry *prometheus.Registry) bool {
+	var (
+		durationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_udp_duration_seconds",
+			Help: "Duration of udp probe by phase",
+		}, []string{"phase"})
+		probeFailedDueToRegex = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_failed_due_to_regex",
+			Help: "Indicates if probe failed due to regex",
+		})
+	)
+	registry.MustRegister(durationGaugeVec)
+
+	udpConfig := module.UDP
+
+	_, addr, ipVersion, lookupTime, err := resolveTargetAddr(ctx, target, "", udpConfig.IPProtocol, udpConfig.IPProtocolFallback, registry)
+	durationGaugeVec.WithLabelValues("resolve").Set(lookupTime)
+	if err != nil {
+		logger.Errorf("%v, target: %s", err, target)
+		return false
+	}
+
+	payload := []byte(udpConfig.Payload)
+	if udpConfig.PayloadHex != "" {
+		// 已经在解析配置的时候校验过了
+		payload, _ = hex.DecodeString(udpConfig.PayloadHex)
+	}
+
+	dialer, err := newDialer("udp", udpConfig.SourceIPAddress)
+	if err != nil {
+		logger.Errorf("%v", err)
+		return false
+	}
+	conn, err := dialer.DialContext(ctx, "udp"+ipVersion, addr)
+	if err != nil {
+		logger.Errorf("error dialing udp: %v, target: %s", err, target)
+		return false
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		logger.Errorf("error setting deadline: %v, target: %s", err, target)
+		return false
+	}
+
+	start := time.Now()
+	if _, err := conn.Write(payload); err != nil {
+		logger.Errorf("error writing to connection: %v, target: %s", err, target)
+		return false
+	}
+
+	if udpConfig.Expect.Regexp == nil {
+		return true
+	}
+
+	buf := make([]byte, maxUDPPayloadSize)
+	n, err := conn.Read(buf)
+	durationGaugeVec.WithLabelValues("roundtrip").Set(time.Since(start).Seconds())
+	if err != nil {
+		logger.Errorf("error reading from connection: %v, target: %s", err, target)
+		return false
+	}
+
+	registry.MustRegister(probeFailedDueToRegex)
+	if !udpConfig.Expect.Regexp.Match(buf[:n]) {
+		probeFailedDueToRegex.Set(1)
+		logger.Errorf("response did not match regular expression, target: %s, regexp: %v", target, udpConfig.Expect.Regexp)
+		return false
+	}
+	probeFailedDueToRegex.Set(0)
+	return true
+}
@@ -0,0 +1,54 @@
+package prober
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestProbeUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen udp: %s", err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(append([]byte("echo: "), buf[:n]...), addr)
+		}
+	}()
+
+	f := func(config string, wantSuccess bool) {
+		t.Helper()
+		var module Module
+		if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+			t.Fatalf("cannot parse config: %s", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if success := ProbeUDP(ctx, pc.LocalAddr().String(), module, prometheus.NewRegistry()); success != wantSuccess {
+			t.Fatalf("unexpected probe result for config %s; got %v; want %v", config, success, wantSuccess)
+		}
+	}
+	f(`{prober: udp, udp: {payload: ping, expect: "^echo: ping$"}}`, true)
+	f(`{prober: udp, udp: {payload_hex: "0102", expect: "^echo: \\x01\\x02$"}}`, true)
+	f(`{prober: udp, udp: {payload: ping, expect: "pong"}}`, false)
+	f(`{prober: udp, udp: {payload: ping}}`, true)
+
+	var module Module
+	if err := yaml.Unmarshal([]byte(`{udp: {payload: a, payload_hex: "01"}}`), &module); err == nil {
+		t.Fatalf("expecting non-nil error when both payload and payload_hex are set")
+	}
+	if err := yaml.Unmarshal([]byte(`{udp: {payload_hex: "xyz"}}`), &module); err == nil {
+		t.Fatalf("expecting non-nil error for invalid payload_hex")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"net"
+	"strings"
 	"time"
 
 	"github.com/cprobe/cprobe/lib/logger"
@@ -135,3 +136,45 @@ func ipHash(ip net.IP) float64 {
 	}
 	return float64(h.Sum32())
 }
+
+// resolveTargetAddr 解析 host:port 格式的 target，target 不带端口的时候使用 defaultPort
+//
+// 返回 ip:port 和对应的 network 后缀，比如 "4" 表示 ipv4，调用方拼成 tcp4、udp4 之类的 network
+func resolveTargetAddr(ctx context.Context, target, defaultPort, ipProtocol string, fallbackIPProtocol bool, registry *prometheus.Registry) (host, addr, ipVersion string, lookupTime float64, err error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		if defaultPort == "" {
+			return "", "", "", 0, fmt.Errorf("error splitting target address and port: %w", err)
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(target, "["), "]")
+		port = defaultPort
+	}
+
+	ip, lookupTime, err := chooseProtocol(ctx, ipProtocol, fallbackIPProtocol, host, registry)
+	if err != nil {
+		return "", "", "", lookupTime, fmt.Errorf("error resolving address %q: %w", host, err)
+	}
+	ipVersion = "4"
+	if ip.IP.To4() == nil {
+		ipVersion = "6"
+	}
+	return host, net.JoinHostPort(ip.String(), port), ipVersion, lookupTime, nil
+}
+
+// newDialer 返回绑定 sourceIPAddress 的 dialer，sourceIPAddress 为空的时候由系统选择
+func newDialer(network, sourceIPAddress string) (*net.Dialer, error) {
+	dialer := &net.Dialer{}
+	if sourceIPAddress == "" {
+		return dialer, nil
+	}
+	srcIP := net.ParseIP(sourceIPAddress)
+	if srcIP == nil {
+		return nil, fmt.Errorf("error parsing source ip address: %s", sourceIPAddress)
+	}
+	if strings.HasPrefix(network, "udp") {
+		dialer.LocalAddr = &net.UDPAddr{IP: srcIP}
+	} else {
+		dialer.LocalAddr = &net.TCPAddr{IP: srcIP}
+	}
+	return dialer, nil
+}
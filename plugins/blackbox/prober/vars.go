@@ -1,6 +1,10 @@
 package prober
 
-import "github.com/prometheus/common/config"
+import (
+	"time"
+
+	"github.com/prometheus/common/config"
+)
 
 var (
 	// DefaultModule set default configuration for the Module
@@ -9,6 +13,15 @@ var (
 		TCP:  DefaultTCPProbe,
 		ICMP: DefaultICMPProbe,
 		DNS:  DefaultDNSProbe,
+
+		HTTPSteps: DefaultHTTPStepsProbe,
+		UDP:       DefaultUDPProbe,
+		NTP:       DefaultNTPProbe,
+		SSH:       DefaultSSHProbe,
+		SMTP:      DefaultSMTPProbe,
+
+		Traceroute: DefaultTracerouteProbe,
+		X509:       DefaultX509Probe,
 	}
 
 	// DefaultHTTPProbe set default value for HTTPProbe
@@ -17,6 +30,11 @@ var (
 		HTTPClientConfig:   config.DefaultHTTPClientConfig,
 	}
 
+	// DefaultHTTPStepsProbe set default value for HTTPStepsProbe
+	DefaultHTTPStepsProbe = HTTPStepsProbe{
+		HTTPClientConfig: config.DefaultHTTPClientConfig,
+	}
+
 	// DefaultGRPCProbe set default value for HTTPProbe
 	DefaultGRPCProbe = GRPCProbe{
 		Service:            "",
@@ -33,6 +51,8 @@ var (
 	DefaultICMPProbe = ICMPProbe{
 		IPProtocolFallback: true,
 		TTL:                DefaultICMPTTL,
+		PacketCount:        1,
+		Interval:           time.Second,
 	}
 
 	// DefaultDNSProbe set default value for DNSProbe
@@ -40,4 +60,42 @@ var (
 		IPProtocolFallback: true,
 		Recursion:          true,
 	}
+
+	// DefaultUDPProbe set default value for UDPProbe
+	DefaultUDPProbe = UDPProbe{
+		IPProtocolFallback: true,
+	}
+
+	// DefaultNTPProbe set default value for NTPProbe
+	DefaultNTPProbe = NTPProbe{
+		IPProtocolFallback: true,
+		MaxStratum:         15,
+	}
+
+	// DefaultSSHProbe set default value for SSHProbe
+	DefaultSSHProbe = SSHProbe{
+		IPProtocolFallback: true,
+	}
+
+	// DefaultSMTPProbe set default value for SMTPProbe
+	DefaultSMTPProbe = SMTPProbe{
+		IPProtocolFallback: true,
+		Protocol:           "smtp",
+		EHLO:               "localhost",
+	}
+
+	// DefaultTracerouteProbe set default value for TracerouteProbe
+	DefaultTracerouteProbe = TracerouteProbe{
+		IPProtocolFallback: true,
+		Protocol:           "icmp",
+		FirstHop:           1,
+		MaxHops:            30,
+		ProbesPerHop:       3,
+		ReplyTimeout:       time.Second,
+	}
+
+	// DefaultX509Probe set default value for X509Probe
+	DefaultX509Probe = X509Probe{
+		IPProtocolFallback: true,
+	}
 )
@@ -0,0 +1,383 @@
+package prober
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	pconfig "github.com/prometheus/common/config"
+	"golang.org/x/crypto/ocsp"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var x509StartTLSDefaultPorts = map[string]string{
+	"":         "443",
+	"smtp":     "25",
+	"imap":     "143",
+	"postgres": "5432",
+	"mysql":    "3306",
+}
+
+func ProbeX509(ctx context.Context, target string, module Module, registry *prometheus.Registry) bool {
+	var (
+		cfg = module.X509
+
+		durationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_x509_duration_seconds",
+			Help: "Duration of x509 probe by phase",
+		}, []string{"phase"})
+		certInfoGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_x509_cert_info",
+			Help: "Contains certificate information, index 0 is the leaf certificate",
+		}, []string{"index", "subject", "issuer", "serial", "sans", "signature_algorithm", "public_key_algorithm"})
+		notAfterGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_x509_cert_not_after_timestamp_seconds",
+			Help: "Returns the NotAfter of the certificate in unixtime",
+		}, []string{"index", "subject", "issuer", "serial"})
+		notBeforeGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_x509_cert_not_before_timestamp_seconds",
+			Help: "Returns the NotBefore of the certificate in unixtime",
+		}, []string{"index", "subject", "issuer", "serial"})
+		keySizeGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_x509_cert_key_size_bits",
+			Help: "Returns the size of the certificate public key in bits",
+		}, []string{"index", "subject", "issuer", "serial"})
+		chainValidGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_x509_chain_valid",
+			Help: "Indicates if the certificate chain is valid against the configured CA bundle",
+		})
+		ocspStapledGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_x509_ocsp_stapled",
+			Help: "Indicates if the server staples an OCSP response",
+		})
+		ocspStatusGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_x509_ocsp_status",
+			Help: "OCSP status of the leaf certificate: 0 good, 1 revoked, 2 unknown",
+		})
+		probeSSLEarliestCertExpiry = prometheus.NewGauge(sslEarliestCertExpiryGaugeOpts)
+	)
+	registry.MustRegister(durationGaugeVec, chainValidGauge)
+
+	tlsConfig, err := pconfig.NewTLSConfig(&cfg.TLSConfig)
+	if err != nil {
+		logger.Errorf("error creating tls configuration: %v", err)
+		return false
+	}
+
+	var (
+		certs     []*x509.Certificate
+		stapled   []byte
+		keyUsages []x509.ExtKeyUsage
+	)
+	if strings.HasPrefix(target, "file://") {
+		path := strings.TrimPrefix(target, "file://")
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(module.BaseDir, path)
+		}
+		start := time.Now()
+		certs, err = loadCertificateFile(path, string(cfg.Password))
+		durationGaugeVec.WithLabelValues("load").Set(time.Since(start).Seconds())
+		if err != nil {
+			logger.Errorf("error loading certificates: %v, target: %s", err, target)
+			return false
+		}
+		// 本地文件可能是客户端证书，不限制用途
+		keyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	} else {
+		state, err := getEndpointTLSState(ctx, target, cfg, tlsConfig, durationGaugeVec, registry)
+		if err != nil {
+			logger.Errorf("%v, target: %s", err, target)
+			return false
+		}
+		certs = state.PeerCertificates
+		stapled = state.OCSPResponse
+		registry.MustRegister(ocspStapledGauge)
+		if len(stapled) > 0 {
+			ocspStapledGauge.Set(1)
+		}
+	}
+	if len(certs) == 0 {
+		logger.Errorf("no certificate found, target: %s", target)
+		return false
+	}
+
+	registry.MustRegister(certInfoGaugeVec, notAfterGaugeVec, notBeforeGaugeVec, keySizeGaugeVec, probeSSLEarliestCertExpiry)
+	var earliest time.Time
+	for i, cert := range certs {
+		index := strconv.Itoa(i)
+		subject := cert.Subject.String()
+		issuer := cert.Issuer.String()
+		serial := cert.SerialNumber.Text(16)
+		certInfoGaugeVec.WithLabelValues(index, subject, issuer, serial, strings.Join(getSANs(cert), ","),
+			cert.SignatureAlgorithm.String(), cert.PublicKeyAlgorithm.String()).Set(1)
+		notAfterGaugeVec.WithLabelValues(index, subject, issuer, serial).Set(float64(cert.NotAfter.Unix()))
+		notBeforeGaugeVec.WithLabelValues(index, subject, issuer, serial).Set(float64(cert.NotBefore.Unix()))
+		keySizeGaugeVec.WithLabelValues(index, subject, issuer, serial).Set(float64(getPublicKeySize(cert)))
+		if earliest.IsZero() || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+	probeSSLEarliestCertExpiry.Set(float64(earliest.Unix()))
+
+	success := true
+	chains, err := verifyCertificateChain(certs, tlsConfig.RootCAs, tlsConfig.ServerName, keyUsages)
+	if err != nil {
+		logger.Warnf("certificate chain is invalid: %v, target: %s", err, target)
+		if !cfg.TLSConfig.InsecureSkipVerify {
+			success = false
+		}
+	} else {
+		chainValidGauge.Set(1)
+	}
+
+	if len(stapled) > 0 || cfg.OCSP {
+		start := time.Now()
+		status, err := getOCSPStatus(ctx, certs[0], findIssuer(certs, chains), stapled)
+		durationGaugeVec.WithLabelValues("ocsp").Set(time.Since(start).Seconds())
+		if err != nil {
+			logger.Warnf("error checking ocsp status: %v, target: %s", err, target)
+		} else {
+			registry.MustRegister(ocspStatusGauge)
+			ocspStatusGauge.Set(float64(status))
+			if status == ocsp.Revoked {
+				logger.Errorf("certificate has been revoked, target: %s", target)
+				success = false
+			}
+		}
+	}
+	return success
+}
+
+// getEndpointTLSState 连接 target 并完成 TLS 握手，握手时不校验证书，证书链由调用方统一校验，这样无效的证书也能采集到指标
+func getEndpointTLSState(ctx context.Context, target string, cfg X509Probe, tlsConfig *tls.Config, durationGaugeVec *prometheus.GaugeVec, registry *prometheus.Registry) (tls.ConnectionState, error) {
+	host, addr, ipVersion, lookupTime, err := resolveTargetAddr(ctx, target, x509StartTLSDefaultPorts[cfg.StartTLS], cfg.IPProtocol, cfg.IPProtocolFallback, registry)
+	durationGaugeVec.WithLabelValues("resolve").Set(lookupTime)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+
+	dialer, err := newDialer("tcp", cfg.SourceIPAddress)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp"+ipVersion, addr)
+	durationGaugeVec.WithLabelValues("connect").Set(time.Since(start).Seconds())
+	if err != nil {
+		return tls.ConnectionState{}, fmt.Errorf("error dialing tcp: %w", err)
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return tls.ConnectionState{}, fmt.Errorf("error setting deadline: %w", err)
+	}
+
+	if cfg.StartTLS != "" {
+		start = time.Now()
+		err := startTLS(conn, cfg.StartTLS)
+		durationGaugeVec.WithLabelValues("starttls").Set(time.Since(start).Seconds())
+		if err != nil {
+			return tls.ConnectionState{}, fmt.Errorf("error doing %s starttls: %w", cfg.StartTLS, err)
+		}
+	}
+
+	handshakeConfig := tlsConfig.Clone()
+	handshakeConfig.InsecureSkipVerify = true
+	start = time.Now()
+	tlsConn := tls.Client(conn, handshakeConfig)
+	err = tlsConn.HandshakeContext(ctx)
+	durationGaugeVec.WithLabelValues("tls").Set(time.Since(start).Seconds())
+	if err != nil {
+		return tls.ConnectionState{}, fmt.Errorf("error doing tls handshake: %w", err)
+	}
+	return tlsConn.ConnectionState(), nil
+}
+
+// loadCertificateFile 读取本地的证书文件，依次尝试 PEM、DER、JKS 和 PKCS12 格式
+func loadCertificateFile(path, password string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if certs, found, err := parsePEMCertificates(data); found {
+		return certs, err
+	}
+	if certs, err := x509.ParseCertificates(data); err == nil && len(certs) > 0 {
+		return certs, nil
+	}
+	if isJavaKeyStore(data) {
+		certs, err := parseJavaKeyStore(data, password)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s as JKS: %w", path, err)
+		}
+		return leafFirst(certs), nil
+	}
+
+	// 带私钥的 keystore 用 DecodeChain，只有证书的 truststore 用 DecodeTrustStore
+	_, leaf, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err == nil {
+		return leafFirst(append([]*x509.Certificate{leaf}, caCerts...)), nil
+	}
+	certs, trustStoreErr := pkcs12.DecodeTrustStore(data, password)
+	if trustStoreErr != nil {
+		return nil, fmt.Errorf("cannot parse %s as PEM, DER, JKS or PKCS12: %w", path, err)
+	}
+	return leafFirst(certs), nil
+}
+
+// parsePEMCertificates 解析 PEM 中的所有证书，found 为 false 说明数据不是 PEM 格式
+func parsePEMCertificates(data []byte) (certs []*x509.Certificate, found bool, err error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		found = true
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, true, fmt.Errorf("cannot parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if found && len(certs) == 0 {
+		return nil, true, errors.New("no CERTIFICATE block found in PEM data")
+	}
+	return certs, found, nil
+}
+
+// leafFirst 把不是其他证书签发者的证书放到最前面，PKCS12 中的证书顺序是不确定的
+func leafFirst(certs []*x509.Certificate) []*x509.Certificate {
+	for i, c := range certs {
+		isIssuer := false
+		for j, other := range certs {
+			if i != j && bytes.Equal(other.RawIssuer, c.RawSubject) {
+				isIssuer = true
+				break
+			}
+		}
+		if !isIssuer {
+			result := append([]*x509.Certificate{c}, certs[:i]...)
+			return append(result, certs[i+1:]...)
+		}
+	}
+	return certs
+}
+
+// verifyCertificateChain 以第一个证书为叶子证书，其他证书作为中间证书校验证书链，roots 为 nil 的时候使用系统的 CA
+func verifyCertificateChain(certs []*x509.Certificate, roots *x509.CertPool, dnsName string, keyUsages []x509.ExtKeyUsage) ([][]*x509.Certificate, error) {
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		DNSName:       dnsName,
+		KeyUsages:     keyUsages,
+	}
+	for _, c := range certs[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+	return certs[0].Verify(opts)
+}
+
+// findIssuer 找到叶子证书的签发者，优先使用校验通过的证书链
+func findIssuer(certs []*x509.Certificate, chains [][]*x509.Certificate) *x509.Certificate {
+	for _, chain := range chains {
+		if len(chain) > 1 {
+			return chain[1]
+		}
+	}
+	for _, c := range certs[1:] {
+		if certs[0].CheckSignatureFrom(c) == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// getOCSPStatus 返回叶子证书的 OCSP 状态，stapled 为空的时候向证书中的 OCSP 地址查询
+func getOCSPStatus(ctx context.Context, leaf, issuer *x509.Certificate, stapled []byte) (int, error) {
+	if issuer == nil {
+		return 0, errors.New("cannot find the issuer of the leaf certificate")
+	}
+
+	if len(stapled) == 0 {
+		if len(leaf.OCSPServer) == 0 {
+			return 0, errors.New("the leaf certificate doesn't have an OCSP server")
+		}
+		body, err := ocsp.CreateRequest(leaf, issuer, nil)
+		if err != nil {
+			return 0, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(body))
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Content-Type", "application/ocsp-request")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return 0, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, leaf.OCSPServer[0])
+		}
+		stapled, err = io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	resp, err := ocsp.ParseResponseForCert(stapled, leaf, issuer)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse ocsp response: %w", err)
+	}
+	return resp.Status, nil
+}
+
+func getSANs(cert *x509.Certificate) []string {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	return sans
+}
+
+func getPublicKeySize(cert *x509.Certificate) int {
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	default:
+		return 0
+	}
+}
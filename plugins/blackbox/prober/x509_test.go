@@ -0,0 +1,394 @@
+package prober
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/ocsp"
+	yaml "gopkg.in/yaml.v3"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// newTestCert issues a certificate signed by parent, or a self-signed CA when parent is nil.
+func newTestCert(t *testing.T, parent *testCert, cn string, notAfter time.Time) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %s", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	signer, signerKey := tmpl, crypto.Signer(key)
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		tmpl.DNSNames = []string{cn}
+		tmpl.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, key.Public(), signerKey)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %s", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %s", err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func writeCertFile(t *testing.T, name string, certs ...*testCert) string {
+	t.Helper()
+	var data []byte
+	for _, c := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})...)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("cannot write %s: %s", path, err)
+	}
+	return path
+}
+
+// writeJavaKeyStore writes a JKS keystore holding chain as a private key entry and trusted as a trusted certificate entry.
+// The private key is not a real encrypted key since only certificates are read.
+func writeJavaKeyStore(t *testing.T, name, password string, chain []*testCert, trusted *testCert) string {
+	t.Helper()
+	var buf bytes.Buffer
+	writeUTF := func(s string) {
+		binary.Write(&buf, binary.BigEndian, uint16(len(s)))
+		buf.WriteString(s)
+	}
+	writeCert := func(c *testCert) {
+		writeUTF("X.509")
+		binary.Write(&buf, binary.BigEndian, uint32(len(c.cert.Raw)))
+		buf.Write(c.cert.Raw)
+	}
+	binary.Write(&buf, binary.BigEndian, []uint32{0xFEEDFEED, 2, 2})
+
+	binary.Write(&buf, binary.BigEndian, uint32(1))
+	writeUTF("server")
+	binary.Write(&buf, binary.BigEndian, time.Now().UnixMilli())
+	binary.Write(&buf, binary.BigEndian, uint32(4))
+	buf.WriteString("key!")
+	binary.Write(&buf, binary.BigEndian, uint32(len(chain)))
+	for _, c := range chain {
+		writeCert(c)
+	}
+
+	binary.Write(&buf, binary.BigEndian, uint32(2))
+	writeUTF("ca")
+	binary.Write(&buf, binary.BigEndian, time.Now().UnixMilli())
+	writeCert(trusted)
+
+	h := sha1.New()
+	for _, c := range utf16.Encode([]rune(password)) {
+		h.Write([]byte{byte(c >> 8), byte(c)})
+	}
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(buf.Bytes())
+	buf.Write(h.Sum(nil))
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("cannot write %s: %s", path, err)
+	}
+	return path
+}
+
+func probeX509(t *testing.T, target, config string) (bool, map[string]float64) {
+	t.Helper()
+	var module Module
+	if err := yaml.Unmarshal([]byte(config), &module); err != nil {
+		t.Fatalf("cannot parse config: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	registry := prometheus.NewRegistry()
+	success := ProbeX509(ctx, target, module, registry)
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("cannot gather metrics: %s", err)
+	}
+	values := make(map[string]float64)
+	for _, mf := range mfs {
+		values[mf.GetName()] = mf.GetMetric()[0].GetGauge().GetValue()
+	}
+	return success, values
+}
+
+func TestProbeX509File(t *testing.T) {
+	ca := newTestCert(t, nil, "test ca", time.Now().Add(24*time.Hour))
+	leaf := newTestCert(t, ca, "example.com", time.Now().Add(time.Hour))
+	expired := newTestCert(t, ca, "example.com", time.Now().Add(-time.Minute))
+	caFile := writeCertFile(t, "ca.pem", ca)
+	leafFile := writeCertFile(t, "leaf.pem", leaf, ca)
+
+	f := func(path, config string, wantSuccess bool, wantChainValid float64) {
+		t.Helper()
+		success, values := probeX509(t, "file://"+path, config)
+		if success != wantSuccess {
+			t.Fatalf("unexpected probe result for config %s; got %v; want %v", config, success, wantSuccess)
+		}
+		if v := values["probe_x509_chain_valid"]; v != wantChainValid {
+			t.Fatalf("unexpected probe_x509_chain_valid; got %v; want %v", v, wantChainValid)
+		}
+		if !wantSuccess {
+			return
+		}
+		if v := values["probe_ssl_earliest_cert_expiry"]; v != float64(leaf.cert.NotAfter.Unix()) {
+			t.Fatalf("unexpected probe_ssl_earliest_cert_expiry; got %v; want %v", v, leaf.cert.NotAfter.Unix())
+		}
+		if v := values["probe_x509_cert_key_size_bits"]; v != 256 {
+			t.Fatalf("unexpected probe_x509_cert_key_size_bits; got %v; want 256", v)
+		}
+	}
+
+	f(leafFile, fmt.Sprintf("x509: {tls_config: {ca_file: %s}}", caFile), true, 1)
+	f(leafFile, fmt.Sprintf("x509: {tls_config: {ca_file: %s, server_name: example.com}}", caFile), true, 1)
+	f(leafFile, fmt.Sprintf("x509: {tls_config: {ca_file: %s, server_name: example.org}}", caFile), false, 0)
+	// the chain isn't trusted by the system roots
+	f(leafFile, "prober: x509", false, 0)
+	f(leafFile, "x509: {tls_config: {insecure_skip_verify: true}}", true, 0)
+	f(writeCertFile(t, "expired.pem", expired, ca), fmt.Sprintf("x509: {tls_config: {ca_file: %s}}", caFile), false, 0)
+
+	// DER
+	derFile := filepath.Join(t.TempDir(), "leaf.der")
+	if err := os.WriteFile(derFile, leaf.cert.Raw, 0o600); err != nil {
+		t.Fatalf("cannot write %s: %s", derFile, err)
+	}
+	f(derFile, fmt.Sprintf("x509: {tls_config: {ca_file: %s}}", caFile), true, 1)
+
+	// JKS with the leaf in a private key entry and the CA as a trusted certificate entry
+	jksFile := writeJavaKeyStore(t, "keystore.jks", "changeit", []*testCert{leaf}, ca)
+	f(jksFile, fmt.Sprintf("x509: {password: changeit, tls_config: {ca_file: %s}}", caFile), true, 1)
+	// the password is only used to check the integrity
+	f(jksFile, fmt.Sprintf("x509: {tls_config: {ca_file: %s}}", caFile), true, 1)
+	if success, _ := probeX509(t, "file://"+jksFile, "x509: {password: wrong}"); success {
+		t.Fatalf("expecting failure for wrong keystore password")
+	}
+
+	// missing or malformed files
+	if success, _ := probeX509(t, "file:///non-existing.pem", "prober: x509"); success {
+		t.Fatalf("expecting failure for missing file")
+	}
+	garbageFile := filepath.Join(t.TempDir(), "garbage.p12")
+	if err := os.WriteFile(garbageFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatalf("cannot write %s: %s", garbageFile, err)
+	}
+	if success, _ := probeX509(t, "file://"+garbageFile, "prober: x509"); success {
+		t.Fatalf("expecting failure for malformed file")
+	}
+}
+
+func TestProbeX509PKCS12(t *testing.T) {
+	// modern.p12 is exported by OpenSSL 3 defaults (PBES2/AES-256-CBC, SHA-256 MAC), legacy.p12 with -legacy (3DES/RC2, SHA-1 MAC).
+	// Both hold the example.com leaf with its key and the CA from p12-ca.pem, valid until 2126.
+	config := "x509: {password: changeit, tls_config: {ca_file: testdata/p12-ca.pem, server_name: example.com}}"
+	for _, name := range []string{"modern.p12", "legacy.p12"} {
+		success, values := probeX509(t, "file://testdata/"+name, config)
+		if !success || values["probe_x509_chain_valid"] != 1 {
+			t.Fatalf("unexpected probe failure for %s; metrics: %v", name, values)
+		}
+		if v := values["probe_ssl_earliest_cert_expiry"]; v < float64(time.Date(2126, 1, 1, 0, 0, 0, 0, time.UTC).Unix()) {
+			t.Fatalf("unexpected probe_ssl_earliest_cert_expiry for %s; got %v", name, v)
+		}
+		if success, _ := probeX509(t, "file://testdata/"+name, "x509: {password: wrong}"); success {
+			t.Fatalf("expecting failure for wrong password of %s", name)
+		}
+	}
+
+	// trust store with certificates only, as created by keytool -importcert
+	ca := newTestCert(t, nil, "test ca", time.Now().Add(24*time.Hour))
+	data, err := pkcs12.Modern.EncodeTrustStore([]*x509.Certificate{ca.cert}, "changeit")
+	if err != nil {
+		t.Fatalf("cannot encode trust store: %s", err)
+	}
+	trustStoreFile := filepath.Join(t.TempDir(), "truststore.p12")
+	if err := os.WriteFile(trustStoreFile, data, 0o600); err != nil {
+		t.Fatalf("cannot write %s: %s", trustStoreFile, err)
+	}
+	success, values := probeX509(t, "file://"+trustStoreFile, fmt.Sprintf("x509: {password: changeit, tls_config: {ca_file: %s}}", writeCertFile(t, "ca.pem", ca)))
+	if !success || values["probe_ssl_earliest_cert_expiry"] != float64(ca.cert.NotAfter.Unix()) {
+		t.Fatalf("unexpected probe result for trust store; success: %v; metrics: %v", success, values)
+	}
+}
+
+// startTLSServer serves TLS with the given certificate chain, preamble is called before the TLS handshake to emulate STARTTLS.
+func startTLSServer(t *testing.T, leaf, ca *testCert, ocspStaple []byte, preamble func(c net.Conn) error) string {
+	t.Helper()
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen tcp: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{leaf.cert.Raw, ca.cert.Raw},
+			PrivateKey:  leaf.key,
+			OCSPStaple:  ocspStaple,
+		}},
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				if preamble != nil {
+					if err := preamble(c); err != nil {
+						return
+					}
+				}
+				tc := tls.Server(c, tlsConfig)
+				tc.Handshake()
+				io.Copy(io.Discard, tc)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestProbeX509Endpoint(t *testing.T) {
+	ca := newTestCert(t, nil, "test ca", time.Now().Add(24*time.Hour))
+	leaf := newTestCert(t, ca, "example.com", time.Now().Add(time.Hour))
+	caFile := writeCertFile(t, "ca.pem", ca)
+
+	staple := func(status int) []byte {
+		resp, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+			Status:       status,
+			SerialNumber: leaf.cert.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Hour),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now().Add(-time.Minute),
+		}, ca.key)
+		if err != nil {
+			t.Fatalf("cannot create ocsp response: %s", err)
+		}
+		return resp
+	}
+
+	config := fmt.Sprintf("x509: {tls_config: {ca_file: %s, server_name: example.com}}", caFile)
+
+	success, values := probeX509(t, startTLSServer(t, leaf, ca, nil, nil), config)
+	if !success || values["probe_x509_chain_valid"] != 1 || values["probe_x509_ocsp_stapled"] != 0 {
+		t.Fatalf("unexpected result; success: %v, metrics: %v", success, values)
+	}
+	if _, ok := values["probe_x509_ocsp_status"]; ok {
+		t.Fatalf("unexpected probe_x509_ocsp_status without stapled response")
+	}
+
+	success, values = probeX509(t, startTLSServer(t, leaf, ca, staple(ocsp.Good), nil), config)
+	if !success || values["probe_x509_ocsp_stapled"] != 1 || values["probe_x509_ocsp_status"] != ocsp.Good {
+		t.Fatalf("unexpected result; success: %v, metrics: %v", success, values)
+	}
+
+	success, values = probeX509(t, startTLSServer(t, leaf, ca, staple(ocsp.Revoked), nil), config)
+	if success || values["probe_x509_ocsp_status"] != ocsp.Revoked {
+		t.Fatalf("unexpected result; success: %v, metrics: %v", success, values)
+	}
+
+	// postgres SSLRequest
+	postgres := func(c net.Conn) error {
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(c, buf); err != nil {
+			return err
+		}
+		if binary.BigEndian.Uint32(buf[4:]) != 80877103 {
+			return fmt.Errorf("unexpected request")
+		}
+		_, err := c.Write([]byte("S"))
+		return err
+	}
+	addr := startTLSServer(t, leaf, ca, nil, postgres)
+	if success, _ := probeX509(t, addr, config); success {
+		t.Fatalf("expecting failure without starttls")
+	}
+	if success, _ := probeX509(t, addr, config[:len(config)-1]+", starttls: postgres}"); !success {
+		t.Fatalf("unexpected failure with postgres starttls")
+	}
+
+	// mysql SSLRequest
+	mysql := func(c net.Conn) error {
+		payload := []byte{10}
+		payload = append(payload, "8.0.35\x00"...)
+		payload = append(payload, 1, 0, 0, 0)
+		payload = append(payload, "abcdefgh"...)
+		payload = append(payload, 0, 0x00, 0x0a, 45, 2, 0)
+		packet := append([]byte{byte(len(payload)), 0, 0, 0}, payload...)
+		if _, err := c.Write(packet); err != nil {
+			return err
+		}
+		req := make([]byte, 36)
+		if _, err := io.ReadFull(c, req); err != nil {
+			return err
+		}
+		if req[3] != 1 || binary.LittleEndian.Uint32(req[4:])&mysqlClientSSL == 0 || req[12] != 45 {
+			return fmt.Errorf("unexpected SSLRequest")
+		}
+		return nil
+	}
+	addr = startTLSServer(t, leaf, ca, nil, mysql)
+	if success, _ := probeX509(t, addr, config[:len(config)-1]+", starttls: mysql}"); !success {
+		t.Fatalf("unexpected failure with mysql starttls")
+	}
+
+	// smtp STARTTLS
+	smtp := func(c net.Conn) error {
+		fmt.Fprintf(c, "220 mail.example.com ESMTP\r\n")
+		buf := make([]byte, 512)
+		if _, err := c.Read(buf); err != nil {
+			return err
+		}
+		fmt.Fprintf(c, "250-mail.example.com\r\n250 STARTTLS\r\n")
+		if _, err := c.Read(buf); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(c, "220 Ready to start TLS\r\n")
+		return err
+	}
+	addr = startTLSServer(t, leaf, ca, nil, smtp)
+	if success, _ := probeX509(t, addr, config[:len(config)-1]+", starttls: smtp}"); !success {
+		t.Fatalf("unexpected failure with smtp starttls")
+	}
+
+	var module Module
+	if err := yaml.Unmarshal([]byte(`x509: {starttls: ftp}`), &module); err == nil {
+		t.Fatalf("expecting non-nil error for unsupported starttls protocol")
+	}
+}
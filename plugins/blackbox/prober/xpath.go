@@ -0,0 +1,245 @@
+package prober
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlNode 是 XML 文档的一个元素，text 是元素内所有文本拼接之后的结果
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     []byte
+}
+
+func (n *xmlNode) attr(name string) (string, bool) {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// parseXMLBody 解析响应 body，返回虚拟的根节点。解析是非严格模式，所以简单的 HTML 也可以用
+func parseXMLBody(body []byte) (*xmlNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse response body as XML: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{
+				name:  t.Name.Local,
+				attrs: t.Attr,
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			for _, n := range stack[1:] {
+				n.text = append(n.text, t...)
+			}
+		}
+	}
+	if len(root.children) == 0 {
+		return nil, fmt.Errorf("cannot parse response body as XML: missing root element")
+	}
+	return root, nil
+}
+
+// evalXPath 执行 XPath 的一个常用子集，返回匹配到的节点的文本或者属性值，支持：
+//
+//   - 绝对路径和任意层级匹配，比如 /response/status 和 //status
+//   - 通配符 *
+//   - 下标和属性过滤，比如 item[2]、item[@id]、item[@id='42']
+//   - 以 @attr 或者 text() 结尾取属性值或者文本
+func evalXPath(root *xmlNode, path string) ([]string, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("unsupported xpath %q: it must start with /", path)
+	}
+
+	nodes := []*xmlNode{root}
+	rest := path
+	for rest != "" {
+		descendant := strings.HasPrefix(rest, "//")
+		if descendant {
+			rest = rest[2:]
+		} else if strings.HasPrefix(rest, "/") {
+			rest = rest[1:]
+		} else {
+			return nil, fmt.Errorf("unsupported xpath %q: unexpected %q", path, rest)
+		}
+
+		var step string
+		step, rest = nextXPathStep(rest)
+		if step == "" {
+			return nil, fmt.Errorf("unsupported xpath %q: empty step", path)
+		}
+
+		if step == "text()" || strings.HasPrefix(step, "@") {
+			if rest != "" {
+				return nil, fmt.Errorf("unsupported xpath %q: %s must be the last step", path, step)
+			}
+			if descendant {
+				nodes = appendDescendants(nil, nodes, true)
+			}
+			var values []string
+			for _, n := range nodes {
+				if n == root {
+					continue
+				}
+				if step == "text()" {
+					values = append(values, string(n.text))
+				} else if v, ok := n.attr(step[1:]); ok {
+					values = append(values, v)
+				}
+			}
+			return values, nil
+		}
+
+		name, pred, err := parseXPathStep(step)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported xpath %q: %w", path, err)
+		}
+
+		var next []*xmlNode
+		for _, n := range nodes {
+			var candidates []*xmlNode
+			if descendant {
+				candidates = appendDescendants(nil, []*xmlNode{n}, false)
+			} else {
+				candidates = n.children
+			}
+			var matched []*xmlNode
+			for _, c := range candidates {
+				if (name == "*" || c.name == name) && pred.matchAttr(c) {
+					matched = append(matched, c)
+				}
+			}
+			if pred.index > 0 {
+				if pred.index > len(matched) {
+					continue
+				}
+				matched = matched[pred.index-1 : pred.index]
+			}
+			next = append(next, matched...)
+		}
+		nodes = next
+	}
+
+	values := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		values = append(values, string(n.text))
+	}
+	return values, nil
+}
+
+// nextXPathStep 返回下一个 / 之前的 step，忽略 [] 和引号里的 /
+func nextXPathStep(s string) (step, rest string) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '/' && depth == 0:
+			return s[:i], s[i:]
+		}
+	}
+	return s, ""
+}
+
+type xpathPredicate struct {
+	index     int
+	attrName  string
+	attrValue string
+	hasValue  bool
+}
+
+func (p *xpathPredicate) matchAttr(n *xmlNode) bool {
+	if p.attrName == "" {
+		return true
+	}
+	v, ok := n.attr(p.attrName)
+	if !ok {
+		return false
+	}
+	return !p.hasValue || v == p.attrValue
+}
+
+func parseXPathStep(step string) (string, *xpathPredicate, error) {
+	pred := &xpathPredicate{}
+	n := strings.IndexByte(step, '[')
+	if n < 0 {
+		return step, pred, nil
+	}
+	name := step[:n]
+	if !strings.HasSuffix(step, "]") {
+		return "", nil, fmt.Errorf("missing ] in %q", step)
+	}
+	expr := strings.TrimSpace(step[n+1 : len(step)-1])
+	if !strings.HasPrefix(expr, "@") {
+		index, err := strconv.Atoi(expr)
+		if err != nil || index < 1 {
+			return "", nil, fmt.Errorf("unsupported predicate %q; only [N], [@attr] and [@attr='value'] are supported", expr)
+		}
+		pred.index = index
+		return name, pred, nil
+	}
+
+	expr = expr[1:]
+	eq := strings.IndexByte(expr, '=')
+	if eq < 0 {
+		pred.attrName = expr
+		return name, pred, nil
+	}
+	pred.attrName = strings.TrimSpace(expr[:eq])
+	value := strings.TrimSpace(expr[eq+1:])
+	if len(value) < 2 || (value[0] != '\'' && value[0] != '"') || value[len(value)-1] != value[0] {
+		return "", nil, fmt.Errorf("attribute value must be quoted in %q", step)
+	}
+	pred.attrValue = value[1 : len(value)-1]
+	pred.hasValue = true
+	return name, pred, nil
+}
+
+// appendDescendants 把 nodes 的所有后代节点追加到 dst，includeSelf 为 true 时也包括 nodes 本身
+func appendDescendants(dst, nodes []*xmlNode, includeSelf bool) []*xmlNode {
+	for _, n := range nodes {
+		if includeSelf {
+			dst = append(dst, n)
+		}
+		dst = appendDescendants(dst, n.children, true)
+	}
+	return dst
+}
@@ -0,0 +1,40 @@
+package clickhouse
+
+import (
+	"context"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cprobe/cprobe/plugins"
+	"github.com/cprobe/cprobe/types"
+)
+
+func init() {
+	plugins.RegisterPlugin(types.PluginClickHouse, &ClickHouse{})
+}
+
+type ClickHouse struct {
+	// 这个数据结构中未来如果有变量，千万要小心并发使用变量的问题
+}
+
+func (*ClickHouse) ParseConfig(baseDir string, bs []byte) (any, error) {
+	var c Config
+	err := toml.Unmarshal(bs, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	c.BaseDir = baseDir
+
+	if c.Namespace == "" {
+		c.Namespace = "clickhouse"
+	} else if c.Namespace == "-" {
+		c.Namespace = ""
+	}
+
+	return &c, nil
+}
+
+func (*ClickHouse) Scrape(ctx context.Context, target string, c any, ss *types.Samples) error {
+	cfg := c.(*Config)
+	return cfg.Scrape(ctx, target, ss)
+}
@@ -0,0 +1,119 @@
+package clickhouse
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/clienttls"
+	"github.com/cprobe/cprobe/lib/httpreq"
+	"github.com/pkg/errors"
+)
+
+// client 通过 ClickHouse 的 HTTP 接口（默认 8123 端口）执行 SQL，结果统一使用 TabSeparatedWithNames 格式返回
+type client struct {
+	baseDir string
+	address string
+	cli     *http.Client
+	opts    *httpreq.RequestOptions
+}
+
+func newClient(baseDir, target string, opts *httpreq.RequestOptions, tlsOpts *clienttls.ClientConfig) (*client, error) {
+	if !strings.HasPrefix(target, "http") {
+		target = "http://" + target
+	}
+
+	var tlsConfig *tls.Config
+	var err error
+	if strings.HasPrefix(target, "https://") {
+		tlsConfig, err = tlsOpts.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cli, err := opts.NewClient(tlsConfig, true)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "new client failed, target: %s", target)
+	}
+
+	return &client{
+		baseDir: baseDir,
+		address: strings.TrimSuffix(target, "/") + "/",
+		cli:     cli,
+		opts:    opts,
+	}, nil
+}
+
+// query 执行 SQL，返回的每一行是 列名（小写）-> 列值 的 map，方便复用 sqlc.ParseRow
+func (c *client) query(ctx context.Context, sql string) ([]map[string]string, error) {
+	params := url.Values{}
+	params.Set("default_format", "TabSeparatedWithNames")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.address+"?"+params.Encode(), strings.NewReader(sql))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "new request failed, address: %s", c.address)
+	}
+
+	if err = c.opts.FillHeaders(req, c.baseDir); err != nil {
+		return nil, errors.WithMessagef(err, "fill headers failed, address: %s", c.address)
+	}
+
+	res, err := c.cli.Do(req)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "do request failed, address: %s", c.address)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		bs, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return nil, errors.Errorf("response status code is not 200, address: %s, code: %d, response body: %s", c.address, res.StatusCode, strings.TrimSpace(string(bs)))
+	}
+
+	return parseTSVWithNames(res.Body)
+}
+
+func parseTSVWithNames(r io.Reader) ([]map[string]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var cols []string
+	var rows []map[string]string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if cols == nil {
+			cols = strings.Split(line, "\t")
+			for i := range cols {
+				cols[i] = strings.ToLower(unescapeTSV(cols[i]))
+			}
+			continue
+		}
+
+		values := strings.Split(line, "\t")
+		if len(values) != len(cols) {
+			return nil, errors.Errorf("unexpected number of columns, expected: %d, got: %d, line: %s", len(cols), len(values), line)
+		}
+
+		row := make(map[string]string, len(cols))
+		for i := range cols {
+			row[cols[i]] = unescapeTSV(values[i])
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, scanner.Err()
+}
+
+var tsvUnescaper = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r", `\0`, "\x00", `\'`, "'")
+
+func unescapeTSV(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return tsvUnescaper.Replace(s)
+}
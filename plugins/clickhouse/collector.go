@@ -0,0 +1,299 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/cprobe/cprobe/types"
+)
+
+type collector struct {
+	cli       *client
+	ss        *types.Samples
+	namespace string
+	filter    *tableFilter
+}
+
+func (c *collector) addMetric(fields map[string]interface{}, tags ...map[string]string) {
+	c.ss.AddMetric(c.namespace, fields, tags...)
+}
+
+// tableLabels 返回 database、table 标签，如果表被过滤掉了，返回 nil
+func (c *collector) tableLabels(row map[string]string) map[string]string {
+	if !c.filter.match(row["database"], row["table"]) {
+		return nil
+	}
+	return map[string]string{
+		"database": row["database"],
+		"table":    row["table"],
+	}
+}
+
+func scrapeSystemMetrics(ctx context.Context, col *collector) error {
+	rows, err := col.cli.query(ctx, "SELECT metric, value FROM system.metrics")
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		col.addMetric(map[string]interface{}{toSnakeCase(row["metric"]): row["value"]})
+	}
+
+	return nil
+}
+
+func scrapeSystemEvents(ctx context.Context, col *collector) error {
+	rows, err := col.cli.query(ctx, "SELECT event, value FROM system.events")
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		col.addMetric(map[string]interface{}{toSnakeCase(row["event"]) + "_total": row["value"]})
+	}
+
+	return nil
+}
+
+func scrapeAsynchronousMetrics(ctx context.Context, col *collector) error {
+	rows, err := col.cli.query(ctx, "SELECT metric, value FROM system.asynchronous_metrics")
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		col.addMetric(map[string]interface{}{"async_" + toSnakeCase(row["metric"]): row["value"]})
+	}
+
+	return nil
+}
+
+func scrapeParts(ctx context.Context, col *collector) error {
+	// 先按 partition 聚合再按表聚合，不用窗口函数，21.9 之前的版本默认没有开启窗口函数
+	rows, err := col.cli.query(ctx, `
+SELECT
+    database,
+    table,
+    sum(partition_parts) AS parts,
+    sum(partition_rows) AS rows,
+    sum(partition_bytes_on_disk) AS bytes_on_disk,
+    sum(partition_data_compressed_bytes) AS data_compressed_bytes,
+    sum(partition_data_uncompressed_bytes) AS data_uncompressed_bytes,
+    max(partition_parts) AS max_parts_per_partition
+FROM (
+    SELECT
+        database,
+        table,
+        partition_id,
+        count() AS partition_parts,
+        sum(rows) AS partition_rows,
+        sum(bytes_on_disk) AS partition_bytes_on_disk,
+        sum(data_compressed_bytes) AS partition_data_compressed_bytes,
+        sum(data_uncompressed_bytes) AS partition_data_uncompressed_bytes
+    FROM system.parts
+    WHERE active
+    GROUP BY database, table, partition_id
+)
+GROUP BY database, table`)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		tags := col.tableLabels(row)
+		if tags == nil {
+			continue
+		}
+
+		col.addMetric(map[string]interface{}{
+			"table_parts":                   row["parts"],
+			"table_rows":                    row["rows"],
+			"table_bytes_on_disk":           row["bytes_on_disk"],
+			"table_data_compressed_bytes":   row["data_compressed_bytes"],
+			"table_data_uncompressed_bytes": row["data_uncompressed_bytes"],
+			"table_max_parts_per_partition": row["max_parts_per_partition"],
+		}, tags)
+	}
+
+	return nil
+}
+
+func scrapeReplicas(ctx context.Context, col *collector) error {
+	rows, err := col.cli.query(ctx, `
+SELECT
+    database,
+    table,
+    is_leader,
+    is_readonly,
+    is_session_expired,
+    future_parts,
+    parts_to_check,
+    queue_size,
+    inserts_in_queue,
+    merges_in_queue,
+    absolute_delay,
+    log_max_index - log_pointer AS log_lag,
+    total_replicas,
+    active_replicas
+FROM system.replicas`)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		tags := col.tableLabels(row)
+		if tags == nil {
+			continue
+		}
+
+		col.addMetric(map[string]interface{}{
+			"replica_is_leader":          row["is_leader"],
+			"replica_is_readonly":        row["is_readonly"],
+			"replica_is_session_expired": row["is_session_expired"],
+			"replica_future_parts":       row["future_parts"],
+			"replica_parts_to_check":     row["parts_to_check"],
+			"replica_queue_size":         row["queue_size"],
+			"replica_inserts_in_queue":   row["inserts_in_queue"],
+			"replica_merges_in_queue":    row["merges_in_queue"],
+			"replica_absolute_delay":     row["absolute_delay"],
+			"replica_log_lag":            row["log_lag"],
+			"replica_total_replicas":     row["total_replicas"],
+			"replica_active_replicas":    row["active_replicas"],
+		}, tags)
+	}
+
+	return nil
+}
+
+// scrapeMutations 统计未完成的 mutation，执行时间超过 threshold 或者最近一次执行失败的 mutation 认为是卡住了
+func scrapeMutations(ctx context.Context, col *collector, threshold time.Duration) error {
+	rows, err := col.cli.query(ctx, fmt.Sprintf(`
+SELECT
+    database,
+    table,
+    count() AS running,
+    max(dateDiff('second', create_time, now())) AS oldest_age_seconds,
+    countIf(latest_fail_reason != '') AS failed,
+    countIf(latest_fail_reason != '' OR dateDiff('second', create_time, now()) > %d) AS stuck,
+    sum(length(parts_to_do_names)) AS parts_to_do
+FROM system.mutations
+WHERE is_done = 0
+GROUP BY database, table`, int64(threshold.Seconds())))
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		tags := col.tableLabels(row)
+		if tags == nil {
+			continue
+		}
+
+		col.addMetric(map[string]interface{}{
+			"mutations_running":            row["running"],
+			"mutations_oldest_age_seconds": row["oldest_age_seconds"],
+			"mutations_failed":             row["failed"],
+			"mutations_stuck":              row["stuck"],
+			"mutations_parts_to_do":        row["parts_to_do"],
+		}, tags)
+	}
+
+	return nil
+}
+
+func scrapeMerges(ctx context.Context, col *collector) error {
+	rows, err := col.cli.query(ctx, `
+SELECT
+    database,
+    table,
+    count() AS merges,
+    max(elapsed) AS max_elapsed_seconds,
+    min(progress) AS min_progress,
+    sum(total_size_bytes_compressed) AS total_size_bytes_compressed,
+    sum(memory_usage) AS memory_usage
+FROM system.merges
+GROUP BY database, table`)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		tags := col.tableLabels(row)
+		if tags == nil {
+			continue
+		}
+
+		col.addMetric(map[string]interface{}{
+			"merges_running":                     row["merges"],
+			"merges_max_elapsed_seconds":         row["max_elapsed_seconds"],
+			"merges_min_progress":                row["min_progress"],
+			"merges_total_size_bytes_compressed": row["total_size_bytes_compressed"],
+			"merges_memory_usage_bytes":          row["memory_usage"],
+		}, tags)
+	}
+
+	// 复制表的合并队列，按任务类型统计
+	rows, err = col.cli.query(ctx, `
+SELECT
+    database,
+    table,
+    type,
+    count() AS tasks,
+    countIf(is_currently_executing) AS executing,
+    max(num_tries) AS max_num_tries,
+    max(dateDiff('second', create_time, now())) AS oldest_age_seconds
+FROM system.replication_queue
+GROUP BY database, table, type`)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		tags := col.tableLabels(row)
+		if tags == nil {
+			continue
+		}
+
+		col.addMetric(map[string]interface{}{
+			"replication_queue_tasks":              row["tasks"],
+			"replication_queue_executing":          row["executing"],
+			"replication_queue_max_num_tries":      row["max_num_tries"],
+			"replication_queue_oldest_age_seconds": row["oldest_age_seconds"],
+		}, tags, map[string]string{"type": row["type"]})
+	}
+
+	return nil
+}
+
+// toSnakeCase 把 ClickHouse 的指标名（比如 ReplicatedChecks、OSCPUVirtualTimeMicroseconds）转换成 replicated_checks 这种形式
+func toSnakeCase(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+
+	for i, r := range runes {
+		if r == '.' || r == '-' || r == ' ' {
+			b.WriteByte('_')
+			continue
+		}
+
+		if unicode.IsUpper(r) {
+			if i > 0 && runes[i-1] != '_' {
+				prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+					b.WriteByte('_')
+				}
+			}
+			b.WriteRune(unicode.ToLower(r))
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
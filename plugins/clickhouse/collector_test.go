@@ -0,0 +1,76 @@
+package clickhouse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToSnakeCase(t *testing.T) {
+	cases := map[string]string{
+		"Query":                        "query",
+		"ReplicatedChecks":             "replicated_checks",
+		"OSCPUVirtualTimeMicroseconds": "oscpu_virtual_time_microseconds",
+		"jemalloc.background_thread":   "jemalloc_background_thread",
+		"MarkCacheBytes":               "mark_cache_bytes",
+		"NumberOfTables":               "number_of_tables",
+		"ZooKeeperRequest":             "zoo_keeper_request",
+		"Uptime":                       "uptime",
+		"BlockReadBytes_ram1":          "block_read_bytes_ram1",
+	}
+
+	for in, want := range cases {
+		if got := toSnakeCase(in); got != want {
+			t.Errorf("toSnakeCase(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseTSVWithNames(t *testing.T) {
+	body := "Database\ttable\tparts\n" +
+		"default\tevents\t12\n" +
+		"db\\tx\tlogs\\\\2\t3\n"
+
+	rows, err := parseTSVWithNames(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+
+	if rows[0]["database"] != "default" || rows[0]["table"] != "events" || rows[0]["parts"] != "12" {
+		t.Fatalf("unexpected first row: %v", rows[0])
+	}
+
+	if rows[1]["database"] != "db\tx" || rows[1]["table"] != `logs\2` {
+		t.Fatalf("unexpected second row: %v", rows[1])
+	}
+
+	if _, err = parseTSVWithNames(strings.NewReader("a\tb\n1\n")); err == nil {
+		t.Fatalf("expected error for mismatched columns")
+	}
+}
+
+func TestTableFilter(t *testing.T) {
+	f, err := newTableFilter(`^(default|metrics)\.`, `\.tmp_`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !f.match("default", "events") {
+		t.Errorf("default.events should match")
+	}
+
+	if f.match("system", "query_log") {
+		t.Errorf("system.query_log should not match include")
+	}
+
+	if f.match("metrics", "tmp_load") {
+		t.Errorf("metrics.tmp_load should be excluded")
+	}
+
+	if _, err = newTableFilter("(", ""); err == nil {
+		t.Errorf("expected error for invalid include regex")
+	}
+}
@@ -0,0 +1,201 @@
+package clickhouse
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/clienttls"
+	"github.com/cprobe/cprobe/lib/httpreq"
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/cprobe/cprobe/plugins/sqlc"
+	"github.com/cprobe/cprobe/types"
+	"github.com/pkg/errors"
+)
+
+type Config struct {
+	BaseDir   string `toml:"-"`
+	Namespace string `toml:"namespace"`
+
+	// 针对 system.parts、system.replicas、system.mutations、system.merges 等按表输出的指标做过滤
+	// 匹配的对象是 database.table 这样的字符串
+	TableInclude string `toml:"table_include"`
+	TableExclude string `toml:"table_exclude"`
+
+	Queries []sqlc.CustomQuery `toml:"queries"`
+
+	CollectSystemMetrics struct {
+		Enabled bool `toml:"enabled"`
+	} `toml:"collect_system_metrics"`
+	CollectSystemEvents struct {
+		Enabled bool `toml:"enabled"`
+	} `toml:"collect_system_events"`
+	CollectAsynchronousMetrics struct {
+		Enabled bool `toml:"enabled"`
+	} `toml:"collect_asynchronous_metrics"`
+	CollectParts struct {
+		Enabled bool `toml:"enabled"`
+	} `toml:"collect_parts"`
+	CollectReplicas struct {
+		Enabled bool `toml:"enabled"`
+	} `toml:"collect_replicas"`
+	CollectMutations struct {
+		Enabled        bool          `toml:"enabled"`
+		StuckThreshold time.Duration `toml:"stuck_threshold"`
+	} `toml:"collect_mutations"`
+	CollectMerges struct {
+		Enabled bool `toml:"enabled"`
+	} `toml:"collect_merges"`
+
+	httpreq.RequestOptions
+	clienttls.ClientConfig
+}
+
+// target: ip:port 或者 http(s)://ip:port，端口是 ClickHouse 的 HTTP 端口，默认 8123
+func (c *Config) Scrape(ctx context.Context, target string, ss *types.Samples) error {
+	filter, err := newTableFilter(c.TableInclude, c.TableExclude)
+	if err != nil {
+		return err
+	}
+
+	cli, err := newClient(c.BaseDir, target, &c.RequestOptions, &c.ClientConfig)
+	if err != nil {
+		return err
+	}
+
+	rows, err := cli.query(ctx, "SELECT version() AS version, uptime() AS uptime")
+	if err != nil {
+		return errors.WithMessagef(err, "cannot ping clickhouse: %s", target)
+	}
+
+	for _, row := range rows {
+		ss.AddMetric(c.Namespace, map[string]interface{}{"uptime_seconds": row["uptime"]})
+		ss.AddMetric(c.Namespace, map[string]interface{}{"version_info": 1}, map[string]string{"version": row["version"]})
+	}
+
+	col := &collector{
+		cli:       cli,
+		ss:        ss,
+		namespace: c.Namespace,
+		filter:    filter,
+	}
+
+	for _, s := range c.enabledScrapers() {
+		if err := s.scrape(ctx, col); err != nil {
+			logger.Errorf("clickhouse(%s) failed to collect %s: %s", target, s.name, err)
+		}
+	}
+
+	c.collectCustomQueries(ctx, cli, ss)
+
+	return nil
+}
+
+type scraper struct {
+	name   string
+	scrape func(ctx context.Context, col *collector) error
+}
+
+func (c *Config) enabledScrapers() (ret []scraper) {
+	if c.CollectSystemMetrics.Enabled {
+		ret = append(ret, scraper{name: "system.metrics", scrape: scrapeSystemMetrics})
+	}
+
+	if c.CollectSystemEvents.Enabled {
+		ret = append(ret, scraper{name: "system.events", scrape: scrapeSystemEvents})
+	}
+
+	if c.CollectAsynchronousMetrics.Enabled {
+		ret = append(ret, scraper{name: "system.asynchronous_metrics", scrape: scrapeAsynchronousMetrics})
+	}
+
+	if c.CollectParts.Enabled {
+		ret = append(ret, scraper{name: "system.parts", scrape: scrapeParts})
+	}
+
+	if c.CollectReplicas.Enabled {
+		ret = append(ret, scraper{name: "system.replicas", scrape: scrapeReplicas})
+	}
+
+	if c.CollectMutations.Enabled {
+		threshold := c.CollectMutations.StuckThreshold
+		if threshold <= 0 {
+			threshold = time.Hour
+		}
+		ret = append(ret, scraper{name: "system.mutations", scrape: func(ctx context.Context, col *collector) error {
+			return scrapeMutations(ctx, col, threshold)
+		}})
+	}
+
+	if c.CollectMerges.Enabled {
+		ret = append(ret, scraper{name: "system.merges", scrape: scrapeMerges})
+	}
+
+	return
+}
+
+func (c *Config) collectCustomQueries(ctx context.Context, cli *client, ss *types.Samples) {
+	// 做成顺序执行，避免并发导致的连接数过多
+	for i := 0; i < len(c.Queries); i++ {
+		query := c.Queries[i]
+		if c.Namespace != "" {
+			query.Mesurement = c.Namespace + "_" + query.Mesurement
+		}
+
+		if query.Timeout == 0 {
+			query.Timeout = 5 * time.Second
+		}
+
+		qctx, cancel := context.WithTimeout(ctx, query.Timeout)
+		rows, err := cli.query(qctx, query.Request)
+		cancel()
+
+		if err != nil {
+			logger.Errorf("failed to query: %s, error: %s", query.Request, err)
+			continue
+		}
+
+		for _, row := range rows {
+			if err = sqlc.ParseRow(row, query, ss); err != nil {
+				logger.Errorf("failed to parse row: %s, sql: %s", err, query.Request)
+			}
+		}
+	}
+}
+
+type tableFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+func newTableFilter(include, exclude string) (*tableFilter, error) {
+	var f tableFilter
+	var err error
+
+	if include != "" {
+		f.include, err = regexp.Compile(include)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid table_include: %s", include)
+		}
+	}
+
+	if exclude != "" {
+		f.exclude, err = regexp.Compile(exclude)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid table_exclude: %s", exclude)
+		}
+	}
+
+	return &f, nil
+}
+
+func (f *tableFilter) match(database, table string) bool {
+	name := database + "." + table
+	if f.include != nil && !f.include.MatchString(name) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return false
+	}
+	return true
+}
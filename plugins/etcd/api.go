@@ -0,0 +1,179 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/httpreq"
+	"github.com/pkg/errors"
+)
+
+// int64String etcd 的 gRPC gateway 会把 int64、uint64 编码成字符串，这里两种形式都兼容
+type int64String int64
+
+func (i *int64String) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*i = 0
+		return nil
+	}
+
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*i = int64String(v)
+		return nil
+	}
+
+	// member id 之类的字段是 uint64，可能会超出 int64 的范围
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = int64String(v)
+	return nil
+}
+
+func (i int64String) id() string {
+	return strconv.FormatUint(uint64(i), 16)
+}
+
+type ResponseHeader struct {
+	ClusterID int64String `json:"cluster_id"`
+	MemberID  int64String `json:"member_id"`
+	Revision  int64String `json:"revision"`
+	RaftTerm  int64String `json:"raft_term"`
+}
+
+type Member struct {
+	ID         int64String `json:"ID"`
+	Name       string      `json:"name"`
+	PeerURLs   []string    `json:"peerURLs"`
+	ClientURLs []string    `json:"clientURLs"`
+	IsLearner  bool        `json:"isLearner"`
+}
+
+type MemberListResponse struct {
+	Header  ResponseHeader `json:"header"`
+	Members []Member       `json:"members"`
+}
+
+type StatusResponse struct {
+	Header           ResponseHeader `json:"header"`
+	Version          string         `json:"version"`
+	DBSize           int64String    `json:"dbSize"`
+	DBSizeInUse      int64String    `json:"dbSizeInUse"`
+	Leader           int64String    `json:"leader"`
+	RaftIndex        int64String    `json:"raftIndex"`
+	RaftTerm         int64String    `json:"raftTerm"`
+	RaftAppliedIndex int64String    `json:"raftAppliedIndex"`
+	IsLearner        bool           `json:"isLearner"`
+	Errors           []string       `json:"errors"`
+}
+
+type AlarmMember struct {
+	MemberID int64String `json:"memberID"`
+	Alarm    string      `json:"alarm"`
+}
+
+type AlarmResponse struct {
+	Header ResponseHeader `json:"header"`
+	Alarms []AlarmMember  `json:"alarms"`
+}
+
+type HealthResponse struct {
+	Health string `json:"health"`
+	Reason string `json:"reason"`
+}
+
+type client struct {
+	baseDir   string
+	apiPrefix string
+	token     string
+	cli       *http.Client
+	opts      *httpreq.RequestOptions
+}
+
+// post 调用 gRPC gateway 的接口，endpoint 形如 https://10.0.0.1:2379
+func (c *client) post(ctx context.Context, endpoint, path string, body, v interface{}) error {
+	bs, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(endpoint, "/") + c.apiPrefix + path
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(bs))
+	if err != nil {
+		return errors.WithMessagef(err, "new request failed, url: %s", url)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", c.token)
+	}
+
+	bs, _, err = c.do(req)
+	if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(bs, v); err != nil {
+		return errors.WithMessagef(err, "decode json failed, url: %s", url)
+	}
+
+	return nil
+}
+
+// get 请求 /health、/metrics 这类不在 gRPC gateway 下面的接口
+func (c *client) get(ctx context.Context, endpoint, path string) ([]byte, http.Header, error) {
+	url := strings.TrimSuffix(endpoint, "/") + path
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "new request failed, url: %s", url)
+	}
+
+	return c.do(req)
+}
+
+func (c *client) do(req *http.Request) ([]byte, http.Header, error) {
+	url := req.URL.String()
+	if err := c.opts.FillHeaders(req, c.baseDir); err != nil {
+		return nil, nil, errors.WithMessagef(err, "fill headers failed, url: %s", url)
+	}
+
+	res, err := c.cli.Do(req)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "do request failed, url: %s", url)
+	}
+
+	defer res.Body.Close()
+
+	bs, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, errors.WithMessagef(err, "read response body failed, url: %s", url)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, errors.Errorf("response status code is not 200, url: %s, code: %d, response body: %s", url, res.StatusCode, string(bs))
+	}
+
+	return bs, res.Header, nil
+}
+
+// authenticate 开启了 etcd 的用户认证时，需要先获取 token
+func (c *client) authenticate(ctx context.Context, endpoint, username, password string) error {
+	var res struct {
+		Token string `json:"token"`
+	}
+
+	err := c.post(ctx, endpoint, "/auth/authenticate", map[string]string{"name": username, "password": password}, &res)
+	if err != nil {
+		return err
+	}
+
+	c.token = res.Token
+	return nil
+}
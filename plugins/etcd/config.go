@@ -0,0 +1,184 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/clienttls"
+	"github.com/cprobe/cprobe/lib/httpreq"
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/cprobe/cprobe/types"
+	"github.com/pkg/errors"
+)
+
+type Config struct {
+	BaseDir string `toml:"-"`
+
+	// gRPC gateway 的路径前缀，etcd 3.4 及以上版本是 /v3，3.3 是 /v3beta
+	APIPrefix string `toml:"api_prefix"`
+
+	// 开启了 etcd 用户认证的时候需要配置
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+
+	// 是否同时抓取 target 的 /metrics 接口
+	GatherMetrics bool `toml:"gather_metrics"`
+
+	// 碎片率（1 - dbSizeInUse/dbSize）超过这个阈值，并且 db 大小超过 DefragMinDBSizeBytes 的时候，建议做 defrag
+	DefragFragmentationThreshold float64 `toml:"defrag_fragmentation_threshold"`
+	DefragMinDBSizeBytes         int64   `toml:"defrag_min_db_size_bytes"`
+
+	httpreq.RequestOptions
+	clienttls.ClientConfig
+}
+
+// target: ip:port 或者 http(s)://ip:port，是集群中任意一个成员的 client URL
+// 通过这个成员拿到成员列表，然后逐个成员获取 status 和 health
+func (c *Config) Scrape(ctx context.Context, target string, ss *types.Samples) error {
+	if !strings.HasPrefix(target, "http") {
+		target = "http://" + target
+	}
+
+	tlsConfig, err := c.ClientConfig.TLSConfig()
+	if err != nil {
+		return err
+	}
+
+	cli, err := c.RequestOptions.NewClient(tlsConfig, true)
+	if err != nil {
+		return errors.WithMessagef(err, "new client failed, target: %s", target)
+	}
+
+	api := &client{
+		baseDir:   c.BaseDir,
+		apiPrefix: c.APIPrefix,
+		cli:       cli,
+		opts:      &c.RequestOptions,
+	}
+
+	if c.Username != "" {
+		if err = api.authenticate(ctx, target, c.Username, c.Password); err != nil {
+			return errors.WithMessagef(err, "failed to authenticate, target: %s", target)
+		}
+	}
+
+	var members MemberListResponse
+	if err = api.post(ctx, target, "/cluster/member/list", map[string]interface{}{}, &members); err != nil {
+		return err
+	}
+
+	var alarms AlarmResponse
+	if err = api.post(ctx, target, "/maintenance/alarm", map[string]interface{}{"action": "GET"}, &alarms); err != nil {
+		logger.Errorf("etcd(%s) failed to get alarms: %s", target, err)
+	}
+
+	memberAlarms := make(map[int64String][]string)
+	for _, a := range alarms.Alarms {
+		memberAlarms[a.MemberID] = append(memberAlarms[a.MemberID], a.Alarm)
+	}
+
+	leaders := make(map[int64String]struct{})
+	healthy := 0
+
+	for _, m := range members.Members {
+		tags := map[string]string{
+			"member":    m.Name,
+			"member_id": m.ID.id(),
+		}
+
+		for _, alarm := range memberAlarms[m.ID] {
+			ss.AddMetric(types.PluginEtcd, map[string]interface{}{"member_alarm": 1}, tags, map[string]string{"alarm": alarm})
+		}
+
+		if len(m.ClientURLs) == 0 {
+			// 还没有启动的成员，没有 client URL
+			ss.AddMetric(types.PluginEtcd, map[string]interface{}{"member_up": 0, "member_healthy": 0}, tags)
+			continue
+		}
+
+		endpoint := m.ClientURLs[0]
+
+		var status StatusResponse
+		err := api.post(ctx, endpoint, "/maintenance/status", map[string]interface{}{}, &status)
+		if err != nil {
+			logger.Errorf("etcd(%s) failed to get status of member %s(%s): %s", target, m.Name, endpoint, err)
+			ss.AddMetric(types.PluginEtcd, map[string]interface{}{"member_up": 0, "member_healthy": 0}, tags)
+			continue
+		}
+
+		isHealthy := c.memberHealthy(ctx, api, endpoint)
+		if isHealthy {
+			healthy++
+		}
+
+		if status.Leader != 0 {
+			leaders[status.Leader] = struct{}{}
+		}
+
+		fields := map[string]interface{}{
+			"member_up":                   1,
+			"member_healthy":              isHealthy,
+			"member_is_leader":            status.Leader != 0 && status.Leader == status.Header.MemberID,
+			"member_is_learner":           status.IsLearner || m.IsLearner,
+			"member_has_leader":           status.Leader != 0,
+			"member_db_size_bytes":        int64(status.DBSize),
+			"member_db_size_in_use_bytes": int64(status.DBSizeInUse),
+			"member_raft_index":           int64(status.RaftIndex),
+			"member_raft_applied_index":   int64(status.RaftAppliedIndex),
+			"member_raft_term":            int64(status.RaftTerm),
+			"member_revision":             int64(status.Header.Revision),
+			"member_errors":               len(status.Errors),
+			"member_alarms":               len(memberAlarms[m.ID]),
+		}
+
+		if status.RaftAppliedIndex > 0 {
+			fields["member_raft_apply_lag"] = int64(status.RaftIndex - status.RaftAppliedIndex)
+		}
+
+		// dbSizeInUse 是 etcd 3.4 才有的字段
+		if status.DBSize > 0 && status.DBSizeInUse > 0 {
+			fragmentation := 1 - float64(status.DBSizeInUse)/float64(status.DBSize)
+			fields["member_db_fragmentation_ratio"] = fragmentation
+			fields["member_defrag_recommended"] = fragmentation >= c.DefragFragmentationThreshold && int64(status.DBSize) >= c.DefragMinDBSizeBytes
+		}
+
+		ss.AddMetric(types.PluginEtcd, fields, tags)
+		ss.AddMetric(types.PluginEtcd, map[string]interface{}{"member_version_info": 1}, tags, map[string]string{"version": status.Version})
+	}
+
+	ss.AddMetric(types.PluginEtcd, map[string]interface{}{
+		"cluster_members":         len(members.Members),
+		"cluster_healthy_members": healthy,
+		"cluster_alarms":          len(alarms.Alarms),
+		// 所有成员认定的 leader 数量，正常情况下是 1，0 表示没有 leader，大于 1 表示成员之间对 leader 的认知不一致
+		"cluster_leaders": len(leaders),
+	}, map[string]string{"cluster_id": members.Header.ClusterID.id()})
+
+	if c.GatherMetrics {
+		bs, header, err := api.get(ctx, target, "/metrics")
+		if err != nil {
+			logger.Errorf("etcd(%s) failed to get metrics: %s", target, err)
+		} else if err = ss.AddMetricsBody(bs, header, false); err != nil {
+			logger.Errorf("etcd(%s) failed to parse metrics: %s", target, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) memberHealthy(ctx context.Context, api *client, endpoint string) bool {
+	bs, _, err := api.get(ctx, endpoint, "/health")
+	if err != nil {
+		logger.Warnf("etcd member(%s) is unhealthy: %s", endpoint, err)
+		return false
+	}
+
+	var res HealthResponse
+	if err = json.Unmarshal(bs, &res); err != nil {
+		logger.Warnf("etcd member(%s) returns invalid health response: %s", endpoint, string(bs))
+		return false
+	}
+
+	return res.Health == "true"
+}
@@ -0,0 +1,77 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cprobe/cprobe/lib/conv"
+	"github.com/cprobe/cprobe/types"
+)
+
+func TestScrape(t *testing.T) {
+	var url string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v3/cluster/member/list", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"header":{"cluster_id":"14841639068965178418","member_id":"10276657743932975437"},
+"members":[{"ID":"10276657743932975437","name":"infra1","clientURLs":["%s"]},{"ID":"42","name":"infra2"}]}`, url)
+	})
+	mux.HandleFunc("/v3/maintenance/alarm", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"alarms":[{"memberID":"10276657743932975437","alarm":"NOSPACE"}]}`)
+	})
+	mux.HandleFunc("/v3/maintenance/status", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"header":{"member_id":"10276657743932975437","revision":"77"},"version":"3.5.9",
+"dbSize":"209715200","dbSizeInUse":"52428800","leader":"10276657743932975437","raftIndex":"120","raftTerm":"3","raftAppliedIndex":"118"}`)
+	})
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"health":"true"}`)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	url = srv.URL
+
+	cfg, err := (&Etcd{}).ParseConfig("", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ss := types.NewSamples()
+	if err = cfg.(*Config).Scrape(context.Background(), srv.URL, ss); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	values := make(map[string]float64)
+	for _, m := range ss.PopBackAll() {
+		for k, v := range m.Fields() {
+			f, err := conv.ToFloat64(v)
+			if err != nil {
+				t.Fatalf("field %s has invalid value %v", k, v)
+			}
+			values[m.Tags()["member"]+"/"+k] = f
+		}
+	}
+
+	expected := map[string]float64{
+		"infra1/member_up":                     1,
+		"infra1/member_healthy":                1,
+		"infra1/member_is_leader":              1,
+		"infra1/member_raft_apply_lag":         2,
+		"infra1/member_db_fragmentation_ratio": 0.75,
+		"infra1/member_defrag_recommended":     1,
+		"infra1/member_alarm":                  1,
+		"infra2/member_up":                     0,
+		"/cluster_members":                     2,
+		"/cluster_healthy_members":             1,
+		"/cluster_leaders":                     1,
+		"/cluster_alarms":                      1,
+	}
+
+	for k, want := range expected {
+		if got, ok := values[k]; !ok || got != want {
+			t.Errorf("%s: expected %v, got %v (present: %v)", k, want, got, ok)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package etcd
+
+import (
+	"context"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cprobe/cprobe/plugins"
+	"github.com/cprobe/cprobe/types"
+)
+
+func init() {
+	plugins.RegisterPlugin(types.PluginEtcd, &Etcd{})
+}
+
+type Etcd struct{}
+
+func (*Etcd) ParseConfig(baseDir string, bs []byte) (any, error) {
+	var c Config
+	err := toml.Unmarshal(bs, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	c.BaseDir = baseDir
+
+	if c.APIPrefix == "" {
+		c.APIPrefix = "/v3"
+	}
+
+	if c.DefragFragmentationThreshold <= 0 {
+		c.DefragFragmentationThreshold = 0.5
+	}
+
+	if c.DefragMinDBSizeBytes <= 0 {
+		c.DefragMinDBSizeBytes = 100 * 1024 * 1024
+	}
+
+	return &c, nil
+}
+
+func (*Etcd) Scrape(ctx context.Context, target string, c any, ss *types.Samples) error {
+	cfg := c.(*Config)
+	return cfg.Scrape(ctx, target, ss)
+}
@@ -0,0 +1,152 @@
+package haproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/clienttls"
+	"github.com/cprobe/cprobe/lib/httpreq"
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/cprobe/cprobe/types"
+	"github.com/pkg/errors"
+)
+
+type Config struct {
+	BaseDir string `toml:"-"`
+
+	// 通过 stats socket 抓取数据时的超时时间，HTTP 方式使用 request_timeout_millis
+	Timeout time.Duration `toml:"timeout"`
+
+	// 是否采集 server 级别的指标，server 很多的时候可以关掉
+	IgnoreServers bool `toml:"ignore_servers"`
+
+	httpreq.RequestOptions
+	clienttls.ClientConfig
+}
+
+// target 支持下面几种格式：
+//
+//	http(s)://127.0.0.1:8404/stats;csv  HAProxy stats 页面的 CSV 格式，没有 ;csv 后缀的话会自动加上
+//	127.0.0.1:8404/stats                 同上，默认 http
+//	unix:///var/run/haproxy.sock         admin socket，会同时执行 show stat 和 show info
+//	tcp://127.0.0.1:9999                 监听在 TCP 端口上的 admin socket
+func (c *Config) Scrape(ctx context.Context, target string, ss *types.Samples) error {
+	if strings.HasPrefix(target, "unix://") || strings.HasPrefix(target, "tcp://") {
+		return c.scrapeSocket(ctx, target, ss)
+	}
+	return c.scrapeHTTP(ctx, target, ss)
+}
+
+func (c *Config) scrapeHTTP(ctx context.Context, target string, ss *types.Samples) error {
+	if !strings.HasPrefix(target, "http") {
+		target = "http://" + target
+	}
+
+	if !strings.HasSuffix(target, ";csv") && !strings.Contains(target, ";csv;") {
+		target += ";csv"
+	}
+
+	var tlsConfig *tls.Config
+	var err error
+	if strings.HasPrefix(target, "https://") {
+		tlsConfig, err = c.ClientConfig.TLSConfig()
+		if err != nil {
+			return err
+		}
+	}
+
+	cli, err := c.RequestOptions.NewClient(tlsConfig, true)
+	if err != nil {
+		return errors.WithMessagef(err, "new client failed, target: %s", target)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return errors.WithMessagef(err, "new request failed, target: %s", target)
+	}
+
+	if err = c.RequestOptions.FillHeaders(req, c.BaseDir); err != nil {
+		return errors.WithMessagef(err, "fill headers failed, target: %s", target)
+	}
+
+	res, err := cli.Do(req)
+	if err != nil {
+		return errors.WithMessagef(err, "do request failed, target: %s", target)
+	}
+
+	defer res.Body.Close()
+
+	bs, err := io.ReadAll(res.Body)
+	if err != nil {
+		return errors.WithMessagef(err, "read response body failed, target: %s", target)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("response status code is not 200, target: %s, code: %d, response body: %s", target, res.StatusCode, string(bs))
+	}
+
+	return parseStat(bytes.NewReader(bs), ss, c.IgnoreServers)
+}
+
+func (c *Config) scrapeSocket(ctx context.Context, target string, ss *types.Samples) error {
+	network := "unix"
+	address := strings.TrimPrefix(target, "unix://")
+	if strings.HasPrefix(target, "tcp://") {
+		network = "tcp"
+		address = strings.TrimPrefix(target, "tcp://")
+	}
+
+	stat, err := c.socketCommand(ctx, network, address, "show stat")
+	if err != nil {
+		return err
+	}
+
+	if err = parseStat(bytes.NewReader(stat), ss, c.IgnoreServers); err != nil {
+		return err
+	}
+
+	info, err := c.socketCommand(ctx, network, address, "show info")
+	if err != nil {
+		logger.Errorf("haproxy(%s) failed to execute show info: %s", target, err)
+		return nil
+	}
+
+	parseInfo(bytes.NewReader(info), ss)
+	return nil
+}
+
+// socketCommand 非交互模式下，HAProxy 执行完一个命令之后会主动关闭连接，所以每个命令都要新建一个连接
+func (c *Config) socketCommand(ctx context.Context, network, address, command string) ([]byte, error) {
+	dialer := &net.Dialer{Timeout: c.Timeout}
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to connect to %s://%s", network, address)
+	}
+
+	defer conn.Close()
+
+	if err = conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return nil, err
+	}
+
+	if _, err = conn.Write([]byte(command + "\n")); err != nil {
+		return nil, errors.WithMessagef(err, "failed to send command %q to %s://%s", command, network, address)
+	}
+
+	bs, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to read response of command %q from %s://%s", command, network, address)
+	}
+
+	if bytes.HasPrefix(bs, []byte("Unknown command")) || bytes.HasPrefix(bs, []byte("Permission denied")) {
+		return nil, errors.Errorf("command %q failed: %s", command, strings.TrimSpace(string(bs)))
+	}
+
+	return bs, nil
+}
@@ -0,0 +1,233 @@
+package haproxy
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cprobe/cprobe/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	typeFrontend = "0"
+	typeBackend  = "1"
+	typeServer   = "2"
+)
+
+// statFields show stat 输出中的字段 -> 指标名称，时间类的字段单位是毫秒，统一转换成秒
+var statFields = map[string]string{
+	"qcur":           "current_queue",
+	"qmax":           "max_queue",
+	"scur":           "current_sessions",
+	"smax":           "max_sessions",
+	"slim":           "limit_sessions",
+	"stot":           "sessions_total",
+	"bin":            "bytes_in_total",
+	"bout":           "bytes_out_total",
+	"dreq":           "requests_denied_total",
+	"dresp":          "responses_denied_total",
+	"ereq":           "request_errors_total",
+	"econ":           "connection_errors_total",
+	"eresp":          "response_errors_total",
+	"wretr":          "retry_warnings_total",
+	"wredis":         "redispatch_warnings_total",
+	"weight":         "weight",
+	"act":            "active_servers",
+	"bck":            "backup_servers",
+	"chkfail":        "check_failures_total",
+	"chkdown":        "check_up_down_total",
+	"lastchg":        "last_change_seconds",
+	"downtime":       "downtime_seconds_total",
+	"lbtot":          "server_selected_total",
+	"rate":           "current_session_rate",
+	"rate_lim":       "limit_session_rate",
+	"rate_max":       "max_session_rate",
+	"req_rate":       "current_request_rate",
+	"req_rate_max":   "max_request_rate",
+	"req_tot":        "http_requests_total",
+	"cli_abrt":       "client_aborts_total",
+	"srv_abrt":       "server_aborts_total",
+	"conn_rate":      "current_connection_rate",
+	"conn_rate_max":  "max_connection_rate",
+	"conn_tot":       "connections_total",
+	"check_duration": "check_duration_seconds",
+	"qtime":          "http_queue_time_average_seconds",
+	"ctime":          "http_connect_time_average_seconds",
+	"rtime":          "http_response_time_average_seconds",
+	"ttime":          "http_total_time_average_seconds",
+}
+
+var millisecondFields = map[string]bool{
+	"check_duration": true,
+	"qtime":          true,
+	"ctime":          true,
+	"rtime":          true,
+	"ttime":          true,
+}
+
+var httpResponseFields = []string{"hrsp_1xx", "hrsp_2xx", "hrsp_3xx", "hrsp_4xx", "hrsp_5xx", "hrsp_other"}
+
+// parseStat 解析 show stat 的输出，HTTP stats 页面的 CSV 格式和 socket 的输出格式是一样的
+// 第一行是以 "# " 开头的表头：# pxname,svname,qcur,qmax,...
+func parseStat(r io.Reader, ss *types.Samples, ignoreServers bool) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return errors.WithMessage(err, "failed to read csv header")
+	}
+
+	if len(header) == 0 || !strings.HasPrefix(header[0], "# ") {
+		return errors.Errorf("unexpected csv header: %s", strings.Join(header, ","))
+	}
+
+	header[0] = strings.TrimPrefix(header[0], "# ")
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return errors.WithMessage(err, "failed to read csv record")
+		}
+
+		row := make(map[string]string, len(header))
+		for i := 0; i < len(header) && i < len(record); i++ {
+			row[header[i]] = record[i]
+		}
+
+		var prefix string
+		tags := map[string]string{"proxy": row["pxname"]}
+
+		switch row["type"] {
+		case typeFrontend:
+			prefix = "frontend_"
+		case typeBackend:
+			prefix = "backend_"
+		case typeServer:
+			if ignoreServers {
+				continue
+			}
+			prefix = "server_"
+			tags["server"] = row["svname"]
+		default:
+			// listener 或者老版本没有 type 字段的情况
+			continue
+		}
+
+		fields := make(map[string]interface{})
+		for col, name := range statFields {
+			value, ok := parseNumber(row[col])
+			if !ok {
+				continue
+			}
+
+			if millisecondFields[col] {
+				value /= 1000
+			}
+
+			fields[prefix+name] = value
+		}
+
+		if status := row["status"]; status != "" {
+			fields[prefix+"up"] = statusUp(status)
+			ss.AddMetric(types.PluginHAProxy, map[string]interface{}{prefix + "status": 1}, tags, map[string]string{"status": statusName(status)})
+		}
+
+		if len(fields) > 0 {
+			ss.AddMetric(types.PluginHAProxy, fields, tags)
+		}
+
+		for _, col := range httpResponseFields {
+			value, ok := parseNumber(row[col])
+			if !ok {
+				continue
+			}
+
+			ss.AddMetric(types.PluginHAProxy, map[string]interface{}{prefix + "http_responses_total": value}, tags, map[string]string{
+				"code": strings.TrimPrefix(col, "hrsp_"),
+			})
+		}
+	}
+
+	return nil
+}
+
+// parseInfo 解析 show info 的输出，每一行都是 Key: value 的格式
+func parseInfo(r io.Reader, ss *types.Samples) {
+	fields := make(map[string]interface{})
+	info := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(kv[0])
+		value := strings.TrimSpace(kv[1])
+
+		switch key {
+		case "Name", "Version", "Release_date", "Node":
+			info[strings.ToLower(key)] = value
+			continue
+		}
+
+		number, ok := parseNumber(value)
+		if !ok {
+			continue
+		}
+
+		fields["process_"+strings.ToLower(strings.ReplaceAll(key, "-", "_"))] = number
+	}
+
+	if len(fields) > 0 {
+		ss.AddMetric(types.PluginHAProxy, fields)
+	}
+
+	if len(info) > 0 {
+		ss.AddMetric(types.PluginHAProxy, map[string]interface{}{"version_info": 1}, info)
+	}
+}
+
+func parseNumber(s string) (float64, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return f, true
+}
+
+// statusUp 把 status 字段转换成 0/1，比如 UP、UP 1/3、OPEN、no check 都认为是 up
+// statusName 去掉状态后面的健康检查进度和原因，比如 "UP 1/3"、"MAINT (via b/s)"，否则每次健康检查状态变化都会产生新的时间序列
+func statusName(status string) string {
+	if strings.HasPrefix(status, "no check") {
+		return "no check"
+	}
+	if i := strings.IndexByte(status, ' '); i >= 0 {
+		return status[:i]
+	}
+	return status
+}
+
+func statusUp(status string) float64 {
+	switch {
+	case strings.HasPrefix(status, "UP"), status == "OPEN", status == "no check", strings.HasPrefix(status, "NOLB"):
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,142 @@
+package haproxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cprobe/cprobe/lib/conv"
+	"github.com/cprobe/cprobe/types"
+)
+
+const statCSV = `
+# pxname,svname,qcur,qmax,scur,smax,slim,stot,bin,bout,dreq,dresp,ereq,econ,eresp,wretr,wredis,status,weight,act,bck,chkfail,chkdown,lastchg,downtime,qlimit,pid,iid,sid,throttle,lbtot,tracked,type,rate,rate_lim,rate_max,check_status,check_code,check_duration,hrsp_1xx,hrsp_2xx,hrsp_3xx,hrsp_4xx,hrsp_5xx,hrsp_other,hanafail,req_rate,req_rate_max,req_tot,cli_abrt,srv_abrt,comp_in,comp_out,comp_byp,comp_rsp,lastsess,last_chk,last_agt,qtime,ctime,rtime,ttime,
+http-in,FRONTEND,,,3,10,2000,120,1000,2000,0,0,1,,,,,OPEN,,,,,,,,,1,2,0,,,,0,5,0,9,,,,0,100,2,15,3,0,,1,8,120,,,0,0,0,0,,,,,,,,
+app,web1,0,0,1,4,,60,500,900,,0,,0,2,0,0,UP,1,1,0,0,0,3600,0,,1,3,1,,60,,2,1,,4,L7OK,200,12,0,50,0,8,2,0,0,,,,3,0,,,,,,,,0,1,20,25,
+app,web2,0,0,0,0,,0,0,0,,0,,0,0,0,0,DOWN,1,1,0,3,1,10,10,,1,3,2,,0,,2,0,,0,L4CON,,1001,0,0,0,0,0,0,0,,,,0,0,,,,,,,,0,0,0,0,
+app,BACKEND,0,0,1,4,200,60,500,900,0,0,,0,2,0,0,UP,1,1,0,,1,3600,0,,1,3,0,,60,,1,1,,4,,,,0,50,0,8,2,0,,,,60,3,0,0,0,0,0,,,,0,1,20,25,
+`
+
+func collect(ss *types.Samples) map[string]float64 {
+	values := make(map[string]float64)
+	for _, m := range ss.PopBackAll() {
+		tags := m.Tags()
+		for k, v := range m.Fields() {
+			f, _ := conv.ToFloat64(v)
+			key := tags["proxy"] + "/" + tags["server"] + "/" + k
+			if tags["code"] != "" {
+				key += "/" + tags["code"]
+			}
+			if tags["status"] != "" {
+				key += "/" + tags["status"]
+			}
+			values[key] = f
+		}
+	}
+	return values
+}
+
+func TestStatusName(t *testing.T) {
+	f := func(status, want string) {
+		t.Helper()
+		if got := statusName(status); got != want {
+			t.Errorf("unexpected status name for %q; got %q; want %q", status, got, want)
+		}
+	}
+	f("UP", "UP")
+	f("UP 1/3", "UP")
+	f("DOWN 2/2", "DOWN")
+	f("MAINT (via b/s)", "MAINT")
+	f("DRAIN (agent)", "DRAIN")
+	f("NOLB", "NOLB")
+	f("no check", "no check")
+	f("OPEN", "OPEN")
+}
+
+func TestParseStat(t *testing.T) {
+	ss := types.NewSamples()
+	if err := parseStat(strings.NewReader(statCSV), ss, false); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	values := collect(ss)
+
+	expected := map[string]float64{
+		"http-in//frontend_current_sessions":                 3,
+		"http-in//frontend_up":                               1,
+		"http-in//frontend_status/OPEN":                      1,
+		"http-in//frontend_http_responses_total/2xx":         100,
+		"http-in//frontend_http_requests_total":              120,
+		"app/web1/server_up":                                 1,
+		"app/web1/server_check_duration_seconds":             0.012,
+		"app/web1/server_http_response_time_average_seconds": 0.02,
+		"app/web2/server_up":                                 0,
+		"app/web2/server_status/DOWN":                        1,
+		"app/web2/server_check_failures_total":               3,
+		"app//backend_active_servers":                        1,
+		"app//backend_http_responses_total/5xx":              2,
+		"app//backend_http_total_time_average_seconds":       0.025,
+	}
+
+	for k, want := range expected {
+		if got, ok := values[k]; !ok || got != want {
+			t.Errorf("%s: expected %v, got %v (present: %v)", k, want, got, ok)
+		}
+	}
+
+	ss = types.NewSamples()
+	if err := parseStat(strings.NewReader(statCSV), ss, true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for k := range collect(ss) {
+		if strings.Contains(k, "/server_") {
+			t.Fatalf("server metrics should be ignored, got %s", k)
+		}
+	}
+
+	if err := parseStat(strings.NewReader("<html></html>\n"), types.NewSamples(), false); err == nil {
+		t.Fatalf("expected error for non-csv response")
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	info := `Name: HAProxy
+Version: 2.8.3
+Release_date: 2023/09/08
+Nbthread: 4
+Uptime_sec: 3600
+Ulimit-n: 200039
+CurrConns: 12
+Idle_pct: 97
+`
+
+	ss := types.NewSamples()
+	parseInfo(strings.NewReader(info), ss)
+
+	values := make(map[string]float64)
+	var version string
+	for _, m := range ss.PopBackAll() {
+		for k, v := range m.Fields() {
+			f, _ := conv.ToFloat64(v)
+			values[k] = f
+		}
+		if v, ok := m.Tags()["version"]; ok {
+			version = v
+		}
+	}
+
+	if version != "2.8.3" {
+		t.Errorf("expected version 2.8.3, got %q", version)
+	}
+
+	for k, want := range map[string]float64{
+		"process_uptime_sec": 3600,
+		"process_ulimit_n":   200039,
+		"process_currconns":  12,
+		"process_idle_pct":   97,
+	} {
+		if values[k] != want {
+			t.Errorf("%s: expected %v, got %v", k, want, values[k])
+		}
+	}
+}
@@ -30,6 +30,8 @@ type Global struct {
 	ScraperEnabled        []string `toml:"scraper_enabled"`
 	LockWaitTimeout       int      `toml:"lock_wait_timeout"`
 	LogSlowFilter         bool     `toml:"log_slow_filter"`
+	// Params 是额外的 DSN 连接参数，比如 charset、timeout 等
+	Params map[string]string `toml:"params"`
 }
 
 func (g Global) FormDSN(target string) (string, error) {
@@ -40,6 +42,7 @@ func (g Global) FormDSN(target string) (string, error) {
 	config := mysql.NewConfig()
 	config.User = g.User
 	config.Passwd = g.Password
+	config.Params = g.Params
 	config.Net = "tcp"
 	if prefix := "unix://"; strings.HasPrefix(target, prefix) {
 		config.Net = "unix"
@@ -67,6 +70,31 @@ func (g Global) FormDSN(target string) (string, error) {
 	return config.FormatDSN(), nil
 }
 
+// applyTargetParams 用服务发现给 target 单独设置的账号密码和 DSN 参数覆盖 rule 文件里的配置
+//
+// 每个 target 都会单独 ParseConfig，所以这里可以直接修改 g
+func (g *Global) applyTargetParams(tp *plugins.TargetParams) {
+	if tp == nil {
+		return
+	}
+	if tp.Username != "" {
+		g.User = tp.Username
+	}
+	if tp.Password != "" {
+		g.Password = tp.Password
+	}
+	if len(tp.Params) > 0 {
+		params := make(map[string]string, len(g.Params)+len(tp.Params))
+		for k, v := range g.Params {
+			params[k] = v
+		}
+		for k, v := range tp.Params {
+			params[k] = v
+		}
+		g.Params = params
+	}
+}
+
 func (g Global) CustomizeTLS() error {
 	var tlsCfg tls.Config
 	caBundle := x509.NewCertPool()
@@ -411,6 +439,11 @@ func (*MySQL) ParseConfig(baseDir string, bs []byte) (any, error) {
 func (*MySQL) Scrape(ctx context.Context, address string, c any, ss *types.Samples) error {
 	// 这个方法中如果要对配置 c 变量做修改，一定要 clone 一份之后再修改，因为并发的多个 target 共享了一个 c 变量
 	cfg := c.(*Config)
+	if cfg.Global == nil {
+		cfg.Global = &Global{}
+	}
+	cfg.Global.applyTargetParams(plugins.GetTargetParams(ctx))
+
 	dsn, err := cfg.Global.FormDSN(address)
 	if err != nil {
 		return fmt.Errorf("failed to form dsn for %s: %s", address, err)
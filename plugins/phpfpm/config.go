@@ -0,0 +1,240 @@
+package phpfpm
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/clienttls"
+	"github.com/cprobe/cprobe/lib/httpreq"
+	"github.com/cprobe/cprobe/types"
+	"github.com/pkg/errors"
+)
+
+const defaultStatusPath = "/status"
+
+type Config struct {
+	BaseDir string `toml:"-"`
+
+	// 通过 FastCGI 直连 php-fpm 时的超时时间，HTTP 方式使用 request_timeout_millis
+	Timeout time.Duration `toml:"timeout"`
+
+	// 是否采集每个 worker 进程的指标，进程多的时候时间序列会比较多，默认关闭
+	GatherProcesses bool `toml:"gather_processes"`
+
+	httpreq.RequestOptions
+	clienttls.ClientConfig
+}
+
+// Status php-fpm 状态页 ?json&full 的输出
+type Status struct {
+	Pool               string    `json:"pool"`
+	ProcessManager     string    `json:"process manager"`
+	StartTime          int64     `json:"start time"`
+	StartSince         int64     `json:"start since"`
+	AcceptedConn       int64     `json:"accepted conn"`
+	ListenQueue        int64     `json:"listen queue"`
+	MaxListenQueue     int64     `json:"max listen queue"`
+	ListenQueueLen     int64     `json:"listen queue len"`
+	IdleProcesses      int64     `json:"idle processes"`
+	ActiveProcesses    int64     `json:"active processes"`
+	TotalProcesses     int64     `json:"total processes"`
+	MaxActiveProcesses int64     `json:"max active processes"`
+	MaxChildrenReached int64     `json:"max children reached"`
+	SlowRequests       int64     `json:"slow requests"`
+	Processes          []Process `json:"processes"`
+}
+
+type Process struct {
+	PID               int64   `json:"pid"`
+	State             string  `json:"state"`
+	StartTime         int64   `json:"start time"`
+	StartSince        int64   `json:"start since"`
+	Requests          int64   `json:"requests"`
+	RequestDuration   int64   `json:"request duration"`
+	RequestMethod     string  `json:"request method"`
+	RequestURI        string  `json:"request uri"`
+	ContentLength     int64   `json:"content length"`
+	User              string  `json:"user"`
+	Script            string  `json:"script"`
+	LastRequestCPU    float64 `json:"last request cpu"`
+	LastRequestMemory int64   `json:"last request memory"`
+}
+
+// target 支持下面几种格式：
+//
+//	http(s)://127.0.0.1/status              通过 Web Server 暴露的状态页
+//	127.0.0.1/status                        同上，默认 http
+//	fcgi://127.0.0.1:9000/status            通过 FastCGI 协议直连 php-fpm 的 TCP 端口，没有写路径的话默认是 /status
+//	unix:///var/run/php-fpm.sock;/status    通过 FastCGI 协议直连 php-fpm 的 unix socket，分号后面是状态页路径
+func (c *Config) Scrape(ctx context.Context, target string, ss *types.Samples) error {
+	var (
+		bs  []byte
+		err error
+	)
+
+	if strings.HasPrefix(target, "fcgi://") || strings.HasPrefix(target, "unix://") {
+		bs, err = c.fetchFastCGI(ctx, target)
+	} else {
+		bs, err = c.fetchHTTP(ctx, target)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var status Status
+	if err = json.Unmarshal(bs, &status); err != nil {
+		return errors.WithMessagef(err, "failed to unmarshal php-fpm status, target: %s", target)
+	}
+
+	c.gather(&status, ss)
+	return nil
+}
+
+func (c *Config) fetchHTTP(ctx context.Context, target string) ([]byte, error) {
+	if !strings.HasPrefix(target, "http") {
+		target = "http://" + target
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "invalid target: %s", target)
+	}
+
+	if u.Path == "" || u.Path == "/" {
+		u.Path = defaultStatusPath
+	}
+
+	u.RawQuery = "json&full"
+	target = u.String()
+
+	var tlsConfig *tls.Config
+	if u.Scheme == "https" {
+		tlsConfig, err = c.ClientConfig.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cli, err := c.RequestOptions.NewClient(tlsConfig, true)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "new client failed, target: %s", target)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "new request failed, target: %s", target)
+	}
+
+	if err = c.RequestOptions.FillHeaders(req, c.BaseDir); err != nil {
+		return nil, errors.WithMessagef(err, "fill headers failed, target: %s", target)
+	}
+
+	res, err := cli.Do(req)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "do request failed, target: %s", target)
+	}
+
+	defer res.Body.Close()
+
+	bs, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "read response body failed, target: %s", target)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("response status code is not 200, target: %s, code: %d, response body: %s", target, res.StatusCode, string(bs))
+	}
+
+	return bs, nil
+}
+
+func (c *Config) fetchFastCGI(ctx context.Context, target string) ([]byte, error) {
+	var network, address, path string
+
+	if strings.HasPrefix(target, "unix://") {
+		network = "unix"
+		address, path, _ = strings.Cut(strings.TrimPrefix(target, "unix://"), ";")
+	} else {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid target: %s", target)
+		}
+		network = "tcp"
+		address = u.Host
+		path = u.Path
+	}
+
+	if path == "" || path == "/" {
+		path = defaultStatusPath
+	}
+
+	code, bs, err := fcgiGet(ctx, network, address, path, "json&full", c.Timeout)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "fastcgi request failed, target: %s", target)
+	}
+
+	if code != http.StatusOK {
+		return nil, errors.Errorf("response status code is not 200, target: %s, code: %d, response body: %s", target, code, string(bs))
+	}
+
+	return bs, nil
+}
+
+func (c *Config) gather(status *Status, ss *types.Samples) {
+	tags := map[string]string{"pool": status.Pool}
+
+	ss.AddMetric(types.PluginPhpFpm, map[string]interface{}{
+		"start_since_seconds":        status.StartSince,
+		"accepted_connections_total": status.AcceptedConn,
+		"listen_queue":               status.ListenQueue,
+		"max_listen_queue":           status.MaxListenQueue,
+		"listen_queue_length":        status.ListenQueueLen,
+		"idle_processes":             status.IdleProcesses,
+		"active_processes":           status.ActiveProcesses,
+		"total_processes":            status.TotalProcesses,
+		"max_active_processes":       status.MaxActiveProcesses,
+		"max_children_reached_total": status.MaxChildrenReached,
+		"slow_requests_total":        status.SlowRequests,
+	}, tags)
+
+	ss.AddMetric(types.PluginPhpFpm, map[string]interface{}{"process_manager_info": 1}, tags, map[string]string{
+		"process_manager": status.ProcessManager,
+	})
+
+	states := make(map[string]int)
+	for _, p := range status.Processes {
+		states[stateName(p.State)]++
+	}
+
+	for state, count := range states {
+		ss.AddMetric(types.PluginPhpFpm, map[string]interface{}{"process_state": count}, tags, map[string]string{"state": state})
+	}
+
+	if !c.GatherProcesses {
+		return
+	}
+
+	for _, p := range status.Processes {
+		ss.AddMetric(types.PluginPhpFpm, map[string]interface{}{
+			"process_start_since_seconds":         p.StartSince,
+			"process_requests_total":              p.Requests,
+			"process_request_duration_seconds":    float64(p.RequestDuration) / 1e6,
+			"process_last_request_cpu":            p.LastRequestCPU,
+			"process_last_request_memory_bytes":   p.LastRequestMemory,
+			"process_last_request_content_length": p.ContentLength,
+		}, tags, map[string]string{"pid": strconv.FormatInt(p.PID, 10)})
+	}
+}
+
+// stateName 把 Idle、Reading headers 这种状态转换成 idle、reading_headers
+func stateName(state string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(state)), " ", "_")
+}
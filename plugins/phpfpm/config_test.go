@@ -0,0 +1,129 @@
+package phpfpm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/fcgi"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/conv"
+	"github.com/cprobe/cprobe/types"
+)
+
+const statusJSON = `{"pool":"www","process manager":"dynamic","start time":1697700000,"start since":3600,"accepted conn":1024,"listen queue":2,"max listen queue":5,"listen queue len":511,"idle processes":1,"active processes":2,"total processes":3,"max active processes":4,"max children reached":1,"slow requests":7,"processes":[{"pid":101,"state":"Idle","start time":1697700000,"start since":3600,"requests":500,"request duration":1500,"request method":"GET","request uri":"/index.php","content length":0,"user":"-","script":"/var/www/index.php","last request cpu":12.5,"last request memory":2097152},{"pid":102,"state":"Running","start time":1697700000,"start since":3600,"requests":300,"request duration":250000,"request method":"GET","request uri":"/status?json&full","content length":0,"user":"-","script":"-","last request cpu":0.00,"last request memory":0},{"pid":103,"state":"Reading headers","start time":1697700000,"start since":3600,"requests":224,"request duration":0,"request method":"-","request uri":"-","content length":0,"user":"-","script":"-","last request cpu":0.00,"last request memory":0}]}`
+
+func serveFastCGI(t *testing.T, network, address string) net.Listener {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+
+	go fcgi.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.RawQuery != "json&full" {
+			http.Error(w, "unexpected query: "+r.URL.RawQuery, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, statusJSON)
+	}))
+
+	return l
+}
+
+func collect(ss *types.Samples) map[string]float64 {
+	values := make(map[string]float64)
+	for _, m := range ss.PopBackAll() {
+		tags := m.Tags()
+		for k, v := range m.Fields() {
+			f, _ := conv.ToFloat64(v)
+			key := tags["pool"] + "/" + k
+			if tags["state"] != "" {
+				key += "/" + tags["state"]
+			}
+			if tags["pid"] != "" {
+				key += "/" + tags["pid"]
+			}
+			values[key] = f
+		}
+	}
+	return values
+}
+
+func TestScrapeFastCGI(t *testing.T) {
+	tcp := serveFastCGI(t, "tcp", "127.0.0.1:0")
+	defer tcp.Close()
+
+	sock := filepath.Join(t.TempDir(), "php-fpm.sock")
+	unix := serveFastCGI(t, "unix", sock)
+	defer unix.Close()
+
+	cfg := &Config{Timeout: 5 * time.Second, GatherProcesses: true}
+
+	for _, target := range []string{
+		"fcgi://" + tcp.Addr().String() + "/status",
+		"fcgi://" + tcp.Addr().String(),
+		"unix://" + sock + ";/status",
+		"unix://" + sock,
+	} {
+		ss := types.NewSamples()
+		if err := cfg.Scrape(context.Background(), target, ss); err != nil {
+			t.Fatalf("%s: unexpected error: %s", target, err)
+		}
+
+		values := collect(ss)
+		expected := map[string]float64{
+			"www/accepted_connections_total":            1024,
+			"www/listen_queue":                          2,
+			"www/total_processes":                       3,
+			"www/slow_requests_total":                   7,
+			"www/process_manager_info":                  1,
+			"www/process_state/idle":                    1,
+			"www/process_state/running":                 1,
+			"www/process_state/reading_headers":         1,
+			"www/process_requests_total/101":            500,
+			"www/process_request_duration_seconds/102":  0.25,
+			"www/process_last_request_memory_bytes/101": 2097152,
+		}
+
+		for k, want := range expected {
+			if got, ok := values[k]; !ok || got != want {
+				t.Errorf("%s: %s: expected %v, got %v (present: %v)", target, k, want, got, ok)
+			}
+		}
+	}
+
+	ss := types.NewSamples()
+	if err := cfg.Scrape(context.Background(), "fcgi://"+tcp.Addr().String()+"/not-found", ss); err == nil {
+		t.Errorf("expected error for 404 response")
+	}
+}
+
+func TestGatherWithoutProcesses(t *testing.T) {
+	cfg := &Config{Timeout: time.Second}
+
+	ss := types.NewSamples()
+	if err := cfg.Scrape(context.Background(), "fcgi://127.0.0.1:1/status", ss); err == nil {
+		t.Errorf("expected error for unreachable target")
+	}
+
+	srv := serveFastCGI(t, "tcp", "127.0.0.1:0")
+	defer srv.Close()
+
+	if err := cfg.Scrape(context.Background(), "fcgi://"+srv.Addr().String(), ss); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for k := range collect(ss) {
+		if k == "www/process_requests_total/101" {
+			t.Fatalf("process metrics should not be gathered")
+		}
+	}
+}
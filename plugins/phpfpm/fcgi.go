@@ -0,0 +1,217 @@
+package phpfpm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// 一个极简的 FastCGI 客户端，只用于请求 php-fpm 的状态页，协议细节参考：
+// https://fastcgi-archives.github.io/FastCGI_Specification.html
+
+const (
+	fcgiVersion1  = 1
+	fcgiRequestID = 1
+
+	fcgiBeginRequest = 1
+	fcgiEndRequest   = 3
+	fcgiParams       = 4
+	fcgiStdin        = 5
+	fcgiStdout       = 6
+	fcgiStderr       = 7
+
+	fcgiResponder = 1
+
+	fcgiMaxContent = 65535
+)
+
+type fcgiHeader struct {
+	Version       uint8
+	Type          uint8
+	RequestID     uint16
+	ContentLength uint16
+	PaddingLength uint8
+	Reserved      uint8
+}
+
+func writeRecord(w io.Writer, recType uint8, content []byte) error {
+	for {
+		n := len(content)
+		if n > fcgiMaxContent {
+			n = fcgiMaxContent
+		}
+
+		h := fcgiHeader{
+			Version:       fcgiVersion1,
+			Type:          recType,
+			RequestID:     fcgiRequestID,
+			ContentLength: uint16(n),
+			PaddingLength: uint8(-n & 7),
+		}
+
+		if err := binary.Write(w, binary.BigEndian, h); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(content[:n]); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(make([]byte, h.PaddingLength)); err != nil {
+			return err
+		}
+
+		content = content[n:]
+		if len(content) == 0 {
+			return nil
+		}
+	}
+}
+
+func encodeSize(buf *bytes.Buffer, size int) {
+	if size < 128 {
+		buf.WriteByte(byte(size))
+		return
+	}
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(size)|1<<31)
+	buf.Write(b[:])
+}
+
+func encodeParams(params map[string]string) []byte {
+	var buf bytes.Buffer
+	for k, v := range params {
+		encodeSize(&buf, len(k))
+		encodeSize(&buf, len(v))
+		buf.WriteString(k)
+		buf.WriteString(v)
+	}
+	return buf.Bytes()
+}
+
+// fcgiGet 以 responder 角色发起一个 GET 请求，返回 HTTP 状态码和响应体
+func fcgiGet(ctx context.Context, network, address, path, query string, timeout time.Duration) (int, []byte, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return 0, nil, errors.WithMessagef(err, "failed to connect to %s://%s", network, address)
+	}
+
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	if err = conn.SetDeadline(deadline); err != nil {
+		return 0, nil, err
+	}
+
+	requestURI := path
+	if query != "" {
+		requestURI += "?" + query
+	}
+
+	params := map[string]string{
+		"GATEWAY_INTERFACE": "CGI/1.1",
+		"SERVER_PROTOCOL":   "HTTP/1.1",
+		"REQUEST_METHOD":    "GET",
+		"SCRIPT_FILENAME":   path,
+		"SCRIPT_NAME":       path,
+		"REQUEST_URI":       requestURI,
+		"QUERY_STRING":      query,
+	}
+
+	// role(2) + flags(1) + reserved(5)，flags 为 0 表示请求结束之后由 php-fpm 关闭连接
+	begin := []byte{0, fcgiResponder, 0, 0, 0, 0, 0, 0}
+
+	w := bufio.NewWriter(conn)
+	for _, rec := range []struct {
+		typ     uint8
+		content []byte
+	}{
+		{fcgiBeginRequest, begin},
+		{fcgiParams, encodeParams(params)},
+		{fcgiParams, nil},
+		{fcgiStdin, nil},
+	} {
+		if err = writeRecord(w, rec.typ, rec.content); err != nil {
+			return 0, nil, errors.WithMessage(err, "failed to write fastcgi request")
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		return 0, nil, errors.WithMessage(err, "failed to write fastcgi request")
+	}
+
+	stdout, stderr, err := readResponse(bufio.NewReader(conn))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(stdout) == 0 && len(stderr) > 0 {
+		return 0, nil, errors.Errorf("fastcgi stderr: %s", strings.TrimSpace(string(stderr)))
+	}
+
+	return parseCGIResponse(stdout)
+}
+
+func readResponse(r io.Reader) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	for {
+		var h fcgiHeader
+		if err := binary.Read(r, binary.BigEndian, &h); err != nil {
+			return nil, nil, errors.WithMessage(err, "failed to read fastcgi record header")
+		}
+
+		content := make([]byte, int(h.ContentLength)+int(h.PaddingLength))
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, nil, errors.WithMessage(err, "failed to read fastcgi record content")
+		}
+		content = content[:h.ContentLength]
+
+		switch h.Type {
+		case fcgiStdout:
+			stdout.Write(content)
+		case fcgiStderr:
+			stderr.Write(content)
+		case fcgiEndRequest:
+			return stdout.Bytes(), stderr.Bytes(), nil
+		}
+	}
+}
+
+// parseCGIResponse 解析 CGI 格式的响应，头部里的 Status 字段表示状态码，没有的话就是 200
+func parseCGIResponse(bs []byte) (int, []byte, error) {
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(bs)))
+	header, err := tp.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return 0, nil, errors.WithMessage(err, "failed to parse fastcgi response header")
+	}
+
+	code := http.StatusOK
+	if status := header.Get("Status"); status != "" {
+		code, err = strconv.Atoi(strings.Fields(status)[0])
+		if err != nil {
+			return 0, nil, errors.Errorf("invalid status in fastcgi response: %s", status)
+		}
+	}
+
+	body, err := io.ReadAll(tp.R)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return code, body, nil
+}
@@ -0,0 +1,37 @@
+package phpfpm
+
+import (
+	"context"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cprobe/cprobe/plugins"
+	"github.com/cprobe/cprobe/types"
+)
+
+func init() {
+	plugins.RegisterPlugin(types.PluginPhpFpm, &PhpFpm{})
+}
+
+type PhpFpm struct{}
+
+func (*PhpFpm) ParseConfig(baseDir string, bs []byte) (any, error) {
+	var c Config
+	err := toml.Unmarshal(bs, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	c.BaseDir = baseDir
+
+	if c.Timeout == 0 {
+		c.Timeout = 5 * time.Second
+	}
+
+	return &c, nil
+}
+
+func (*PhpFpm) Scrape(ctx context.Context, target string, c any, ss *types.Samples) error {
+	cfg := c.(*Config)
+	return cfg.Scrape(ctx, target, ss)
+}
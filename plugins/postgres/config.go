@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/cprobe/cprobe/plugins"
 	"github.com/cprobe/cprobe/plugins/postgres/collector"
 	"github.com/cprobe/cprobe/plugins/postgres/dsn"
 	"github.com/cprobe/cprobe/types"
@@ -20,6 +21,31 @@ type Config struct {
 	EnabledCollectors      []string          `toml:"enabled_collectors"`
 }
 
+// applyTargetParams 用服务发现给 target 单独设置的账号密码和连接参数覆盖 rule 文件里的配置
+//
+// 每个 target 都会单独 ParseConfig，所以这里可以直接修改 c
+func (c *Config) applyTargetParams(tp *plugins.TargetParams) {
+	if tp == nil {
+		return
+	}
+	if tp.Username != "" {
+		c.Username = tp.Username
+	}
+	if tp.Password != "" {
+		c.Password = tp.Password
+	}
+	if len(tp.Params) > 0 {
+		options := make(map[string]string, len(c.Options)+len(tp.Params))
+		for k, v := range c.Options {
+			options[k] = v
+		}
+		for k, v := range tp.Params {
+			options[k] = v
+		}
+		c.Options = options
+	}
+}
+
 func (c *Config) ConfigureTarget(target string) (dsn.DSN, error) {
 	d, err := dsn.DsnFromString(target)
 	if err != nil {
@@ -26,5 +26,6 @@ func (*Postgres) ParseConfig(baseDir string, bs []byte) (any, error) {
 
 func (*Postgres) Scrape(ctx context.Context, target string, c any, ss *types.Samples) error {
 	cfg := c.(*Config)
+	cfg.applyTargetParams(plugins.GetTargetParams(ctx))
 	return cfg.Scrape(ctx, target, ss)
 }
@@ -0,0 +1,332 @@
+package rabbitmq
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/clienttls"
+	"github.com/cprobe/cprobe/lib/httpreq"
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/cprobe/cprobe/types"
+	"github.com/pkg/errors"
+)
+
+type Config struct {
+	BaseDir string `toml:"-"`
+
+	GatherNodes     bool `toml:"gather_nodes"`
+	GatherVhosts    bool `toml:"gather_vhosts"`
+	GatherExchanges bool `toml:"gather_exchanges"`
+	GatherQueues    bool `toml:"gather_queues"`
+
+	// 队列过滤，匹配的对象是 vhost/queue 这样的字符串
+	QueueInclude string `toml:"queue_include"`
+	QueueExclude string `toml:"queue_exclude"`
+	// 最多采集多少个队列，按照堆积的消息数量从大到小排序之后截断，0 表示不限制
+	MaxQueues int `toml:"max_queues"`
+
+	httpreq.RequestOptions
+	clienttls.ClientConfig
+}
+
+type client struct {
+	baseDir string
+	address string
+	cli     *http.Client
+	opts    *httpreq.RequestOptions
+}
+
+func (c *client) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.address+path, nil)
+	if err != nil {
+		return errors.WithMessagef(err, "new request failed, url: %s", c.address+path)
+	}
+
+	if err = c.opts.FillHeaders(req, c.baseDir); err != nil {
+		return errors.WithMessagef(err, "fill headers failed, url: %s", c.address+path)
+	}
+
+	res, err := c.cli.Do(req)
+	if err != nil {
+		return errors.WithMessagef(err, "do request failed, url: %s", c.address+path)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		bs, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return errors.Errorf("response status code is not 200, url: %s, code: %d, response body: %s", c.address+path, res.StatusCode, string(bs))
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(v); err != nil {
+		return errors.WithMessagef(err, "decode json failed, url: %s", c.address+path)
+	}
+
+	return nil
+}
+
+// target: ip:port 或者 http(s)://ip:port，端口是 management 插件的端口，默认 15672
+func (c *Config) Scrape(ctx context.Context, target string, ss *types.Samples) error {
+	filter, err := newQueueFilter(c.QueueInclude, c.QueueExclude)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(target, "http") {
+		target = "http://" + target
+	}
+
+	var tlsConfig *tls.Config
+	if strings.HasPrefix(target, "https://") {
+		tlsConfig, err = c.ClientConfig.TLSConfig()
+		if err != nil {
+			return err
+		}
+	}
+
+	cli, err := c.RequestOptions.NewClient(tlsConfig, true)
+	if err != nil {
+		return errors.WithMessagef(err, "new client failed, target: %s", target)
+	}
+
+	api := &client{
+		baseDir: c.BaseDir,
+		address: strings.TrimSuffix(target, "/"),
+		cli:     cli,
+		opts:    &c.RequestOptions,
+	}
+
+	// overview 失败说明整个 management API 都不可用，直接返回错误，其他的接口失败只打印日志
+	var overview Overview
+	if err = api.get(ctx, "/api/overview", &overview); err != nil {
+		return err
+	}
+
+	gatherOverview(&overview, ss)
+
+	if c.GatherNodes {
+		var nodes []Node
+		if err = api.get(ctx, "/api/nodes", &nodes); err != nil {
+			logger.Errorf("rabbitmq(%s) failed to gather nodes: %s", target, err)
+		} else {
+			gatherNodes(nodes, ss)
+		}
+	}
+
+	if c.GatherVhosts {
+		var vhosts []Vhost
+		if err = api.get(ctx, "/api/vhosts", &vhosts); err != nil {
+			logger.Errorf("rabbitmq(%s) failed to gather vhosts: %s", target, err)
+		} else {
+			gatherVhosts(vhosts, ss)
+		}
+	}
+
+	if c.GatherExchanges {
+		var exchanges []Exchange
+		if err = api.get(ctx, "/api/exchanges?columns=name,vhost,type,durable,message_stats.publish_in,message_stats.publish_out", &exchanges); err != nil {
+			logger.Errorf("rabbitmq(%s) failed to gather exchanges: %s", target, err)
+		} else {
+			gatherExchanges(exchanges, ss)
+		}
+	}
+
+	if c.GatherQueues {
+		var queues []Queue
+		if err = api.get(ctx, queuesPath, &queues); err != nil {
+			logger.Errorf("rabbitmq(%s) failed to gather queues: %s", target, err)
+		} else {
+			selected, truncated := selectQueues(queues, filter, c.MaxQueues)
+			gatherQueues(selected, ss)
+			ss.AddMetric(types.PluginRabbitMQ, map[string]interface{}{"queues_truncated": truncated})
+		}
+	}
+
+	return nil
+}
+
+func gatherOverview(o *Overview, ss *types.Samples) {
+	ss.AddMetric(types.PluginRabbitMQ, map[string]interface{}{
+		"channels":                       o.ObjectTotals.Channels,
+		"connections":                    o.ObjectTotals.Connections,
+		"consumers":                      o.ObjectTotals.Consumers,
+		"exchanges":                      o.ObjectTotals.Exchanges,
+		"queues":                         o.ObjectTotals.Queues,
+		"messages":                       o.QueueTotals.Messages,
+		"messages_ready":                 o.QueueTotals.MessagesReady,
+		"messages_unacked":               o.QueueTotals.MessagesUnacknowledged,
+		"messages_published_total":       o.MessageStats.Publish,
+		"messages_delivered_total":       o.MessageStats.DeliverGet,
+		"messages_acked_total":           o.MessageStats.Ack,
+		"messages_redelivered_total":     o.MessageStats.Redeliver,
+		"messages_confirmed_total":       o.MessageStats.Confirm,
+		"messages_unroutable_total":      o.MessageStats.ReturnUnroutable + o.MessageStats.DropUnroutable,
+		"messages_get_empty_total":       o.MessageStats.GetEmpty,
+		"messages_disk_reads_total":      o.MessageStats.DiskReads,
+		"messages_disk_writes_total":     o.MessageStats.DiskWrites,
+		"messages_delivered_noack_total": o.MessageStats.DeliverNoAck + o.MessageStats.GetNoAck,
+	})
+
+	ss.AddMetric(types.PluginRabbitMQ, map[string]interface{}{"version_info": 1}, map[string]string{
+		"version":            o.RabbitMQVersion,
+		"erlang_version":     o.ErlangVersion,
+		"management_version": o.ManagementVersion,
+		"cluster":            o.ClusterName,
+	})
+}
+
+func gatherNodes(nodes []Node, ss *types.Samples) {
+	for _, n := range nodes {
+		ss.AddMetric(types.PluginRabbitMQ, map[string]interface{}{
+			"node_running":         n.Running,
+			"node_mem_used":        n.MemUsed,
+			"node_mem_limit":       n.MemLimit,
+			"node_mem_alarm":       n.MemAlarm,
+			"node_disk_free":       n.DiskFree,
+			"node_disk_free_limit": n.DiskFreeLimit,
+			"node_disk_free_alarm": n.DiskFreeAlarm,
+			"node_fd_used":         n.FdUsed,
+			"node_fd_total":        n.FdTotal,
+			"node_sockets_used":    n.SocketsUsed,
+			"node_sockets_total":   n.SocketsTotal,
+			"node_proc_used":       n.ProcUsed,
+			"node_proc_total":      n.ProcTotal,
+			"node_run_queue":       n.RunQueue,
+			"node_uptime_seconds":  float64(n.Uptime) / 1000,
+			"node_partitions":      len(n.Partitions),
+		}, map[string]string{
+			"node": n.Name,
+			"type": n.Type,
+		})
+	}
+}
+
+func gatherVhosts(vhosts []Vhost, ss *types.Samples) {
+	for _, v := range vhosts {
+		ss.AddMetric(types.PluginRabbitMQ, map[string]interface{}{
+			"vhost_messages":                 v.Messages,
+			"vhost_messages_ready":           v.MessagesReady,
+			"vhost_messages_unacked":         v.MessagesUnacknowledged,
+			"vhost_messages_published_total": v.MessageStats.Publish,
+			"vhost_messages_delivered_total": v.MessageStats.DeliverGet,
+			"vhost_messages_acked_total":     v.MessageStats.Ack,
+		}, map[string]string{
+			"vhost": v.Name,
+		})
+	}
+}
+
+func gatherExchanges(exchanges []Exchange, ss *types.Samples) {
+	for _, e := range exchanges {
+		name := e.Name
+		if name == "" {
+			// 默认的 exchange 名字是空字符串
+			name = "amq.default"
+		}
+
+		ss.AddMetric(types.PluginRabbitMQ, map[string]interface{}{
+			"exchange_messages_published_in_total":  e.MessageStats.PublishIn,
+			"exchange_messages_published_out_total": e.MessageStats.PublishOut,
+		}, map[string]string{
+			"vhost":    e.Vhost,
+			"exchange": name,
+			"type":     e.Type,
+		})
+	}
+}
+
+// queuesPath 只请求 gatherQueues 和 selectQueues 用到的字段，队列很多的时候完整的 /api/queues 每次会有几 MB
+const queuesPath = "/api/queues?columns=name,vhost,node,state,type,messages,messages_ready,messages_unacknowledged," +
+	"consumers,consumer_utilisation,memory,message_stats.publish,message_stats.deliver_get,message_stats.ack,message_stats.redeliver"
+
+func gatherQueues(queues []Queue, ss *types.Samples) {
+	for _, q := range queues {
+		fields := map[string]interface{}{
+			"queue_messages":                   q.Messages,
+			"queue_messages_ready":             q.MessagesReady,
+			"queue_messages_unacked":           q.MessagesUnacknowledged,
+			"queue_consumers":                  q.Consumers,
+			"queue_memory_bytes":               q.Memory,
+			"queue_running":                    q.State == "" || q.State == "running",
+			"queue_messages_published_total":   q.MessageStats.Publish,
+			"queue_messages_delivered_total":   q.MessageStats.DeliverGet,
+			"queue_messages_acked_total":       q.MessageStats.Ack,
+			"queue_messages_redelivered_total": q.MessageStats.Redeliver,
+		}
+
+		if q.ConsumerUtilisation != nil {
+			fields["queue_consumer_utilisation"] = *q.ConsumerUtilisation
+		}
+
+		ss.AddMetric(types.PluginRabbitMQ, fields, map[string]string{
+			"vhost":      q.Vhost,
+			"queue":      q.Name,
+			"node":       q.Node,
+			"queue_type": q.Type,
+			"state":      q.State,
+		})
+	}
+}
+
+type queueFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+func newQueueFilter(include, exclude string) (*queueFilter, error) {
+	var f queueFilter
+	var err error
+
+	if include != "" {
+		f.include, err = regexp.Compile(include)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid queue_include: %s", include)
+		}
+	}
+
+	if exclude != "" {
+		f.exclude, err = regexp.Compile(exclude)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid queue_exclude: %s", exclude)
+		}
+	}
+
+	return &f, nil
+}
+
+func (f *queueFilter) match(vhost, queue string) bool {
+	name := vhost + "/" + queue
+	if f.include != nil && !f.include.MatchString(name) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// selectQueues 按照 include/exclude 过滤队列，再按照消息堆积数量从大到小保留前 max 个，返回因为超过 max 而被丢弃的队列数量
+func selectQueues(queues []Queue, filter *queueFilter, max int) ([]Queue, int) {
+	selected := make([]Queue, 0, len(queues))
+	for _, q := range queues {
+		if filter.match(q.Vhost, q.Name) {
+			selected = append(selected, q)
+		}
+	}
+
+	if max <= 0 || len(selected) <= max {
+		return selected, 0
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Messages > selected[j].Messages
+	})
+
+	return selected[:max], len(selected) - max
+}
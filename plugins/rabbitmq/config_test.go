@@ -0,0 +1,48 @@
+package rabbitmq
+
+import (
+	"testing"
+)
+
+func TestSelectQueues(t *testing.T) {
+	queues := []Queue{
+		{Vhost: "/", Name: "orders", Messages: 10},
+		{Vhost: "/", Name: "amq.gen-abc", Messages: 1},
+		{Vhost: "billing", Name: "invoices", Messages: 500},
+		{Vhost: "billing", Name: "refunds", Messages: 30},
+		{Vhost: "test", Name: "tmp", Messages: 9999},
+	}
+
+	filter, err := newQueueFilter("", `^test/|amq\.gen-`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	selected, truncated := selectQueues(queues, filter, 0)
+	if len(selected) != 3 || truncated != 0 {
+		t.Fatalf("expected 3 queues without truncation, got %d, truncated %d", len(selected), truncated)
+	}
+
+	selected, truncated = selectQueues(queues, filter, 2)
+	if len(selected) != 2 || truncated != 1 {
+		t.Fatalf("expected 2 queues with 1 truncated, got %d, truncated %d", len(selected), truncated)
+	}
+
+	if selected[0].Name != "invoices" || selected[1].Name != "refunds" {
+		t.Fatalf("expected the queues with most messages to be kept, got %s, %s", selected[0].Name, selected[1].Name)
+	}
+
+	filter, err = newQueueFilter("^billing/", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	selected, _ = selectQueues(queues, filter, 0)
+	if len(selected) != 2 {
+		t.Fatalf("expected 2 queues in billing vhost, got %d", len(selected))
+	}
+
+	if _, err = newQueueFilter("", "["); err == nil {
+		t.Fatalf("expected error for invalid queue_exclude")
+	}
+}
@@ -0,0 +1,97 @@
+package rabbitmq
+
+type ObjectTotals struct {
+	Channels    int64 `json:"channels"`
+	Connections int64 `json:"connections"`
+	Consumers   int64 `json:"consumers"`
+	Exchanges   int64 `json:"exchanges"`
+	Queues      int64 `json:"queues"`
+}
+
+type QueueTotals struct {
+	Messages               int64 `json:"messages"`
+	MessagesReady          int64 `json:"messages_ready"`
+	MessagesUnacknowledged int64 `json:"messages_unacknowledged"`
+}
+
+type MessageStats struct {
+	Ack              int64 `json:"ack"`
+	Confirm          int64 `json:"confirm"`
+	Deliver          int64 `json:"deliver"`
+	DeliverGet       int64 `json:"deliver_get"`
+	Publish          int64 `json:"publish"`
+	PublishIn        int64 `json:"publish_in"`
+	PublishOut       int64 `json:"publish_out"`
+	Redeliver        int64 `json:"redeliver"`
+	ReturnUnroutable int64 `json:"return_unroutable"`
+	DropUnroutable   int64 `json:"drop_unroutable"`
+	DeliverNoAck     int64 `json:"deliver_no_ack"`
+	GetNoAck         int64 `json:"get_no_ack"`
+	GetEmpty         int64 `json:"get_empty"`
+	DiskReads        int64 `json:"disk_reads"`
+	DiskWrites       int64 `json:"disk_writes"`
+}
+
+type Overview struct {
+	ManagementVersion string       `json:"management_version"`
+	RabbitMQVersion   string       `json:"rabbitmq_version"`
+	ErlangVersion     string       `json:"erlang_version"`
+	ClusterName       string       `json:"cluster_name"`
+	Node              string       `json:"node"`
+	ObjectTotals      ObjectTotals `json:"object_totals"`
+	QueueTotals       QueueTotals  `json:"queue_totals"`
+	MessageStats      MessageStats `json:"message_stats"`
+}
+
+type Node struct {
+	Name          string        `json:"name"`
+	Type          string        `json:"type"`
+	Running       bool          `json:"running"`
+	MemUsed       int64         `json:"mem_used"`
+	MemLimit      int64         `json:"mem_limit"`
+	MemAlarm      bool          `json:"mem_alarm"`
+	DiskFree      int64         `json:"disk_free"`
+	DiskFreeLimit int64         `json:"disk_free_limit"`
+	DiskFreeAlarm bool          `json:"disk_free_alarm"`
+	FdUsed        int64         `json:"fd_used"`
+	FdTotal       int64         `json:"fd_total"`
+	SocketsUsed   int64         `json:"sockets_used"`
+	SocketsTotal  int64         `json:"sockets_total"`
+	ProcUsed      int64         `json:"proc_used"`
+	ProcTotal     int64         `json:"proc_total"`
+	Uptime        int64         `json:"uptime"` // milliseconds
+	RunQueue      int64         `json:"run_queue"`
+	Partitions    []interface{} `json:"partitions"`
+}
+
+type Vhost struct {
+	Name                   string       `json:"name"`
+	Messages               int64        `json:"messages"`
+	MessagesReady          int64        `json:"messages_ready"`
+	MessagesUnacknowledged int64        `json:"messages_unacknowledged"`
+	MessageStats           MessageStats `json:"message_stats"`
+}
+
+type Exchange struct {
+	Name         string       `json:"name"`
+	Vhost        string       `json:"vhost"`
+	Type         string       `json:"type"`
+	Durable      bool         `json:"durable"`
+	MessageStats MessageStats `json:"message_stats"`
+}
+
+type Queue struct {
+	Name                   string       `json:"name"`
+	Vhost                  string       `json:"vhost"`
+	Node                   string       `json:"node"`
+	State                  string       `json:"state"`
+	Type                   string       `json:"type"`
+	Durable                bool         `json:"durable"`
+	Messages               int64        `json:"messages"`
+	MessagesReady          int64        `json:"messages_ready"`
+	MessagesUnacknowledged int64        `json:"messages_unacknowledged"`
+	Consumers              int64        `json:"consumers"`
+	ConsumerUtilisation    *float64     `json:"consumer_utilisation"`
+	Memory                 int64        `json:"memory"`
+	MessageStats           MessageStats `json:"message_stats"`
+}
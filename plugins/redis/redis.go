@@ -119,6 +119,16 @@ func (*Redis) ParseConfig(baseDir string, bs []byte) (any, error) {
 func (*Redis) Scrape(ctx context.Context, target string, c any, ss *types.Samples) error {
 	// 这个方法中如果要对配置 c 变量做修改，一定要 clone 一份之后再修改，因为并发的多个 target 共享了一个 c 变量
 	conf := c.(*Config)
+	// 服务发现可以给 target 单独设置账号密码，每个 target 都会单独 ParseConfig，所以这里可以直接修改 conf
+	if tp := plugins.GetTargetParams(ctx); tp != nil {
+		if tp.Username != "" {
+			conf.User = tp.Username
+		}
+		if tp.Password != "" {
+			conf.Password = tp.Password
+		}
+	}
+
 	if !strings.Contains(target, "://") {
 		target = "redis://" + target
 	}
@@ -88,13 +88,15 @@ func collectCustomQuery(ctx context.Context, db *sql.DB, ss *types.Samples, quer
 			row[strings.ToLower(colName)] = string(*val)
 		}
 
-		if err = parseRow(row, query, ss); err != nil {
+		if err = ParseRow(row, query, ss); err != nil {
 			logger.Errorf("failed to parse row: %s, sql: %s", err, query.Request)
 		}
 	}
 }
 
-func parseRow(row map[string]string, query CustomQuery, ss *types.Samples) error {
+// ParseRow 把一行查询结果（列名需小写）按照 CustomQuery 的配置转换成指标
+// 对于不是通过 database/sql 查询的数据源（比如 ClickHouse 的 HTTP 接口），可以直接复用这个方法
+func ParseRow(row map[string]string, query CustomQuery, ss *types.Samples) error {
 	labels := make(map[string]string)
 
 	for _, label := range query.LabelFields {
@@ -0,0 +1,34 @@
+package plugins
+
+import "context"
+
+// TargetParams 是某个 target 专属的参数，由服务发现或者 relabel 通过特殊标签设置，调度器会放到 Scrape 的 ctx 里传给插件
+//
+// 支持的标签如下，这些标签只用来传参，不会出现在最终的监控数据里：
+//
+//	__auth_username__       用户名
+//	__auth_password__       密码
+//	__auth_password_file__  从文件读取密码，相对路径是相对 main.yaml 所在目录
+//	__param_<key>__         其他参数，比如 DSN 里的连接参数
+//
+// 这样一个 job 就可以覆盖多个账号不同的数据库实例，不用每个账号拆一个 job
+type TargetParams struct {
+	Username string
+	Password string
+	Params   map[string]string
+}
+
+type targetParamsKey struct{}
+
+// WithTargetParams returns a copy of ctx carrying tp.
+func WithTargetParams(ctx context.Context, tp *TargetParams) context.Context {
+	return context.WithValue(ctx, targetParamsKey{}, tp)
+}
+
+// GetTargetParams returns TargetParams stored in ctx by the scheduler.
+//
+// nil is returned if the target has no special labels.
+func GetTargetParams(ctx context.Context) *TargetParams {
+	tp, _ := ctx.Value(targetParamsKey{}).(*TargetParams)
+	return tp
+}
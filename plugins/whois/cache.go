@@ -0,0 +1,48 @@
+package whois
+
+import (
+	"sync"
+	"time"
+)
+
+// ParseConfig 每次采集都会调用，所以缓存放在包级别，key 是域名加上查询配置的 hash，见 Config.cacheKey
+var resultCache = &domainCache{m: make(map[string]cacheEntry)}
+
+// maxErrorCacheTTL 查询失败的结果也缓存一段时间，避免解析不了或者被限流的域名每次采集都去查询
+const maxErrorCacheTTL = 10 * time.Minute
+
+type cacheEntry struct {
+	info      *domainInfo
+	err       error
+	expiresAt time.Time
+}
+
+type domainCache struct {
+	sync.Mutex
+	m map[string]cacheEntry
+}
+
+// get 返回缓存的查询结果，查询失败的时候 err 不为空
+func (dc *domainCache) get(key string) (cacheEntry, bool) {
+	dc.Lock()
+	defer dc.Unlock()
+
+	e, has := dc.m[key]
+	if !has || time.Now().After(e.expiresAt) {
+		return cacheEntry{}, false
+	}
+	return e, true
+}
+
+func (dc *domainCache) set(key string, info *domainInfo, err error, ttl time.Duration) {
+	dc.Lock()
+	defer dc.Unlock()
+
+	now := time.Now()
+	for k, e := range dc.m {
+		if now.After(e.expiresAt) {
+			delete(dc.m, k)
+		}
+	}
+	dc.m[key] = cacheEntry{info: info, err: err, expiresAt: now.Add(ttl)}
+}
@@ -0,0 +1,235 @@
+package whois
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/cprobe/cprobe/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultTimeout          = 10 * time.Second
+	defaultCacheTTL         = 6 * time.Hour
+	defaultRDAPBootstrapURL = "https://data.iana.org/rdap/dns.json"
+)
+
+// 默认的正则，第一个捕获组是要提取的值
+var defaultTLD = TLDConfig{
+	ExpiryRegex:    `(?i)(?:\[有効期限]|Registry Expiry Date|paid-till|Expiration Date|Expiration Time|Expiry.*|expires.*|expire-date)[?:|\s][ \t](.*)`,
+	CreationRegex:  `(?i)(?:\[登録年月日]|Creation Date|Created On|Registration Time|created)[?:|\s][ \t](.*)`,
+	RegistrarRegex: `(?im)^\s*(?:Registrar|Sponsoring Registrar|registrar name)\s*:[ \t]*(.+)$`,
+	StatusRegex:    `(?im)^\s*(?:Domain Status|Status|state)\s*:[ \t]*(\S+)`,
+}
+
+type Config struct {
+	BaseDir string `toml:"-"`
+	// 单次查询的超时时间，默认 10s
+	Timeout time.Duration `toml:"timeout"`
+	// 查询结果的缓存时间，默认 6h。域名信息很少变化，缓存可以避免每次采集都去查询，触发注册局的频率限制。
+	// 查询失败的结果也会缓存，时间是 cache_ttl 和 10m 中较小的那个
+	CacheTTL time.Duration `toml:"cache_ttl"`
+	// 默认优先使用 RDAP 查询，查询失败或者没有到期时间的时候回退到 WHOIS
+	DisableRDAP bool `toml:"disable_rdap"`
+	// RDAP bootstrap 文件的地址，用于根据 TLD 查找 RDAP 服务
+	RDAPBootstrapURL string `toml:"rdap_bootstrap_url"`
+	// 按照 TLD 覆盖查询方式和解析规则，target 按照最长后缀匹配，没有匹配的使用默认规则
+	TLDs []*TLDConfig `toml:"tlds"`
+
+	defaultTLD *TLDConfig
+}
+
+type TLDConfig struct {
+	// TLD 是域名后缀，比如 jp、com.cn
+	TLD string `toml:"tld"`
+	// RDAPURL 是 RDAP 服务的地址，比如 https://rdap.verisign.com/com/v1/，不配置的时候从 bootstrap 文件中查找
+	RDAPURL     string `toml:"rdap_url"`
+	DisableRDAP bool   `toml:"disable_rdap"`
+	// WhoisServer 是 WHOIS 服务器的地址，不配置的时候自动查找
+	WhoisServer string `toml:"whois_server"`
+	// 下面几个正则用于解析 WHOIS 的响应，第一个捕获组是要提取的值，不配置的时候使用默认的正则
+	ExpiryRegex    string `toml:"expiry_regex"`
+	CreationRegex  string `toml:"creation_regex"`
+	RegistrarRegex string `toml:"registrar_regex"`
+	StatusRegex    string `toml:"status_regex"`
+	// DateLayout 是 Go 的时间格式，比如 2006/01/02，不配置的时候自动识别
+	DateLayout string `toml:"date_layout"`
+
+	expiryRegex    *regexp.Regexp
+	creationRegex  *regexp.Regexp
+	registrarRegex *regexp.Regexp
+	statusRegex    *regexp.Regexp
+}
+
+func (c *Config) init() error {
+	if c.Timeout == 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.CacheTTL == 0 {
+		c.CacheTTL = defaultCacheTTL
+	}
+	if c.RDAPBootstrapURL == "" {
+		c.RDAPBootstrapURL = defaultRDAPBootstrapURL
+	}
+
+	c.defaultTLD = &TLDConfig{}
+	if err := c.defaultTLD.init(); err != nil {
+		return err
+	}
+	for _, t := range c.TLDs {
+		t.TLD = strings.Trim(strings.ToLower(t.TLD), ".")
+		if t.TLD == "" {
+			return errors.New("tld must not be empty in [[tlds]]")
+		}
+		if err := t.init(); err != nil {
+			return errors.WithMessagef(err, "invalid config for tld %s", t.TLD)
+		}
+	}
+	return nil
+}
+
+func (t *TLDConfig) init() error {
+	for _, r := range []struct {
+		expr     *string
+		fallback string
+		re       **regexp.Regexp
+	}{
+		{&t.ExpiryRegex, defaultTLD.ExpiryRegex, &t.expiryRegex},
+		{&t.CreationRegex, defaultTLD.CreationRegex, &t.creationRegex},
+		{&t.RegistrarRegex, defaultTLD.RegistrarRegex, &t.registrarRegex},
+		{&t.StatusRegex, defaultTLD.StatusRegex, &t.statusRegex},
+	} {
+		if *r.expr == "" {
+			*r.expr = r.fallback
+		}
+		re, err := regexp.Compile(*r.expr)
+		if err != nil {
+			return errors.WithMessagef(err, "cannot compile regex %q", *r.expr)
+		}
+		if re.NumSubexp() < 1 {
+			return errors.Errorf("regex %q must have a capturing group", *r.expr)
+		}
+		*r.re = re
+	}
+	return nil
+}
+
+// tldConfig 按照最长后缀匹配 domain 对应的配置
+func (c *Config) tldConfig(domain string) *TLDConfig {
+	var best *TLDConfig
+	for _, t := range c.TLDs {
+		if domain != t.TLD && !strings.HasSuffix(domain, "."+t.TLD) {
+			continue
+		}
+		if best == nil || len(t.TLD) > len(best.TLD) {
+			best = t
+		}
+	}
+	if best == nil {
+		return c.defaultTLD
+	}
+	return best
+}
+
+// domainInfo 是一次查询的结果，source 是 rdap 或者 whois
+type domainInfo struct {
+	source     string
+	expiration time.Time
+	creation   time.Time
+	registrar  string
+	statuses   []string
+}
+
+// target 是要查询的域名，比如 flashcat.cloud
+func (c *Config) Scrape(ctx context.Context, target string, ss *types.Samples) error {
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(target)), ".")
+	if domain == "" {
+		return errors.New("empty domain")
+	}
+
+	key := c.cacheKey(domain)
+	e, cached := resultCache.get(key)
+	if cached && e.err != nil {
+		return errors.WithMessage(e.err, "cached lookup error")
+	}
+	info := e.info
+	if !cached {
+		var err error
+		info, err = c.lookup(ctx, domain)
+		if err != nil {
+			// 采集被取消的时候不是域名本身的问题，不缓存
+			if ctx.Err() == nil {
+				ttl := c.CacheTTL
+				if ttl > maxErrorCacheTTL {
+					ttl = maxErrorCacheTTL
+				}
+				resultCache.set(key, nil, err, ttl)
+			}
+			return err
+		}
+		resultCache.set(key, info, nil, c.CacheTTL)
+	}
+
+	fields := map[string]interface{}{
+		"domain_expiration":            float64(info.expiration.Unix()),
+		"domain_days_until_expiration": time.Until(info.expiration).Hours() / 24,
+		"lookup_cached":                0,
+	}
+	if cached {
+		fields["lookup_cached"] = 1
+	}
+	if !info.creation.IsZero() {
+		fields["domain_creation"] = float64(info.creation.Unix())
+	}
+	ss.AddMetric(types.PluginWhois, fields)
+	ss.AddMetric(types.PluginWhois, map[string]interface{}{"domain_info": 1}, map[string]string{
+		"registrar": info.registrar,
+		"source":    info.source,
+	})
+	for _, status := range info.statuses {
+		ss.AddMetric(types.PluginWhois, map[string]interface{}{"domain_status": 1}, map[string]string{"status": status})
+	}
+	return nil
+}
+
+// cacheKey 带上影响查询结果的配置，不同 job 的 [[tlds]] 配置不同的时候不会拿到对方的结果
+func (c *Config) cacheKey(domain string) string {
+	t := c.tldConfig(domain)
+	h := xxhash.New()
+	fmt.Fprintf(h, "%t\x00%s\x00%s\x00%s\x00%t\x00%s\x00%s\x00%s\x00%s\x00%s\x00%s",
+		c.DisableRDAP, c.RDAPBootstrapURL, t.TLD, t.RDAPURL, t.DisableRDAP, t.WhoisServer,
+		t.ExpiryRegex, t.CreationRegex, t.RegistrarRegex, t.StatusRegex, t.DateLayout)
+	return fmt.Sprintf("%s/%016x", domain, h.Sum64())
+}
+
+// lookup 优先使用 RDAP 查询，失败或者没有到期时间的时候回退到 WHOIS
+func (c *Config) lookup(ctx context.Context, domain string) (*domainInfo, error) {
+	tld := c.tldConfig(domain)
+
+	var rdapErr error
+	if !c.DisableRDAP && !tld.DisableRDAP {
+		info, err := c.lookupRDAP(ctx, domain, tld)
+		if err == nil && !info.expiration.IsZero() {
+			return info, nil
+		}
+		rdapErr = err
+		if err == nil {
+			rdapErr = errors.New("no expiration event in rdap response")
+		}
+		logger.Warnf("rdap lookup of %s failed, fallback to whois: %v", domain, rdapErr)
+	}
+
+	info, err := c.lookupWhois(ctx, domain, tld)
+	if err != nil {
+		if rdapErr != nil {
+			return nil, fmt.Errorf("failed to lookup domain(%s): rdap: %v; whois: %w", domain, rdapErr, err)
+		}
+		return nil, err
+	}
+	return info, nil
+}
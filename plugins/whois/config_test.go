@@ -0,0 +1,249 @@
+package whois
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/conv"
+	"github.com/cprobe/cprobe/types"
+)
+
+const comWhois = `   Domain Name: EXAMPLE.COM
+   Registry Domain ID: 2336799_DOMAIN_COM-VRSN
+   Registrar WHOIS Server: whois.iana.org
+   Updated Date: 2024-08-14T07:01:34Z
+   Creation Date: 1995-08-14T04:00:00Z
+   Registry Expiry Date: 2025-08-13T04:00:00Z
+   Registrar: RESERVED-Internet Assigned Numbers Authority
+   Domain Status: clientDeleteProhibited https://icann.org/epp#clientDeleteProhibited
+   Domain Status: clientTransferProhibited https://icann.org/epp#clientTransferProhibited
+   Domain Status: clientTransferProhibited https://icann.org/epp#clientTransferProhibited
+`
+
+const jpWhois = `[ JPRS database provides information on network administration. ]
+
+Domain Information:
+[Domain Name]                   EXAMPLE.JP
+[登録者名]                      日本レジストリサービス
+[登録年月日]                    2001/02/28
+[有効期限]                      2025/02/28
+[状態]                          Active
+`
+
+func TestParse(t *testing.T) {
+	c := &Config{TLDs: []*TLDConfig{{TLD: ".JP", StatusRegex: `\[状態\]\s+(\S+)`, DateLayout: "2006/01/02"}}}
+	if err := c.init(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	info, err := parse("example.com", []byte(comWhois), c.tldConfig("example.com"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !info.expiration.Equal(time.Date(2025, 8, 13, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected expiration %s", info.expiration)
+	}
+	if !info.creation.Equal(time.Date(1995, 8, 14, 4, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected creation %s", info.creation)
+	}
+	if info.registrar != "RESERVED-Internet Assigned Numbers Authority" {
+		t.Errorf("unexpected registrar %q", info.registrar)
+	}
+	if want := []string{"clientDeleteProhibited", "clientTransferProhibited"}; !reflect.DeepEqual(info.statuses, want) {
+		t.Errorf("unexpected statuses %v, want %v", info.statuses, want)
+	}
+
+	tld := c.tldConfig("www.example.jp")
+	if tld.TLD != "jp" {
+		t.Fatalf("expected tld config jp, got %q", tld.TLD)
+	}
+	info, err = parse("example.jp", []byte(jpWhois), tld)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !info.expiration.Equal(time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected expiration %s", info.expiration)
+	}
+	if want := []string{"Active"}; !reflect.DeepEqual(info.statuses, want) {
+		t.Errorf("unexpected statuses %v, want %v", info.statuses, want)
+	}
+
+	if _, err := parse("example.com", []byte("No match for domain"), c.defaultTLD); err == nil {
+		t.Errorf("expected error for response without expiry date")
+	}
+}
+
+func TestInitInvalidRegex(t *testing.T) {
+	c := &Config{TLDs: []*TLDConfig{{TLD: "jp", ExpiryRegex: `有効期限`}}}
+	if err := c.init(); err == nil {
+		t.Errorf("expected error for regex without capturing group")
+	}
+}
+
+func TestNormalizeStatus(t *testing.T) {
+	for in, want := range map[string]string{
+		"client transfer prohibited": "clientTransferProhibited",
+		"active":                     "active",
+		"clientHold":                 "clientHold",
+		"Pending Delete":             "pendingDelete",
+	} {
+		if got := normalizeStatus(in); got != want {
+			t.Errorf("normalizeStatus(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestScrapeRDAP(t *testing.T) {
+	var bootstrapHits, domainHits int
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/dns.json", func(w http.ResponseWriter, r *http.Request) {
+		bootstrapHits++
+		fmt.Fprintf(w, `{"services": [[["test"], ["%s/rdap/"]]]}`, srv.URL)
+	})
+	mux.HandleFunc("/rdap/domain/example.test", func(w http.ResponseWriter, r *http.Request) {
+		domainHits++
+		fmt.Fprint(w, `{
+  "events": [
+    {"eventAction": "registration", "eventDate": "2001-02-28T00:00:00Z"},
+    {"eventAction": "expiration", "eventDate": "2099-02-28T00:00:00Z"}
+  ],
+  "status": ["client transfer prohibited", "active"],
+  "entities": [
+    {"roles": ["registrar"], "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example Registrar"]]]}
+  ]
+}`)
+	})
+
+	cfg := &Config{RDAPBootstrapURL: srv.URL + "/dns.json"}
+	if err := cfg.init(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for i, wantCached := range []float64{0, 1} {
+		ss := types.NewSamples()
+		if err := cfg.Scrape(context.Background(), "Example.Test.", ss); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		values := make(map[string]float64)
+		tags := make(map[string]map[string]string)
+		for _, m := range ss.PopBackAll() {
+			for k, v := range m.Fields() {
+				f, _ := conv.ToFloat64(v)
+				key := k
+				if status, has := m.Tags()["status"]; has {
+					key += "/" + status
+				}
+				values[key] = f
+				tags[key] = m.Tags()
+			}
+		}
+
+		if values["domain_expiration"] != float64(time.Date(2099, 2, 28, 0, 0, 0, 0, time.UTC).Unix()) {
+			t.Errorf("scrape %d: unexpected whois_domain_expiration %v", i, values["domain_expiration"])
+		}
+		if values["domain_creation"] != float64(time.Date(2001, 2, 28, 0, 0, 0, 0, time.UTC).Unix()) {
+			t.Errorf("scrape %d: unexpected whois_domain_creation %v", i, values["domain_creation"])
+		}
+		if values["domain_days_until_expiration"] <= 0 {
+			t.Errorf("scrape %d: unexpected whois_domain_days_until_expiration %v", i, values["domain_days_until_expiration"])
+		}
+		if values["lookup_cached"] != wantCached {
+			t.Errorf("scrape %d: expected whois_lookup_cached %v, got %v", i, wantCached, values["lookup_cached"])
+		}
+		if tags["domain_info"]["registrar"] != "Example Registrar" || tags["domain_info"]["source"] != "rdap" {
+			t.Errorf("scrape %d: unexpected whois_domain_info tags %v", i, tags["domain_info"])
+		}
+		for _, status := range []string{"clientTransferProhibited", "active"} {
+			if values["domain_status/"+status] != 1 {
+				t.Errorf("scrape %d: expected whois_domain_status{status=%q}", i, status)
+			}
+		}
+	}
+
+	if bootstrapHits != 1 || domainHits != 1 {
+		t.Errorf("expected results to be cached, got %d bootstrap and %d domain requests", bootstrapHits, domainHits)
+	}
+}
+
+func TestScrapeRDAPURLOverride(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/domain/example.override" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"events": [{"eventAction": "expiration", "eventDate": "2099-02-28T00:00:00Z"}]}`)
+	}))
+	defer srv.Close()
+
+	cfg := &Config{
+		RDAPBootstrapURL: srv.URL + "/missing.json",
+		TLDs:             []*TLDConfig{{TLD: "override", RDAPURL: srv.URL + "/v1"}},
+	}
+	if err := cfg.init(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	info, err := cfg.lookupRDAP(context.Background(), "example.override", cfg.tldConfig("example.override"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !info.expiration.Equal(time.Date(2099, 2, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected expiration %s", info.expiration)
+	}
+}
+
+func TestScrapeCachesErrors(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	cfg := &Config{
+		TLDs: []*TLDConfig{{TLD: "errors", RDAPURL: srv.URL, WhoisServer: "127.0.0.1:1"}},
+	}
+	if err := cfg.init(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := cfg.Scrape(context.Background(), "example.errors", types.NewSamples()); err == nil {
+			t.Fatalf("scrape %d: expecting non-nil error", i)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("expected lookup error to be cached, got %d rdap requests", hits)
+	}
+}
+
+func TestCacheKeyDependsOnTLDConfig(t *testing.T) {
+	newConfig := func(whoisServer string) *Config {
+		cfg := &Config{TLDs: []*TLDConfig{{TLD: "jp", WhoisServer: whoisServer}}}
+		if err := cfg.init(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return cfg
+	}
+
+	a := newConfig("whois.jprs.jp")
+	b := newConfig("whois.example.jp")
+	if a.cacheKey("example.jp") == b.cacheKey("example.jp") {
+		t.Errorf("jobs with different [[tlds]] config must not share cached results")
+	}
+	if a.cacheKey("example.jp") != newConfig("whois.jprs.jp").cacheKey("example.jp") {
+		t.Errorf("jobs with the same config must share cached results")
+	}
+	if a.cacheKey("example.jp") == a.cacheKey("example.com") {
+		t.Errorf("different domains must not share cached results")
+	}
+}
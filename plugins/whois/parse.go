@@ -0,0 +1,79 @@
+package whois
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/domainr/whois"
+)
+
+func (c *Config) lookupWhois(ctx context.Context, domain string, tld *TLDConfig) (*domainInfo, error) {
+	req := &whois.Request{Query: domain, Host: tld.WhoisServer}
+	if err := req.Prepare(); err != nil {
+		return nil, err
+	}
+
+	res, err := whois.NewClient(c.Timeout).FetchContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return parse(domain, res.Body, tld)
+}
+
+// parse 按照 tld 中的正则解析 WHOIS 的响应
+func parse(host string, res []byte, tld *TLDConfig) (*domainInfo, error) {
+	body := string(res)
+	info := &domainInfo{source: "whois"}
+
+	value := firstSubmatch(tld.expiryRegex, body)
+	if value == "" {
+		return nil, fmt.Errorf("failed to parse domain(%s): unexpected regexp results", host)
+	}
+	expiration, err := parseDate(value, tld.DateLayout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse domain(%s) date: %s, error: %v", host, value, err)
+	}
+	info.expiration = expiration
+
+	if value := firstSubmatch(tld.creationRegex, body); value != "" {
+		if creation, err := parseDate(value, tld.DateLayout); err == nil {
+			info.creation = creation
+		}
+	}
+
+	info.registrar = firstSubmatch(tld.registrarRegex, body)
+
+	seen := make(map[string]struct{})
+	for _, m := range tld.statusRegex.FindAllStringSubmatch(body, -1) {
+		status := normalizeStatus(m[1])
+		if status == "" {
+			continue
+		}
+		if _, has := seen[status]; has {
+			continue
+		}
+		seen[status] = struct{}{}
+		info.statuses = append(info.statuses, status)
+	}
+	return info, nil
+}
+
+func firstSubmatch(re *regexp.Regexp, s string) string {
+	results := re.FindStringSubmatch(s)
+	if len(results) < 2 {
+		return ""
+	}
+	return strings.TrimSpace(results[1])
+}
+
+func parseDate(value, layout string) (time.Time, error) {
+	if layout != "" {
+		return time.Parse(layout, value)
+	}
+	return dateparse.ParseAny(value)
+}
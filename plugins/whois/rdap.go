@@ -0,0 +1,220 @@
+package whois
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/araddon/dateparse"
+	"github.com/pkg/errors"
+)
+
+// bootstrap 文件很少变化，缓存一天
+const rdapBootstrapTTL = 24 * time.Hour
+
+var bootstrapCache = struct {
+	sync.Mutex
+	url       string
+	services  map[string]string
+	expiresAt time.Time
+}{}
+
+// rdapBootstrap 是 RFC 9224 定义的 bootstrap 文件格式，services 的每一项是 [[tld...], [url...]]
+type rdapBootstrap struct {
+	Services [][][]string `json:"services"`
+}
+
+type rdapEvent struct {
+	EventAction string `json:"eventAction"`
+	EventDate   string `json:"eventDate"`
+}
+
+type rdapEntity struct {
+	Roles      []string      `json:"roles"`
+	VcardArray []interface{} `json:"vcardArray"`
+	Entities   []rdapEntity  `json:"entities"`
+}
+
+type rdapDomain struct {
+	Events   []rdapEvent  `json:"events"`
+	Status   []string     `json:"status"`
+	Entities []rdapEntity `json:"entities"`
+}
+
+func (c *Config) lookupRDAP(ctx context.Context, domain string, tld *TLDConfig) (*domainInfo, error) {
+	base := tld.RDAPURL
+	if base == "" {
+		var err error
+		base, err = c.rdapServer(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	var d rdapDomain
+	if err := c.getJSON(ctx, base+"domain/"+domain, &d); err != nil {
+		return nil, err
+	}
+
+	info := &domainInfo{source: "rdap"}
+	for _, e := range d.Events {
+		t, err := time.Parse(time.RFC3339, e.EventDate)
+		if err != nil {
+			t, err = dateparse.ParseAny(e.EventDate)
+			if err != nil {
+				continue
+			}
+		}
+		switch e.EventAction {
+		case "expiration":
+			info.expiration = t
+		case "registration":
+			info.creation = t
+		}
+	}
+	for _, s := range d.Status {
+		info.statuses = append(info.statuses, normalizeStatus(s))
+	}
+	info.registrar = findRegistrar(d.Entities)
+	return info, nil
+}
+
+// rdapServer 根据 bootstrap 文件找到 domain 所属 TLD 的 RDAP 服务，按照最长后缀匹配
+func (c *Config) rdapServer(ctx context.Context, domain string) (string, error) {
+	services, err := c.rdapServices(ctx)
+	if err != nil {
+		return "", err
+	}
+	labels := strings.Split(domain, ".")
+	for i := range labels {
+		if u, has := services[strings.Join(labels[i:], ".")]; has {
+			return u, nil
+		}
+	}
+	return "", fmt.Errorf("no rdap server found for domain %s", domain)
+}
+
+func (c *Config) rdapServices(ctx context.Context) (map[string]string, error) {
+	bootstrapCache.Lock()
+	defer bootstrapCache.Unlock()
+
+	if bootstrapCache.url == c.RDAPBootstrapURL && time.Now().Before(bootstrapCache.expiresAt) {
+		return bootstrapCache.services, nil
+	}
+
+	var b rdapBootstrap
+	if err := c.getJSON(ctx, c.RDAPBootstrapURL, &b); err != nil {
+		return nil, errors.WithMessage(err, "failed to fetch rdap bootstrap")
+	}
+
+	services := make(map[string]string)
+	for _, s := range b.Services {
+		if len(s) < 2 || len(s[1]) == 0 {
+			continue
+		}
+		// 优先使用 https 的地址
+		u := s[1][0]
+		for _, candidate := range s[1] {
+			if strings.HasPrefix(candidate, "https://") {
+				u = candidate
+				break
+			}
+		}
+		for _, tld := range s[0] {
+			services[strings.ToLower(tld)] = u
+		}
+	}
+
+	bootstrapCache.url = c.RDAPBootstrapURL
+	bootstrapCache.services = services
+	bootstrapCache.expiresAt = time.Now().Add(rdapBootstrapTTL)
+	return services, nil
+}
+
+func (c *Config) getJSON(ctx context.Context, url string, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/rdap+json, application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// findRegistrar 返回 role 为 registrar 的 entity 的 vcard fn 字段
+func findRegistrar(entities []rdapEntity) string {
+	for _, e := range entities {
+		for _, role := range e.Roles {
+			if role == "registrar" {
+				if fn := vcardFN(e.VcardArray); fn != "" {
+					return fn
+				}
+			}
+		}
+		if fn := findRegistrar(e.Entities); fn != "" {
+			return fn
+		}
+	}
+	return ""
+}
+
+// vcardFN 解析 jCard 格式：["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Example Registrar"], ...]]
+func vcardFN(vcard []interface{}) string {
+	if len(vcard) < 2 {
+		return ""
+	}
+	props, ok := vcard[1].([]interface{})
+	if !ok {
+		return ""
+	}
+	for _, p := range props {
+		prop, ok := p.([]interface{})
+		if !ok || len(prop) < 4 {
+			continue
+		}
+		if name, _ := prop[0].(string); name == "fn" {
+			fn, _ := prop[3].(string)
+			return strings.TrimSpace(fn)
+		}
+	}
+	return ""
+}
+
+// normalizeStatus 把 RDAP 的状态（client transfer prohibited）和 WHOIS 的状态（clientTransferProhibited）统一成 EPP 的格式
+func normalizeStatus(s string) string {
+	words := strings.Fields(strings.TrimSpace(s))
+	if len(words) == 0 {
+		return ""
+	}
+	if len(words) == 1 {
+		return words[0]
+	}
+	var b strings.Builder
+	b.WriteString(strings.ToLower(words[0]))
+	for _, w := range words[1:] {
+		w = strings.ToLower(w)
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	return b.String()
+}
@@ -2,21 +2,12 @@ package whois
 
 import (
 	"context"
-	"fmt"
-	"regexp"
-	"strings"
-
-	"github.com/araddon/dateparse"
-	"github.com/domainr/whois"
 
+	"github.com/BurntSushi/toml"
 	"github.com/cprobe/cprobe/plugins"
 	"github.com/cprobe/cprobe/types"
 )
 
-var (
-	expiryRegex = regexp.MustCompile(`(?i)(\[有効期限]|Registry Expiry Date|paid-till|Expiration Date|Expiration Time|Expiry.*|expires.*|expire-date)[?:|\s][ \t](.*)`)
-)
-
 type Whois struct {
 }
 
@@ -25,38 +16,19 @@ func init() {
 }
 
 func (wh *Whois) ParseConfig(baseDir string, bs []byte) (any, error) {
-	return nil, nil
-}
-
-func (wh *Whois) Scrape(ctx context.Context, target string, cfg any, ss *types.Samples) error {
-	req, err := whois.NewRequest(target)
-	if err != nil {
-		return err
-	}
-
-	res, err := whois.DefaultClient.Fetch(req)
+	var c Config
+	err := toml.Unmarshal(bs, &c)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	date, err := parse(target, res.Body)
-	if err != nil {
-		return err
+	c.BaseDir = baseDir
+	if err := c.init(); err != nil {
+		return nil, err
 	}
-
-	ss.AddMetric("whois", map[string]interface{}{"domain_expiration": date})
-	return nil
+	return &c, nil
 }
 
-func parse(host string, res []byte) (float64, error) {
-	results := expiryRegex.FindStringSubmatch(string(res))
-	if len(results) < 3 {
-		return 0, fmt.Errorf("failed to parse domain(%s): unexpected regexp results", host)
-	}
-
-	if parsedTime, err := dateparse.ParseAny(strings.TrimSpace(results[2])); err == nil {
-		return float64(parsedTime.Unix()), nil
-	} else {
-		return 0, fmt.Errorf("failed to parse domain(%s) date: %s, error: %v", host, strings.TrimSpace(results[2]), err)
-	}
+func (wh *Whois) Scrape(ctx context.Context, target string, c any, ss *types.Samples) error {
+	cfg := c.(*Config)
+	return cfg.Scrape(ctx, target, ss)
 }
@@ -0,0 +1,110 @@
+package probe
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+var (
+	clusterMembersCount = flag.Int("cluster.members", 1, "The number of cprobe instances in the cluster. Each instance scrapes only its share of targets, "+
+		"which is chosen by the hash of target labels after relabeling. See also -cluster.memberNum and -cluster.replicationFactor")
+	clusterMemberNum = flag.String("cluster.memberNum", "0", "The number of this cprobe instance in the cluster. Must be in the range 0 ... cluster.members-1. "+
+		"It can also be a name ending with the number, e.g. the pod name cprobe-1 of a StatefulSet")
+	clusterReplicationFactor = flag.Int("cluster.replicationFactor", 1, "The number of cprobe instances scraping every target. "+
+		"Values greater than 1 are useful for HA; the duplicate samples must be deduplicated by the storage")
+	clusterMemberLabel = flag.String("cluster.memberLabel", "", "Optional label name for storing -cluster.memberNum in every scraped sample. "+
+		"It is added only if -cluster.members is greater than 1. It cannot be used together with -cluster.replicationFactor greater than 1, "+
+		"since the samples from replicas must be identical in order to be deduplicated by the storage")
+)
+
+// clusterMemberID 是解析之后的 -cluster.memberNum
+var clusterMemberID int
+
+var clusterMemberNumRegexp = regexp.MustCompile(`(\d+)$`)
+
+// initCluster 校验集群相关的参数，并解析 -cluster.memberNum
+func initCluster() error {
+	if *clusterMembersCount < 1 {
+		return fmt.Errorf("-cluster.members must be greater than 0; got %d", *clusterMembersCount)
+	}
+	if *clusterReplicationFactor < 1 {
+		return fmt.Errorf("-cluster.replicationFactor must be greater than 0; got %d", *clusterReplicationFactor)
+	}
+	if *clusterReplicationFactor > *clusterMembersCount {
+		return fmt.Errorf("-cluster.replicationFactor=%d cannot exceed -cluster.members=%d", *clusterReplicationFactor, *clusterMembersCount)
+	}
+	if *clusterReplicationFactor > 1 && *clusterMemberLabel != "" {
+		// 每个副本的标签值不同，存储就没办法去重了
+		return fmt.Errorf("-cluster.memberLabel=%q cannot be used with -cluster.replicationFactor=%d; the samples scraped by replicas must be identical for deduplication",
+			*clusterMemberLabel, *clusterReplicationFactor)
+	}
+	n, err := parseClusterMemberNum(*clusterMemberNum)
+	if err != nil {
+		return err
+	}
+	if n >= *clusterMembersCount {
+		return fmt.Errorf("-cluster.memberNum=%d must be smaller than -cluster.members=%d", n, *clusterMembersCount)
+	}
+	clusterMemberID = n
+	if *clusterMembersCount > 1 {
+		logger.Infof("cluster mode: member %d of %d, replication factor %d", clusterMemberID, *clusterMembersCount, *clusterReplicationFactor)
+	}
+	return nil
+}
+
+// parseClusterMemberNum 支持纯数字，也支持以数字结尾的名字，比如 StatefulSet 的 pod 名 cprobe-1
+func parseClusterMemberNum(s string) (int, error) {
+	m := clusterMemberNumRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("cannot find member number at the end of -cluster.memberNum=%q", s)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse -cluster.memberNum=%q: %w", s, err)
+	}
+	return n, nil
+}
+
+// getClusterMemberNums 返回应该抓取这个 target 的实例编号，key 是 relabel 之后的 target 标签
+//
+// 跟 hashmod 一样先对 key 做 hash 取模拿到第一个实例，开启副本的话依次往后顺延
+func getClusterMemberNums(key string, membersCount, replicationFactor int) []int {
+	if membersCount <= 1 {
+		return []int{0}
+	}
+	if replicationFactor < 1 {
+		replicationFactor = 1
+	}
+	idx := int(xxhash.Sum64String(key) % uint64(membersCount))
+	memberNums := make([]int, replicationFactor)
+	for i := range memberNums {
+		memberNums[i] = idx
+		idx++
+		if idx >= membersCount {
+			idx = 0
+		}
+	}
+	return memberNums
+}
+
+// shouldScrapeTarget 判断当前实例是否负责抓取这个 target，是的话按需加上 -cluster.memberLabel 标签
+func shouldScrapeTarget(t *promutils.Labels) bool {
+	if *clusterMembersCount <= 1 {
+		return true
+	}
+	for _, n := range getClusterMemberNums(t.String(), *clusterMembersCount, *clusterReplicationFactor) {
+		if n == clusterMemberID {
+			if *clusterMemberLabel != "" {
+				t.Add(*clusterMemberLabel, strconv.Itoa(clusterMemberID))
+			}
+			return true
+		}
+	}
+	return false
+}
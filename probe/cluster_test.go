@@ -0,0 +1,101 @@
+package probe
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+func TestParseClusterMemberNum(t *testing.T) {
+	f := func(s string, want int) {
+		t.Helper()
+		n, err := parseClusterMemberNum(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n != want {
+			t.Fatalf("unexpected member num for %q; got %d; want %d", s, n, want)
+		}
+	}
+	f("0", 0)
+	f("12", 12)
+	f("cprobe-3", 3)
+	f("cprobe-0-1", 1)
+
+	for _, s := range []string{"", "cprobe", "1-cprobe"} {
+		if _, err := parseClusterMemberNum(s); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+}
+
+func TestGetClusterMemberNums(t *testing.T) {
+	f := func(key string, membersCount, replicationFactor int, want []int) {
+		t.Helper()
+		got := getClusterMemberNums(key, membersCount, replicationFactor)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("unexpected member nums for key=%q, membersCount=%d, replicationFactor=%d; got %v; want %v",
+				key, membersCount, replicationFactor, got, want)
+		}
+	}
+	f("foo", 0, 0, []int{0})
+	f("foo", 1, 1, []int{0})
+	f("foo", 3, 1, []int{2})
+	f("foo", 3, 2, []int{2, 0})
+	f("foo", 3, 3, []int{2, 0, 1})
+}
+
+func TestGetClusterMemberNumsDistribution(t *testing.T) {
+	const membersCount = 4
+	const replicationFactor = 2
+	const targets = 1000
+
+	counts := make([]int, membersCount)
+	for i := 0; i < targets; i++ {
+		nums := getClusterMemberNums(`{__address__="10.0.0.`+strconv.Itoa(i)+`:3306",job="mysql"}`, membersCount, replicationFactor)
+		if len(nums) != replicationFactor || nums[0] == nums[1] {
+			t.Fatalf("unexpected member nums: %v", nums)
+		}
+		for _, n := range nums {
+			counts[n]++
+		}
+	}
+	// every target is scraped by replicationFactor members, so each member gets about targets*replicationFactor/membersCount targets
+	for n, count := range counts {
+		if count < 400 || count > 600 {
+			t.Fatalf("unbalanced distribution for member %d: %d targets; counts: %v", n, count, counts)
+		}
+	}
+}
+
+func TestInitClusterMemberLabel(t *testing.T) {
+	defer func(members, replicationFactor int, memberNum, memberLabel string) {
+		*clusterMembersCount, *clusterReplicationFactor, *clusterMemberNum, *clusterMemberLabel = members, replicationFactor, memberNum, memberLabel
+		clusterMemberID = 0
+	}(*clusterMembersCount, *clusterReplicationFactor, *clusterMemberNum, *clusterMemberLabel)
+
+	f := func(replicationFactor int, memberLabel string, wantErr bool) {
+		t.Helper()
+		*clusterMembersCount, *clusterReplicationFactor, *clusterMemberNum, *clusterMemberLabel = 3, replicationFactor, "cprobe-1", memberLabel
+		if err := initCluster(); (err != nil) != wantErr {
+			t.Fatalf("unexpected error for replicationFactor=%d, memberLabel=%q: %v", replicationFactor, memberLabel, err)
+		}
+	}
+	f(1, "cluster_member", false)
+	f(2, "", false)
+	// replicas would produce series with different labels, which cannot be deduplicated
+	f(2, "cluster_member", true)
+
+	// with replication the targets scraped by this member keep their labels
+	f(3, "", false)
+	labels := promutils.NewLabelsFromMap(map[string]string{"__address__": "10.0.0.1:3306", "job": "mysql"})
+	want := labels.String()
+	if !shouldScrapeTarget(labels) {
+		t.Fatalf("expecting the target to be scraped by every member when replicationFactor equals members count")
+	}
+	if got := labels.String(); got != want {
+		t.Fatalf("unexpected labels after shouldScrapeTarget; got %s; want %s", got, want)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/cprobe/cprobe/discovery/azure"
+	"github.com/cprobe/cprobe/discovery/consul"
 	"github.com/cprobe/cprobe/discovery/digitalocean"
 	"github.com/cprobe/cprobe/discovery/dns"
 	"github.com/cprobe/cprobe/discovery/docker"
@@ -13,7 +14,10 @@ import (
 	"github.com/cprobe/cprobe/discovery/eureka"
 	"github.com/cprobe/cprobe/discovery/gce"
 	"github.com/cprobe/cprobe/discovery/http"
+	"github.com/cprobe/cprobe/discovery/kubernetes"
+	"github.com/cprobe/cprobe/discovery/nacos"
 	"github.com/cprobe/cprobe/discovery/openstack"
+	"github.com/cprobe/cprobe/discovery/serverset"
 	"github.com/cprobe/cprobe/discovery/yandexcloud"
 	"github.com/cprobe/cprobe/lib/envtemplate"
 	"github.com/cprobe/cprobe/lib/promrelabel"
@@ -88,6 +92,7 @@ type ScrapeConfig struct {
 	// SampleLimit          int                         `yaml:"sample_limit,omitempty"`
 
 	AzureSDConfigs        []azure.SDConfig        `yaml:"azure_sd_configs,omitempty"`
+	ConsulSDConfigs       []consul.SDConfig       `yaml:"consul_sd_configs,omitempty"`
 	DigitaloceanSDConfigs []digitalocean.SDConfig `yaml:"digitalocean_sd_configs,omitempty"`
 	DNSSDConfigs          []dns.SDConfig          `yaml:"dns_sd_configs,omitempty"`
 	DockerSDConfigs       []docker.SDConfig       `yaml:"docker_sd_configs,omitempty"`
@@ -97,7 +102,10 @@ type ScrapeConfig struct {
 	FileSDConfigs         []FileSDConfig          `yaml:"file_sd_configs,omitempty"`
 	GCESDConfigs          []gce.SDConfig          `yaml:"gce_sd_configs,omitempty"`
 	HTTPSDConfigs         []http.SDConfig         `yaml:"http_sd_configs,omitempty"`
+	KubernetesSDConfigs   []kubernetes.SDConfig   `yaml:"kubernetes_sd_configs,omitempty"`
+	NacosSDConfigs        []nacos.SDConfig        `yaml:"nacos_sd_configs,omitempty"`
 	OpenStackSDConfigs    []openstack.SDConfig    `yaml:"openstack_sd_configs,omitempty"`
+	ServersetSDConfigs    []serverset.SDConfig    `yaml:"serverset_sd_configs,omitempty"`
 	StaticConfigs         []StaticConfig          `yaml:"static_configs,omitempty"`
 	YandexCloudSDConfigs  []yandexcloud.SDConfig  `yaml:"yandexcloud_sd_configs,omitempty"`
 
@@ -121,8 +129,8 @@ type ScrapeConfig struct {
 //
 // See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#file_sd_config
 type FileSDConfig struct {
-	Files []string `yaml:"files"`
-	// `refresh_interval` is ignored. See `-promscrape.fileSDCheckInterval`
+	Files           []string            `yaml:"files"`
+	RefreshInterval *promutils.Duration `yaml:"refresh_interval,omitempty"`
 }
 
 // StaticConfig represents essential parts for `static_config` section of Prometheus config.
@@ -131,7 +139,13 @@ type FileSDConfig struct {
 type StaticConfig struct {
 	Targets []string          `yaml:"targets"`
 	Labels  *promutils.Labels `yaml:"labels,omitempty"`
-	Paths   []string          `yaml:"paths,omitempty"` // TODO: 是否要通过这个字段和 CMDB 打通，纠结
+
+	// Paths 是 CMDB 导出的查找表（CSV/JSON/YAML），可以是本地文件也可以是 http(s) 地址。
+	// 查找表里和 target 的 PathKey 标签值匹配的那一行，其他列会作为标签合并到这个 job 的所有 target 上（包括服务发现的 target），
+	// 在 relabel 之前执行，target 上已有的标签不会被覆盖
+	Paths []string `yaml:"paths,omitempty"`
+	// PathKey 是查找表和 target 关联的标签名，默认是 __address__
+	PathKey string `yaml:"path_key,omitempty"`
 }
 
 func (cfg *Config) unmarshal(data []byte, isStrict bool) error {
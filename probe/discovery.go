@@ -0,0 +1,356 @@
+package probe
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/cprobe/cprobe/discovery/azure"
+	"github.com/cprobe/cprobe/discovery/consul"
+	"github.com/cprobe/cprobe/discovery/digitalocean"
+	"github.com/cprobe/cprobe/discovery/dns"
+	"github.com/cprobe/cprobe/discovery/docker"
+	"github.com/cprobe/cprobe/discovery/dockerswarm"
+	"github.com/cprobe/cprobe/discovery/ec2"
+	"github.com/cprobe/cprobe/discovery/eureka"
+	"github.com/cprobe/cprobe/discovery/gce"
+	"github.com/cprobe/cprobe/discovery/http"
+	"github.com/cprobe/cprobe/discovery/kubernetes"
+	"github.com/cprobe/cprobe/discovery/nacos"
+	"github.com/cprobe/cprobe/discovery/openstack"
+	"github.com/cprobe/cprobe/discovery/serverset"
+	"github.com/cprobe/cprobe/discovery/yandexcloud"
+	"github.com/cprobe/cprobe/lib/fs"
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+var (
+	fileSDCheckInterval = flag.Duration("scrape.fileSDCheckInterval", time.Minute, "Interval for checking for changes in 'file_sd_config'. "+
+		"It can be overridden by `refresh_interval` in file_sd_configs")
+	discoveryWaitTimeout = flag.Duration("scrape.discoveryWaitTimeout", 10*time.Second, "The maximum duration for waiting the first service discovery "+
+		"of a job to complete before scraping. The job is scraped with the targets discovered so far when the timeout is reached")
+)
+
+// discoveryConfig 各种 *_sd_configs 都实现了这两个方法
+type discoveryConfig interface {
+	GetLabels(baseDir string) ([]*promutils.Labels, error)
+	MustStop()
+}
+
+// discoveryEntry 对应 scrape config 中的一个服务发现配置，比如 http_sd_configs 的第 0 个
+type discoveryEntry struct {
+	typ      string
+	index    int
+	interval time.Duration
+	cfg      discoveryConfig
+}
+
+// discoveryEntries 列出 scrape config 中所有的服务发现配置，static_configs 不需要远程调用，不在这里处理
+// 新增服务发现类型的时候，只需要在这里加上即可
+func discoveryEntries(sc *ScrapeConfig) []discoveryEntry {
+	var entries []discoveryEntry
+	add := func(typ string, index int, cfg discoveryConfig, ri *promutils.Duration, defaultInterval time.Duration) {
+		interval := ri.Duration()
+		if interval <= 0 {
+			interval = defaultInterval
+		}
+		entries = append(entries, discoveryEntry{typ: typ, index: index, interval: interval, cfg: cfg})
+	}
+
+	for i := range sc.FileSDConfigs {
+		add("file_sd_configs", i, &sc.FileSDConfigs[i], sc.FileSDConfigs[i].RefreshInterval, *fileSDCheckInterval)
+	}
+	for i := range sc.HTTPSDConfigs {
+		add("http_sd_configs", i, &sc.HTTPSDConfigs[i], sc.HTTPSDConfigs[i].RefreshInterval, *http.SDCheckInterval)
+	}
+	for i := range sc.DNSSDConfigs {
+		add("dns_sd_configs", i, &sc.DNSSDConfigs[i], sc.DNSSDConfigs[i].RefreshInterval, *dns.SDCheckInterval)
+	}
+	for i := range sc.AzureSDConfigs {
+		add("azure_sd_configs", i, &sc.AzureSDConfigs[i], sc.AzureSDConfigs[i].RefreshInterval, *azure.SDCheckInterval)
+	}
+	for i := range sc.ConsulSDConfigs {
+		add("consul_sd_configs", i, &sc.ConsulSDConfigs[i], sc.ConsulSDConfigs[i].RefreshInterval, *consul.SDCheckInterval)
+	}
+	for i := range sc.DockerSDConfigs {
+		add("docker_sd_configs", i, &sc.DockerSDConfigs[i], sc.DockerSDConfigs[i].RefreshInterval, *docker.SDCheckInterval)
+	}
+	for i := range sc.DockerSwarmSDConfigs {
+		add("dockerswarm_sd_configs", i, &sc.DockerSwarmSDConfigs[i], sc.DockerSwarmSDConfigs[i].RefreshInterval, *dockerswarm.SDCheckInterval)
+	}
+	for i := range sc.EC2SDConfigs {
+		add("ec2_sd_configs", i, &sc.EC2SDConfigs[i], sc.EC2SDConfigs[i].RefreshInterval, *ec2.SDCheckInterval)
+	}
+	for i := range sc.EurekaSDConfigs {
+		add("eureka_sd_configs", i, &sc.EurekaSDConfigs[i], sc.EurekaSDConfigs[i].RefreshInterval, *eureka.SDCheckInterval)
+	}
+	for i := range sc.GCESDConfigs {
+		add("gce_sd_configs", i, &sc.GCESDConfigs[i], sc.GCESDConfigs[i].RefreshInterval, *gce.SDCheckInterval)
+	}
+	for i := range sc.DigitaloceanSDConfigs {
+		add("digitalocean_sd_configs", i, &sc.DigitaloceanSDConfigs[i], sc.DigitaloceanSDConfigs[i].RefreshInterval, *digitalocean.SDCheckInterval)
+	}
+	for i := range sc.KubernetesSDConfigs {
+		add("kubernetes_sd_configs", i, &sc.KubernetesSDConfigs[i], sc.KubernetesSDConfigs[i].RefreshInterval, *kubernetes.SDCheckInterval)
+	}
+	for i := range sc.OpenStackSDConfigs {
+		add("openstack_sd_configs", i, &sc.OpenStackSDConfigs[i], sc.OpenStackSDConfigs[i].RefreshInterval, *openstack.SDCheckInterval)
+	}
+	for i := range sc.NacosSDConfigs {
+		add("nacos_sd_configs", i, &sc.NacosSDConfigs[i], sc.NacosSDConfigs[i].RefreshInterval, *nacos.SDCheckInterval)
+	}
+	for i := range sc.ServersetSDConfigs {
+		add("serverset_sd_configs", i, &sc.ServersetSDConfigs[i], sc.ServersetSDConfigs[i].RefreshInterval, *serverset.SDCheckInterval)
+	}
+	for i := range sc.YandexCloudSDConfigs {
+		add("yandexcloud_sd_configs", i, &sc.YandexCloudSDConfigs[i], sc.YandexCloudSDConfigs[i].RefreshInterval, *yandexcloud.SDCheckInterval)
+	}
+
+	return entries
+}
+
+// sameDiscoveryEntries 判断两份服务发现配置是否一样，reload 的时候如果没变，就继续沿用之前的 discoverer，避免重新发现期间 target 为空
+func sameDiscoveryEntries(a, b []discoveryEntry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].typ != b[i].typ || a[i].interval != b[i].interval || !reflect.DeepEqual(a[i].cfg, b[i].cfg) {
+			return false
+		}
+	}
+	return true
+}
+
+// jobDiscovery 一个 job 的所有服务发现，每个服务发现配置一个 goroutine，在后台按照 refresh_interval 周期性刷新 targets
+type jobDiscovery struct {
+	entries     []discoveryEntry
+	discoverers []*discoverer
+}
+
+func newJobDiscovery(plugin string, jobID JobID, sc *ScrapeConfig) *jobDiscovery {
+	entries := discoveryEntries(sc)
+	jd := &jobDiscovery{
+		entries:     entries,
+		discoverers: make([]*discoverer, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		jd.discoverers = append(jd.discoverers, newDiscoverer(plugin, jobID, sc.ConfigRef.BaseDir, entry))
+	}
+
+	return jd
+}
+
+func (jd *jobDiscovery) start() {
+	for _, d := range jd.discoverers {
+		d.start()
+	}
+}
+
+func (jd *jobDiscovery) stop() {
+	for _, d := range jd.discoverers {
+		d.stop()
+	}
+}
+
+// getTargets 返回最近一次成功发现的 targets，如果某个服务发现还没有完成第一次刷新，最多等待 -scrape.discoveryWaitTimeout
+func (jd *jobDiscovery) getTargets() []*promutils.Labels {
+	timer := time.NewTimer(*discoveryWaitTimeout)
+	defer timer.Stop()
+
+	var targets []*promutils.Labels
+	timedOut := false
+	for _, d := range jd.discoverers {
+		if !timedOut {
+			select {
+			case <-d.ready:
+			case <-timer.C:
+				// 超时之后后面的 discoverer 都不再等待，直接用已经发现的 targets
+				timedOut = true
+			}
+		}
+		targets = append(targets, d.getTargets()...)
+	}
+
+	return targets
+}
+
+type discoverer struct {
+	plugin  string
+	jobID   JobID
+	baseDir string
+	entry   discoveryEntry
+
+	mu      sync.RWMutex
+	targets []*promutils.Labels
+
+	ready    chan struct{}
+	quitChan chan struct{}
+
+	metricsSet         *metrics.Set
+	refreshesTotal     *metrics.Counter
+	refreshErrorsTotal *metrics.Counter
+	refreshDuration    *metrics.FloatCounter
+	lastDuration       float64
+	lastSuccess        int64
+}
+
+func newDiscoverer(plugin string, jobID JobID, baseDir string, entry discoveryEntry) *discoverer {
+	d := &discoverer{
+		plugin:     plugin,
+		jobID:      jobID,
+		baseDir:    baseDir,
+		entry:      entry,
+		ready:      make(chan struct{}),
+		quitChan:   make(chan struct{}),
+		metricsSet: metrics.NewSet(),
+	}
+
+	// 不同入口文件中可以有同名的 job，用 file 标签区分，否则会导出重复的 series
+	labels := fmt.Sprintf(`{plugin=%q,file=%q,job=%q,type=%q,index="%d"}`, plugin, jobID.EntryFile(), jobID.JobName, entry.typ, entry.index)
+	d.refreshesTotal = d.metricsSet.NewCounter(`cprobe_sd_refreshes_total` + labels)
+	d.refreshErrorsTotal = d.metricsSet.NewCounter(`cprobe_sd_refresh_errors_total` + labels)
+	d.refreshDuration = d.metricsSet.NewFloatCounter(`cprobe_sd_refresh_duration_seconds_total` + labels)
+	d.metricsSet.NewGauge(`cprobe_sd_last_refresh_duration_seconds`+labels, func() float64 {
+		d.mu.RLock()
+		defer d.mu.RUnlock()
+		return d.lastDuration
+	})
+	d.metricsSet.NewGauge(`cprobe_sd_last_success_timestamp_seconds`+labels, func() float64 {
+		d.mu.RLock()
+		defer d.mu.RUnlock()
+		return float64(d.lastSuccess)
+	})
+	d.metricsSet.NewGauge(`cprobe_sd_targets`+labels, func() float64 {
+		d.mu.RLock()
+		defer d.mu.RUnlock()
+		return float64(len(d.targets))
+	})
+
+	return d
+}
+
+func (d *discoverer) start() {
+	metrics.RegisterSet(d.metricsSet)
+	go d.run()
+}
+
+// stop 不等待正在进行中的刷新，GetLabels 可能会比较慢，MustStop 在刷新 goroutine 退出的时候调用
+func (d *discoverer) stop() {
+	close(d.quitChan)
+	metrics.UnregisterSet(d.metricsSet)
+}
+
+func (d *discoverer) run() {
+	defer d.entry.cfg.MustStop()
+
+	d.refresh()
+	close(d.ready)
+
+	ticker := time.NewTicker(d.entry.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.refresh()
+		case <-d.quitChan:
+			return
+		}
+	}
+}
+
+// refresh 刷新失败的时候保留上一次成功发现的 targets，避免服务发现接口偶尔抖动导致 target 全部丢失
+func (d *discoverer) refresh() {
+	start := time.Now()
+	targets, err := d.entry.cfg.GetLabels(d.baseDir)
+	duration := time.Since(start).Seconds()
+
+	d.refreshesTotal.Inc()
+	d.refreshDuration.Add(duration)
+
+	select {
+	case <-d.quitChan:
+		// 已经被停止了，结果直接丢弃
+		return
+	default:
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastDuration = duration
+
+	if err != nil {
+		d.refreshErrorsTotal.Inc()
+		logger.Errorf("job(%s) in %s %s[%d] refresh targets error, keep using %d targets discovered last time: %s", d.jobID.JobName, d.jobID.EntryFile(), d.entry.typ, d.entry.index, len(d.targets), err)
+		return
+	}
+
+	d.targets = targets
+	d.lastSuccess = start.Unix()
+}
+
+func (d *discoverer) getTargets() []*promutils.Labels {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.targets
+}
+
+// GetLabels returns targets from files according to sdc.
+func (sdc *FileSDConfig) GetLabels(baseDir string) ([]*promutils.Labels, error) {
+	var targets []*promutils.Labels
+	for _, file := range sdc.Files {
+		pathPattern := fs.GetFilepath(baseDir, file)
+		paths := []string{pathPattern}
+		if strings.Contains(pathPattern, "*") {
+			var err error
+			paths, err = filepath.Glob(pathPattern)
+			if err != nil {
+				// Do not return this error, since other files may contain valid scrape configs.
+				logger.Errorf("skipping entry %q in `file_sd_config->files` because of error: %s", file, err)
+				continue
+			}
+		}
+		for _, path := range paths {
+			stcs, err := loadStaticConfigs(path)
+			if err != nil {
+				// Do not return this error, since other paths may contain valid scrape configs.
+				logger.Errorf("skipping file %s at `file_sd_configs` because of error: %s", path, err)
+				continue
+			}
+
+			pathShort := path
+			if strings.HasPrefix(pathShort, baseDir) {
+				pathShort = path[len(baseDir):]
+				if len(pathShort) > 0 && pathShort[0] == filepath.Separator {
+					pathShort = pathShort[1:]
+				}
+			}
+
+			for _, stc := range stcs {
+				for _, t := range stc.Targets {
+					m := promutils.NewLabels(2 + stc.Labels.Len())
+					m.AddFrom(stc.Labels)
+					m.Add("__address__", t)
+					m.Add("__meta_filepath", pathShort)
+					m.RemoveDuplicates()
+					targets = append(targets, m)
+				}
+			}
+		}
+	}
+	return targets, nil
+}
+
+// MustStop stops further usage for sdc.
+func (sdc *FileSDConfig) MustStop() {
+	// nothing to do
+}
@@ -0,0 +1,164 @@
+package probe
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cprobe/cprobe/discovery/dns"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+type fakeDiscoveryConfig struct {
+	mu      sync.Mutex
+	calls   int
+	fail    bool
+	stopped bool
+}
+
+func (f *fakeDiscoveryConfig) GetLabels(_ string) ([]*promutils.Labels, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if f.fail {
+		return nil, fmt.Errorf("api unavailable")
+	}
+
+	m := promutils.NewLabels(1)
+	m.Add("__address__", fmt.Sprintf("127.0.0.1:%d", 9000+f.calls))
+	return []*promutils.Labels{m}, nil
+}
+
+func (f *fakeDiscoveryConfig) MustStop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stopped = true
+}
+
+func TestDiscovererKeepsLastKnownGood(t *testing.T) {
+	cfg := &fakeDiscoveryConfig{}
+	d := newDiscoverer("test", JobID{YamlFile: "main.yaml", JobName: "job"}, "", discoveryEntry{typ: "fake_sd_configs", interval: time.Hour, cfg: cfg})
+
+	d.refresh()
+	targets := d.getTargets()
+	if len(targets) != 1 || targets[0].Get("__address__") != "127.0.0.1:9001" {
+		t.Fatalf("unexpected targets after first refresh: %v", targets)
+	}
+
+	cfg.fail = true
+	d.refresh()
+	targets = d.getTargets()
+	if len(targets) != 1 || targets[0].Get("__address__") != "127.0.0.1:9001" {
+		t.Fatalf("targets should be kept after failed refresh, got %v", targets)
+	}
+
+	if n := d.refreshErrorsTotal.Get(); n != 1 {
+		t.Fatalf("expected 1 refresh error, got %d", n)
+	}
+
+	if n := d.refreshesTotal.Get(); n != 2 {
+		t.Fatalf("expected 2 refreshes, got %d", n)
+	}
+
+	cfg.fail = false
+	d.refresh()
+	targets = d.getTargets()
+	if len(targets) != 1 || targets[0].Get("__address__") != "127.0.0.1:9003" {
+		t.Fatalf("unexpected targets after recovery: %v", targets)
+	}
+}
+
+func TestDiscovererMetricsSameJobNameInDifferentFiles(t *testing.T) {
+	entry := discoveryEntry{typ: "fake_sd_configs", interval: time.Hour, cfg: &fakeDiscoveryConfig{}}
+	d1 := newDiscoverer("test", JobID{YamlFile: "conf.d/test/main_a.yaml", JobName: "job"}, "", entry)
+	d2 := newDiscoverer("test", JobID{YamlFile: "conf.d/test/main_b.yaml", JobName: "job"}, "", entry)
+
+	var b1, b2 bytes.Buffer
+	d1.metricsSet.WritePrometheus(&b1)
+	d2.metricsSet.WritePrometheus(&b2)
+	if !bytes.Contains(b1.Bytes(), []byte(`file="main_a.yaml"`)) {
+		t.Fatalf("missing file label in metrics:\n%s", b1.String())
+	}
+	seen := make(map[string]bool)
+	for _, line := range bytes.Split(bytes.TrimSpace(b1.Bytes()), []byte("\n")) {
+		seen[string(bytes.Fields(line)[0])] = true
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(b2.Bytes()), []byte("\n")) {
+		if name := string(bytes.Fields(line)[0]); seen[name] {
+			t.Fatalf("duplicate series %s for jobs with the same name in different files", name)
+		}
+	}
+}
+
+func TestJobDiscoveryBackground(t *testing.T) {
+	cfg := &fakeDiscoveryConfig{}
+	jd := &jobDiscovery{}
+	jd.discoverers = append(jd.discoverers, newDiscoverer("test", JobID{YamlFile: "main.yaml", JobName: "job"}, "", discoveryEntry{typ: "fake_sd_configs", interval: 10 * time.Millisecond, cfg: cfg}))
+	jd.start()
+
+	if targets := jd.getTargets(); len(targets) != 1 {
+		t.Fatalf("expected targets after first discovery, got %v", targets)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cfg.mu.Lock()
+		calls := cfg.calls
+		cfg.mu.Unlock()
+		if calls >= 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("discovery should be refreshed periodically, calls: %d", calls)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	jd.stop()
+
+	for {
+		cfg.mu.Lock()
+		stopped := cfg.stopped
+		cfg.mu.Unlock()
+		if stopped {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("MustStop should be called after stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSameDiscoveryEntries(t *testing.T) {
+	newScrapeConfig := func(names ...string) *ScrapeConfig {
+		return &ScrapeConfig{
+			DNSSDConfigs: []dns.SDConfig{{Names: names}},
+		}
+	}
+
+	a := discoveryEntries(newScrapeConfig("a.example.com"))
+	b := discoveryEntries(newScrapeConfig("a.example.com"))
+	c := discoveryEntries(newScrapeConfig("b.example.com"))
+
+	if !sameDiscoveryEntries(a, b) {
+		t.Fatalf("entries with the same config should be equal")
+	}
+
+	if sameDiscoveryEntries(a, c) {
+		t.Fatalf("entries with different config should not be equal")
+	}
+
+	if len(a) != 1 || a[0].interval != *dns.SDCheckInterval {
+		t.Fatalf("expected default dns refresh interval, got %v", a)
+	}
+
+	sc := newScrapeConfig("a.example.com")
+	sc.DNSSDConfigs[0].RefreshInterval = promutils.NewDuration(time.Minute)
+	if e := discoveryEntries(sc); e[0].interval != time.Minute {
+		t.Fatalf("expected refresh_interval to override the default, got %s", e[0].interval)
+	}
+}
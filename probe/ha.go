@@ -0,0 +1,149 @@
+package probe
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/lease"
+	"github.com/cprobe/cprobe/lib/logger"
+)
+
+var (
+	haBackend = flag.String("ha.backend", "", "The lease backend for electing the active replica of every job among cprobe HA replicas. "+
+		"Supported values: file, http, consul, etcd. Leader election is disabled if empty. See also -ha.endpoint and -ha.replica")
+	haEndpoint = flag.String("ha.endpoint", "", "The directory for lease files if -ha.backend=file, "+
+		"or the url of the lease service, e.g. http://127.0.0.1:8500 for consul and http://127.0.0.1:2379 for etcd")
+	haReplica      = flag.String("ha.replica", "", "The unique name of this cprobe replica. The hostname is used if empty")
+	haReplicaLabel = flag.String("ha.replicaLabel", "", "Optional label name for storing -ha.replica in every scraped sample. "+
+		"Use it instead of -ha.backend if the storage deduplicates samples from HA replicas by this label")
+	haKeyPrefix = flag.String("ha.keyPrefix", "cprobe/leader", "The prefix for lease keys. Replicas of the same HA group must use the same prefix")
+)
+
+// minLeaseTTL 防止 scrape_interval 很小的时候频繁续约
+const minLeaseTTL = 5 * time.Second
+
+var (
+	haLeaseBackend lease.Backend
+	haReplicaName  string
+)
+
+// initHA 初始化高可用相关的配置，开启 -ha.backend 之后每个 job 只有拿到租约的副本才会抓取
+func initHA() error {
+	haReplicaName = *haReplica
+	if haReplicaName == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("cannot obtain hostname for -ha.replica: %w", err)
+		}
+		haReplicaName = hostname
+	}
+	if *haBackend == "" {
+		return nil
+	}
+	b, err := lease.NewBackend(*haBackend, *haEndpoint)
+	if err != nil {
+		return fmt.Errorf("cannot initialize -ha.backend: %w", err)
+	}
+	haLeaseBackend = b
+	logger.Infof("HA mode: replica %q elects the active scraper of every job via %s lease backend", haReplicaName, *haBackend)
+	return nil
+}
+
+// leaderElector 负责某个 job 的选主，租约时长是 job 的 scrape_interval，每 1/3 个周期续约一次，
+// 这样 leader 挂掉之后，standby 最多一个周期就能接管
+type leaderElector struct {
+	backend lease.Backend
+	key     string
+	holder  string
+
+	mu        sync.Mutex
+	leader    bool
+	renewedAt time.Time
+}
+
+// newLeaderElector 的租约 key 是 prefix/plugin/入口文件/job，不同入口文件中的同名 job 各自选主
+func newLeaderElector(backend lease.Backend, plugin string, jobID JobID, holder string) *leaderElector {
+	return &leaderElector{
+		backend: backend,
+		key:     *haKeyPrefix + "/" + plugin + "/" + jobID.EntryFile() + "/" + jobID.JobName,
+		holder:  holder,
+	}
+}
+
+func (e *leaderElector) isLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// run 定期抢占或续约租约，直到 quitCh 关闭或者 ctx 结束，退出时主动释放租约，方便 standby 立即接管
+func (e *leaderElector) run(ctx context.Context, quitCh <-chan struct{}, getInterval func() time.Duration) {
+	defer e.release()
+	for {
+		ttl := getLeaseTTL(getInterval())
+		t := time.NewTimer(ttl / 3)
+		select {
+		case <-t.C:
+			e.tryAcquire(ctx, ttl)
+		case <-quitCh:
+			t.Stop()
+			return
+		case <-ctx.Done():
+			t.Stop()
+			return
+		}
+	}
+}
+
+func (e *leaderElector) tryAcquire(ctx context.Context, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, ttl/3)
+	defer cancel()
+	ok, err := e.backend.Acquire(ctx, e.key, e.holder, ttl)
+	now := time.Now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		// 后端暂时不可用的时候，在租约到期之前继续当 leader，避免两边都不抓或者都在抓
+		logger.Errorf("cannot acquire lease %q: %s", e.key, err)
+		if e.leader && now.Sub(e.renewedAt) >= ttl {
+			e.leader = false
+			logger.Warnf("lost lease %q: it wasn't renewed in %s", e.key, ttl)
+		}
+		return
+	}
+	if ok != e.leader {
+		if ok {
+			logger.Infof("acquired lease %q; start scraping as the active replica %q", e.key, e.holder)
+		} else {
+			logger.Infof("lease %q is owned by another replica; %q stays standby", e.key, e.holder)
+		}
+	}
+	e.leader = ok
+	if ok {
+		e.renewedAt = now
+	}
+}
+
+func (e *leaderElector) release() {
+	e.mu.Lock()
+	e.leader = false
+	e.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.backend.Release(ctx, e.key, e.holder); err != nil {
+		logger.Errorf("cannot release lease %q: %s", e.key, err)
+	}
+}
+
+func getLeaseTTL(interval time.Duration) time.Duration {
+	if interval < minLeaseTTL {
+		return minLeaseTTL
+	}
+	return interval
+}
@@ -0,0 +1,108 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeLeaseBackend keeps the holder of every key
+type fakeLeaseBackend struct {
+	holders map[string]string
+	err     error
+}
+
+func newFakeLeaseBackend() *fakeLeaseBackend {
+	return &fakeLeaseBackend{holders: make(map[string]string)}
+}
+
+func (b *fakeLeaseBackend) Acquire(_ context.Context, key, holder string, _ time.Duration) (bool, error) {
+	if b.err != nil {
+		return false, b.err
+	}
+	if b.holders[key] == "" {
+		b.holders[key] = holder
+	}
+	return b.holders[key] == holder, nil
+}
+
+func (b *fakeLeaseBackend) Release(_ context.Context, key, holder string) error {
+	if b.holders[key] == holder {
+		delete(b.holders, key)
+	}
+	return nil
+}
+
+func TestLeaderElector(t *testing.T) {
+	b := newFakeLeaseBackend()
+	jobID := JobID{YamlFile: "conf.d/mysql/main.yaml", JobName: "job1"}
+	e1 := newLeaderElector(b, "mysql", jobID, "replica-1")
+	e2 := newLeaderElector(b, "mysql", jobID, "replica-2")
+	if e1.key != "cprobe/leader/mysql/main.yaml/job1" {
+		t.Fatalf("unexpected lease key: %q", e1.key)
+	}
+
+	ctx := context.Background()
+	e1.tryAcquire(ctx, time.Minute)
+	e2.tryAcquire(ctx, time.Minute)
+	if !e1.isLeader() || e2.isLeader() {
+		t.Fatalf("replica-1 must be the leader; got replica-1=%v, replica-2=%v", e1.isLeader(), e2.isLeader())
+	}
+
+	// The leader stays the leader until the lease expires if the backend is unavailable
+	b.err = fmt.Errorf("backend is unavailable")
+	e1.tryAcquire(ctx, time.Minute)
+	if !e1.isLeader() {
+		t.Fatalf("replica-1 must stay the leader until the lease expires")
+	}
+	e1.renewedAt = time.Now().Add(-time.Minute)
+	e1.tryAcquire(ctx, time.Minute)
+	if e1.isLeader() {
+		t.Fatalf("replica-1 must lose the leadership after the lease expires")
+	}
+
+	// The standby takes over after the leader releases the lease
+	b.err = nil
+	e1.release()
+	e2.tryAcquire(ctx, time.Minute)
+	e1.tryAcquire(ctx, time.Minute)
+	if e1.isLeader() || !e2.isLeader() {
+		t.Fatalf("replica-2 must be the leader; got replica-1=%v, replica-2=%v", e1.isLeader(), e2.isLeader())
+	}
+}
+
+func TestLeaderElectorSameJobNameInDifferentFiles(t *testing.T) {
+	b := newFakeLeaseBackend()
+	jobA := JobID{YamlFile: "conf.d/mysql/main_a.yaml", JobName: "default"}
+	jobB := JobID{YamlFile: "conf.d/mysql/main_b.yaml", JobName: "default"}
+
+	// replica-2 can still own default in main_b.yaml after replica-1 owns default in main_a.yaml
+	a1 := newLeaderElector(b, "mysql", jobA, "replica-1")
+	a2 := newLeaderElector(b, "mysql", jobA, "replica-2")
+	b2 := newLeaderElector(b, "mysql", jobB, "replica-2")
+	b1 := newLeaderElector(b, "mysql", jobB, "replica-1")
+	if a1.key == b1.key {
+		t.Fatalf("jobs with the same name in different files must use different lease keys; got %q", a1.key)
+	}
+
+	ctx := context.Background()
+	for _, e := range []*leaderElector{a1, b2, a2, b1} {
+		e.tryAcquire(ctx, time.Minute)
+	}
+	if !a1.isLeader() || a2.isLeader() {
+		t.Fatalf("replica-1 must be the leader of %v; got replica-1=%v, replica-2=%v", jobA, a1.isLeader(), a2.isLeader())
+	}
+	if !b2.isLeader() || b1.isLeader() {
+		t.Fatalf("replica-2 must be the leader of %v; got replica-1=%v, replica-2=%v", jobB, b1.isLeader(), b2.isLeader())
+	}
+}
+
+func TestGetLeaseTTL(t *testing.T) {
+	if ttl := getLeaseTTL(time.Second); ttl != minLeaseTTL {
+		t.Fatalf("unexpected ttl; got %s; want %s", ttl, minLeaseTTL)
+	}
+	if ttl := getLeaseTTL(time.Minute); ttl != time.Minute {
+		t.Fatalf("unexpected ttl; got %s; want %s", ttl, time.Minute)
+	}
+}
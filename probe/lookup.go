@@ -0,0 +1,179 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/fs"
+	"github.com/cprobe/cprobe/lib/logger"
+	"github.com/cprobe/cprobe/lib/promutils"
+	"gopkg.in/yaml.v2"
+)
+
+const defaultLookupKey = "__address__"
+
+// lookupTableCacheDuration 查找表通常是 CMDB 定期导出的，没必要每个周期都重新读取和解析
+const lookupTableCacheDuration = 30 * time.Second
+
+// lookupTable 是从 CMDB 导出文件里解析出来的查找表，key 是关联字段的值，value 是要合并到 target 上的标签
+type lookupTable struct {
+	key  string
+	rows map[string]*promutils.Labels
+}
+
+// enrichTargets 用 static_configs 里 paths 指定的查找表给 targets 补充标签，在 relabel 之前执行
+//
+// target 上已经有的标签不会被覆盖
+func enrichTargets(baseDir string, staticConfigs []StaticConfig, targets []*promutils.Labels) {
+	for _, c := range staticConfigs {
+		for _, path := range c.Paths {
+			lt, err := getLookupTable(fs.GetFilepath(baseDir, path), c.PathKey)
+			if err != nil {
+				logger.Errorf("cannot load lookup table %q: %s", path, err)
+				continue
+			}
+			for i := range targets {
+				targets[i] = lt.enrich(targets[i])
+			}
+		}
+	}
+}
+
+// enrich 返回补充了标签的 target，服务发现的 target 是多个周期共享的，所以这里不能原地修改，要复制一份
+func (lt *lookupTable) enrich(t *promutils.Labels) *promutils.Labels {
+	v := t.Get(lt.key)
+	if v == "" {
+		return t
+	}
+	row, ok := lt.rows[v]
+	if !ok {
+		return t
+	}
+	m := t.Clone()
+	for _, lb := range row.GetLabels() {
+		if m.Get(lb.Name) == "" {
+			m.Add(lb.Name, lb.Value)
+		}
+	}
+	return m
+}
+
+func getLookupTable(path, key string) (*lookupTable, error) {
+	if key == "" {
+		key = defaultLookupKey
+	}
+	cacheKey := "lookup:" + key + ":" + path
+	if x, found := c.Get(cacheKey); found {
+		return x.(*lookupTable), nil
+	}
+	data, err := fs.ReadFileOrHTTP(path)
+	if err != nil {
+		return nil, err
+	}
+	lt, err := parseLookupTable(path, key, data)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(cacheKey, lt, lookupTableCacheDuration)
+	return lt, nil
+}
+
+// parseLookupTable 解析 CSV/JSON/YAML 格式的查找表，格式根据文件扩展名判断
+//
+// CSV 第一行是表头，列名就是标签名；JSON 和 YAML 是对象数组，对象的 key 就是标签名。
+// 每一行都必须有 key 对应的列，否则报错
+func parseLookupTable(path, key string, data []byte) (*lookupTable, error) {
+	var records []map[string]string
+	var err error
+
+	ext := strings.ToLower(filepath.Ext(strings.SplitN(path, "?", 2)[0]))
+	switch ext {
+	case ".csv":
+		records, err = parseLookupCSV(data)
+	case ".json", ".yaml", ".yml":
+		// JSON 是 YAML 的子集，直接用 YAML 解析
+		records, err = parseLookupYAML(data)
+	default:
+		return nil, fmt.Errorf("unsupported lookup table format %q; supported formats: .csv, .json, .yaml, .yml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", path, err)
+	}
+
+	lt := &lookupTable{
+		key:  key,
+		rows: make(map[string]*promutils.Labels, len(records)),
+	}
+	for i, record := range records {
+		v := record[key]
+		if v == "" {
+			return nil, fmt.Errorf("missing %q in row #%d of %q", key, i+1, path)
+		}
+		m := promutils.NewLabels(len(record))
+		for name, value := range record {
+			if name == key {
+				continue
+			}
+			m.Add(discoveryutils.SanitizeLabelName(name), value)
+		}
+		m.Sort()
+		lt.rows[v] = m
+	}
+	return lt, nil
+}
+
+func parseLookupCSV(data []byte) ([]map[string]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	header := rows[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	records := make([]map[string]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		record := make(map[string]string, len(header))
+		for i, name := range header {
+			if name == "" || row[i] == "" {
+				continue
+			}
+			record[name] = row[i]
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func parseLookupYAML(data []byte) ([]map[string]string, error) {
+	var items []map[string]interface{}
+	if err := yaml.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	records := make([]map[string]string, 0, len(items))
+	for _, item := range items {
+		record := make(map[string]string, len(item))
+		for name, value := range item {
+			switch v := value.(type) {
+			case nil:
+				continue
+			case map[interface{}]interface{}, []interface{}:
+				return nil, fmt.Errorf("unsupported nested value for %q; only scalar values are allowed", name)
+			default:
+				record[name] = fmt.Sprint(v)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
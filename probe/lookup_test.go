@@ -0,0 +1,104 @@
+package probe
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+func TestParseLookupTable(t *testing.T) {
+	f := func(path, key, data string, want map[string]map[string]string) {
+		t.Helper()
+		lt, err := parseLookupTable(path, key, []byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(lt.rows) != len(want) {
+			t.Fatalf("unexpected number of rows; got %d; want %d", len(lt.rows), len(want))
+		}
+		for k, labels := range want {
+			row, ok := lt.rows[k]
+			if !ok {
+				t.Fatalf("missing row %q", k)
+			}
+			got := row.ToMap()
+			if len(got) != len(labels) {
+				t.Fatalf("unexpected labels for row %q; got %v; want %v", k, got, labels)
+			}
+			for name, value := range labels {
+				if got[name] != value {
+					t.Fatalf("unexpected %q for row %q; got %q; want %q", name, k, got[name], value)
+				}
+			}
+		}
+	}
+
+	f("cmdb.csv", "__address__", "__address__, owner, env, business unit\n10.0.0.1:3306,dba,prod,pay\n10.0.0.2:3306,dba,,\n",
+		map[string]map[string]string{
+			"10.0.0.1:3306": {"owner": "dba", "env": "prod", "business_unit": "pay"},
+			"10.0.0.2:3306": {"owner": "dba"},
+		})
+	f("http://cmdb/export.json?format=json", "host", `[{"host": "db-1", "owner": "dba", "port": 3306}, {"host": "db-2", "owner": null}]`,
+		map[string]map[string]string{
+			"db-1": {"owner": "dba", "port": "3306"},
+			"db-2": {},
+		})
+	f("cmdb.yml", "__address__", "- __address__: 10.0.0.1:6379\n  env: test\n",
+		map[string]map[string]string{
+			"10.0.0.1:6379": {"env": "test"},
+		})
+}
+
+func TestParseLookupTableFailure(t *testing.T) {
+	f := func(path, data string) {
+		t.Helper()
+		if _, err := parseLookupTable(path, "__address__", []byte(data)); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// unsupported format
+	f("cmdb.txt", "__address__\n10.0.0.1\n")
+	// missing key column
+	f("cmdb.csv", "host,owner\ndb-1,dba\n")
+	// nested values
+	f("cmdb.json", `[{"__address__": "10.0.0.1", "owner": {"name": "dba"}}]`)
+	// rows with different number of columns
+	f("cmdb.csv", "__address__,owner\n10.0.0.1,dba,extra\n")
+}
+
+func TestGetTargetsWithLookupTables(t *testing.T) {
+	dir := t.TempDir()
+	data := "__address__,owner,env\n10.0.0.1:3306,dba,prod\n10.0.0.2:3306,dev,test\n"
+	if err := os.WriteFile(filepath.Join(dir, "cmdb.csv"), []byte(data), 0o644); err != nil {
+		t.Fatalf("cannot write lookup table: %s", err)
+	}
+
+	sc := &ScrapeConfig{
+		ConfigRef: &Config{BaseDir: dir},
+		StaticConfigs: []StaticConfig{
+			{
+				Targets: []string{"10.0.0.1:3306", "10.0.0.3:3306"},
+				Labels:  promutils.NewLabelsFromMap(map[string]string{"env": "staging"}),
+			},
+			{
+				Paths: []string{"cmdb.csv"},
+			},
+		},
+	}
+	j := NewJobGoroutine(JobID{JobName: "mysql"}, "mysql", sc)
+	targets := j.getTargets()
+	if len(targets) != 2 {
+		t.Fatalf("unexpected number of targets; got %d; want 2", len(targets))
+	}
+
+	// existing labels must not be overridden by the lookup table
+	if got := targets[0].ToMap(); got["owner"] != "dba" || got["env"] != "staging" {
+		t.Fatalf("unexpected labels for the first target: %v", got)
+	}
+	if got := targets[1].ToMap(); got["owner"] != "" || got["env"] != "staging" {
+		t.Fatalf("unexpected labels for the target missing in the lookup table: %v", got)
+	}
+}
@@ -43,6 +43,16 @@ func listPlugins(configDirectory string) ([]string, error) {
 
 // Start starts the probe goroutines.
 func Start(ctx context.Context, configDirectory string) error {
+	if err := initCluster(); err != nil {
+		return err
+	}
+	if err := initHA(); err != nil {
+		return err
+	}
+	if err := checkDuplicateTargetsPolicy(); err != nil {
+		return err
+	}
+
 	pluginDirs, err := listPlugins(configDirectory)
 	if err != nil {
 		return err
@@ -90,7 +100,9 @@ func startEntry(ctx context.Context, pluginName, entryYamlFilePath string) error
 	}
 	PluginCfgs[pluginName] = append(PluginCfgs[pluginName], cfg)
 
+	JobsLock.RLock()
 	pluginJobs, has := Jobs[pluginName]
+	JobsLock.RUnlock()
 	if !has {
 		return fmt.Errorf("unsupported plugin %s", pluginName)
 	}
@@ -101,8 +113,10 @@ func startEntry(ctx context.Context, pluginName, entryYamlFilePath string) error
 		}
 
 		jobID := JobID{YamlFile: entryYamlFilePath, JobName: cfg.ScrapeConfigs[i].JobName}
-		jobGoroutine := NewJobGoroutine(pluginName, cfg.ScrapeConfigs[i])
+		jobGoroutine := NewJobGoroutine(jobID, pluginName, cfg.ScrapeConfigs[i])
+		JobsLock.Lock()
 		pluginJobs[jobID] = jobGoroutine
+		JobsLock.Unlock()
 
 		// 启动 goroutine，稍微 sleep 一下，避免所有 goroutine 同时启动
 		time.Sleep(time.Millisecond * 10)
@@ -120,6 +134,9 @@ func Reload(ctx context.Context, configDirectory string) {
 		return
 	}
 
+	JobsLock.Lock()
+	defer JobsLock.Unlock()
+
 	// 遍历内存中的老 Jobs，如果磁盘上的新 Jobs 中没有，就删除
 	for pluginName, jobs := range Jobs {
 		newPluginJobs := newJobs[pluginName]
@@ -189,7 +206,7 @@ func readFiles(configDirectory string) (map[string]map[JobID]*JobGoroutine, erro
 				}
 
 				jobID := JobID{YamlFile: entryYamlFilePath, JobName: cfg.ScrapeConfigs[i].JobName}
-				jobGoroutine := NewJobGoroutine(pluginDir, cfg.ScrapeConfigs[i])
+				jobGoroutine := NewJobGoroutine(jobID, pluginDir, cfg.ScrapeConfigs[i])
 				pluginJobs[jobID] = jobGoroutine
 			}
 		}
@@ -3,11 +3,15 @@ package probe
 import (
 	"github.com/cprobe/cprobe/types"
 
+	_ "github.com/cprobe/cprobe/plugins/apache"
 	_ "github.com/cprobe/cprobe/plugins/blackbox"
+	_ "github.com/cprobe/cprobe/plugins/clickhouse"
 	_ "github.com/cprobe/cprobe/plugins/consul"
 	_ "github.com/cprobe/cprobe/plugins/dm8"
 	_ "github.com/cprobe/cprobe/plugins/elasticsearch"
+	_ "github.com/cprobe/cprobe/plugins/etcd"
 	_ "github.com/cprobe/cprobe/plugins/filebeat"
+	_ "github.com/cprobe/cprobe/plugins/haproxy"
 	_ "github.com/cprobe/cprobe/plugins/json"
 	_ "github.com/cprobe/cprobe/plugins/kafka"
 	_ "github.com/cprobe/cprobe/plugins/memcached"
@@ -15,8 +19,10 @@ import (
 	_ "github.com/cprobe/cprobe/plugins/mysql"
 	_ "github.com/cprobe/cprobe/plugins/nginx"
 	_ "github.com/cprobe/cprobe/plugins/oracledb"
+	_ "github.com/cprobe/cprobe/plugins/phpfpm"
 	_ "github.com/cprobe/cprobe/plugins/postgres"
 	_ "github.com/cprobe/cprobe/plugins/prometheus"
+	_ "github.com/cprobe/cprobe/plugins/rabbitmq"
 	_ "github.com/cprobe/cprobe/plugins/redis"
 	_ "github.com/cprobe/cprobe/plugins/tomcat"
 	_ "github.com/cprobe/cprobe/plugins/whois"
@@ -43,5 +49,11 @@ func makeJobs() map[string]map[JobID]*JobGoroutine {
 		types.PluginZookeeper:     make(map[JobID]*JobGoroutine),
 		types.PluginNginx:         make(map[JobID]*JobGoroutine),
 		types.PluginDm:            make(map[JobID]*JobGoroutine),
+		types.PluginClickHouse:    make(map[JobID]*JobGoroutine),
+		types.PluginRabbitMQ:      make(map[JobID]*JobGoroutine),
+		types.PluginEtcd:          make(map[JobID]*JobGoroutine),
+		types.PluginHAProxy:       make(map[JobID]*JobGoroutine),
+		types.PluginApache:        make(map[JobID]*JobGoroutine),
+		types.PluginPhpFpm:        make(map[JobID]*JobGoroutine),
 	}
 }
@@ -0,0 +1,114 @@
+package probe
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cprobe/cprobe/lib/promrelabel"
+	"github.com/cprobe/cprobe/lib/promutils"
+	"gopkg.in/yaml.v2"
+)
+
+// GetRelabelDebugInput 返回 relabel 调试页面上选中的 job 和 target 对应的标签以及 relabel 配置，用来预填调试表单
+//
+// target 是 __address__ 的值，为空表示取这个 job 的第一个 target。
+// isTargetRelabel 为 true 时返回服务发现拿到的原始标签和 relabel_configs，
+// 否则返回 relabel 之后附加到每条监控数据上的标签和 metric_relabel_configs
+func GetRelabelDebugInput(jobName, target string, isTargetRelabel bool) (metric, relabelConfigs string, err error) {
+	j, err := findJobGoroutine(jobName)
+	if err != nil {
+		return "", "", err
+	}
+
+	j.RLock()
+	sc := j.scrapeConfig
+	j.RUnlock()
+
+	rcs := sc.MetricRelabelConfigs
+	if isTargetRelabel {
+		rcs = sc.RelabelConfigs
+	}
+	if len(rcs) > 0 {
+		data, err := yaml.Marshal(rcs)
+		if err != nil {
+			return "", "", fmt.Errorf("cannot marshal relabel configs of job %q: %w", jobName, err)
+		}
+		relabelConfigs = string(data)
+	}
+
+	var labels *promutils.Labels
+	for _, t := range j.getTargets() {
+		if target == "" || t.Get("__address__") == target {
+			labels = t
+			break
+		}
+	}
+	if labels == nil {
+		if target != "" {
+			return "", relabelConfigs, fmt.Errorf("cannot find target %q in job %q", target, jobName)
+		}
+		return "{}", relabelConfigs, nil
+	}
+
+	if !isTargetRelabel {
+		// 跟 run() 里的逻辑保持一致：parseTarget 之后去掉 __address__ 和 target 参数，加上 job 的 external_labels
+		labels = j.parseTarget(jobName, labels)
+		if labels == nil {
+			return "", relabelConfigs, fmt.Errorf("target %q is dropped by relabel_configs of job %q", target, jobName)
+		}
+		if _, err := extractTargetParams(sc.ConfigRef.BaseDir, labels); err != nil {
+			return "", relabelConfigs, err
+		}
+		if sc.ExternalLabels != nil {
+			labels.AddFrom(sc.ExternalLabels)
+		}
+		dst := labels.Labels[:0]
+		for _, label := range labels.Labels {
+			if label.Name != "__address__" {
+				dst = append(dst, label)
+			}
+		}
+		labels.Labels = dst
+	}
+
+	return promrelabel.LabelsToString(redactAuthPassword(labels).GetLabels()), relabelConfigs, nil
+}
+
+// redactAuthPassword 隐藏 __auth_password__ 的值，httpd 的 basic auth 是可选的，调试页面不能把密码暴露出去
+func redactAuthPassword(labels *promutils.Labels) *promutils.Labels {
+	if labels.Get(labelAuthPassword) == "" {
+		return labels
+	}
+	// getTargets 返回的标签可能是服务发现缓存的，不能直接修改
+	labels = labels.Clone()
+	labels.Set(labelAuthPassword, "<secret>")
+	return labels
+}
+
+func findJobGoroutine(jobName string) (*JobGoroutine, error) {
+	var ids []JobID
+	var found []*JobGoroutine
+	JobsLock.RLock()
+	for _, jobs := range Jobs {
+		for id, j := range jobs {
+			if id.JobName == jobName {
+				ids = append(ids, id)
+				found = append(found, j)
+			}
+		}
+	}
+	JobsLock.RUnlock()
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("cannot find job %q", jobName)
+	case 1:
+		return found[0], nil
+	default:
+		files := make([]string, 0, len(ids))
+		for _, id := range ids {
+			files = append(files, id.YamlFile)
+		}
+		sort.Strings(files)
+		return nil, fmt.Errorf("job %q is defined in multiple files: %q", jobName, files)
+	}
+}
@@ -0,0 +1,48 @@
+package probe
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cprobe/cprobe/lib/promutils"
+	"github.com/cprobe/cprobe/types"
+)
+
+func TestGetRelabelDebugInputRedactsPassword(t *testing.T) {
+	sc := &ScrapeConfig{
+		ConfigRef: &Config{BaseDir: t.TempDir()},
+		StaticConfigs: []StaticConfig{{
+			Targets: []string{"10.0.0.1:3306"},
+			Labels: promutils.NewLabelsFromMap(map[string]string{
+				"__auth_username__": "monitor",
+				"__auth_password__": "s3cret",
+			}),
+		}},
+	}
+	id := JobID{YamlFile: "main.yaml", JobName: "redact-test"}
+	j := NewJobGoroutine(id, types.PluginMySQL, sc)
+	JobsLock.Lock()
+	Jobs[types.PluginMySQL][id] = j
+	JobsLock.Unlock()
+	defer func() {
+		JobsLock.Lock()
+		delete(Jobs[types.PluginMySQL], id)
+		JobsLock.Unlock()
+	}()
+
+	metric, _, err := GetRelabelDebugInput("redact-test", "", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Contains(metric, "s3cret") || !strings.Contains(metric, `__auth_password__="<secret>"`) {
+		t.Fatalf("password must be redacted; got %s", metric)
+	}
+	if !strings.Contains(metric, `__auth_username__="monitor"`) {
+		t.Fatalf("missing username in %s", metric)
+	}
+
+	// the discovered labels used for scraping keep the password
+	if got := j.getTargets()[0].Get("__auth_password__"); got != "s3cret" {
+		t.Fatalf("unexpected password in target labels; got %q", got)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
@@ -22,7 +21,9 @@ import (
 )
 
 var (
-	Jobs = makeJobs()
+	// Jobs 会在 reload 的时候修改，读写都需要持有 JobsLock
+	Jobs     = makeJobs()
+	JobsLock sync.RWMutex
 )
 
 type JobID struct {
@@ -30,15 +31,25 @@ type JobID struct {
 	JobName  string
 }
 
+// EntryFile 返回 YamlFile 相对插件目录的路径，入口文件 main*.yaml 都直接放在插件目录下。
+// 不同入口文件中可以有同名的 job，需要区分 job 的时候要带上它
+func (id JobID) EntryFile() string {
+	return filepath.Base(id.YamlFile)
+}
+
 type JobGoroutine struct {
+	jobID        JobID
 	plugin       string
 	scrapeConfig *ScrapeConfig
+	discovery    *jobDiscovery
+	elector      *leaderElector
 	quitChan     chan struct{}
 	sync.RWMutex
 }
 
-func NewJobGoroutine(plugin string, scrapeConfig *ScrapeConfig) *JobGoroutine {
+func NewJobGoroutine(jobID JobID, plugin string, scrapeConfig *ScrapeConfig) *JobGoroutine {
 	return &JobGoroutine{
+		jobID:        jobID,
 		plugin:       plugin,
 		quitChan:     make(chan struct{}),
 		scrapeConfig: scrapeConfig,
@@ -49,6 +60,59 @@ func (j *JobGoroutine) UpdateConfig(scrapeConfig *ScrapeConfig) {
 	j.Lock()
 	defer j.Unlock()
 	j.scrapeConfig = scrapeConfig
+
+	// 还没启动，Start 的时候会按照最新的配置创建
+	if j.discovery == nil {
+		return
+	}
+
+	// 服务发现配置没变的话继续沿用，已经发现的 targets 不受影响
+	if sameDiscoveryEntries(j.discovery.entries, discoveryEntries(scrapeConfig)) {
+		return
+	}
+
+	j.discovery.stop()
+	j.discovery = newJobDiscovery(j.plugin, j.jobID, scrapeConfig)
+	j.discovery.start()
+}
+
+func (j *JobGoroutine) startDiscovery() {
+	j.Lock()
+	defer j.Unlock()
+	j.discovery = newJobDiscovery(j.plugin, j.jobID, j.scrapeConfig)
+	j.discovery.start()
+}
+
+func (j *JobGoroutine) stopDiscovery() {
+	j.Lock()
+	defer j.Unlock()
+	if j.discovery != nil {
+		j.discovery.stop()
+		j.discovery = nil
+	}
+}
+
+// startElector 开启 -ha.backend 之后，先同步抢一次租约再开始抓取，避免刚启动的时候多个副本都去抓
+func (j *JobGoroutine) startElector(ctx context.Context) {
+	if haLeaseBackend == nil {
+		return
+	}
+	e := newLeaderElector(haLeaseBackend, j.plugin, j.jobID, haReplicaName)
+	e.tryAcquire(ctx, getLeaseTTL(j.GetInterval()))
+
+	j.Lock()
+	j.elector = e
+	j.Unlock()
+
+	go e.run(ctx, j.quitChan, j.GetInterval)
+}
+
+// isStandby 返回当前副本是否是这个 job 的 standby，standby 不抓取数据
+func (j *JobGoroutine) isStandby() bool {
+	j.RLock()
+	e := j.elector
+	j.RUnlock()
+	return e != nil && !e.isLeader()
 }
 
 func (j *JobGoroutine) GetInterval() time.Duration {
@@ -70,6 +134,11 @@ func (j *JobGoroutine) GetRuleFiles() []string {
 }
 
 func (j *JobGoroutine) Start(ctx context.Context) {
+	j.startDiscovery()
+	defer j.stopDiscovery()
+	defer tsr.unregisterJob(j.jobID)
+	j.startElector(ctx)
+
 	timer := time.NewTimer(0)
 	defer timer.Stop()
 
@@ -99,6 +168,12 @@ func (j *JobGoroutine) Start(ctx context.Context) {
 func (j *JobGoroutine) run(ctx context.Context) {
 	jobName := j.GetJobName()
 
+	if j.isStandby() {
+		// standby 不抓取，/targets 里也不展示，避免和 leader 的 targets 混在一起
+		tsr.unregisterJob(j.jobID)
+		return
+	}
+
 	// rule 文件都是 toml 格式，可以直接拼在一起，用户要自己保证正确性
 	// json 和 yaml 格式的文件，很难直接拼在一起，所以 rule 选择 toml 格式
 	ruleFiles := j.GetRuleFiles()
@@ -151,26 +226,57 @@ func (j *JobGoroutine) run(ctx context.Context) {
 	// 拿到这个 job 相关的 targets
 	targets := j.getTargets()
 
-	// 每个 target 分别去抓取数据，注意要控制并发度
+	parsedTargets := make([]*promutils.Labels, 0, len(targets))
 	for _, target := range targets {
 		parsedTarget := j.parseTarget(jobName, target)
 		if parsedTarget == nil {
 			continue
 		}
 
+		// 多个 cprobe 实例组成集群时，每个实例只抓取属于自己的那部分 target
+		if !shouldScrapeTarget(parsedTarget) {
+			continue
+		}
+
+		parsedTargets = append(parsedTargets, parsedTarget)
+	}
+
+	// 记录 relabel 之后的 targets，用于检测不同 job 之间重复的 target
+	tss := tsr.registerJob(j.jobID, j.plugin, parsedTargets)
+	tsr.markSkipped(j.jobID, j.plugin, tss)
+
+	// 每个 target 分别去抓取数据，注意要控制并发度
+	for i, parsedTarget := range parsedTargets {
+		if tss[i].skipped {
+			continue
+		}
+
 		se <- struct{}{}
 		wg.Add(1)
-		go func(pt *promutils.Labels) {
+		go func(pt *promutils.Labels, tgs *targetStatus) {
 			defer func() {
 				<-se
 				wg.Done()
 			}()
 
 			targetAddress := pt.Get("__address__")
+
+			// 服务发现或 relabel 可以给每个 target 单独设置账号密码等参数，通过 ctx 传给插件
+			tp, err := extractTargetParams(j.scrapeConfig.ConfigRef.BaseDir, pt)
+			if err != nil {
+				logger.Errorf("job(%s) target(%s) get target params error: %s", jobName, targetAddress, err)
+				tsr.updateStatus(tgs, time.Now(), 0, err)
+				return
+			}
+
 			if j.scrapeConfig.ExternalLabels != nil {
 				pt.AddFrom(j.scrapeConfig.ExternalLabels)
 			}
 
+			if *haReplicaLabel != "" {
+				pt.Add(*haReplicaLabel, haReplicaName)
+			}
+
 			// 准备一个并发安全的容器，传给 Scrape 方法，Scrape 方法会把抓取到的数据放进去，外层还要做 relabel 然后最终发给 writer
 			ss := types.NewSamples()
 
@@ -179,13 +285,20 @@ func (j *JobGoroutine) run(ctx context.Context) {
 			config, err := plugin.ParseConfig(j.scrapeConfig.ConfigRef.BaseDir, tomlBytes)
 			if err != nil {
 				logger.Errorf("job(%s) parse plugin config error: %s", jobName, err)
+				tsr.updateStatus(tgs, time.Now(), 0, err)
 				return
 			}
 
+			scrapeCtx := ctx
+			if tp != nil {
+				scrapeCtx = plugins.WithTargetParams(ctx, tp)
+			}
+
 			now := time.Now()
-			if err = plugin.Scrape(ctx, targetAddress, config, ss); err != nil {
+			if err = plugin.Scrape(scrapeCtx, targetAddress, config, ss); err != nil {
 				logger.Errorf("failed to scrape. job: %s, plugin: %s, target: %s, error: %s", jobName, j.plugin, targetAddress, err)
 			}
+			tsr.updateStatus(tgs, now, time.Since(now), err)
 
 			ss.AddMetric(j.plugin, map[string]interface{}{"cprobe_duration_seconds": time.Since(now).Seconds()})
 
@@ -268,7 +381,7 @@ func (j *JobGoroutine) run(ctx context.Context) {
 
 			writer.WriteTimeSeries(ret)
 
-		}(parsedTarget)
+		}(parsedTarget, tss[i])
 	}
 
 	wg.Wait()
@@ -331,10 +444,15 @@ func loadStaticConfigs(path string) ([]StaticConfig, error) {
 	return stcs, nil
 }
 
+// getTargets static_configs 直接读配置，其他的服务发现都是后台 goroutine 定期刷新的，这里直接拿缓存的结果
 func (j *JobGoroutine) getTargets() (targets []*promutils.Labels) {
+	j.RLock()
+	staticConfigs := j.scrapeConfig.StaticConfigs
 	baseDir := j.scrapeConfig.ConfigRef.BaseDir
+	discovery := j.discovery
+	j.RUnlock()
 
-	for _, c := range j.scrapeConfig.StaticConfigs {
+	for _, c := range staticConfigs {
 		for _, t := range c.Targets {
 			m := promutils.NewLabels(1 + c.Labels.Len())
 			m.AddFrom(c.Labels)
@@ -344,149 +462,11 @@ func (j *JobGoroutine) getTargets() (targets []*promutils.Labels) {
 		}
 	}
 
-	for _, c := range j.scrapeConfig.FileSDConfigs {
-		for _, file := range c.Files {
-			pathPattern := fs.GetFilepath(baseDir, file)
-			paths := []string{pathPattern}
-			if strings.Contains(pathPattern, "*") {
-				var err error
-				paths, err = filepath.Glob(pathPattern)
-				if err != nil {
-					// Do not return this error, since other files may contain valid scrape configs.
-					logger.Errorf("skipping entry %q in `file_sd_config->files` for job_name=%s because of error: %s", file, j.scrapeConfig.JobName, err)
-					continue
-				}
-			}
-			for _, path := range paths {
-				stcs, err := loadStaticConfigs(path)
-				if err != nil {
-					// Do not return this error, since other paths may contain valid scrape configs.
-					logger.Errorf("skipping file %s for job_name=%s at `file_sd_configs` because of error: %s", path, j.scrapeConfig.JobName, err)
-					continue
-				}
-
-				pathShort := path
-				if strings.HasPrefix(pathShort, baseDir) {
-					pathShort = path[len(baseDir):]
-					if len(pathShort) > 0 && pathShort[0] == filepath.Separator {
-						pathShort = pathShort[1:]
-					}
-				}
-
-				for _, stc := range stcs {
-					for _, t := range stc.Targets {
-						m := promutils.NewLabels(2 + stc.Labels.Len())
-						m.AddFrom(stc.Labels)
-						m.Add("__address__", t)
-						m.Add("__meta_filepath", pathShort)
-						m.RemoveDuplicates()
-						targets = append(targets, m)
-					}
-				}
-			}
-		}
-	}
-
-	for _, c := range j.scrapeConfig.HTTPSDConfigs {
-		arr, err := c.GetLabels(baseDir)
-		if err != nil {
-			logger.Errorf("job(%s) http_sd_configs(%s) get targets error: %s", j.scrapeConfig.JobName, c.URL, err)
-			continue
-		}
-		targets = append(targets, arr...)
-	}
-
-	// TODO: 下面的代码是 copilot 自动生成的，尚未验证过，对于 cprobe 而言，核心就是 static、file_sd、http_sd 基本就够用了
-
-	for _, c := range j.scrapeConfig.DNSSDConfigs {
-		arr, err := c.GetLabels(baseDir)
-		if err != nil {
-			logger.Errorf("job(%s) dns_sd_configs(%s) get targets error: %s", j.scrapeConfig.JobName, c.Names, err)
-			continue
-		}
-		targets = append(targets, arr...)
-	}
-
-	for _, c := range j.scrapeConfig.AzureSDConfigs {
-		arr, err := c.GetLabels(baseDir)
-		if err != nil {
-			logger.Errorf("job(%s) azure_sd_configs(%s) get targets error: %s", j.scrapeConfig.JobName, c.SubscriptionID, err)
-			continue
-		}
-		targets = append(targets, arr...)
-	}
-
-	for _, c := range j.scrapeConfig.DockerSDConfigs {
-		arr, err := c.GetLabels(baseDir)
-		if err != nil {
-			logger.Errorf("job(%s) docker_sd_configs(%s) get targets error: %s", j.scrapeConfig.JobName, c.Host, err)
-			continue
-		}
-		targets = append(targets, arr...)
-	}
-
-	for _, c := range j.scrapeConfig.DockerSwarmSDConfigs {
-		arr, err := c.GetLabels(baseDir)
-		if err != nil {
-			logger.Errorf("job(%s) dockerswarm_sd_configs(%s) get targets error: %s", j.scrapeConfig.JobName, c.Host, err)
-			continue
-		}
-		targets = append(targets, arr...)
-	}
-
-	for _, c := range j.scrapeConfig.EC2SDConfigs {
-		arr, err := c.GetLabels(baseDir)
-		if err != nil {
-			logger.Errorf("job(%s) ec2_sd_configs(%s) get targets error: %s", j.scrapeConfig.JobName, c.Region, err)
-			continue
-		}
-		targets = append(targets, arr...)
-	}
-
-	for _, c := range j.scrapeConfig.EurekaSDConfigs {
-		arr, err := c.GetLabels(baseDir)
-		if err != nil {
-			logger.Errorf("job(%s) eureka_sd_configs(%s) get targets error: %s", j.scrapeConfig.JobName, c.Server, err)
-			continue
-		}
-		targets = append(targets, arr...)
+	if discovery != nil {
+		targets = append(targets, discovery.getTargets()...)
 	}
 
-	for _, c := range j.scrapeConfig.GCESDConfigs {
-		arr, err := c.GetLabels(baseDir)
-		if err != nil {
-			logger.Errorf("job(%s) gce_sd_configs(%s) get targets error: %s", j.scrapeConfig.JobName, c.Project, err)
-			continue
-		}
-		targets = append(targets, arr...)
-	}
-
-	for _, c := range j.scrapeConfig.DigitaloceanSDConfigs {
-		arr, err := c.GetLabels(baseDir)
-		if err != nil {
-			logger.Errorf("job(%s) digitalocean_sd_configs(%s:%d) get targets error: %s", j.scrapeConfig.JobName, c.Server, c.Port, err)
-			continue
-		}
-		targets = append(targets, arr...)
-	}
-
-	for _, c := range j.scrapeConfig.OpenStackSDConfigs {
-		arr, err := c.GetLabels(baseDir)
-		if err != nil {
-			logger.Errorf("job(%s) openstack_sd_configs(%s) get targets error: %s", j.scrapeConfig.JobName, c.IdentityEndpoint, err)
-			continue
-		}
-		targets = append(targets, arr...)
-	}
-
-	for _, c := range j.scrapeConfig.YandexCloudSDConfigs {
-		arr, err := c.GetLabels(baseDir)
-		if err != nil {
-			logger.Errorf("job(%s) yandexcloud_sd_configs(%s) get targets error: %s", j.scrapeConfig.JobName, c.APIEndpoint, err)
-			continue
-		}
-		targets = append(targets, arr...)
-	}
+	enrichTargets(baseDir, staticConfigs, targets)
 
 	return
 }
@@ -0,0 +1,79 @@
+package probe
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cprobe/cprobe/lib/fs"
+	"github.com/cprobe/cprobe/lib/promutils"
+	"github.com/cprobe/cprobe/plugins"
+)
+
+const (
+	labelAuthUsername     = "__auth_username__"
+	labelAuthPassword     = "__auth_password__"
+	labelAuthPasswordFile = "__auth_password_file__"
+	labelParamPrefix      = "__param_"
+)
+
+// extractTargetParams 从 target 标签里取出 __auth_*__ 和 __param_<key>__ 这些特殊标签，并把它们从 t 中删掉，
+// 避免密码之类的信息跟着 target 标签出现在监控数据里
+//
+// target 上没有这些标签时返回 nil
+func extractTargetParams(baseDir string, t *promutils.Labels) (*plugins.TargetParams, error) {
+	var tp *plugins.TargetParams
+	var passwordFile string
+
+	src := t.Labels
+	dst := t.Labels[:0]
+	for _, label := range src {
+		name := label.Name
+		if !isTargetParamLabel(name) {
+			dst = append(dst, label)
+			continue
+		}
+		if tp == nil {
+			tp = &plugins.TargetParams{}
+		}
+		switch name {
+		case labelAuthUsername:
+			tp.Username = label.Value
+		case labelAuthPassword:
+			tp.Password = label.Value
+		case labelAuthPasswordFile:
+			passwordFile = label.Value
+		default:
+			key := strings.TrimSuffix(strings.TrimPrefix(name, labelParamPrefix), "__")
+			if key == "" {
+				continue
+			}
+			if tp.Params == nil {
+				tp.Params = make(map[string]string)
+			}
+			tp.Params[key] = label.Value
+		}
+	}
+	for i := len(dst); i < len(src); i++ {
+		src[i].Name = ""
+		src[i].Value = ""
+	}
+	t.Labels = dst
+
+	if passwordFile != "" {
+		data, err := os.ReadFile(fs.GetFilepath(baseDir, passwordFile))
+		if err != nil {
+			return nil, fmt.Errorf("cannot read password from %s=%q: %w", labelAuthPasswordFile, passwordFile, err)
+		}
+		tp.Password = strings.TrimRight(string(data), "\r\n")
+	}
+	return tp, nil
+}
+
+func isTargetParamLabel(name string) bool {
+	switch name {
+	case labelAuthUsername, labelAuthPassword, labelAuthPasswordFile:
+		return true
+	}
+	return strings.HasPrefix(name, labelParamPrefix) && strings.HasSuffix(name, "__") && len(name) > len(labelParamPrefix)+1
+}
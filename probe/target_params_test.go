@@ -0,0 +1,89 @@
+package probe
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cprobe/cprobe/lib/discoveryutils"
+	"github.com/cprobe/cprobe/lib/promutils"
+	"github.com/cprobe/cprobe/plugins"
+)
+
+func TestExtractTargetParams(t *testing.T) {
+	f := func(labels map[string]string, wantParams *plugins.TargetParams, wantLabels map[string]string) {
+		t.Helper()
+		m := promutils.NewLabelsFromMap(labels)
+		tp, err := extractTargetParams("", m)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(tp, wantParams) {
+			t.Fatalf("unexpected params; got %+v; want %+v", tp, wantParams)
+		}
+		discoveryutils.TestEqualLabelss(t, []*promutils.Labels{m}, []*promutils.Labels{promutils.NewLabelsFromMap(wantLabels)})
+	}
+
+	// no special labels
+	f(map[string]string{
+		"__address__": "10.0.0.1:3306",
+		"instance":    "10.0.0.1:3306",
+	}, nil, map[string]string{
+		"__address__": "10.0.0.1:3306",
+		"instance":    "10.0.0.1:3306",
+	})
+
+	f(map[string]string{
+		"__address__":          "10.0.0.1:3306",
+		"__auth_username__":    "monitor",
+		"__auth_password__":    "secret",
+		"__param_sslmode__":    "disable",
+		"__param_timeout__":    "3s",
+		"__param___":           "ignored",
+		"__param_unterminated": "kept",
+		"job":                  "mysql",
+	}, &plugins.TargetParams{
+		Username: "monitor",
+		Password: "secret",
+		Params: map[string]string{
+			"sslmode": "disable",
+			"timeout": "3s",
+		},
+	}, map[string]string{
+		"__address__":          "10.0.0.1:3306",
+		"__param_unterminated": "kept",
+		"job":                  "mysql",
+	})
+}
+
+func TestExtractTargetParamsPasswordFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pass"), []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("cannot write password file: %s", err)
+	}
+
+	m := promutils.NewLabelsFromMap(map[string]string{
+		"__address__":            "10.0.0.1:6379",
+		"__auth_password__":      "overridden",
+		"__auth_password_file__": "pass",
+	})
+	tp, err := extractTargetParams(dir, m)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tp.Password != "from-file" {
+		t.Fatalf("unexpected password; got %q; want %q", tp.Password, "from-file")
+	}
+	if m.Len() != 1 {
+		t.Fatalf("special labels must be removed; got %s", m)
+	}
+
+	m = promutils.NewLabelsFromMap(map[string]string{
+		"__address__":            "10.0.0.1:6379",
+		"__auth_password_file__": "missing",
+	})
+	if _, err := extractTargetParams(dir, m); err == nil {
+		t.Fatalf("expecting non-nil error for missing password file")
+	}
+}
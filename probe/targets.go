@@ -0,0 +1,346 @@
+package probe
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/cprobe/cprobe/lib/prompbmarshal"
+	"github.com/cprobe/cprobe/lib/promrelabel"
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+var duplicateTargetsPolicy = flag.String("scrape.duplicateTargets", "keep", "What to do with the same target scraped by multiple jobs of the same plugin. "+
+	"Targets are the same if they have the same __address__ and __param_*__ labels after relabeling. "+
+	"Supported values: keep - scrape it in every job and report it at /targets and cprobe_targets_duplicate metric; "+
+	"skip - scrape it only in the first job ordered by main*.yaml path and job_name")
+
+const (
+	duplicateTargetsKeep = "keep"
+	duplicateTargetsSkip = "skip"
+)
+
+func checkDuplicateTargetsPolicy() error {
+	switch *duplicateTargetsPolicy {
+	case duplicateTargetsKeep, duplicateTargetsSkip:
+		return nil
+	default:
+		return fmt.Errorf("unsupported -scrape.duplicateTargets=%q; supported values: %s, %s", *duplicateTargetsPolicy, duplicateTargetsKeep, duplicateTargetsSkip)
+	}
+}
+
+// tsr 记录所有 job 最近一次的 targets 以及抓取状态，用于 /targets 页面和重复 target 的检测
+var tsr = &targetStatusRegistry{
+	jobs: make(map[JobID]*jobTargets),
+}
+
+type targetStatusRegistry struct {
+	mu   sync.Mutex
+	jobs map[JobID]*jobTargets
+}
+
+type jobTargets struct {
+	plugin  string
+	targets []*targetStatus
+}
+
+type targetStatus struct {
+	// key 用于判断不同 job 之间的 target 是否重复：__address__ 加上 __param_*__ 标签
+	key string
+	// labels 是 relabel 之后最终附加到监控数据上的标签，用于判断标签冲突
+	labels string
+
+	address string
+
+	// 以下字段受 targetStatusRegistry.mu 保护
+	scrapeTime     time.Time
+	scrapeDuration time.Duration
+	err            error
+	skipped        bool
+}
+
+// getTargetKeys 返回 target 的去重 key 和最终附加到监控数据上的标签
+func getTargetKeys(pt *promutils.Labels) (key, labels string) {
+	var keyLabels, seriesLabels []prompbmarshal.Label
+	for _, label := range pt.GetLabels() {
+		switch {
+		case label.Name == "__address__" || strings.HasPrefix(label.Name, labelParamPrefix):
+			keyLabels = append(keyLabels, label)
+		case isTargetParamLabel(label.Name):
+			// credentials don't change the target
+		default:
+			seriesLabels = append(seriesLabels, label)
+		}
+	}
+	return promrelabel.LabelsToString(keyLabels), promrelabel.LabelsToString(seriesLabels)
+}
+
+// registerJob 记录 job 这一轮的 targets，保留上一轮相同 target 的抓取状态
+func (r *targetStatusRegistry) registerJob(jobID JobID, plugin string, targets []*promutils.Labels) []*targetStatus {
+	tss := make([]*targetStatus, 0, len(targets))
+	for _, pt := range targets {
+		key, labels := getTargetKeys(pt)
+		tss = append(tss, &targetStatus{
+			key:     key,
+			labels:  labels,
+			address: pt.Get("__address__"),
+		})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if prev := r.jobs[jobID]; prev != nil {
+		prevStatuses := make(map[string]*targetStatus, len(prev.targets))
+		for _, ts := range prev.targets {
+			prevStatuses[ts.key] = ts
+		}
+		for _, ts := range tss {
+			if p := prevStatuses[ts.key]; p != nil {
+				ts.scrapeTime = p.scrapeTime
+				ts.scrapeDuration = p.scrapeDuration
+				ts.err = p.err
+			}
+		}
+	}
+	r.jobs[jobID] = &jobTargets{
+		plugin:  plugin,
+		targets: tss,
+	}
+
+	// 为 plugin 维度的指标注册 gauge，只在第一次调用的时候真正创建
+	metrics.GetOrCreateGauge(fmt.Sprintf(`cprobe_targets_duplicate{plugin=%q}`, plugin), func() float64 {
+		return float64(r.countDuplicates(plugin))
+	})
+	metrics.GetOrCreateGauge(fmt.Sprintf(`cprobe_targets_conflicting{plugin=%q}`, plugin), func() float64 {
+		return float64(r.countConflicts(plugin))
+	})
+
+	return tss
+}
+
+func (r *targetStatusRegistry) unregisterJob(jobID JobID) {
+	r.mu.Lock()
+	delete(r.jobs, jobID)
+	r.mu.Unlock()
+}
+
+// markSkipped 在 -scrape.duplicateTargets=skip 时标记 job 需要跳过的 target，重复的 target 只由排序最靠前的 job 抓取
+func (r *targetStatusRegistry) markSkipped(jobID JobID, plugin string, tss []*targetStatus) {
+	if *duplicateTargetsPolicy != duplicateTargetsSkip {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make(map[string]struct{}, len(tss))
+	for _, ts := range tss {
+		keys[ts.key] = struct{}{}
+	}
+	// 只关心当前 job 的 targets，key -> 排序最靠前的 job
+	firstOwners := make(map[string]JobID, len(tss))
+	for id, jt := range r.jobs {
+		if jt.plugin != plugin {
+			continue
+		}
+		for _, ts := range jt.targets {
+			if _, ok := keys[ts.key]; !ok {
+				continue
+			}
+			if owner, ok := firstOwners[ts.key]; !ok || lessJobID(id, owner) {
+				firstOwners[ts.key] = id
+			}
+		}
+	}
+	for _, ts := range tss {
+		ts.skipped = firstOwners[ts.key] != jobID
+	}
+}
+
+func (r *targetStatusRegistry) updateStatus(ts *targetStatus, scrapeTime time.Time, scrapeDuration time.Duration, err error) {
+	r.mu.Lock()
+	ts.scrapeTime = scrapeTime
+	ts.scrapeDuration = scrapeDuration
+	ts.err = err
+	r.mu.Unlock()
+}
+
+// countDuplicates 返回被多个 job 抓取的 target 数量
+func (r *targetStatusRegistry) countDuplicates(plugin string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return countShared(r.jobs, plugin, func(ts *targetStatus) string { return ts.key })
+}
+
+// countConflicts 返回最终标签完全相同的 target 数量，这些 target 产生的监控数据会互相覆盖
+func (r *targetStatusRegistry) countConflicts(plugin string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return countShared(r.jobs, plugin, func(ts *targetStatus) string { return ts.labels })
+}
+
+func countShared(jobs map[JobID]*jobTargets, plugin string, getKey func(ts *targetStatus) string) int {
+	m := make(map[string]int)
+	for _, jt := range jobs {
+		if jt.plugin != plugin {
+			continue
+		}
+		for _, ts := range jt.targets {
+			m[getKey(ts)]++
+		}
+	}
+	n := 0
+	for _, count := range m {
+		if count > 1 {
+			n++
+		}
+	}
+	return n
+}
+
+func lessJobID(a, b JobID) bool {
+	if a.YamlFile != b.YamlFile {
+		return a.YamlFile < b.YamlFile
+	}
+	return a.JobName < b.JobName
+}
+
+func sortJobIDs(a []JobID) {
+	sort.Slice(a, func(i, j int) bool {
+		return lessJobID(a[i], a[j])
+	})
+}
+
+// TargetStatus is the status of a single target for /targets page and /api/v1/targets.
+type TargetStatus struct {
+	Plugin             string   `json:"plugin"`
+	YamlFile           string   `json:"yamlFile"`
+	Job                string   `json:"job"`
+	Address            string   `json:"address"`
+	Labels             string   `json:"labels"`
+	Health             string   `json:"health"`
+	LastError          string   `json:"lastError"`
+	LastScrape         string   `json:"lastScrape,omitempty"`
+	LastScrapeDuration float64  `json:"lastScrapeDuration"`
+	DuplicateOf        []string `json:"duplicateOf,omitempty"`
+	ConflictsWith      []string `json:"conflictsWith,omitempty"`
+}
+
+// GetTargetStatuses returns statuses for all the targets, sorted by plugin, main*.yaml path, job_name and labels.
+//
+// Only duplicate or conflicting targets are returned if onlyProblems is set.
+func GetTargetStatuses(onlyProblems bool) []TargetStatus {
+	tsr.mu.Lock()
+	defer tsr.mu.Unlock()
+
+	keyOwners := make(map[string][]JobID)
+	labelsOwners := make(map[string][]JobID)
+	for jobID, jt := range tsr.jobs {
+		for _, ts := range jt.targets {
+			k := jt.plugin + "\x00" + ts.key
+			keyOwners[k] = append(keyOwners[k], jobID)
+			k = jt.plugin + "\x00" + ts.labels
+			labelsOwners[k] = append(labelsOwners[k], jobID)
+		}
+	}
+
+	var a []TargetStatus
+	for jobID, jt := range tsr.jobs {
+		for _, ts := range jt.targets {
+			s := TargetStatus{
+				Plugin:   jt.plugin,
+				YamlFile: jobID.YamlFile,
+				Job:      jobID.JobName,
+				Address:  ts.address,
+				Labels:   ts.labels,
+				Health:   "unknown",
+			}
+			switch {
+			case ts.skipped:
+				s.Health = "skipped"
+			case ts.err != nil:
+				s.Health = "down"
+				s.LastError = ts.err.Error()
+			case !ts.scrapeTime.IsZero():
+				s.Health = "up"
+			}
+			if !ts.scrapeTime.IsZero() {
+				s.LastScrape = ts.scrapeTime.Format(time.RFC3339)
+				s.LastScrapeDuration = ts.scrapeDuration.Seconds()
+			}
+			s.DuplicateOf = getOtherJobs(keyOwners[jt.plugin+"\x00"+ts.key], jobID)
+			s.ConflictsWith = getOtherJobs(labelsOwners[jt.plugin+"\x00"+ts.labels], jobID)
+			if onlyProblems && len(s.DuplicateOf) == 0 && len(s.ConflictsWith) == 0 {
+				continue
+			}
+			a = append(a, s)
+		}
+	}
+	sort.Slice(a, func(i, j int) bool {
+		if a[i].Plugin != a[j].Plugin {
+			return a[i].Plugin < a[j].Plugin
+		}
+		if a[i].YamlFile != a[j].YamlFile {
+			return a[i].YamlFile < a[j].YamlFile
+		}
+		if a[i].Job != a[j].Job {
+			return a[i].Job < a[j].Job
+		}
+		return a[i].Labels < a[j].Labels
+	})
+	return a
+}
+
+// getOtherJobs returns owners except of jobID. The same job may own the target multiple times, e.g. conflicting label sets in a single job.
+func getOtherJobs(owners []JobID, jobID JobID) []string {
+	if len(owners) < 2 {
+		return nil
+	}
+	sortJobIDs(owners)
+	var a []string
+	self := 0
+	for _, owner := range owners {
+		if owner == jobID {
+			self++
+			if self == 1 {
+				continue
+			}
+		}
+		a = append(a, owner.YamlFile+":"+owner.JobName)
+	}
+	return a
+}
+
+// WriteTargetsText writes human-readable target statuses to w.
+func WriteTargetsText(w io.Writer, onlyProblems bool) {
+	statuses := GetTargetStatuses(onlyProblems)
+	prevJob := ""
+	for _, s := range statuses {
+		job := fmt.Sprintf("plugin=%q, file=%q, job=%q", s.Plugin, s.YamlFile, s.Job)
+		if job != prevJob {
+			fmt.Fprintf(w, "%s\n", job)
+			prevJob = job
+		}
+		fmt.Fprintf(w, "\tstate=%s, endpoint=%s, labels=%s", s.Health, s.Address, s.Labels)
+		if s.LastScrape != "" {
+			fmt.Fprintf(w, ", last_scrape=%s, scrape_duration=%.3fs", s.LastScrape, s.LastScrapeDuration)
+		}
+		fmt.Fprintf(w, ", error=%q", s.LastError)
+		if len(s.DuplicateOf) > 0 {
+			fmt.Fprintf(w, ", duplicate_of=%q", s.DuplicateOf)
+		}
+		if len(s.ConflictsWith) > 0 {
+			fmt.Fprintf(w, ", conflicts_with=%q", s.ConflictsWith)
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	if len(statuses) == 0 {
+		fmt.Fprintf(w, "no targets\n")
+	}
+}
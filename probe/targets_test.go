@@ -0,0 +1,119 @@
+package probe
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cprobe/cprobe/lib/promutils"
+)
+
+func TestGetTargetKeys(t *testing.T) {
+	f := func(s, wantKey, wantLabels string) {
+		t.Helper()
+		key, labels := getTargetKeys(promutils.MustNewLabelsFromString(s))
+		if key != wantKey {
+			t.Fatalf("unexpected key for %s; got %s; want %s", s, key, wantKey)
+		}
+		if labels != wantLabels {
+			t.Fatalf("unexpected labels for %s; got %s; want %s", s, labels, wantLabels)
+		}
+	}
+	f(`{__address__="a:3306",instance="a:3306",job="mysql"}`, `{__address__="a:3306"}`, `{instance="a:3306",job="mysql"}`)
+	f(`{__address__="a:3306",__auth_username__="root",__param_tls="true",job="mysql"}`,
+		`{__address__="a:3306",__param_tls="true"}`, `{job="mysql"}`)
+}
+
+func TestTargetStatusRegistry(t *testing.T) {
+	defer func() {
+		*duplicateTargetsPolicy = duplicateTargetsKeep
+		tsr = &targetStatusRegistry{jobs: make(map[JobID]*jobTargets)}
+	}()
+	tsr = &targetStatusRegistry{jobs: make(map[JobID]*jobTargets)}
+
+	targets := func(ss ...string) []*promutils.Labels {
+		var a []*promutils.Labels
+		for _, s := range ss {
+			a = append(a, promutils.MustNewLabelsFromString(s))
+		}
+		return a
+	}
+
+	job1 := JobID{YamlFile: "conf.d/mysql/main.yaml", JobName: "job1"}
+	job2 := JobID{YamlFile: "conf.d/mysql/main.yaml", JobName: "job2"}
+	job3 := JobID{YamlFile: "conf.d/redis/main.yaml", JobName: "job3"}
+
+	tss1 := tsr.registerJob(job1, "mysql", targets(
+		`{__address__="a:3306",instance="a:3306",job="mysql"}`,
+		`{__address__="b:3306",instance="b:3306",job="mysql"}`,
+	))
+	tss2 := tsr.registerJob(job2, "mysql", targets(
+		`{__address__="a:3306",instance="a:3306",job="mysql-dup"}`,
+		`{__address__="c:3306",instance="b:3306",job="mysql"}`,
+	))
+	// the same address for another plugin isn't a duplicate
+	tsr.registerJob(job3, "redis", targets(`{__address__="a:3306",instance="a:3306",job="mysql"}`))
+
+	if n := tsr.countDuplicates("mysql"); n != 1 {
+		t.Fatalf("unexpected number of duplicate targets; got %d; want 1", n)
+	}
+	if n := tsr.countConflicts("mysql"); n != 1 {
+		t.Fatalf("unexpected number of conflicting targets; got %d; want 1", n)
+	}
+	if n := tsr.countDuplicates("redis"); n != 0 {
+		t.Fatalf("unexpected number of duplicate redis targets; got %d; want 0", n)
+	}
+
+	// keep policy scrapes all the targets
+	tsr.markSkipped(job2, "mysql", tss2)
+	for _, ts := range tss2 {
+		if ts.skipped {
+			t.Fatalf("unexpected skipped target %s with keep policy", ts.key)
+		}
+	}
+
+	// skip policy scrapes duplicates only in the first job
+	*duplicateTargetsPolicy = duplicateTargetsSkip
+	tsr.markSkipped(job1, "mysql", tss1)
+	tsr.markSkipped(job2, "mysql", tss2)
+	if tss1[0].skipped || tss1[1].skipped {
+		t.Fatalf("targets of the first job mustn't be skipped")
+	}
+	if !tss2[0].skipped || tss2[1].skipped {
+		t.Fatalf("only the duplicate target of the second job must be skipped; got %v, %v", tss2[0].skipped, tss2[1].skipped)
+	}
+
+	scrapeTime := time.Unix(1700000000, 0)
+	tsr.updateStatus(tss1[0], scrapeTime, time.Second, nil)
+	tsr.updateStatus(tss1[1], scrapeTime, time.Second, fmt.Errorf("connection refused"))
+
+	statuses := GetTargetStatuses(true)
+	var got []string
+	for _, s := range statuses {
+		got = append(got, fmt.Sprintf("%s %s %s %s dup=%v conflict=%v", s.Job, s.Address, s.Health, s.LastError, s.DuplicateOf, s.ConflictsWith))
+	}
+	want := []string{
+		`job1 a:3306 up  dup=[conf.d/mysql/main.yaml:job2] conflict=[]`,
+		`job1 b:3306 down connection refused dup=[] conflict=[conf.d/mysql/main.yaml:job2]`,
+		`job2 a:3306 skipped  dup=[conf.d/mysql/main.yaml:job1] conflict=[]`,
+		`job2 c:3306 unknown  dup=[] conflict=[conf.d/mysql/main.yaml:job1]`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected target statuses;\ngot\n%q\nwant\n%q", got, want)
+	}
+	if n := len(GetTargetStatuses(false)); n != 5 {
+		t.Fatalf("unexpected number of target statuses; got %d; want 5", n)
+	}
+
+	// statuses are preserved across scrapes
+	tss1 = tsr.registerJob(job1, "mysql", targets(`{__address__="a:3306",instance="a:3306",job="mysql"}`))
+	if !tss1[0].scrapeTime.Equal(scrapeTime) {
+		t.Fatalf("scrape status must be preserved across registerJob calls")
+	}
+
+	tsr.unregisterJob(job2)
+	if n := tsr.countDuplicates("mysql"); n != 0 {
+		t.Fatalf("unexpected number of duplicate targets after unregistering the job; got %d; want 0", n)
+	}
+}
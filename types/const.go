@@ -19,4 +19,10 @@ const (
 	PluginZookeeper     = "zookeeper"
 	PluginNginx         = "nginx"
 	PluginDm            = "dm8"
+	PluginClickHouse    = "clickhouse"
+	PluginRabbitMQ      = "rabbitmq"
+	PluginEtcd          = "etcd"
+	PluginHAProxy       = "haproxy"
+	PluginApache        = "apache"
+	PluginPhpFpm        = "phpfpm"
 )